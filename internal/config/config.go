@@ -1,5 +1,14 @@
 package config
 
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// appConfigDirName is the application directory under the user config dir
+const appConfigDirName = "gocmder"
+
 // Config manages application configuration (YAML)
 type Config struct {
 	// TODO: Implement config manager
@@ -9,3 +18,43 @@ type Config struct {
 func NewConfig() *Config {
 	return &Config{}
 }
+
+// ConfigDir returns the application config directory, creating it if needed
+func ConfigDir() (string, error) {
+	base, err := os.UserConfigDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to locate user config directory: %w", err)
+	}
+
+	dir := filepath.Join(base, appConfigDirName)
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return "", fmt.Errorf("failed to create config directory: %w", err)
+	}
+
+	return dir, nil
+}
+
+// WriteFileAtomic writes data to a temporary file and renames it over path
+func WriteFileAtomic(path string, data []byte, perm os.FileMode) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	tmpPath := tmp.Name()
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmpPath)
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmpPath)
+		return err
+	}
+	if err := os.Chmod(tmpPath, perm); err != nil {
+		os.Remove(tmpPath)
+		return err
+	}
+
+	return os.Rename(tmpPath, path)
+}
@@ -0,0 +1,242 @@
+package config
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// historyFileName is the query history file in the config directory
+	historyFileName = "history.jsonl"
+	// DefaultHistoryLimit is the number of history entries kept
+	DefaultHistoryLimit = 10000
+	// DefaultHistoryAge is how long history entries are kept
+	DefaultHistoryAge = 90 * 24 * time.Hour
+)
+
+// HistoryEntry is an executed statement
+type HistoryEntry struct {
+	Time     time.Time `json:"time"`
+	Session  string    `json:"session"`
+	Database string    `json:"database,omitempty"`
+	Query    string    `json:"query"`
+	// Duration is the execution time in milliseconds
+	Duration int64 `json:"duration_ms"`
+	// Rows is the number of rows fetched or affected
+	Rows  int64  `json:"rows"`
+	Error string `json:"error,omitempty"`
+}
+
+// Elapsed returns the execution time of the entry
+func (e HistoryEntry) Elapsed() time.Duration {
+	return time.Duration(e.Duration) * time.Millisecond
+}
+
+// HistoryStore persists executed statements to disk, one JSON object per
+// line. New entries are appended, the file is rewritten when entries
+// beyond the limit or older than the maximum age are dropped.
+type HistoryStore struct {
+	mu      sync.Mutex
+	path    string
+	limit   int
+	maxAge  time.Duration
+	entries []HistoryEntry // oldest first
+}
+
+// NewHistoryStore creates a history store in the user config directory and loads it
+func NewHistoryStore() (*HistoryStore, error) {
+	dir, err := ConfigDir()
+	if err != nil {
+		return nil, err
+	}
+
+	store := &HistoryStore{
+		path:   filepath.Join(dir, historyFileName),
+		limit:  DefaultHistoryLimit,
+		maxAge: DefaultHistoryAge,
+	}
+	if err := store.Load(); err != nil {
+		return store, err
+	}
+
+	return store, nil
+}
+
+// Path returns the history file path
+func (s *HistoryStore) Path() string {
+	return s.path
+}
+
+// Load reads the history from disk, a missing file yields an empty history.
+// Lines that cannot be parsed, e.g. after a crash during a write, are skipped.
+func (s *HistoryStore) Load() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	data, err := os.ReadFile(s.path)
+	if errors.Is(err, os.ErrNotExist) {
+		s.entries = nil
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read history: %w", err)
+	}
+
+	s.entries = nil
+	lines := 0
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(nil, len(data)+1)
+	for scanner.Scan() {
+		if len(bytes.TrimSpace(scanner.Bytes())) == 0 {
+			continue
+		}
+		lines++
+		var entry HistoryEntry
+		if json.Unmarshal(scanner.Bytes(), &entry) == nil {
+			s.entries = append(s.entries, entry)
+		}
+	}
+
+	if s.prune() || len(s.entries) < lines {
+		return s.save()
+	}
+	return nil
+}
+
+// prune drops entries older than the maximum age and the oldest entries
+// beyond the limit, caller must hold the lock. It returns true if entries
+// were dropped.
+func (s *HistoryStore) prune() bool {
+	drop := 0
+	if s.maxAge > 0 {
+		cutoff := time.Now().Add(-s.maxAge)
+		for drop < len(s.entries) && s.entries[drop].Time.Before(cutoff) {
+			drop++
+		}
+	}
+	if s.limit > 0 {
+		drop = max(drop, len(s.entries)-s.limit)
+	}
+	if drop == 0 {
+		return false
+	}
+
+	s.entries = slices.Clone(s.entries[drop:])
+	return true
+}
+
+// save rewrites the history file, caller must hold the lock
+func (s *HistoryStore) save() error {
+	var data bytes.Buffer
+	encoder := json.NewEncoder(&data)
+	for _, entry := range s.entries {
+		if err := encoder.Encode(entry); err != nil {
+			return err
+		}
+	}
+
+	if err := WriteFileAtomic(s.path, data.Bytes(), 0600); err != nil {
+		return fmt.Errorf("failed to write history: %w", err)
+	}
+	return nil
+}
+
+// Add records an entry and appends it to the history file
+func (s *HistoryStore) Add(entry HistoryEntry) error {
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.entries = append(s.entries, entry)
+
+	// Rewriting the file on every entry past the limit would be slow, so a
+	// tenth of the limit is dropped at once
+	if s.limit > 0 && len(s.entries) > s.limit+s.limit/10 {
+		s.prune()
+		return s.save()
+	}
+
+	file, err := os.OpenFile(s.path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0600)
+	if err != nil {
+		return fmt.Errorf("failed to write history: %w", err)
+	}
+	defer file.Close()
+
+	if _, err := file.Write(append(data, '\n')); err != nil {
+		return fmt.Errorf("failed to write history: %w", err)
+	}
+	return nil
+}
+
+// Search returns the entries of session whose query contains all words of
+// text, ignoring case, newest first. An empty session matches all sessions.
+func (s *HistoryStore) Search(text, session string) []HistoryEntry {
+	words := strings.Fields(strings.ToLower(text))
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var entries []HistoryEntry
+	for i := len(s.entries) - 1; i >= 0; i-- {
+		entry := s.entries[i]
+		if session != "" && entry.Session != session {
+			continue
+		}
+		query := strings.ToLower(entry.Query)
+		matched := true
+		for _, word := range words {
+			if !strings.Contains(query, word) {
+				matched = false
+				break
+			}
+		}
+		if matched {
+			entries = append(entries, entry)
+		}
+	}
+
+	return entries
+}
+
+// Sessions returns the names of the sessions with history entries, sorted
+func (s *HistoryStore) Sessions() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var names []string
+	for _, entry := range s.entries {
+		if !slices.Contains(names, entry.Session) {
+			names = append(names, entry.Session)
+		}
+	}
+	slices.SortFunc(names, func(a, b string) int {
+		return strings.Compare(strings.ToLower(a), strings.ToLower(b))
+	})
+
+	return names
+}
+
+// Clear removes all entries and the history file
+func (s *HistoryStore) Clear() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.entries = nil
+	if err := os.Remove(s.path); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("failed to remove history: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,243 @@
+package config
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// sessionsFileName is the connection sessions file in the config directory
+const sessionsFileName = "sessions.json"
+
+// DefaultRowLimit caps the rows fetched for one result when a session sets no limit
+const DefaultRowLimit = 100000
+
+// Session is a named database connection profile.
+// Passwords are never stored in the sessions file.
+type Session struct {
+	Name     string `json:"name"`
+	Driver   string `json:"driver"`
+	Host     string `json:"host"`
+	Port     string `json:"port"`
+	Username string `json:"username"`
+	Database string `json:"database"`
+	// StatementTimeout limits each query in seconds, 0 means no limit
+	StatementTimeout int `json:"statement_timeout,omitempty"`
+	// RowLimit caps the rows fetched for one result, 0 means DefaultRowLimit
+	RowLimit int `json:"row_limit,omitempty"`
+	// TLSMode is disable, prefer, require, verify-ca or verify-full, empty means disable
+	TLSMode     string `json:"tls_mode,omitempty"`
+	TLSCAFile   string `json:"tls_ca_file,omitempty"`
+	TLSCertFile string `json:"tls_cert_file,omitempty"`
+	TLSKeyFile  string `json:"tls_key_file,omitempty"`
+	// ConnectTimeout limits connecting in seconds, 0 means the default of 15 seconds
+	ConnectTimeout int `json:"connect_timeout,omitempty"`
+	// Params are further driver parameters added to the DSN
+	Params map[string]string `json:"params,omitempty"`
+	// SSHHost reaches the database through an SSH tunnel, empty connects directly
+	SSHHost string `json:"ssh_host,omitempty"`
+	SSHPort string `json:"ssh_port,omitempty"`
+	SSHUser string `json:"ssh_user,omitempty"`
+	// SSHKeyFile is the private key of SSHUser, empty uses the SSH agent
+	SSHKeyFile string `json:"ssh_key_file,omitempty"`
+	// SSHInsecure skips checking the host key against known_hosts
+	SSHInsecure bool `json:"ssh_insecure,omitempty"`
+}
+
+// Timeout returns the statement timeout of the session, 0 means no limit
+func (s Session) Timeout() time.Duration {
+	return time.Duration(s.StatementTimeout) * time.Second
+}
+
+// MaxRows returns the row limit of the session
+func (s Session) MaxRows() int {
+	if s.RowLimit > 0 {
+		return s.RowLimit
+	}
+	return DefaultRowLimit
+}
+
+// SessionStore persists named connection sessions to disk
+type SessionStore struct {
+	mu       sync.Mutex
+	path     string
+	sessions []Session
+}
+
+// sessionsFile is the on-disk format of the sessions file
+type sessionsFile struct {
+	Sessions []Session `json:"sessions"`
+}
+
+// NewSessionStore creates a session store in the user config directory and loads it
+func NewSessionStore() (*SessionStore, error) {
+	dir, err := ConfigDir()
+	if err != nil {
+		return nil, err
+	}
+
+	store := &SessionStore{path: filepath.Join(dir, sessionsFileName)}
+	if err := store.Load(); err != nil {
+		return store, err
+	}
+
+	return store, nil
+}
+
+// Path returns the sessions file path
+func (s *SessionStore) Path() string {
+	return s.path
+}
+
+// Load reads sessions from disk, a missing file yields an empty store
+func (s *SessionStore) Load() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	data, err := os.ReadFile(s.path)
+	if errors.Is(err, os.ErrNotExist) {
+		s.sessions = nil
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read sessions: %w", err)
+	}
+
+	var file sessionsFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		return fmt.Errorf("failed to parse sessions file %s: %w", s.path, err)
+	}
+
+	s.sessions = file.Sessions
+	return nil
+}
+
+// save writes sessions to disk, caller must hold the lock
+func (s *SessionStore) save() error {
+	data, err := json.MarshalIndent(sessionsFile{Sessions: s.sessions}, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	if err := WriteFileAtomic(s.path, data, 0600); err != nil {
+		return fmt.Errorf("failed to write sessions: %w", err)
+	}
+
+	return nil
+}
+
+// List returns all sessions sorted by name
+func (s *SessionStore) List() []Session {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	sessions := make([]Session, len(s.sessions))
+	copy(sessions, s.sessions)
+	sort.Slice(sessions, func(i, j int) bool {
+		return strings.ToLower(sessions[i].Name) < strings.ToLower(sessions[j].Name)
+	})
+
+	return sessions
+}
+
+// Get returns the session with the given name
+func (s *SessionStore) Get(name string) (Session, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if i := s.indexOf(name); i >= 0 {
+		return s.sessions[i], true
+	}
+	return Session{}, false
+}
+
+// Put adds or replaces a session and saves the store
+func (s *SessionStore) Put(session Session) error {
+	session.Name = strings.TrimSpace(session.Name)
+	if session.Name == "" {
+		return fmt.Errorf("session name is required")
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if i := s.indexOf(session.Name); i >= 0 {
+		s.sessions[i] = session
+	} else {
+		s.sessions = append(s.sessions, session)
+	}
+
+	return s.save()
+}
+
+// Rename renames a session and saves the store
+func (s *SessionStore) Rename(oldName, newName string) error {
+	newName = strings.TrimSpace(newName)
+	if newName == "" {
+		return fmt.Errorf("session name is required")
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	i := s.indexOf(oldName)
+	if i < 0 {
+		return fmt.Errorf("session not found: %s", oldName)
+	}
+	if newName != oldName && s.indexOf(newName) >= 0 {
+		return fmt.Errorf("session already exists: %s", newName)
+	}
+
+	s.sessions[i].Name = newName
+	return s.save()
+}
+
+// Duplicate copies a session under a new unique name and saves the store
+func (s *SessionStore) Duplicate(name string) (Session, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	i := s.indexOf(name)
+	if i < 0 {
+		return Session{}, fmt.Errorf("session not found: %s", name)
+	}
+
+	session := s.sessions[i]
+	session.Name = name + " (copy)"
+	for n := 2; s.indexOf(session.Name) >= 0; n++ {
+		session.Name = fmt.Sprintf("%s (copy %d)", name, n)
+	}
+
+	s.sessions = append(s.sessions, session)
+	return session, s.save()
+}
+
+// Delete removes a session and saves the store
+func (s *SessionStore) Delete(name string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	i := s.indexOf(name)
+	if i < 0 {
+		return fmt.Errorf("session not found: %s", name)
+	}
+
+	s.sessions = append(s.sessions[:i], s.sessions[i+1:]...)
+	return s.save()
+}
+
+// indexOf returns the index of a session by name, caller must hold the lock
+func (s *SessionStore) indexOf(name string) int {
+	for i, session := range s.sessions {
+		if session.Name == name {
+			return i
+		}
+	}
+	return -1
+}
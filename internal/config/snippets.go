@@ -0,0 +1,299 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+)
+
+const (
+	// snippetsDirName is the snippets directory in the config directory
+	snippetsDirName = "snippets"
+	// snippetsFileName is the file new snippets are saved to
+	snippetsFileName = "snippets.sql"
+	// ProjectSnippetsDir holds the snippets shared in a project, relative
+	// to the working directory
+	ProjectSnippetsDir = ".gocmder/snippets"
+)
+
+// Snippet is a named query. Snippet files are SQL files where each snippet
+// starts with a "-- name:" comment, followed by optional "-- description:"
+// and "-- session:" comments and the query:
+//
+//	-- name: table_sizes
+//	-- description: Largest tables of a schema
+//	-- session: Production
+//	SELECT relname FROM pg_class WHERE relnamespace = :schema::regnamespace;
+type Snippet struct {
+	Name        string
+	Description string
+	// Session limits the snippet to one connection session, empty for all
+	Session string
+	Query   string
+	// File is the file the snippet is stored in
+	File string
+}
+
+// Global returns true if the snippet is available in all sessions
+func (s Snippet) Global() bool {
+	return s.Session == ""
+}
+
+// snippetFile is a snippet file with the text before its first snippet
+type snippetFile struct {
+	header   string
+	snippets []Snippet
+}
+
+// SnippetStore reads the snippet files of the user config directory and of
+// the project directory and saves snippets back to their file
+type SnippetStore struct {
+	mu    sync.Mutex
+	dirs  []string // directories read, the first one holds new snippets
+	files map[string]*snippetFile
+	order []string // file paths in the order they were read
+}
+
+// NewSnippetStore creates a snippet store for the user config directory and
+// the project directory and loads it
+func NewSnippetStore() (*SnippetStore, error) {
+	dir, err := ConfigDir()
+	if err != nil {
+		return nil, err
+	}
+
+	store := &SnippetStore{dirs: []string{filepath.Join(dir, snippetsDirName)}}
+	if project, err := filepath.Abs(ProjectSnippetsDir); err == nil && project != store.dirs[0] {
+		store.dirs = append(store.dirs, project)
+	}
+	if err := store.Load(); err != nil {
+		return store, err
+	}
+
+	return store, nil
+}
+
+// Path returns the file new snippets are saved to
+func (s *SnippetStore) Path() string {
+	return filepath.Join(s.dirs[0], snippetsFileName)
+}
+
+// Load reads the .sql files of the snippet directories, missing
+// directories are skipped
+func (s *SnippetStore) Load() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.files = map[string]*snippetFile{}
+	s.order = nil
+
+	var errs []error
+	for _, dir := range s.dirs {
+		paths, err := filepath.Glob(filepath.Join(dir, "*.sql"))
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		sort.Strings(paths)
+
+		for _, path := range paths {
+			data, err := os.ReadFile(path)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("failed to read snippets: %w", err))
+				continue
+			}
+			s.files[path] = parseSnippets(string(data), path)
+			s.order = append(s.order, path)
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// parseSnippets parses the text of a snippet file
+func parseSnippets(text, path string) *snippetFile {
+	file := &snippetFile{}
+	var current *Snippet
+	var query []string
+	inHeader := false
+
+	finish := func() {
+		if current != nil {
+			current.Query = strings.TrimSpace(strings.Join(query, "\n"))
+			file.snippets = append(file.snippets, *current)
+		}
+		query = nil
+	}
+
+	var header []string
+	for _, line := range strings.Split(strings.ReplaceAll(text, "\r\n", "\n"), "\n") {
+		key, value, ok := snippetComment(line)
+		if ok && key == "name" {
+			finish()
+			current = &Snippet{Name: value, File: path}
+			inHeader = true
+			continue
+		}
+		if current == nil {
+			header = append(header, line)
+			continue
+		}
+
+		if inHeader && ok {
+			switch key {
+			case "description":
+				current.Description = value
+				continue
+			case "session":
+				current.Session = value
+				continue
+			}
+		}
+		inHeader = false
+		query = append(query, line)
+	}
+	finish()
+
+	file.header = strings.TrimSpace(strings.Join(header, "\n"))
+	return file
+}
+
+// snippetComment parses a "-- key: value" comment line
+func snippetComment(line string) (string, string, bool) {
+	rest, ok := strings.CutPrefix(strings.TrimSpace(line), "--")
+	if !ok {
+		return "", "", false
+	}
+	key, value, ok := strings.Cut(rest, ":")
+	if !ok {
+		return "", "", false
+	}
+	key = strings.ToLower(strings.TrimSpace(key))
+	if key != "name" && key != "description" && key != "session" {
+		return "", "", false
+	}
+	return key, strings.TrimSpace(value), true
+}
+
+// format returns the text of a snippet file
+func (f *snippetFile) format() string {
+	var text strings.Builder
+	if f.header != "" {
+		text.WriteString(f.header)
+		text.WriteString("\n\n")
+	}
+	for i, snippet := range f.snippets {
+		if i > 0 {
+			text.WriteString("\n")
+		}
+		fmt.Fprintf(&text, "-- name: %s\n", snippet.Name)
+		if snippet.Description != "" {
+			fmt.Fprintf(&text, "-- description: %s\n", snippet.Description)
+		}
+		if snippet.Session != "" {
+			fmt.Fprintf(&text, "-- session: %s\n", snippet.Session)
+		}
+		text.WriteString(strings.TrimSpace(snippet.Query))
+		text.WriteString("\n")
+	}
+	return text.String()
+}
+
+// List returns the snippets available in session, global ones included,
+// sorted by name. An empty session lists all snippets.
+func (s *SnippetStore) List(session string) []Snippet {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var snippets []Snippet
+	for _, path := range s.order {
+		for _, snippet := range s.files[path].snippets {
+			if session == "" || snippet.Global() || snippet.Session == session {
+				snippets = append(snippets, snippet)
+			}
+		}
+	}
+	sort.SliceStable(snippets, func(i, j int) bool {
+		return strings.ToLower(snippets[i].Name) < strings.ToLower(snippets[j].Name)
+	})
+
+	return snippets
+}
+
+// Put adds or replaces the snippet of the same name in its file, snippets
+// without a file go to the personal snippets file
+func (s *SnippetStore) Put(snippet Snippet) error {
+	snippet.Name = strings.TrimSpace(snippet.Name)
+	if snippet.Name == "" || strings.ContainsAny(snippet.Name, "\r\n") {
+		return fmt.Errorf("snippet name is required")
+	}
+	if strings.TrimSpace(snippet.Query) == "" {
+		return fmt.Errorf("snippet query is empty")
+	}
+	snippet.Description = strings.Join(strings.Fields(snippet.Description), " ")
+	if snippet.File == "" {
+		snippet.File = s.Path()
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	file, ok := s.files[snippet.File]
+	if !ok {
+		file = &snippetFile{}
+		s.files[snippet.File] = file
+		s.order = append(s.order, snippet.File)
+	}
+
+	replaced := false
+	for i, existing := range file.snippets {
+		if existing.Name == snippet.Name {
+			file.snippets[i] = snippet
+			replaced = true
+			break
+		}
+	}
+	if !replaced {
+		file.snippets = append(file.snippets, snippet)
+	}
+
+	return s.save(snippet.File)
+}
+
+// Delete removes a snippet from its file
+func (s *SnippetStore) Delete(snippet Snippet) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	file, ok := s.files[snippet.File]
+	if !ok {
+		return fmt.Errorf("snippet not found: %s", snippet.Name)
+	}
+	for i, existing := range file.snippets {
+		if existing.Name == snippet.Name {
+			file.snippets = append(file.snippets[:i], file.snippets[i+1:]...)
+			return s.save(snippet.File)
+		}
+	}
+	return fmt.Errorf("snippet not found: %s", snippet.Name)
+}
+
+// save writes a snippet file, caller must hold the lock
+func (s *SnippetStore) save(path string) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return fmt.Errorf("failed to create snippets directory: %w", err)
+	}
+	// Shared snippet files keep their permissions
+	perm := os.FileMode(0600)
+	if info, err := os.Stat(path); err == nil {
+		perm = info.Mode().Perm()
+	}
+	if err := WriteFileAtomic(path, []byte(s.files[path].format()), perm); err != nil {
+		return fmt.Errorf("failed to write snippets: %w", err)
+	}
+	return nil
+}
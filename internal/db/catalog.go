@@ -0,0 +1,58 @@
+package db
+
+import (
+	"context"
+	"strings"
+)
+
+// DatabaseSwitcher is implemented by drivers whose connections are bound to
+// one database, so browsing another database needs a new connection
+type DatabaseSwitcher interface {
+	// CurrentDatabase returns the database the driver is connected to
+	CurrentDatabase() string
+	// UseDatabase reconnects to another database on the same server. The
+	// old connection is only closed once the new one works.
+	UseDatabase(ctx context.Context, database string) error
+}
+
+// SchemaBrowser is implemented by drivers with schemas inside a database
+type SchemaBrowser interface {
+	// GetSchemas returns the schemas of the connected database
+	GetSchemas(ctx context.Context) ([]string, error)
+	// GetSchemaObjects returns the objects of a schema grouped by kind
+	GetSchemaObjects(ctx context.Context, schema string) (*SchemaObjects, error)
+}
+
+// SchemaObjects lists the objects of a schema by kind
+type SchemaObjects struct {
+	Tables            []string
+	Views             []string
+	MaterializedViews []string
+	Functions         []string // with their argument types, e.g. add(integer, integer)
+	Sequences         []string
+	EnumTypes         []string
+}
+
+// IsSystemDatabase returns whether a database belongs to the server rather
+// than to its users
+func IsSystemDatabase(dialect Dialect, name string) bool {
+	switch dialect {
+	case DialectMySQL:
+		switch strings.ToLower(name) {
+		case "information_schema", "mysql", "performance_schema", "sys":
+			return true
+		}
+	case DialectPostgres:
+		return name == "postgres"
+	case DialectSQLite:
+		return name == "temp"
+	}
+	return false
+}
+
+// IsSystemSchema returns whether a PostgreSQL schema holds the catalog,
+// TOAST tables or the temporary tables of a session
+func IsSystemSchema(name string) bool {
+	return name == "pg_catalog" || name == "information_schema" ||
+		strings.HasPrefix(name, "pg_toast") || strings.HasPrefix(name, "pg_temp_")
+}
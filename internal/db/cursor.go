@@ -0,0 +1,107 @@
+package db
+
+import (
+	"database/sql"
+	"sync"
+	"sync/atomic"
+)
+
+// Cursor streams the rows of a result set. Fetch may be called from a
+// background goroutine while Done and Notices are read elsewhere.
+type Cursor struct {
+	mu      sync.Mutex // serializes Fetch and Close
+	rows    *sql.Rows
+	columns []Column
+	closed  bool
+	done    atomic.Bool
+	notices []string
+	onClose func() []string
+}
+
+// newCursor creates a cursor over rows
+func newCursor(rows *sql.Rows, columns []Column) *Cursor {
+	return &Cursor{
+		rows:    rows,
+		columns: columns,
+	}
+}
+
+// Columns returns the column metadata
+func (c *Cursor) Columns() []Column {
+	return c.columns
+}
+
+// Fetch reads up to n rows, or all remaining rows if n <= 0. The cursor
+// closes itself once the result set is exhausted or fails.
+func (c *Cursor) Fetch(n int) ([][]Value, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.closed {
+		return nil, nil
+	}
+
+	var result [][]Value
+	for n <= 0 || len(result) < n {
+		if !c.rows.Next() {
+			err := c.rows.Err()
+			c.close()
+			return result, err
+		}
+
+		values := make([]interface{}, len(c.columns))
+		valuePtrs := make([]interface{}, len(c.columns))
+		for i := range values {
+			valuePtrs[i] = &values[i]
+		}
+
+		if err := c.rows.Scan(valuePtrs...); err != nil {
+			c.close()
+			return result, err
+		}
+
+		row := make([]Value, len(c.columns))
+		for i, val := range values {
+			row[i] = newValue(c.columns[i], val)
+		}
+		result = append(result, row)
+	}
+
+	return result, nil
+}
+
+// Done returns true if all rows have been fetched or the cursor was closed
+func (c *Cursor) Done() bool {
+	return c.done.Load()
+}
+
+// Notices returns the server notices collected when the cursor was closed
+func (c *Cursor) Notices() []string {
+	if !c.done.Load() {
+		return nil
+	}
+	return c.notices
+}
+
+// Close releases the result set, it blocks until a running Fetch returns
+func (c *Cursor) Close() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.close()
+}
+
+// close closes the rows and runs the close hook once, caller must hold the lock
+func (c *Cursor) close() error {
+	if c.closed {
+		return nil
+	}
+	c.closed = true
+
+	err := c.rows.Close()
+	if c.onClose != nil {
+		c.notices = c.onClose()
+	}
+	c.done.Store(true)
+	return err
+}
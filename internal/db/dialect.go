@@ -0,0 +1,322 @@
+package db
+
+import (
+	"strings"
+	"unicode"
+)
+
+// Dialect identifies the SQL dialect of a driver
+type Dialect int
+
+const (
+	DialectPostgres Dialect = iota
+	DialectMySQL
+	DialectSQLite
+)
+
+// StatementKind tells how a statement has to be executed
+type StatementKind int
+
+const (
+	// StatementCommand returns no rows and is run with Exec
+	StatementCommand StatementKind = iota
+	// StatementQuery returns a result set and is run with Query
+	StatementQuery
+)
+
+// String returns the statement kind name
+func (k StatementKind) String() string {
+	if k == StatementQuery {
+		return "query"
+	}
+	return "command"
+}
+
+// sqlWord is a keyword or identifier found outside quotes and comments
+type sqlWord struct {
+	text  string // upper case
+	depth int    // parenthesis depth
+}
+
+// Classify returns whether a single statement produces a result set in dialect
+func Classify(dialect Dialect, query string) StatementKind {
+	words := scanWords(dialect, query)
+	if len(words) == 0 {
+		return StatementCommand
+	}
+
+	first := words[0]
+	if first.text == "WITH" {
+		// The statement after the CTE list decides
+		for _, word := range words[1:] {
+			if word.depth != first.depth {
+				continue
+			}
+			switch word.text {
+			case "SELECT", "VALUES", "TABLE", "INSERT", "UPDATE", "DELETE", "MERGE":
+				return classifyWords(dialect, word.text, words)
+			}
+		}
+		return StatementQuery
+	}
+
+	return classifyWords(dialect, first.text, words)
+}
+
+// ChangesSchema returns true if a statement creates, alters or drops
+// objects, so cached metadata of the database is stale afterwards
+func ChangesSchema(dialect Dialect, query string) bool {
+	words := scanWords(dialect, query)
+	if len(words) == 0 {
+		return false
+	}
+
+	switch words[0].text {
+	case "CREATE", "ALTER", "DROP", "RENAME":
+		return true
+	case "ATTACH", "DETACH":
+		return dialect == DialectSQLite
+	}
+	return false
+}
+
+// classifyWords classifies a statement by its leading keyword
+func classifyWords(dialect Dialect, keyword string, words []sqlWord) StatementKind {
+	switch keyword {
+	case "SELECT":
+		// SELECT ... INTO stores the result instead of returning it
+		if dialect != DialectSQLite && hasTopLevelWord(words, "INTO") {
+			return StatementCommand
+		}
+		return StatementQuery
+	case "VALUES", "TABLE", "SHOW", "EXPLAIN", "CALL":
+		return StatementQuery
+	case "INSERT", "UPDATE", "DELETE", "MERGE", "REPLACE":
+		if hasWord(words, "RETURNING") {
+			return StatementQuery
+		}
+		return StatementCommand
+	}
+
+	switch dialect {
+	case DialectPostgres:
+		switch keyword {
+		case "FETCH", "EXECUTE":
+			return StatementQuery
+		}
+	case DialectMySQL:
+		switch keyword {
+		case "DESCRIBE", "DESC", "HELP", "CHECKSUM":
+			return StatementQuery
+		case "ANALYZE", "CHECK", "OPTIMIZE", "REPAIR":
+			// Table maintenance statements report a status table
+			return StatementQuery
+		}
+	case DialectSQLite:
+		if keyword == "PRAGMA" {
+			// PRAGMA name = value sets a value, PRAGMA name reads it
+			if hasWord(words, "=") {
+				return StatementCommand
+			}
+			return StatementQuery
+		}
+	}
+
+	return StatementCommand
+}
+
+// hasWord returns true if words contains text at any depth
+func hasWord(words []sqlWord, text string) bool {
+	for _, word := range words {
+		if word.text == text {
+			return true
+		}
+	}
+	return false
+}
+
+// hasTopLevelWord returns true if words contains text outside parentheses
+func hasTopLevelWord(words []sqlWord, text string) bool {
+	depth := words[0].depth
+	for _, word := range words {
+		if word.depth == depth && word.text == text {
+			return true
+		}
+	}
+	return false
+}
+
+// scanWords returns the upper cased words of a statement, skipping
+// comments, string literals and quoted identifiers. "=" is reported as a
+// word so that assignments can be detected.
+func scanWords(dialect Dialect, query string) []sqlWord {
+	var words []sqlWord
+	depth := 0
+
+	runes := []rune(query)
+	for i := 0; i < len(runes); {
+		if next, kind := skipLexeme(dialect, runes, i); kind != lexemeNone {
+			i = next
+			continue
+		}
+
+		switch r := runes[i]; {
+		case r == '(':
+			depth++
+			i++
+		case r == ')':
+			if depth > 0 {
+				depth--
+			}
+			i++
+		case r == '=':
+			words = append(words, sqlWord{text: "=", depth: depth})
+			i++
+		case unicode.IsLetter(r) || r == '_':
+			start := i
+			for i < len(runes) && isWordRune(runes[i]) {
+				i++
+			}
+			words = append(words, sqlWord{text: strings.ToUpper(string(runes[start:i])), depth: depth})
+		default:
+			i++
+		}
+	}
+
+	return words
+}
+
+// lexemeKind is the kind of text skipped by skipLexeme
+type lexemeKind int
+
+const (
+	lexemeNone lexemeKind = iota
+	lexemeComment
+	// lexemeString is a string literal, a PostgreSQL dollar quote or a
+	// MySQL double-quoted string
+	lexemeString
+	// lexemeIdentifier is an identifier in double quotes, backticks or
+	// SQLite brackets
+	lexemeIdentifier
+)
+
+// skipLexeme skips the comment, string literal or quoted identifier
+// starting at i and returns the position after it and its kind, or i and
+// lexemeNone if there is none there. Every scanner of statements uses it,
+// so they agree on where literals and comments begin and end. Literals and
+// comments that are not closed run to the end of the text.
+func skipLexeme(dialect Dialect, runes []rune, i int) (int, lexemeKind) {
+	r := runes[i]
+	next := rune(0)
+	if i+1 < len(runes) {
+		next = runes[i+1]
+	}
+
+	switch {
+	case r == '-' && next == '-', r == '#' && dialect == DialectMySQL:
+		return skipLine(runes, i), lexemeComment
+	case r == '/' && next == '*':
+		// Only PostgreSQL nests block comments
+		return skipBlockComment(runes, i, dialect == DialectPostgres), lexemeComment
+	case r == '\'':
+		return skipQuoted(runes, i, '\'', dialect == DialectMySQL), lexemeString
+	case next == '\'' && strings.ContainsRune("EeXxBbNn", r) && (i == 0 || !isWordRune(runes[i-1])):
+		// A prefix such as E'...' or X'...' belongs to the literal, PostgreSQL
+		// escape strings take backslash escapes
+		backslash := dialect == DialectMySQL || dialect == DialectPostgres && (r == 'E' || r == 'e')
+		return skipQuoted(runes, i+1, '\'', backslash), lexemeString
+	case r == '"' && dialect == DialectMySQL:
+		// MySQL treats double quotes as strings unless ANSI_QUOTES is set
+		return skipQuoted(runes, i, '"', true), lexemeString
+	case r == '"':
+		return skipQuoted(runes, i, '"', false), lexemeIdentifier
+	case r == '`' && dialect != DialectPostgres:
+		return skipQuoted(runes, i, '`', false), lexemeIdentifier
+	case r == '[' && dialect == DialectSQLite:
+		return skipQuoted(runes, i, ']', false), lexemeIdentifier
+	case r == '$' && dialect == DialectPostgres:
+		// A $ that does not open a tag is e.g. a $1 parameter
+		if end := skipDollarQuoted(runes, i); end > i+1 {
+			return end, lexemeString
+		}
+	}
+	return i, lexemeNone
+}
+
+// isWordRune returns true if r may be part of a name
+func isWordRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r) || r == '_' || r == '$'
+}
+
+// skipLine skips a line comment starting at i
+func skipLine(runes []rune, i int) int {
+	for i < len(runes) && runes[i] != '\n' {
+		i++
+	}
+	return i
+}
+
+// skipBlockComment skips a /* */ comment starting at i
+func skipBlockComment(runes []rune, i int, nested bool) int {
+	depth := 0
+	for i < len(runes) {
+		switch {
+		case runes[i] == '/' && i+1 < len(runes) && runes[i+1] == '*':
+			if depth == 0 || nested {
+				depth++
+			}
+			i += 2
+		case runes[i] == '*' && i+1 < len(runes) && runes[i+1] == '/':
+			depth--
+			i += 2
+			if depth == 0 {
+				return i
+			}
+		default:
+			i++
+		}
+	}
+	return i
+}
+
+// skipQuoted skips a quoted literal or identifier starting at i, a doubled
+// closing quote is an escaped quote
+func skipQuoted(runes []rune, i int, closing rune, backslash bool) int {
+	i++
+	for i < len(runes) {
+		switch {
+		case backslash && runes[i] == '\\':
+			i += 2
+		case runes[i] == closing:
+			if i+1 < len(runes) && runes[i+1] == closing {
+				i += 2
+				continue
+			}
+			return i + 1
+		default:
+			i++
+		}
+	}
+	return i
+}
+
+// skipDollarQuoted skips a PostgreSQL $tag$ ... $tag$ string starting at i,
+// a $ that does not open a tag (e.g. a $1 parameter) is skipped alone
+func skipDollarQuoted(runes []rune, i int) int {
+	end := i + 1
+	for end < len(runes) && (unicode.IsLetter(runes[end]) || runes[end] == '_' || (end > i+1 && unicode.IsDigit(runes[end]))) {
+		end++
+	}
+	if end >= len(runes) || runes[end] != '$' {
+		return i + 1
+	}
+
+	tag := string(runes[i : end+1])
+	rest := string(runes[end+1:])
+	closing := strings.Index(rest, tag)
+	if closing < 0 {
+		return len(runes)
+	}
+
+	return end + 1 + len([]rune(rest[:closing])) + len([]rune(tag))
+}
@@ -0,0 +1,220 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestClassify(t *testing.T) {
+	tests := []struct {
+		dialect Dialect
+		query   string
+		want    StatementKind
+	}{
+		// PostgreSQL
+		{DialectPostgres, "", StatementCommand},
+		{DialectPostgres, "SELECT 1", StatementQuery},
+		{DialectPostgres, "WITH x AS (SELECT 1) SELECT * FROM x", StatementQuery},
+		{DialectPostgres, "WITH x AS (SELECT 1) INSERT INTO t SELECT * FROM x", StatementCommand},
+		{DialectPostgres, "WITH x AS (SELECT 1) INSERT INTO t SELECT * FROM x RETURNING id", StatementQuery},
+		{DialectPostgres, "WITH d AS (DELETE FROM t RETURNING *) SELECT count(*) FROM d", StatementQuery},
+		{DialectPostgres, "INSERT INTO t VALUES (1) RETURNING id", StatementQuery},
+		{DialectPostgres, "DELETE FROM t WHERE id = 1", StatementCommand},
+		{DialectPostgres, "SELECT * INTO backup FROM t", StatementCommand},
+		{DialectPostgres, "SELECT (SELECT 1), 'into' FROM t", StatementQuery},
+		{DialectPostgres, "SHOW search_path", StatementQuery},
+		{DialectPostgres, "EXPLAIN ANALYZE UPDATE t SET a = 1", StatementQuery},
+		{DialectPostgres, "-- SELECT\nUPDATE t SET a = 1", StatementCommand},
+		{DialectPostgres, "/* outer /* nested */ SELECT */ UPDATE t SET a = 1", StatementCommand},
+		{DialectPostgres, "UPDATE t SET note = 'returning'", StatementCommand},
+		{DialectPostgres, "UPDATE t SET note = E'it\\'s returning'", StatementCommand},
+		{DialectPostgres, `UPDATE "returning" SET a = 1`, StatementCommand},
+		{DialectPostgres, "UPDATE t SET body = $$ RETURNING $$", StatementCommand},
+		{DialectPostgres, "FETCH 10 FROM c", StatementQuery},
+		{DialectPostgres, "PRAGMA x", StatementCommand},
+
+		// MySQL
+		{DialectMySQL, "WITH x AS (SELECT 1) UPDATE t JOIN x SET a = 1", StatementCommand},
+		{DialectMySQL, "WITH x AS (SELECT 1) SELECT * FROM x", StatementQuery},
+		{DialectMySQL, "SELECT a INTO @v FROM t", StatementCommand},
+		{DialectMySQL, "SELECT `into` FROM t", StatementQuery},
+		{DialectMySQL, "SHOW TABLES", StatementQuery},
+		{DialectMySQL, "# comment\nDESCRIBE t", StatementQuery},
+		{DialectMySQL, "-- comment\nDESC t", StatementQuery},
+		{DialectMySQL, "INSERT INTO t VALUES ('it\\'s RETURNING')", StatementCommand},
+		{DialectMySQL, `INSERT INTO t VALUES ("returning")`, StatementCommand},
+		{DialectMySQL, "DELETE FROM t RETURNING id", StatementQuery},
+		{DialectMySQL, "OPTIMIZE TABLE t", StatementQuery},
+		{DialectMySQL, "SET @a = 1", StatementCommand},
+
+		// SQLite
+		{DialectSQLite, "PRAGMA journal_mode=WAL", StatementCommand},
+		{DialectSQLite, "PRAGMA journal_mode", StatementQuery},
+		{DialectSQLite, "PRAGMA table_info('t')", StatementQuery},
+		{DialectSQLite, "WITH RECURSIVE c(n) AS (SELECT 1) DELETE FROM t WHERE id IN (SELECT n FROM c)", StatementCommand},
+		{DialectSQLite, "WITH c AS (SELECT 1) SELECT * FROM c", StatementQuery},
+		{DialectSQLite, "INSERT INTO t VALUES (1) RETURNING id", StatementQuery},
+		{DialectSQLite, "SELECT * FROM t WHERE [into] = 1", StatementQuery},
+		{DialectSQLite, "SHOW tables", StatementQuery},
+		{DialectSQLite, "/* SELECT */ DELETE FROM t", StatementCommand},
+		{DialectSQLite, "UPDATE t SET a = '= returning'", StatementCommand},
+	}
+
+	names := map[Dialect]string{DialectPostgres: "postgres", DialectMySQL: "mysql", DialectSQLite: "sqlite"}
+	for _, tt := range tests {
+		if got := Classify(tt.dialect, tt.query); got != tt.want {
+			t.Errorf("%s %q: got %s, want %s", names[tt.dialect], tt.query, got, tt.want)
+		}
+	}
+}
+
+func TestSkipLexeme(t *testing.T) {
+	tests := []struct {
+		dialect Dialect
+		text    string
+		want    string // the text skipped
+		kind    lexemeKind
+	}{
+		{DialectPostgres, "-- c\nx", "-- c", lexemeComment},
+		{DialectPostgres, "# x", "", lexemeNone},
+		{DialectMySQL, "# c\nx", "# c", lexemeComment},
+		{DialectPostgres, "/* a /* b */ c */ x", "/* a /* b */ c */", lexemeComment},
+		{DialectMySQL, "/* a /* b */ c */ x", "/* a /* b */", lexemeComment},
+		{DialectSQLite, "/* a /* b */ c */ x", "/* a /* b */", lexemeComment},
+		{DialectPostgres, "/* open", "/* open", lexemeComment},
+		{DialectPostgres, "'it''s' x", "'it''s'", lexemeString},
+		{DialectPostgres, `'a\' x'`, `'a\'`, lexemeString},
+		{DialectMySQL, `'a\' x' y`, `'a\' x'`, lexemeString},
+		{DialectPostgres, `E'a\' x' y`, `E'a\' x'`, lexemeString},
+		{DialectPostgres, `x'a\' y'`, `x'a\'`, lexemeString},
+		{DialectMySQL, "N'abc' x", "N'abc'", lexemeString},
+		{DialectPostgres, "Q'abc'", "", lexemeNone},
+		{DialectPostgres, `"a""b" x`, `"a""b"`, lexemeIdentifier},
+		{DialectMySQL, `"a\"b" x`, `"a\"b"`, lexemeString},
+		{DialectMySQL, "`a` x", "`a`", lexemeIdentifier},
+		{DialectPostgres, "`a`", "", lexemeNone},
+		{DialectSQLite, "[a b] x", "[a b]", lexemeIdentifier},
+		{DialectMySQL, "[a b]", "", lexemeNone},
+		{DialectPostgres, "$$ a; $$ x", "$$ a; $$", lexemeString},
+		{DialectPostgres, "$fn$ $$ $fn$ x", "$fn$ $$ $fn$", lexemeString},
+		{DialectPostgres, "$1 x", "", lexemeNone},
+		{DialectMySQL, "$$ a $$", "", lexemeNone},
+	}
+
+	names := map[Dialect]string{DialectPostgres: "postgres", DialectMySQL: "mysql", DialectSQLite: "sqlite"}
+	for _, tt := range tests {
+		runes := []rune(tt.text)
+		end, kind := skipLexeme(tt.dialect, runes, 0)
+		if got := string(runes[:end]); got != tt.want || kind != tt.kind {
+			t.Errorf("%s %q: got %q kind %d, want %q kind %d", names[tt.dialect], tt.text, got, kind, tt.want, tt.kind)
+		}
+	}
+}
+
+// TestLexemeBoundaries checks that the scanners agree on where literals
+// and comments end
+func TestLexemeBoundaries(t *testing.T) {
+	const script = `SELECT E'a\\' + /* x /* y */ ; */ 1; CREATE TABLE t (a int CHECK (a > 0) /* ) */)`
+
+	statements := Split(DialectPostgres, script)
+	if len(statements) != 2 {
+		t.Fatalf("got %d statements, want 2: %q", len(statements), statements)
+	}
+	if want := `SELECT E'a\\' + /* x /* y */ ; */ 1`; statements[0].Text != want {
+		t.Errorf("got statement %q, want %q", statements[0].Text, want)
+	}
+
+	checks := extractChecks(DialectPostgres, statements[1].Text)
+	if len(checks) != 1 || checks[0].Expression != "a > 0" {
+		t.Errorf("got checks %v, want a > 0", checks)
+	}
+
+	var kinds []TokenKind
+	for _, token := range Tokenize(DialectPostgres, statements[0].Text) {
+		kinds = append(kinds, token.Kind)
+	}
+	want := []TokenKind{TokenKeyword, TokenString, TokenPunctuation, TokenComment, TokenNumber}
+	if !slices.Equal(kinds, want) {
+		t.Errorf("got tokens %v, want %v", kinds, want)
+	}
+}
+
+// countingRunner counts the statements sent to the database
+type countingRunner struct {
+	sqlRunner
+	queries, execs int
+}
+
+func (r *countingRunner) QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error) {
+	r.queries++
+	return r.sqlRunner.QueryContext(ctx, query, args...)
+}
+
+func (r *countingRunner) ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	r.execs++
+	return r.sqlRunner.ExecContext(ctx, query, args...)
+}
+
+func TestOpenStatementSQLite(t *testing.T) {
+	ctx := context.Background()
+	conn, err := sql.Open("sqlite", filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	if _, err := conn.Exec("CREATE TABLE t (id INTEGER PRIMARY KEY, name TEXT UNIQUE)"); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		query          string
+		queries, execs int
+		err            string
+		affected, id   int64
+		rows           int
+	}{
+		{query: "INSERT INTO t (name) VALUES ('a')", execs: 1, affected: 1, id: 1},
+		{query: "INSERT INTO t (name) VALUES ('a')", execs: 1, err: "UNIQUE"},
+		{query: "INSERT INTO t (name) VALUES ('b') RETURNING id", queries: 1, rows: 1},
+		{query: "INSERT INTO t (name) VALUES ('b') RETURNING id", queries: 1, err: "UNIQUE"},
+		{query: "UPDATE t SET name = name || 'x'", execs: 1, affected: 2},
+		{query: "SELECT * FROM t", queries: 1, rows: 2},
+	}
+	for _, tt := range tests {
+		runner := &countingRunner{sqlRunner: conn}
+		result, err := fetchAll(openStatement(ctx, runner, DialectSQLite, tt.query))
+
+		if runner.queries != tt.queries || runner.execs != tt.execs {
+			t.Errorf("%s: ran %d queries and %d execs, want %d and %d", tt.query, runner.queries, runner.execs, tt.queries, tt.execs)
+		}
+		if tt.err != "" {
+			if err == nil || !strings.Contains(err.Error(), tt.err) || result.Error != err {
+				t.Errorf("%s: got error %v and result error %v, want %s", tt.query, err, result.Error, tt.err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("%s: %v", tt.query, err)
+		}
+		// SQLite keeps the last insert ID of the connection, only inserts check it
+		if tt.execs > 0 && (result.RowsAffected != tt.affected || (tt.id != 0 && result.LastInsertID != tt.id)) {
+			t.Errorf("%s: got %d rows affected and insert ID %d, want %d and %d", tt.query, result.RowsAffected, result.LastInsertID, tt.affected, tt.id)
+		}
+		if len(result.Rows) != tt.rows {
+			t.Errorf("%s: got %d rows, want %d", tt.query, len(result.Rows), tt.rows)
+		}
+	}
+
+	// The failed statements inserted nothing
+	var count int
+	if err := conn.QueryRow("SELECT count(*) FROM t").Scan(&count); err != nil {
+		t.Fatal(err)
+	}
+	if count != 2 {
+		t.Errorf("got %d rows in t, want 2", count)
+	}
+}
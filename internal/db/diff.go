@@ -0,0 +1,188 @@
+package db
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+)
+
+// DiffKind tells how a row differs between two results
+type DiffKind int
+
+const (
+	DiffAdded   DiffKind = iota // only in the new result
+	DiffRemoved                 // only in the old result
+	DiffChanged                 // in both with different values
+)
+
+// RowDiff is a row that differs between two results. Values are those of
+// ResultDiff.Columns, Old is nil for added rows and New for removed rows.
+type RowDiff struct {
+	Kind    DiffKind
+	Old     []Value
+	New     []Value
+	Changed []bool // columns whose values differ, set for changed rows
+}
+
+// ResultDiff is the difference between two results whose rows are matched
+// by key columns
+type ResultDiff struct {
+	Columns   []Column  // compared columns: those of the new result that the old one has too
+	Key       []int     // key columns, indexes into Columns
+	Rows      []RowDiff // changed and added rows in new result order, then removed rows
+	Added     int
+	Removed   int
+	Changed   int
+	Unchanged int
+	OnlyOld   []string // columns of the old result only, not compared
+	OnlyNew   []string // columns of the new result only, not compared
+}
+
+// Summary returns the number of rows of each kind
+func (d *ResultDiff) Summary() string {
+	return fmt.Sprintf("%d added, %d removed, %d changed, %d unchanged", d.Added, d.Removed, d.Changed, d.Unchanged)
+}
+
+// DiffResults matches the rows of two results by the key columns, named
+// case-insensitively, and returns the rows that differ. Columns are matched
+// by name as well. Each key has to be unique in both results.
+func DiffResults(oldColumns []Column, oldRows [][]Value, newColumns []Column, newRows [][]Value, key []string) (*ResultDiff, error) {
+	if len(key) == 0 {
+		return nil, fmt.Errorf("choose the key columns that identify a row")
+	}
+
+	diff := &ResultDiff{}
+	var oldIndex, newIndex []int // positions of the compared columns in each result
+	matched := make([]bool, len(oldColumns))
+	for i, column := range newColumns {
+		j := columnIndex(oldColumns, column.Name, matched)
+		if j < 0 {
+			diff.OnlyNew = append(diff.OnlyNew, column.Name)
+			continue
+		}
+		matched[j] = true
+		diff.Columns = append(diff.Columns, column)
+		newIndex = append(newIndex, i)
+		oldIndex = append(oldIndex, j)
+	}
+	for j, column := range oldColumns {
+		if !matched[j] {
+			diff.OnlyOld = append(diff.OnlyOld, column.Name)
+		}
+	}
+
+	for _, name := range key {
+		i := columnIndex(diff.Columns, name, nil)
+		if i < 0 {
+			return nil, fmt.Errorf("key column %s is not in both results", name)
+		}
+		diff.Key = append(diff.Key, i)
+	}
+
+	pick := func(row []Value, index []int) []Value {
+		values := make([]Value, len(index))
+		for i, j := range index {
+			values[i] = row[j]
+		}
+		return values
+	}
+
+	old := make(map[string]int, len(oldRows))
+	oldValues := make([][]Value, len(oldRows))
+	for i, row := range oldRows {
+		oldValues[i] = pick(row, oldIndex)
+		k := diff.rowKey(oldValues[i])
+		if _, ok := old[k]; ok {
+			return nil, fmt.Errorf("key %s appears more than once in the old result", diff.keyText(oldValues[i]))
+		}
+		old[k] = i
+	}
+
+	seen := make(map[string]bool, len(newRows))
+	found := make([]bool, len(oldRows))
+	for _, row := range newRows {
+		values := pick(row, newIndex)
+		k := diff.rowKey(values)
+		if seen[k] {
+			return nil, fmt.Errorf("key %s appears more than once in the new result", diff.keyText(values))
+		}
+		seen[k] = true
+
+		i, ok := old[k]
+		if !ok {
+			diff.Rows = append(diff.Rows, RowDiff{Kind: DiffAdded, New: values})
+			diff.Added++
+			continue
+		}
+		found[i] = true
+
+		changed := make([]bool, len(values))
+		differs := false
+		for c := range values {
+			if !sameValue(oldValues[i][c], values[c]) {
+				changed[c] = true
+				differs = true
+			}
+		}
+		if !differs {
+			diff.Unchanged++
+			continue
+		}
+		diff.Rows = append(diff.Rows, RowDiff{Kind: DiffChanged, Old: oldValues[i], New: values, Changed: changed})
+		diff.Changed++
+	}
+
+	for i, values := range oldValues {
+		if !found[i] {
+			diff.Rows = append(diff.Rows, RowDiff{Kind: DiffRemoved, Old: values})
+			diff.Removed++
+		}
+	}
+
+	return diff, nil
+}
+
+// columnIndex returns the index of the first column named name that is not
+// taken yet, -1 if there is none
+func columnIndex(columns []Column, name string, taken []bool) int {
+	for i, column := range columns {
+		if strings.EqualFold(column.Name, name) && (taken == nil || !taken[i]) {
+			return i
+		}
+	}
+	return -1
+}
+
+// rowKey returns the key values of a row as a map key
+func (d *ResultDiff) rowKey(row []Value) string {
+	var key strings.Builder
+	for _, i := range d.Key {
+		if row[i].Null {
+			key.WriteString("\x01")
+		} else {
+			key.WriteString(row[i].String())
+		}
+		key.WriteString("\x00")
+	}
+	return key.String()
+}
+
+// keyText returns the key values of a row for messages
+func (d *ResultDiff) keyText(row []Value) string {
+	values := make([]string, len(d.Key))
+	for i, c := range d.Key {
+		values[i] = row[c].String()
+	}
+	return "(" + strings.Join(values, ", ") + ")"
+}
+
+// sameValue returns true if two values are equal, NULL only equals NULL
+func sameValue(a, b Value) bool {
+	if a.Null || b.Null {
+		return a.Null == b.Null
+	}
+	if a.Kind == ValueBinary || b.Kind == ValueBinary {
+		return a.Kind == b.Kind && bytes.Equal(a.Bytes, b.Bytes)
+	}
+	return a.Text == b.Text
+}
@@ -0,0 +1,213 @@
+package db
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// diffColumns returns text columns named names
+func diffColumns(names ...string) []Column {
+	columns := make([]Column, len(names))
+	for i, name := range names {
+		columns[i] = Column{Name: name}
+	}
+	return columns
+}
+
+// diffRow returns a row of text values, "NULL" stands for NULL
+func diffRow(values ...string) []Value {
+	row := make([]Value, len(values))
+	for i, value := range values {
+		if value == "NULL" {
+			row[i] = Value{Null: true}
+		} else {
+			row[i] = Value{Text: value}
+		}
+	}
+	return row
+}
+
+// diffText formats the rows of a diff, changed cells as old>new
+func diffText(diff *ResultDiff) []string {
+	var lines []string
+	for _, row := range diff.Rows {
+		var cells []string
+		switch row.Kind {
+		case DiffAdded:
+			for _, value := range row.New {
+				cells = append(cells, value.String())
+			}
+			lines = append(lines, "+ "+strings.Join(cells, " "))
+		case DiffRemoved:
+			for _, value := range row.Old {
+				cells = append(cells, value.String())
+			}
+			lines = append(lines, "- "+strings.Join(cells, " "))
+		case DiffChanged:
+			for i, value := range row.New {
+				if row.Changed[i] {
+					cells = append(cells, row.Old[i].String()+">"+value.String())
+				} else {
+					cells = append(cells, value.String())
+				}
+			}
+			lines = append(lines, "~ "+strings.Join(cells, " "))
+		}
+	}
+	return lines
+}
+
+func TestDiffResults(t *testing.T) {
+	tests := []struct {
+		name       string
+		oldColumns []string
+		oldRows    [][]Value
+		newColumns []string
+		newRows    [][]Value
+		key        []string
+		want       []string
+		summary    string
+	}{
+		{
+			name:       "identical",
+			oldColumns: []string{"id", "name"},
+			oldRows:    [][]Value{diffRow("1", "a"), diffRow("2", "b")},
+			newColumns: []string{"id", "name"},
+			newRows:    [][]Value{diffRow("2", "b"), diffRow("1", "a")},
+			key:        []string{"id"},
+			summary:    "0 added, 0 removed, 0 changed, 2 unchanged",
+		},
+		{
+			name:       "added removed and changed",
+			oldColumns: []string{"id", "name"},
+			oldRows:    [][]Value{diffRow("1", "a"), diffRow("2", "b"), diffRow("3", "c")},
+			newColumns: []string{"id", "name"},
+			newRows:    [][]Value{diffRow("4", "d"), diffRow("2", "B"), diffRow("1", "a")},
+			key:        []string{"id"},
+			want:       []string{"+ 4 d", "~ 2 b>B", "- 3 c"},
+			summary:    "1 added, 1 removed, 1 changed, 1 unchanged",
+		},
+		{
+			name:       "NULL only equals NULL",
+			oldColumns: []string{"id", "note"},
+			oldRows:    [][]Value{diffRow("1", "NULL"), diffRow("2", "NULL"), diffRow("3", "")},
+			newColumns: []string{"id", "note"},
+			newRows:    [][]Value{diffRow("1", "NULL"), diffRow("2", "NULL"), diffRow("3", "NULL")},
+			key:        []string{"id"},
+			want:       []string{"~ 3 >NULL"},
+			summary:    "0 added, 0 removed, 1 changed, 2 unchanged",
+		},
+		{
+			name:       "composite key",
+			oldColumns: []string{"a", "b", "v"},
+			oldRows:    [][]Value{diffRow("1", "1", "x"), diffRow("1", "2", "y")},
+			newColumns: []string{"a", "b", "v"},
+			newRows:    [][]Value{diffRow("1", "2", "z"), diffRow("2", "1", "x")},
+			key:        []string{"a", "b"},
+			want:       []string{"~ 1 2 y>z", "+ 2 1 x", "- 1 1 x"},
+			summary:    "1 added, 1 removed, 1 changed, 0 unchanged",
+		},
+		{
+			name:       "NULL key values match each other, not the text NULL",
+			oldColumns: []string{"id", "v"},
+			oldRows:    [][]Value{diffRow("NULL", "a")},
+			newColumns: []string{"id", "v"},
+			newRows:    [][]Value{{{Text: "NULL"}, {Text: "a"}}, diffRow("NULL", "b")},
+			key:        []string{"id"},
+			want:       []string{"+ NULL a", "~ NULL a>b"},
+			summary:    "1 added, 0 removed, 1 changed, 0 unchanged",
+		},
+		{
+			name:       "columns matched by name in any order and case",
+			oldColumns: []string{"name", "ID", "gone"},
+			oldRows:    [][]Value{diffRow("a", "1", "x")},
+			newColumns: []string{"id", "extra", "Name"},
+			newRows:    [][]Value{diffRow("1", "y", "A")},
+			key:        []string{"Id"},
+			want:       []string{"~ 1 a>A"},
+			summary:    "0 added, 0 removed, 1 changed, 0 unchanged",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			diff, err := DiffResults(diffColumns(tt.oldColumns...), tt.oldRows, diffColumns(tt.newColumns...), tt.newRows, tt.key)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := diffText(diff); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got rows %q, want %q", got, tt.want)
+			}
+			if got := diff.Summary(); got != tt.summary {
+				t.Errorf("got summary %q, want %q", got, tt.summary)
+			}
+		})
+	}
+}
+
+func TestDiffResultsColumns(t *testing.T) {
+	diff, err := DiffResults(diffColumns("name", "ID", "gone"), nil, diffColumns("id", "extra", "Name"), nil, []string{"id"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var names []string
+	for _, column := range diff.Columns {
+		names = append(names, column.Name)
+	}
+	if want := []string{"id", "Name"}; !reflect.DeepEqual(names, want) {
+		t.Errorf("got compared columns %v, want %v", names, want)
+	}
+	if want := []int{0}; !reflect.DeepEqual(diff.Key, want) {
+		t.Errorf("got key %v, want %v", diff.Key, want)
+	}
+	if want := []string{"gone"}; !reflect.DeepEqual(diff.OnlyOld, want) {
+		t.Errorf("got old-only columns %v, want %v", diff.OnlyOld, want)
+	}
+	if want := []string{"extra"}; !reflect.DeepEqual(diff.OnlyNew, want) {
+		t.Errorf("got new-only columns %v, want %v", diff.OnlyNew, want)
+	}
+}
+
+func TestDiffResultsBinary(t *testing.T) {
+	columns := diffColumns("id", "data")
+	oldRows := [][]Value{
+		{{Text: "1"}, {Kind: ValueBinary, Bytes: []byte{1, 2}}},
+		{{Text: "2"}, {Kind: ValueBinary, Bytes: []byte{1, 2}}},
+	}
+	newRows := [][]Value{
+		{{Text: "1"}, {Kind: ValueBinary, Bytes: []byte{1, 2}}},
+		{{Text: "2"}, {Kind: ValueBinary, Bytes: []byte{1, 3}}},
+	}
+	diff, err := DiffResults(columns, oldRows, columns, newRows, []string{"id"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"~ 2 0x0102>0x0103"}; !reflect.DeepEqual(diffText(diff), want) {
+		t.Errorf("got rows %q, want %q", diffText(diff), want)
+	}
+}
+
+func TestDiffResultsErrors(t *testing.T) {
+	columns := diffColumns("id", "name")
+	tests := []struct {
+		name    string
+		oldRows [][]Value
+		newRows [][]Value
+		key     []string
+		want    string
+	}{
+		{"no key", nil, nil, nil, "choose the key columns"},
+		{"unknown key", nil, nil, []string{"missing"}, "key column missing is not in both results"},
+		{"duplicate old key", [][]Value{diffRow("1", "a"), diffRow("1", "b")}, nil, []string{"id"}, "key (1) appears more than once in the old result"},
+		{"duplicate new key", nil, [][]Value{diffRow("1", "a"), diffRow("1", "b")}, []string{"id"}, "key (1) appears more than once in the new result"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := DiffResults(columns, tt.oldRows, columns, tt.newRows, tt.key)
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("got %v, want an error containing %q", err, tt.want)
+			}
+		})
+	}
+}
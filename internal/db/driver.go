@@ -1,19 +1,55 @@
 package db
 
-// Driver defines the interface for database drivers
+import "context"
+
+// Driver defines the interface for database drivers. Methods taking a
+// context stop the running statement when the context is cancelled.
 type Driver interface {
-	Connect(dsn string) error
+	Connect(ctx context.Context, dsn string) error
 	Close() error
-	GetDatabases() ([]string, error)
-	GetTables(database string) ([]string, error)
-	ExecuteQuery(query string) (*QueryResult, error)
+	GetDatabases(ctx context.Context) ([]string, error)
+	GetTables(ctx context.Context, database string) ([]string, error)
+	// DescribeTable returns the structure of a table, schema is only used
+	// by PostgreSQL and defaults to public
+	DescribeTable(ctx context.Context, database, schema, table string) (*TableInfo, error)
+	ExecuteQuery(ctx context.Context, query string) (*QueryResult, error)
+	// OpenQuery is like ExecuteQuery but leaves the rows of a result set
+	// unread in QueryResult.Cursor, which the caller must close. The cursor
+	// reads with ctx, so ctx must stay alive until the cursor is closed.
+	// args are bound to the placeholders of the dialect, see BindParameters.
+	OpenQuery(ctx context.Context, query string, args ...any) (*QueryResult, error)
+	// ExecTransaction runs statements in one transaction. check is called
+	// with the rows affected by each statement, an error returned by it or
+	// by a statement rolls the transaction back.
+	ExecTransaction(ctx context.Context, statements []BoundStatement, check func(statement int, affected int64) error) error
+	// ImportRows loads rows into a table in one transaction with the bulk
+	// load of the database, nothing is loaded if a row fails
+	ImportRows(ctx context.Context, target ImportTarget, rows [][]Value) error
+	// DumpSchema reads the objects of a database from the catalogs for a dump
+	DumpSchema(ctx context.Context, database string) (*DumpSchema, error)
+	// RunScript runs statements one after another on one connection using
+	// database and stops at the first error, a *ScriptError. progress is
+	// called with the number of statements run.
+	RunScript(ctx context.Context, database string, statements []Statement, progress func(done int)) error
 	GetDriverName() string
+	// GetDialect returns the SQL dialect used to split and classify statements
+	GetDialect() Dialect
+}
+
+// BoundStatement is a statement with the arguments of its bind placeholders
+type BoundStatement struct {
+	Query string
+	Args  []any
 }
 
 // QueryResult represents the result of a SQL query
 type QueryResult struct {
-	Columns      []string
-	Rows         [][]string
+	Kind         StatementKind
+	Columns      []Column
+	Rows         [][]Value
+	Cursor       *Cursor // set by OpenQuery for result sets
 	RowsAffected int64
+	LastInsertID int64    // set by drivers that report generated IDs
+	Notices      []string // server notices and warnings raised by the statement
 	Error        error
 }
@@ -0,0 +1,513 @@
+package db
+
+import (
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math"
+	"net"
+	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+)
+
+// TLS modes of a connection, named after the PostgreSQL sslmode values
+const (
+	TLSDisable    = "disable"
+	TLSPrefer     = "prefer"      // TLS if the server offers it, without checks
+	TLSRequire    = "require"     // TLS without checking the server certificate
+	TLSVerifyCA   = "verify-ca"   // the server certificate is signed by the CA
+	TLSVerifyFull = "verify-full" // and is issued for the host name
+)
+
+// TLSModes lists the TLS modes from the weakest to the strictest
+var TLSModes = []string{TLSDisable, TLSPrefer, TLSRequire, TLSVerifyCA, TLSVerifyFull}
+
+// ConnConfig describes a connection to a database, from which the DSN of
+// a driver is built
+type ConnConfig struct {
+	Host     string
+	Port     string
+	Username string
+	Password string
+	Database string // the file path for SQLite
+	TLSMode  string // one of TLSModes, empty means TLSDisable
+	// TLSCAFile verifies the server certificate, empty uses the system CAs
+	TLSCAFile string
+	// TLSCertFile and TLSKeyFile are the client certificate, if the server asks for one
+	TLSCertFile string
+	TLSKeyFile  string
+	// TLSServerName is the name the server certificate is checked against
+	// when Host is not the database host, as with an SSH tunnel
+	TLSServerName  string
+	ConnectTimeout time.Duration // 0 means the driver default
+	Params         map[string]string
+}
+
+// serverName returns the host name the server certificate is issued for
+func (c ConnConfig) serverName() string {
+	if c.TLSServerName != "" {
+		return c.TLSServerName
+	}
+	return c.Host
+}
+
+// address returns host:port, or the host alone without a port
+func (c ConnConfig) address() string {
+	if c.Port == "" {
+		return c.Host
+	}
+	return net.JoinHostPort(c.Host, c.Port)
+}
+
+// checkParams fails if a parameter has a field of its own
+func (c ConnConfig) checkParams(reserved ...string) error {
+	for _, key := range reserved {
+		if _, ok := c.Params[key]; ok {
+			return fmt.Errorf("parameter %s is set by the connection fields, remove it from the parameters", key)
+		}
+	}
+	return nil
+}
+
+// postgresFields are the PostgreSQL connection parameters kept in ConnConfig fields
+var postgresFields = []string{"host", "port", "user", "password", "dbname", "sslmode", "sslrootcert", "sslcert", "sslkey", "connect_timeout"}
+
+// PostgresDSN returns a postgres:// URL for lib/pq
+func PostgresDSN(c ConnConfig) (string, error) {
+	if err := c.checkParams(postgresFields...); err != nil {
+		return "", err
+	}
+
+	mode := c.TLSMode
+	switch mode {
+	case "":
+		mode = TLSDisable
+	case TLSPrefer:
+		return "", fmt.Errorf("the PostgreSQL driver cannot fall back to an unencrypted connection, choose TLS mode %s or %s", TLSDisable, TLSRequire)
+	case TLSVerifyFull:
+		if c.serverName() != c.Host {
+			return "", fmt.Errorf("TLS mode %s cannot check the host name of %s through a tunnel, choose %s", TLSVerifyFull, c.serverName(), TLSVerifyCA)
+		}
+	}
+
+	query := url.Values{}
+	for key, value := range c.Params {
+		query.Set(key, value)
+	}
+	query.Set("sslmode", mode)
+	if mode != TLSDisable {
+		setFile(query, "sslrootcert", c.TLSCAFile)
+		setFile(query, "sslcert", c.TLSCertFile)
+		setFile(query, "sslkey", c.TLSKeyFile)
+	}
+	if c.ConnectTimeout > 0 {
+		query.Set("connect_timeout", strconv.Itoa(int(math.Ceil(c.ConnectTimeout.Seconds()))))
+	}
+
+	dsn := url.URL{
+		Scheme:   "postgres",
+		Host:     c.address(),
+		Path:     "/" + c.Database,
+		RawQuery: query.Encode(),
+	}
+	if c.Password != "" {
+		dsn.User = url.UserPassword(c.Username, c.Password)
+	} else if c.Username != "" {
+		dsn.User = url.User(c.Username)
+	}
+	return dsn.String(), nil
+}
+
+// setFile sets a file path parameter unless path is empty
+func setFile(query url.Values, key, path string) {
+	if path != "" {
+		query.Set(key, expandHome(path))
+	}
+}
+
+// MySQLDSN returns a go-sql-driver DSN. TLS with a CA or client certificate
+// registers a TLS config with the driver, which the DSN names.
+func MySQLDSN(c ConnConfig) (string, error) {
+	if err := c.checkParams("tls", "timeout"); err != nil {
+		return "", err
+	}
+
+	config := mysql.NewConfig()
+	config.User = c.Username
+	config.Passwd = c.Password
+	config.Net = "tcp"
+	config.Addr = c.address()
+	config.DBName = c.Database
+	config.Timeout = c.ConnectTimeout
+	if len(c.Params) > 0 {
+		config.Params = make(map[string]string, len(c.Params))
+		for key, value := range c.Params {
+			config.Params[key] = value
+		}
+	}
+
+	var err error
+	if config.TLSConfig, err = mysqlTLS(c); err != nil {
+		return "", err
+	}
+	return config.FormatDSN(), nil
+}
+
+// mysqlTLS returns the tls parameter of a MySQL DSN
+func mysqlTLS(c ConnConfig) (string, error) {
+	files := c.TLSCAFile != "" || c.TLSCertFile != "" || c.TLSKeyFile != ""
+	switch c.TLSMode {
+	case "", TLSDisable:
+		return "", nil
+	case TLSPrefer:
+		if files {
+			return "", fmt.Errorf("TLS mode %s takes no CA or certificate files, choose %s or stricter", TLSPrefer, TLSRequire)
+		}
+		return "preferred", nil
+	case TLSRequire:
+		if !files {
+			return "skip-verify", nil
+		}
+	case TLSVerifyCA:
+	case TLSVerifyFull:
+		if !files && c.serverName() == c.Host {
+			return "true", nil
+		}
+	default:
+		return "", fmt.Errorf("unknown TLS mode: %s", c.TLSMode)
+	}
+
+	config, err := tlsConfig(c)
+	if err != nil {
+		return "", err
+	}
+
+	// The same settings reuse one name, the files are read again though
+	sum := sha256.Sum256([]byte(strings.Join([]string{c.TLSMode, c.serverName(), c.TLSCAFile, c.TLSCertFile, c.TLSKeyFile}, "\x00")))
+	name := "gocmder-" + hex.EncodeToString(sum[:8])
+	if err := mysql.RegisterTLSConfig(name, config); err != nil {
+		return "", err
+	}
+	return name, nil
+}
+
+// tlsConfig returns the TLS config of a connection that checks the server
+// certificate as its TLS mode asks
+func tlsConfig(c ConnConfig) (*tls.Config, error) {
+	config := &tls.Config{ServerName: c.serverName()}
+
+	if c.TLSCAFile != "" {
+		data, err := os.ReadFile(expandHome(c.TLSCAFile))
+		if err != nil {
+			return nil, fmt.Errorf("failed to read CA file: %w", err)
+		}
+		config.RootCAs = x509.NewCertPool()
+		if !config.RootCAs.AppendCertsFromPEM(data) {
+			return nil, fmt.Errorf("no PEM certificate found in CA file %s", c.TLSCAFile)
+		}
+	}
+
+	if c.TLSCertFile != "" || c.TLSKeyFile != "" {
+		if c.TLSCertFile == "" || c.TLSKeyFile == "" {
+			return nil, fmt.Errorf("a client certificate needs both the certificate and the key file")
+		}
+		cert, err := tls.LoadX509KeyPair(expandHome(c.TLSCertFile), expandHome(c.TLSKeyFile))
+		if err != nil {
+			return nil, fmt.Errorf("failed to load client certificate: %w", err)
+		}
+		config.Certificates = []tls.Certificate{cert}
+	}
+
+	switch c.TLSMode {
+	case TLSRequire:
+		config.InsecureSkipVerify = true
+	case TLSVerifyCA:
+		// The chain is verified without the host name
+		roots := config.RootCAs
+		config.InsecureSkipVerify = true
+		config.VerifyPeerCertificate = func(raw [][]byte, _ [][]*x509.Certificate) error {
+			return verifyChain(raw, roots)
+		}
+	}
+	return config, nil
+}
+
+// verifyChain checks that a certificate chain is signed by one of roots,
+// nil meaning the system CAs
+func verifyChain(raw [][]byte, roots *x509.CertPool) error {
+	if len(raw) == 0 {
+		return errors.New("the server sent no certificate")
+	}
+	certs := make([]*x509.Certificate, len(raw))
+	for i, data := range raw {
+		cert, err := x509.ParseCertificate(data)
+		if err != nil {
+			return err
+		}
+		certs[i] = cert
+	}
+
+	intermediates := x509.NewCertPool()
+	for _, cert := range certs[1:] {
+		intermediates.AddCert(cert)
+	}
+	_, err := certs[0].Verify(x509.VerifyOptions{Roots: roots, Intermediates: intermediates})
+	return err
+}
+
+// SQLiteDSN returns the database file path, followed by the parameters
+func SQLiteDSN(c ConnConfig) (string, error) {
+	if len(c.Params) == 0 {
+		return c.Database, nil
+	}
+	query := url.Values{}
+	for key, value := range c.Params {
+		query.Set(key, value)
+	}
+	return c.Database + "?" + query.Encode(), nil
+}
+
+// expandHome replaces a leading ~ of a file path with the home directory
+func expandHome(path string) string {
+	if path != "~" && !strings.HasPrefix(path, "~/") {
+		return path
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return path
+	}
+	return filepath.Join(home, path[1:])
+}
+
+// ParseDSN parses a connection URL or DSN and returns the driver name it is
+// for. It reads postgres:// and mysql:// URLs, PostgreSQL key=value
+// strings, go-sql-driver DSNs and sqlite: or file: paths.
+func ParseDSN(text string) (string, ConnConfig, error) {
+	text = strings.TrimSpace(text)
+	if scheme, rest, ok := strings.Cut(text, "://"); ok {
+		switch strings.ToLower(scheme) {
+		case "postgres", "postgresql":
+			c, err := parsePostgresURL(text)
+			return "PostgreSQL", c, err
+		case "mysql":
+			c, err := parseMySQLURL(text)
+			return "MySQL", c, err
+		case "sqlite", "sqlite3":
+			return "SQLite", ConnConfig{Database: rest}, nil
+		}
+		return "", ConnConfig{}, fmt.Errorf("unknown URL scheme: %s", scheme)
+	}
+
+	if strings.Contains(text, "@tcp(") || strings.HasPrefix(text, "tcp(") {
+		c, err := parseMySQLDSN(text)
+		return "MySQL", c, err
+	}
+	if scheme, path, ok := strings.Cut(text, ":"); ok {
+		switch strings.ToLower(scheme) {
+		case "sqlite", "sqlite3":
+			return "SQLite", ConnConfig{Database: path}, nil
+		case "file":
+			return "SQLite", ConnConfig{Database: text}, nil
+		}
+	}
+	if strings.Contains(text, "=") {
+		c, err := parsePostgresKeywords(text)
+		return "PostgreSQL", c, err
+	}
+	return "", ConnConfig{}, fmt.Errorf("not a postgres://, mysql:// or sqlite: URL, a key=value list or a MySQL DSN")
+}
+
+// parsePostgresURL parses a postgres:// URL
+func parsePostgresURL(text string) (ConnConfig, error) {
+	u, err := url.Parse(text)
+	if err != nil {
+		return ConnConfig{}, err
+	}
+
+	c := ConnConfig{Host: u.Hostname(), Port: u.Port(), Database: strings.TrimPrefix(u.Path, "/")}
+	if u.User != nil {
+		c.Username = u.User.Username()
+		c.Password, _ = u.User.Password()
+	}
+	query := map[string]string{}
+	for key, values := range u.Query() {
+		query[key] = values[len(values)-1]
+	}
+	return c, c.setPostgresParams(query)
+}
+
+// parsePostgresKeywords parses a PostgreSQL key=value connection string,
+// where values with spaces are single-quoted and \ escapes a character
+func parsePostgresKeywords(text string) (ConnConfig, error) {
+	params := map[string]string{}
+	for text = strings.TrimSpace(text); text != ""; text = strings.TrimSpace(text) {
+		key, rest, ok := strings.Cut(text, "=")
+		key = strings.TrimSpace(key)
+		if !ok || key == "" || strings.ContainsAny(key, " \t") {
+			return ConnConfig{}, fmt.Errorf("expected key=value at %q", text)
+		}
+		rest = strings.TrimLeft(rest, " \t")
+
+		var value strings.Builder
+		quoted := strings.HasPrefix(rest, "'")
+		if quoted {
+			rest = rest[1:]
+		}
+		i := 0
+		for ; i < len(rest); i++ {
+			ch := rest[i]
+			if ch == '\\' && i+1 < len(rest) {
+				i++
+				value.WriteByte(rest[i])
+				continue
+			}
+			if quoted && ch == '\'' || !quoted && (ch == ' ' || ch == '\t') {
+				break
+			}
+			value.WriteByte(ch)
+		}
+		if quoted {
+			if i == len(rest) {
+				return ConnConfig{}, fmt.Errorf("unterminated quoted value of %s", key)
+			}
+			i++
+		}
+		params[key] = value.String()
+		text = rest[i:]
+	}
+
+	var c ConnConfig
+	return c, c.setPostgresParams(params)
+}
+
+// setPostgresParams moves PostgreSQL parameters into the fields of the
+// connection, the others are kept as parameters
+func (c *ConnConfig) setPostgresParams(params map[string]string) error {
+	for key, value := range params {
+		switch key {
+		case "host":
+			c.Host = value
+		case "port":
+			c.Port = value
+		case "user":
+			c.Username = value
+		case "password":
+			c.Password = value
+		case "dbname":
+			c.Database = value
+		case "sslmode":
+			c.TLSMode = value
+			if value == "allow" {
+				c.TLSMode = TLSPrefer
+			} else if !slices.Contains(TLSModes, value) {
+				return fmt.Errorf("unknown sslmode: %s", value)
+			}
+		case "sslrootcert":
+			c.TLSCAFile = value
+		case "sslcert":
+			c.TLSCertFile = value
+		case "sslkey":
+			c.TLSKeyFile = value
+		case "connect_timeout":
+			seconds, err := strconv.Atoi(value)
+			if err != nil {
+				return fmt.Errorf("invalid connect_timeout: %s", value)
+			}
+			c.ConnectTimeout = time.Duration(seconds) * time.Second
+		default:
+			if c.Params == nil {
+				c.Params = map[string]string{}
+			}
+			c.Params[key] = value
+		}
+	}
+	return nil
+}
+
+// parseMySQLURL parses a mysql:// URL as used by command line clients
+func parseMySQLURL(text string) (ConnConfig, error) {
+	u, err := url.Parse(text)
+	if err != nil {
+		return ConnConfig{}, err
+	}
+
+	c := ConnConfig{Host: u.Hostname(), Port: u.Port(), Database: strings.TrimPrefix(u.Path, "/")}
+	if u.User != nil {
+		c.Username = u.User.Username()
+		c.Password, _ = u.User.Password()
+	}
+	return c, c.setMySQLParams(u.Query())
+}
+
+// parseMySQLDSN parses a go-sql-driver DSN, user:password@tcp(host:port)/database?params
+func parseMySQLDSN(text string) (ConnConfig, error) {
+	// The parameters are read here, the driver would keep only those it knows
+	base, query := text, ""
+	if slash := strings.LastIndex(text, "/"); slash >= 0 {
+		if mark := strings.Index(text[slash:], "?"); mark >= 0 {
+			base, query = text[:slash+mark], text[slash+mark+1:]
+		}
+	}
+	config, err := mysql.ParseDSN(base)
+	if err != nil {
+		return ConnConfig{}, err
+	}
+	if config.Net != "tcp" {
+		return ConnConfig{}, fmt.Errorf("only tcp connections are supported, not %s", config.Net)
+	}
+
+	c := ConnConfig{Username: config.User, Password: config.Passwd, Database: config.DBName}
+	if c.Host, c.Port, err = net.SplitHostPort(config.Addr); err != nil {
+		c.Host, c.Port = config.Addr, ""
+	}
+	params, err := url.ParseQuery(query)
+	if err != nil {
+		return ConnConfig{}, err
+	}
+	return c, c.setMySQLParams(params)
+}
+
+// setMySQLParams moves the tls and timeout parameters into the fields of
+// the connection, the others are kept as parameters
+func (c *ConnConfig) setMySQLParams(params url.Values) error {
+	for key, values := range params {
+		value := values[len(values)-1]
+		switch key {
+		case "tls":
+			switch strings.ToLower(value) {
+			case "false", "0":
+				c.TLSMode = TLSDisable
+			case "preferred":
+				c.TLSMode = TLSPrefer
+			case "skip-verify":
+				c.TLSMode = TLSRequire
+			case "true", "1":
+				c.TLSMode = TLSVerifyFull
+			default:
+				return fmt.Errorf("unknown tls setting %s, choose a TLS mode instead", value)
+			}
+		case "timeout":
+			timeout, err := time.ParseDuration(value)
+			if err != nil {
+				return fmt.Errorf("invalid timeout: %s", value)
+			}
+			c.ConnectTimeout = timeout
+		default:
+			if c.Params == nil {
+				c.Params = map[string]string{}
+			}
+			c.Params[key] = value
+		}
+	}
+	return nil
+}
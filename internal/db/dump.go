@@ -0,0 +1,241 @@
+package db
+
+import (
+	"bufio"
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+const (
+	// dumpRowsPerInsert is the number of rows of one INSERT statement of a dump
+	dumpRowsPerInsert = 100
+	// dumpInsertSize ends an INSERT statement of a dump once it is this long
+	dumpInsertSize = 1 << 20
+	// dumpPageSize is the number of rows read at once
+	dumpPageSize = 1000
+)
+
+// DumpSchema lists the statements recreating the objects of a database and
+// the tables whose rows are dumped, in the order they are run
+type DumpSchema struct {
+	Before []string    // settings, schemas, types, sequences and functions
+	Tables []DumpTable // tables created and loaded one after another
+	After  []string    // sequence values, foreign keys, indexes, triggers and views
+}
+
+// DumpTable is a table of a dump
+type DumpTable struct {
+	Name   string // table name shown while dumping
+	Create string // CREATE TABLE statement without foreign keys
+	Select string // query reading the rows
+	Insert string // start of the INSERT statements up to VALUES
+}
+
+// DumpProgress counts the tables and rows of a dump
+type DumpProgress struct {
+	Table  string // table being dumped
+	Tables int    // tables dumped
+	Total  int    // tables of the dump
+	Rows   int64  // rows written
+}
+
+// Dump writes database as an SQL script of driver's dialect: the statements
+// of its schema with the rows of every table as multi-row INSERT
+// statements. Foreign keys are added after the rows are loaded. progress is
+// called before each table and after each page of rows.
+func Dump(ctx context.Context, driver Driver, database string, w io.Writer, progress func(progress DumpProgress)) (DumpProgress, error) {
+	var state DumpProgress
+	schema, err := driver.DumpSchema(ctx, database)
+	if err != nil {
+		return state, err
+	}
+	state.Total = len(schema.Tables)
+	if progress == nil {
+		progress = func(DumpProgress) {}
+	}
+
+	dialect := driver.GetDialect()
+	out := bufio.NewWriter(w)
+	fmt.Fprintf(out, "-- Dump of %s database %s\n", driver.GetDriverName(), database)
+	fmt.Fprintf(out, "-- Created %s, %d tables\n", time.Now().Format("2006-01-02 15:04:05"), len(schema.Tables))
+
+	writeStatements(out, dialect, "", schema.Before)
+	for _, table := range schema.Tables {
+		state.Table = table.Name
+		progress(state)
+
+		writeStatements(out, dialect, "Table "+table.Name, []string{table.Create})
+		rows, err := dumpRows(ctx, driver, table, out, func(rows int64) {
+			state.Rows += rows
+			progress(state)
+		})
+		if err != nil {
+			return state, fmt.Errorf("table %s: %w", table.Name, err)
+		}
+		if rows > 0 {
+			fmt.Fprintf(out, "-- %d rows\n", rows)
+		}
+		state.Tables++
+	}
+	writeStatements(out, dialect, "Sequences, constraints, indexes, triggers and views", schema.After)
+
+	state.Table = ""
+	return state, out.Flush()
+}
+
+// writeStatements writes statements after a comment. MySQL statements with
+// semicolons, e.g. trigger bodies, are written with another delimiter.
+func writeStatements(out *bufio.Writer, dialect Dialect, comment string, statements []string) {
+	if len(statements) == 0 {
+		return
+	}
+	out.WriteString("\n")
+	if comment != "" {
+		fmt.Fprintf(out, "-- %s\n\n", comment)
+	}
+	for _, statement := range statements {
+		statement = strings.TrimRight(strings.TrimSpace(statement), ";")
+		if dialect == DialectMySQL && strings.Contains(statement, ";") {
+			fmt.Fprintf(out, "DELIMITER ;;\n%s;;\nDELIMITER ;\n", statement)
+			continue
+		}
+		fmt.Fprintf(out, "%s;\n", statement)
+	}
+}
+
+// dumpRows writes the rows of a table as INSERT statements and returns the
+// number of rows. written is called after each page of rows.
+func dumpRows(ctx context.Context, driver Driver, table DumpTable, out *bufio.Writer, written func(rows int64)) (int64, error) {
+	result, err := driver.OpenQuery(ctx, table.Select)
+	if err != nil {
+		return 0, err
+	}
+	if result.Cursor == nil {
+		return 0, fmt.Errorf("the table query returns no rows")
+	}
+	defer result.Cursor.Close()
+
+	dialect := driver.GetDialect()
+	var total int64
+	var statement strings.Builder
+	tuples := 0
+	flush := func() {
+		if tuples > 0 {
+			out.WriteString(statement.String())
+			out.WriteString(";\n")
+		}
+		statement.Reset()
+		tuples = 0
+	}
+
+	for {
+		page, err := result.Cursor.Fetch(dumpPageSize)
+		if err != nil {
+			return total, err
+		}
+		if len(page) == 0 {
+			break
+		}
+
+		for _, row := range page {
+			if tuples == 0 {
+				statement.WriteString(table.Insert)
+				statement.WriteString("\n(")
+			} else {
+				statement.WriteString(",\n(")
+			}
+			for i, value := range row {
+				if i > 0 {
+					statement.WriteString(", ")
+				}
+				statement.WriteString(Literal(dialect, value))
+			}
+			statement.WriteString(")")
+
+			if tuples++; tuples >= dumpRowsPerInsert || statement.Len() >= dumpInsertSize {
+				flush()
+			}
+		}
+		total += int64(len(page))
+		written(int64(len(page)))
+		// Keep the memory of large tables bounded
+		if out.Buffered() >= out.Size()/2 {
+			if err := out.Flush(); err != nil {
+				return total, err
+			}
+		}
+	}
+	flush()
+
+	return total, nil
+}
+
+// ScriptError is the error of a statement of a script, the statements
+// before it have been run
+type ScriptError struct {
+	Statement Statement
+	Index     int // position of the statement in the script, starting at 0
+	Err       error
+}
+
+// Error returns the error with the line of the statement
+func (e *ScriptError) Error() string {
+	line, _ := e.Code()
+	return fmt.Sprintf("statement at line %d: %v", line, e.Err)
+}
+
+// Code returns the line and text of the first line of the statement that
+// is not a comment, comments before a statement belong to it
+func (e *ScriptError) Code() (int, string) {
+	for i, text := range strings.Split(e.Statement.Text, "\n") {
+		text = strings.TrimSpace(text)
+		if text != "" && !strings.HasPrefix(text, "--") && !strings.HasPrefix(text, "#") {
+			return e.Statement.Line + i, text
+		}
+	}
+	return e.Statement.Line, ""
+}
+
+// Unwrap returns the error of the statement
+func (e *ScriptError) Unwrap() error {
+	return e.Err
+}
+
+// runScript runs statements one after another on one connection of conn,
+// after the setup statements, and stops at the first error. The connection
+// is discarded afterwards so settings changed by the script do not reach
+// other statements. progress is called after each statement.
+func runScript(ctx context.Context, conn *sql.DB, setup []string, statements []Statement, progress func(done int)) error {
+	if conn == nil {
+		return fmt.Errorf("not connected")
+	}
+
+	session, err := conn.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer session.Close()
+	defer session.Raw(func(any) error {
+		return driver.ErrBadConn
+	})
+
+	for _, statement := range setup {
+		if _, err := session.ExecContext(ctx, statement); err != nil {
+			return err
+		}
+	}
+	for i, statement := range statements {
+		if _, err := session.ExecContext(ctx, statement.Text); err != nil {
+			return &ScriptError{Statement: statement, Index: i, Err: err}
+		}
+		if progress != nil {
+			progress(i + 1)
+		}
+	}
+	return nil
+}
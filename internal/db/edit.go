@@ -0,0 +1,429 @@
+package db
+
+import (
+	"encoding/hex"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// ChangeKind tells how a row of a result is changed
+type ChangeKind int
+
+const (
+	ChangeUpdate ChangeKind = iota
+	ChangeInsert
+	ChangeDelete
+)
+
+// RowChange is a pending change of one result row
+type RowChange struct {
+	Kind   ChangeKind
+	Row    []Value       // original values, nil for inserted rows
+	Values map[int]Value // new values by result column, unset columns of inserted rows get their default
+}
+
+// SelectSource is the table read by a SELECT from a single table
+type SelectSource struct {
+	Qualifier string // schema for PostgreSQL, database for MySQL and SQLite
+	Table     string
+	// Plain lists whether each select list item is a plain column
+	// reference, nil if the list contains * and only plain columns
+	Plain []bool
+}
+
+// EditTarget is the table a result was selected from, with the result
+// columns mapped to the table columns needed to change its rows
+type EditTarget struct {
+	Dialect   Dialect
+	Qualifier string
+	Table     string
+	Columns   []string // table column of each result column, empty for computed columns
+	Key       []int    // result columns holding the primary key
+}
+
+// sqlToken is a token of a statement outside comments
+type sqlToken struct {
+	text    string // identifier, keyword or symbol; unquoted for quoted identifiers
+	quoted  bool   // quoted identifier
+	literal bool   // string or number literal
+	depth   int    // parenthesis depth, parentheses have the depth outside them
+}
+
+// is returns true if the token is the unquoted keyword
+func (t sqlToken) is(keyword string) bool {
+	return !t.quoted && !t.literal && strings.EqualFold(t.text, keyword)
+}
+
+// isIdentifier returns true for quoted and unquoted identifiers
+func (t sqlToken) isIdentifier() bool {
+	if t.quoted {
+		return true
+	}
+	if t.literal || t.text == "" {
+		return false
+	}
+	r := []rune(t.text)[0]
+	return unicode.IsLetter(r) || r == '_'
+}
+
+// selectClauses are the keywords that may follow the table of an editable SELECT
+var selectClauses = map[string]bool{"WHERE": true, "ORDER": true, "LIMIT": true, "OFFSET": true, "FETCH": true, "FOR": true}
+
+// selectModifiers are top level keywords making the rows of a SELECT
+// differ from the rows of its table
+var selectModifiers = []string{"DISTINCT", "UNION", "INTERSECT", "EXCEPT", "GROUP", "HAVING", "JOIN", "WINDOW", "INTO"}
+
+// ParseSelectSource returns the table of a SELECT reading the rows of a
+// single table. The error tells why the rows cannot be mapped to a table.
+func ParseSelectSource(dialect Dialect, query string) (*SelectSource, error) {
+	tokens := scanTokens(dialect, query)
+	if len(tokens) == 0 || !tokens[0].is("SELECT") {
+		return nil, fmt.Errorf("not a SELECT statement")
+	}
+
+	from := -1
+	for i, token := range tokens {
+		if token.depth != 0 {
+			continue
+		}
+		for _, keyword := range selectModifiers {
+			if token.is(keyword) {
+				return nil, fmt.Errorf("SELECT uses %s", strings.ToUpper(token.text))
+			}
+		}
+		if from < 0 && token.is("FROM") {
+			from = i
+		}
+	}
+	if from < 0 {
+		return nil, fmt.Errorf("SELECT has no FROM clause")
+	}
+
+	source := &SelectSource{}
+	plain, star := selectList(tokens[1:from])
+	if !star {
+		source.Plain = plain
+	} else {
+		for _, ok := range plain {
+			if !ok {
+				return nil, fmt.Errorf("select list mixes * with expressions")
+			}
+		}
+	}
+
+	// The table name has up to three parts, the last two are used
+	i := from + 1
+	var parts []sqlToken
+	for i < len(tokens) && tokens[i].isIdentifier() && !selectClauses[strings.ToUpper(tokens[i].text)] {
+		parts = append(parts, tokens[i])
+		i++
+		if i < len(tokens) && tokens[i].text == "." && !tokens[i].literal {
+			i++
+			continue
+		}
+		break
+	}
+	if len(parts) == 0 || len(parts) > 3 {
+		return nil, fmt.Errorf("FROM does not name a table")
+	}
+	source.Table = identifierName(dialect, parts[len(parts)-1])
+	if len(parts) > 1 {
+		source.Qualifier = identifierName(dialect, parts[len(parts)-2])
+	}
+
+	// An optional alias, then nothing but row filters and ordering
+	if i < len(tokens) && tokens[i].is("AS") {
+		i++
+	}
+	if i < len(tokens) && tokens[i].isIdentifier() && !selectClauses[strings.ToUpper(tokens[i].text)] {
+		i++
+	}
+	if i < len(tokens) && tokens[i].text != ";" && !selectClauses[strings.ToUpper(tokens[i].text)] {
+		return nil, fmt.Errorf("SELECT reads more than one table")
+	}
+
+	return source, nil
+}
+
+// selectList returns whether each select list item is a plain column
+// reference and whether the list contains *
+func selectList(tokens []sqlToken) (plain []bool, star bool) {
+	var item []sqlToken
+	flush := func() {
+		ok := false
+		switch n := len(item); {
+		case n == 1:
+			ok = item[0].isIdentifier() || item[0].text == "*"
+		case n == 3 && item[1].text == ".":
+			ok = item[0].isIdentifier() && (item[2].isIdentifier() || item[2].text == "*")
+		}
+		if n := len(item); n > 0 && item[n-1].text == "*" {
+			star = true
+		}
+		plain = append(plain, ok)
+		item = nil
+	}
+
+	for _, token := range tokens {
+		if token.depth == 0 && token.text == "," && !token.literal {
+			flush()
+			continue
+		}
+		// ALL is the default of SELECT
+		if len(plain) == 0 && len(item) == 0 && token.is("ALL") {
+			continue
+		}
+		item = append(item, token)
+	}
+	flush()
+
+	return plain, star
+}
+
+// identifierName returns the name of an identifier, PostgreSQL folds unquoted names to lower case
+func identifierName(dialect Dialect, token sqlToken) string {
+	if dialect == DialectPostgres && !token.quoted {
+		return strings.ToLower(token.text)
+	}
+	return token.text
+}
+
+// scanTokens returns the tokens of a statement, skipping comments
+func scanTokens(dialect Dialect, query string) []sqlToken {
+	var tokens []sqlToken
+	depth := 0
+
+	runes := []rune(query)
+	for i := 0; i < len(runes); {
+		r := runes[i]
+		start := i
+
+		if next, kind := skipLexeme(dialect, runes, i); kind != lexemeNone {
+			switch text := string(runes[i:next]); kind {
+			case lexemeString:
+				tokens = append(tokens, sqlToken{text: text, literal: true, depth: depth})
+			case lexemeIdentifier:
+				tokens = append(tokens, sqlToken{text: unquoteIdentifier(text), quoted: true, depth: depth})
+			}
+			i = next
+			continue
+		}
+
+		switch {
+		case unicode.IsSpace(r):
+			i++
+		case unicode.IsDigit(r):
+			for i < len(runes) && (unicode.IsDigit(runes[i]) || runes[i] == '.' || unicode.IsLetter(runes[i])) {
+				i++
+			}
+			tokens = append(tokens, sqlToken{text: string(runes[start:i]), literal: true, depth: depth})
+		case unicode.IsLetter(r) || r == '_':
+			for i < len(runes) && isWordRune(runes[i]) {
+				i++
+			}
+			tokens = append(tokens, sqlToken{text: string(runes[start:i]), depth: depth})
+		case r == '(':
+			tokens = append(tokens, sqlToken{text: "(", depth: depth})
+			depth++
+			i++
+		case r == ')':
+			if depth > 0 {
+				depth--
+			}
+			tokens = append(tokens, sqlToken{text: ")", depth: depth})
+			i++
+		default:
+			tokens = append(tokens, sqlToken{text: string(r), depth: depth})
+			i++
+		}
+	}
+
+	return tokens
+}
+
+// NewEditTarget maps the columns of a result read from source to the
+// columns of the described table. The table needs a primary key and the
+// result has to contain all of its columns.
+func NewEditTarget(dialect Dialect, source *SelectSource, info *TableInfo, columns []Column) (*EditTarget, error) {
+	if len(info.PrimaryKey) == 0 {
+		return nil, fmt.Errorf("table %s has no primary key", info.QualifiedName())
+	}
+
+	target := &EditTarget{
+		Dialect:   dialect,
+		Qualifier: info.Database,
+		Table:     info.Name,
+		Columns:   make([]string, len(columns)),
+	}
+	if dialect == DialectPostgres {
+		target.Qualifier = info.Schema
+	}
+
+	mapped := map[string]bool{}
+	for i, column := range columns {
+		if source.Plain != nil && (i >= len(source.Plain) || !source.Plain[i]) {
+			continue
+		}
+		for _, tableColumn := range info.Columns {
+			// A column selected twice is only changed through the first one
+			if sameIdentifier(dialect, column.Name, tableColumn.Name) && !mapped[tableColumn.Name] {
+				target.Columns[i] = tableColumn.Name
+				mapped[tableColumn.Name] = true
+				break
+			}
+		}
+	}
+
+	for _, key := range info.PrimaryKey {
+		index := -1
+		for i, name := range target.Columns {
+			if name == key {
+				index = i
+				break
+			}
+		}
+		if index < 0 {
+			return nil, fmt.Errorf("primary key column %s is not in the result", key)
+		}
+		target.Key = append(target.Key, index)
+	}
+
+	return target, nil
+}
+
+// sameIdentifier compares a result column name with a table column name
+func sameIdentifier(dialect Dialect, a, b string) bool {
+	if dialect == DialectPostgres {
+		return a == b
+	}
+	return strings.EqualFold(a, b)
+}
+
+// Editable returns true if a result column belongs to the table
+func (t *EditTarget) Editable(column int) bool {
+	return column >= 0 && column < len(t.Columns) && t.Columns[column] != ""
+}
+
+// Statement returns the INSERT, UPDATE or DELETE statement of a change
+// with the values bound to placeholders, so no value is written into the SQL
+func (t *EditTarget) Statement(change RowChange) BoundStatement {
+	var args []any
+	query := t.statement(change, func(value Value) string {
+		args = append(args, editArg(value))
+		if t.Dialect == DialectPostgres {
+			return "$" + strconv.Itoa(len(args))
+		}
+		return "?"
+	})
+	return BoundStatement{Query: query, Args: args}
+}
+
+// Preview returns the statement of a change with the values written as
+// literals, to show what Statement runs
+func (t *EditTarget) Preview(change RowChange) string {
+	return t.statement(change, func(value Value) string {
+		return Literal(t.Dialect, value)
+	})
+}
+
+// statement returns the statement of a change, writing values with write
+func (t *EditTarget) statement(change RowChange, write func(Value) string) string {
+	table := QuoteTable(t.Dialect, t.Qualifier, t.Table)
+
+	columns := make([]int, 0, len(change.Values))
+	for column := range change.Values {
+		if t.Editable(column) {
+			columns = append(columns, column)
+		}
+	}
+	sort.Ints(columns)
+
+	switch change.Kind {
+	case ChangeInsert:
+		if len(columns) == 0 {
+			if t.Dialect == DialectMySQL {
+				return fmt.Sprintf("INSERT INTO %s () VALUES ()", table)
+			}
+			return fmt.Sprintf("INSERT INTO %s DEFAULT VALUES", table)
+		}
+		names := make([]string, len(columns))
+		values := make([]string, len(columns))
+		for i, column := range columns {
+			names[i] = t.quote(t.Columns[column])
+			values[i] = write(change.Values[column])
+		}
+		return fmt.Sprintf("INSERT INTO %s (%s) VALUES (%s)", table, strings.Join(names, ", "), strings.Join(values, ", "))
+	case ChangeDelete:
+		return fmt.Sprintf("DELETE FROM %s WHERE %s", table, t.keyCondition(change.Row, write))
+	}
+
+	assignments := make([]string, len(columns))
+	for i, column := range columns {
+		assignments[i] = fmt.Sprintf("%s = %s", t.quote(t.Columns[column]), write(change.Values[column]))
+	}
+	return fmt.Sprintf("UPDATE %s SET %s WHERE %s", table, strings.Join(assignments, ", "), t.keyCondition(change.Row, write))
+}
+
+// keyCondition returns the WHERE condition selecting a row by its primary key
+func (t *EditTarget) keyCondition(row []Value, write func(Value) string) string {
+	conditions := make([]string, len(t.Key))
+	for i, column := range t.Key {
+		value := row[column]
+		if value.Null {
+			conditions[i] = t.quote(t.Columns[column]) + " IS NULL"
+			continue
+		}
+		conditions[i] = fmt.Sprintf("%s = %s", t.quote(t.Columns[column]), write(value))
+	}
+	return strings.Join(conditions, " AND ")
+}
+
+// quote quotes an identifier
+func (t *EditTarget) quote(name string) string {
+	return quoteIdentifier(t.Dialect, name)
+}
+
+// editArg returns a value as a statement argument. Whole numbers are bound
+// as integers, other text is converted to the column type by the database
+// as a quoted literal would be.
+func editArg(value Value) any {
+	switch {
+	case value.Null:
+		return nil
+	case value.Kind == ValueBinary:
+		return value.Bytes
+	case value.Kind == ValueNumber:
+		if number, err := strconv.ParseInt(value.Text, 10, 64); err == nil {
+			return number
+		}
+	}
+	return value.Text
+}
+
+// numberLiteral matches numbers that can be written without quotes
+var numberLiteral = regexp.MustCompile(`^-?[0-9]+(\.[0-9]+)?([eE][-+]?[0-9]+)?$`)
+
+// Literal returns a value as an SQL literal of dialect
+func Literal(dialect Dialect, value Value) string {
+	switch {
+	case value.Null:
+		return "NULL"
+	case value.Kind == ValueBinary:
+		if dialect == DialectPostgres {
+			return `'\x` + hex.EncodeToString(value.Bytes) + "'::bytea"
+		}
+		return "X'" + hex.EncodeToString(value.Bytes) + "'"
+	case value.Kind == ValueNumber && numberLiteral.MatchString(value.Text):
+		return value.Text
+	}
+
+	text := value.Text
+	if dialect == DialectMySQL {
+		text = strings.ReplaceAll(text, `\`, `\\`)
+	}
+	return "'" + strings.ReplaceAll(text, "'", "''") + "'"
+}
@@ -0,0 +1,85 @@
+package db
+
+import (
+	"bytes"
+	"context"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestEditStatement(t *testing.T) {
+	row := []Value{{Kind: ValueNumber, Text: "7"}, {Text: "old"}}
+	change := RowChange{Kind: ChangeUpdate, Row: row, Values: map[int]Value{1: {Text: `it's \ back`}}}
+
+	tests := []struct {
+		dialect Dialect
+		query   string
+	}{
+		{DialectPostgres, `UPDATE "public"."t" SET "name" = $1 WHERE "id" = $2`},
+		{DialectMySQL, "UPDATE `app`.`t` SET `name` = ? WHERE `id` = ?"},
+		{DialectSQLite, `UPDATE "t" SET "name" = ? WHERE "id" = ?`},
+	}
+	qualifiers := map[Dialect]string{DialectPostgres: "public", DialectMySQL: "app"}
+	for _, tt := range tests {
+		target := &EditTarget{Dialect: tt.dialect, Qualifier: qualifiers[tt.dialect], Table: "t", Columns: []string{"id", "name"}, Key: []int{0}}
+		statement := target.Statement(change)
+		if statement.Query != tt.query {
+			t.Errorf("got %s, want %s", statement.Query, tt.query)
+		}
+		if want := []any{`it's \ back`, int64(7)}; !reflect.DeepEqual(statement.Args, want) {
+			t.Errorf("%s: got args %#v, want %#v", statement.Query, statement.Args, want)
+		}
+	}
+}
+
+func TestEditTransactionSQLite(t *testing.T) {
+	ctx := context.Background()
+	driver := NewSQLite()
+	if err := driver.Connect(ctx, filepath.Join(t.TempDir(), "test.db")); err != nil {
+		t.Fatal(err)
+	}
+	defer driver.Close()
+	if _, err := driver.ExecuteQuery(ctx, "CREATE TABLE t (id INTEGER PRIMARY KEY, name TEXT, data BLOB)"); err != nil {
+		t.Fatal(err)
+	}
+
+	target := &EditTarget{Dialect: DialectSQLite, Table: "t", Columns: []string{"id", "name", "data"}, Key: []int{0}}
+	id := Value{Kind: ValueNumber, Text: "1"}
+	name := Value{Text: `it's \' -- back`}
+	data := Value{Kind: ValueBinary, Bytes: []byte{0, 1, 0xff}}
+	changes := []RowChange{
+		{Kind: ChangeInsert, Values: map[int]Value{0: id, 1: {Text: "a"}, 2: {Null: true}}},
+		{Kind: ChangeInsert, Values: map[int]Value{0: {Kind: ValueNumber, Text: "2"}}},
+		{Kind: ChangeUpdate, Row: []Value{id, {Text: "a"}, {Null: true}}, Values: map[int]Value{1: name, 2: data}},
+		{Kind: ChangeDelete, Row: []Value{{Kind: ValueNumber, Text: "2"}, {Null: true}, {Null: true}}},
+	}
+	statements := make([]BoundStatement, len(changes))
+	for i, change := range changes {
+		statements[i] = target.Statement(change)
+	}
+
+	var affected []int64
+	err := driver.ExecTransaction(ctx, statements, func(i int, n int64) error {
+		affected = append(affected, n)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []int64{1, 1, 1, 1}; !reflect.DeepEqual(affected, want) {
+		t.Errorf("got %v rows affected, want %v", affected, want)
+	}
+
+	result, err := driver.ExecuteQuery(ctx, "SELECT id, name, data FROM t")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(result.Rows) != 1 {
+		t.Fatalf("got %d rows, want 1", len(result.Rows))
+	}
+	got := result.Rows[0]
+	if got[0].Text != "1" || got[1].Text != name.Text || !bytes.Equal(got[2].Bytes, data.Bytes) {
+		t.Errorf("got row %+v, want 1, %q and %x", got, name.Text, data.Bytes)
+	}
+}
@@ -0,0 +1,112 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+)
+
+// sqlRunner is implemented by *sql.DB, *sql.Conn and *sql.Tx
+type sqlRunner interface {
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+}
+
+// openStatement runs a statement once, with Query or Exec depending on its
+// classification. The rows of a result set are left in result.Cursor.
+func openStatement(ctx context.Context, runner sqlRunner, dialect Dialect, query string, args ...any) (*QueryResult, error) {
+	result := &QueryResult{Kind: Classify(dialect, query)}
+
+	if result.Kind == StatementCommand {
+		res, err := runner.ExecContext(ctx, query, args...)
+		if err != nil {
+			result.Error = err
+			return result, err
+		}
+
+		if affected, err := res.RowsAffected(); err == nil {
+			result.RowsAffected = affected
+		}
+		// Not every driver reports insert IDs (lib/pq returns an error)
+		if id, err := res.LastInsertId(); err == nil {
+			result.LastInsertID = id
+		}
+		return result, nil
+	}
+
+	rows, err := runner.QueryContext(ctx, query, args...)
+	if err != nil {
+		result.Error = err
+		return result, err
+	}
+
+	// Get column metadata
+	columnTypes, err := rows.ColumnTypes()
+	if err != nil {
+		rows.Close()
+		result.Error = err
+		return result, err
+	}
+
+	columns := make([]Column, len(columnTypes))
+	for i, columnType := range columnTypes {
+		columns[i] = newColumn(columnType)
+	}
+	result.Columns = columns
+	result.Cursor = newCursor(rows, columns)
+
+	return result, nil
+}
+
+// fetchAll reads all rows of an opened result into result.Rows and closes its cursor
+func fetchAll(result *QueryResult, err error) (*QueryResult, error) {
+	if err != nil || result.Cursor == nil {
+		return result, err
+	}
+
+	cursor := result.Cursor
+	result.Cursor = nil
+
+	result.Rows, err = cursor.Fetch(0)
+	cursor.Close()
+	result.Notices = append(result.Notices, cursor.Notices()...)
+	if err != nil {
+		result.Error = err
+		return result, err
+	}
+
+	result.RowsAffected = int64(len(result.Rows))
+	return result, nil
+}
+
+// execTransaction runs statements in a transaction of conn, see Driver.ExecTransaction
+func execTransaction(ctx context.Context, conn *sql.DB, statements []BoundStatement, check func(statement int, affected int64) error) error {
+	if conn == nil {
+		return fmt.Errorf("not connected")
+	}
+
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+
+	for i, statement := range statements {
+		res, err := tx.ExecContext(ctx, statement.Query, statement.Args...)
+		if err != nil {
+			tx.Rollback()
+			return fmt.Errorf("statement %d: %w", i+1, err)
+		}
+		affected, err := res.RowsAffected()
+		if err != nil {
+			affected = -1
+		}
+		if check != nil {
+			if err := check(i, affected); err != nil {
+				tx.Rollback()
+				return err
+			}
+		}
+	}
+
+	return tx.Commit()
+}
@@ -0,0 +1,281 @@
+package db
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// ExportFormat is a file format result rows are written in
+type ExportFormat int
+
+const (
+	ExportCSV ExportFormat = iota
+	ExportJSONLines
+	ExportMarkdown
+	ExportInsert
+)
+
+// ExportFormats lists the export formats in the order they are offered
+var ExportFormats = []ExportFormat{ExportCSV, ExportJSONLines, ExportMarkdown, ExportInsert}
+
+// String returns the format name
+func (f ExportFormat) String() string {
+	switch f {
+	case ExportJSONLines:
+		return "JSON lines"
+	case ExportMarkdown:
+		return "Markdown"
+	case ExportInsert:
+		return "SQL INSERT"
+	}
+	return "CSV"
+}
+
+// Extension returns the file name extension of the format
+func (f ExportFormat) Extension() string {
+	switch f {
+	case ExportJSONLines:
+		return ".jsonl"
+	case ExportMarkdown:
+		return ".md"
+	case ExportInsert:
+		return ".sql"
+	}
+	return ".csv"
+}
+
+// ExportOptions configures an Exporter
+type ExportOptions struct {
+	Format    ExportFormat
+	Delimiter rune    // CSV field delimiter, a comma if zero
+	QuoteAll  bool    // CSV quotes every field instead of the fields that need it
+	Header    bool    // CSV starts with the column names, Markdown always has a header
+	Table     string  // table of INSERT statements, written as given
+	Dialect   Dialect // quoting of INSERT column names and literals
+}
+
+// Exporter writes result rows in an export format. NULL is written as an
+// unquoted empty CSV field, null in JSON and NULL in SQL. Binary values are
+// written as 0x hex in CSV and Markdown and as base64 strings in JSON.
+type Exporter struct {
+	w       *bufio.Writer
+	columns []Column
+	options ExportOptions
+	started bool
+	rows    int64
+}
+
+// NewExporter returns an exporter writing rows with columns to w
+func NewExporter(w io.Writer, columns []Column, options ExportOptions) (*Exporter, error) {
+	if options.Delimiter == 0 {
+		options.Delimiter = ','
+	}
+	if options.Format == ExportCSV && strings.ContainsRune("\"\r\n", options.Delimiter) {
+		return nil, fmt.Errorf("invalid CSV delimiter %q", options.Delimiter)
+	}
+	options.Table = strings.TrimSpace(options.Table)
+	if options.Format == ExportInsert && options.Table == "" {
+		return nil, fmt.Errorf("INSERT statements need a table name")
+	}
+
+	return &Exporter{
+		w:       bufio.NewWriter(w),
+		columns: columns,
+		options: options,
+	}, nil
+}
+
+// Rows returns the number of rows written
+func (e *Exporter) Rows() int64 {
+	return e.rows
+}
+
+// Write writes rows, the header is written before the first rows
+func (e *Exporter) Write(rows [][]Value) error {
+	e.start()
+	for _, row := range rows {
+		switch e.options.Format {
+		case ExportCSV:
+			e.writeCSV(row)
+		case ExportJSONLines:
+			e.writeJSON(row)
+		case ExportMarkdown:
+			e.writeMarkdown(row)
+		case ExportInsert:
+			e.writeInsert(row)
+		}
+		e.rows++
+	}
+	return e.flushFull()
+}
+
+// Close writes the header of an empty export and flushes the output
+func (e *Exporter) Close() error {
+	e.start()
+	return e.w.Flush()
+}
+
+// start writes the header once
+func (e *Exporter) start() {
+	if e.started {
+		return
+	}
+	e.started = true
+
+	switch e.options.Format {
+	case ExportCSV:
+		if e.options.Header {
+			e.writeCSVFields(ColumnNames(e.columns))
+		}
+	case ExportMarkdown:
+		e.writeMarkdownFields(ColumnNames(e.columns))
+		e.w.WriteString("|")
+		for _, column := range e.columns {
+			if column.IsNumeric() {
+				e.w.WriteString(" ---: |")
+			} else {
+				e.w.WriteString(" --- |")
+			}
+		}
+		e.w.WriteString("\n")
+	}
+}
+
+// flushFull flushes the buffer once it is mostly filled, returning the
+// first write error
+func (e *Exporter) flushFull() error {
+	if e.w.Buffered() < e.w.Size()/2 {
+		return nil
+	}
+	return e.w.Flush()
+}
+
+// writeCSV writes a row as CSV record
+func (e *Exporter) writeCSV(row []Value) {
+	fields := make([]string, len(row))
+	nulls := make([]bool, len(row))
+	for i, value := range row {
+		fields[i], nulls[i] = value.String(), value.Null
+	}
+	e.writeCSVRecord(fields, nulls)
+}
+
+// writeCSVFields writes text fields as CSV record
+func (e *Exporter) writeCSVFields(fields []string) {
+	e.writeCSVRecord(fields, make([]bool, len(fields)))
+}
+
+// writeCSVRecord writes a CSV record, NULL fields stay empty and unquoted
+func (e *Exporter) writeCSVRecord(fields []string, nulls []bool) {
+	delimiter := string(e.options.Delimiter)
+	for i, field := range fields {
+		if i > 0 {
+			e.w.WriteString(delimiter)
+		}
+		switch {
+		case nulls[i]:
+		case e.options.QuoteAll || e.needsQuotes(field):
+			e.w.WriteString(`"` + strings.ReplaceAll(field, `"`, `""`) + `"`)
+		default:
+			e.w.WriteString(field)
+		}
+	}
+	e.w.WriteString("\n")
+}
+
+// needsQuotes returns true if a CSV field cannot be written as it is
+func (e *Exporter) needsQuotes(field string) bool {
+	if field == "" {
+		return false
+	}
+	return strings.ContainsRune(field, e.options.Delimiter) || strings.ContainsAny(field, "\"\r\n") ||
+		field[0] == ' ' || field[0] == '\t'
+}
+
+// writeJSON writes a row as JSON object on one line, keys keep the column order
+func (e *Exporter) writeJSON(row []Value) {
+	e.w.WriteString("{")
+	for i, value := range row {
+		if i > 0 {
+			e.w.WriteString(",")
+		}
+		e.w.WriteString(jsonString(e.columns[i].Name))
+		e.w.WriteString(":")
+		e.w.WriteString(jsonValue(e.columns[i], value))
+	}
+	e.w.WriteString("}\n")
+}
+
+// jsonValue returns a value as JSON. Numbers stay numbers and JSON columns
+// are embedded, other values are strings.
+func jsonValue(column Column, value Value) string {
+	switch {
+	case value.Null:
+		return "null"
+	case value.Kind == ValueBinary:
+		return jsonString(base64.StdEncoding.EncodeToString(value.Bytes))
+	case value.Kind == ValueNumber && numberLiteral.MatchString(value.Text):
+		return value.Text
+	case column.IsJSON() && json.Valid([]byte(value.Text)):
+		var compact bytes.Buffer
+		if json.Compact(&compact, []byte(value.Text)) == nil {
+			return compact.String()
+		}
+	}
+	return jsonString(value.Text)
+}
+
+// jsonString returns text as JSON string without escaping HTML characters
+func jsonString(text string) string {
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+	encoder.SetEscapeHTML(false)
+	encoder.Encode(text)
+	return strings.TrimSuffix(buf.String(), "\n")
+}
+
+// writeMarkdown writes a row as Markdown table row
+func (e *Exporter) writeMarkdown(row []Value) {
+	fields := make([]string, len(row))
+	for i, value := range row {
+		fields[i] = value.String()
+	}
+	e.writeMarkdownFields(fields)
+}
+
+// writeMarkdownFields writes a Markdown table row, pipes are escaped and
+// line breaks become <br>
+func (e *Exporter) writeMarkdownFields(fields []string) {
+	replacer := strings.NewReplacer(`\`, `\\`, "|", `\|`, "\r\n", "<br>", "\n", "<br>", "\r", "<br>")
+	e.w.WriteString("|")
+	for _, field := range fields {
+		e.w.WriteString(" " + replacer.Replace(field) + " |")
+	}
+	e.w.WriteString("\n")
+}
+
+// writeInsert writes a row as INSERT statement
+func (e *Exporter) writeInsert(row []Value) {
+	names := make([]string, len(e.columns))
+	for i, column := range e.columns {
+		names[i] = quoteIdentifier(e.options.Dialect, column.Name)
+	}
+	values := make([]string, len(row))
+	for i, value := range row {
+		values[i] = Literal(e.options.Dialect, value)
+	}
+	fmt.Fprintf(e.w, "INSERT INTO %s (%s) VALUES (%s);\n", e.options.Table, strings.Join(names, ", "), strings.Join(values, ", "))
+}
+
+// QuoteTable returns a possibly qualified table name with quoted parts
+func QuoteTable(dialect Dialect, qualifier, table string) string {
+	if qualifier == "" {
+		return quoteIdentifier(dialect, table)
+	}
+	return quoteIdentifier(dialect, qualifier) + "." + quoteIdentifier(dialect, table)
+}
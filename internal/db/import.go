@@ -0,0 +1,651 @@
+package db
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+)
+
+const (
+	// importSampleSize is the number of records read ahead to find the
+	// columns and infer their types
+	importSampleSize = 1000
+	// maxImportErrors limits the row errors kept in an import report
+	maxImportErrors = 1000
+	// maxPlaceholders limits the parameters of one INSERT statement
+	maxPlaceholders = 65535
+)
+
+// ImportFormat is a file format rows are imported from
+type ImportFormat int
+
+const (
+	ImportCSV ImportFormat = iota
+	ImportJSONLines
+)
+
+// String returns the format name
+func (f ImportFormat) String() string {
+	if f == ImportJSONLines {
+		return "JSON lines"
+	}
+	return "CSV"
+}
+
+// ImportOptions configures reading an import file
+type ImportOptions struct {
+	Format    ImportFormat
+	Delimiter rune // CSV field delimiter, a comma if zero
+	Header    bool // the first CSV record names the columns
+	EmptyNull bool // empty CSV fields are NULL instead of empty strings
+}
+
+// ImportType is the type inferred for an imported column
+type ImportType int
+
+const (
+	ImportText ImportType = iota
+	ImportInteger
+	ImportDecimal
+	ImportBoolean
+	ImportDate
+	ImportTimestamp
+	ImportJSON
+)
+
+// ImportTypes lists the column types in the order they are offered
+var ImportTypes = []ImportType{ImportText, ImportInteger, ImportDecimal, ImportBoolean, ImportDate, ImportTimestamp, ImportJSON}
+
+// String returns the type name
+func (t ImportType) String() string {
+	switch t {
+	case ImportInteger:
+		return "integer"
+	case ImportDecimal:
+		return "decimal"
+	case ImportBoolean:
+		return "boolean"
+	case ImportDate:
+		return "date"
+	case ImportTimestamp:
+		return "timestamp"
+	case ImportJSON:
+		return "json"
+	}
+	return "text"
+}
+
+// SQLType returns the column type of dialect used for new tables
+func (t ImportType) SQLType(dialect Dialect) string {
+	switch t {
+	case ImportInteger:
+		if dialect == DialectSQLite {
+			return "INTEGER"
+		}
+		return "BIGINT"
+	case ImportDecimal:
+		switch dialect {
+		case DialectPostgres:
+			return "NUMERIC"
+		case DialectMySQL:
+			return "DOUBLE"
+		}
+		return "REAL"
+	case ImportBoolean:
+		return "BOOLEAN"
+	case ImportDate:
+		return "DATE"
+	case ImportTimestamp:
+		if dialect == DialectMySQL {
+			return "DATETIME(6)"
+		}
+		return "TIMESTAMP"
+	case ImportJSON:
+		switch dialect {
+		case DialectPostgres:
+			return "JSONB"
+		case DialectMySQL:
+			return "JSON"
+		}
+	}
+	return "TEXT"
+}
+
+// ImportColumn is a column of an import file
+type ImportColumn struct {
+	Name string
+	Type ImportType // inferred from the sampled records
+}
+
+// ImportRecord is a row read from an import file
+type ImportRecord struct {
+	Line   int     // line of the record in the file
+	Values []Value // one text value per column, NULL for missing values
+}
+
+// RowError is an error of a single imported row, the import goes on with the next row
+type RowError struct {
+	Line int
+	Err  error
+}
+
+// Error returns the error with the line of the row
+func (e *RowError) Error() string {
+	return fmt.Sprintf("line %d: %v", e.Line, e.Err)
+}
+
+// Unwrap returns the error of the row
+func (e *RowError) Unwrap() error {
+	return e.Err
+}
+
+// ImportReader reads the records of a CSV or JSON lines file. The columns
+// are known after the first records have been sampled: the CSV header or
+// the keys of the sampled JSON objects in order of appearance.
+type ImportReader struct {
+	options ImportOptions
+	csv     *csv.Reader
+	lines   *bufio.Reader
+	line    int
+	columns []ImportColumn
+	index   map[string]int // column by JSON key
+	sample  []ImportRecord
+	pending []ImportRecord // sampled records not read yet
+	errors  []error        // row errors met while sampling, returned in order
+}
+
+// NewImportReader reads the header and the sample of r
+func NewImportReader(r io.Reader, options ImportOptions) (*ImportReader, error) {
+	reader := &ImportReader{options: options, index: map[string]int{}}
+
+	switch options.Format {
+	case ImportJSONLines:
+		reader.lines = bufio.NewReader(r)
+	default:
+		if options.Delimiter == 0 {
+			options.Delimiter = ','
+		}
+		reader.csv = csv.NewReader(r)
+		reader.csv.Comma = options.Delimiter
+		reader.csv.FieldsPerRecord = -1
+		reader.csv.LazyQuotes = true
+		if options.Header {
+			header, err := reader.csv.Read()
+			if err == io.EOF {
+				return nil, fmt.Errorf("the file is empty")
+			}
+			if err != nil {
+				return nil, err
+			}
+			for i, name := range header {
+				name = strings.TrimSpace(strings.TrimPrefix(name, "\ufeff"))
+				if name == "" {
+					name = fmt.Sprintf("column%d", i+1)
+				}
+				reader.columns = append(reader.columns, ImportColumn{Name: name})
+			}
+		}
+	}
+
+	if err := reader.readSample(); err != nil {
+		return nil, err
+	}
+	if len(reader.columns) == 0 {
+		return nil, fmt.Errorf("no columns found in the first %d records", importSampleSize)
+	}
+	return reader, nil
+}
+
+// readSample reads the first records, adding the columns they use
+func (r *ImportReader) readSample() error {
+	for len(r.sample) < importSampleSize {
+		record, err := r.next(true)
+		if err == io.EOF {
+			break
+		}
+		var rowErr *RowError
+		if errors.As(err, &rowErr) {
+			r.errors = append(r.errors, err)
+			r.pending = append(r.pending, ImportRecord{Line: rowErr.Line})
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		r.sample = append(r.sample, record)
+		r.pending = append(r.pending, record)
+	}
+
+	// Records sampled before a column was added miss its value
+	for i := range r.pending {
+		if r.pending[i].Values == nil {
+			continue
+		}
+		for len(r.pending[i].Values) < len(r.columns) {
+			r.pending[i].Values = append(r.pending[i].Values, Value{Null: true})
+		}
+	}
+	r.sample = r.sample[:0]
+	for _, record := range r.pending {
+		if record.Values != nil {
+			r.sample = append(r.sample, record)
+		}
+	}
+
+	for i := range r.columns {
+		r.columns[i].Type = inferType(r.sample, i)
+	}
+	return nil
+}
+
+// Columns returns the columns of the file with their inferred types
+func (r *ImportReader) Columns() []ImportColumn {
+	return r.columns
+}
+
+// Sample returns the records read to infer the column types
+func (r *ImportReader) Sample() []ImportRecord {
+	return r.sample
+}
+
+// Read returns the next record, starting with the sampled ones. A *RowError
+// reports a record that cannot be read, io.EOF the end of the file.
+func (r *ImportReader) Read() (ImportRecord, error) {
+	if len(r.pending) > 0 {
+		record := r.pending[0]
+		r.pending = r.pending[1:]
+		if record.Values == nil {
+			err := r.errors[0]
+			r.errors = r.errors[1:]
+			return record, err
+		}
+		return record, nil
+	}
+	return r.next(false)
+}
+
+// next reads a record from the file, new columns are only added while sampling
+func (r *ImportReader) next(sampling bool) (ImportRecord, error) {
+	if r.lines != nil {
+		return r.nextJSON(sampling)
+	}
+	return r.nextCSV(sampling)
+}
+
+// nextCSV reads a CSV record
+func (r *ImportReader) nextCSV(sampling bool) (ImportRecord, error) {
+	fields, err := r.csv.Read()
+	var parseErr *csv.ParseError
+	if errors.As(err, &parseErr) {
+		return ImportRecord{}, &RowError{Line: parseErr.StartLine, Err: parseErr.Err}
+	}
+	if err != nil {
+		return ImportRecord{}, err
+	}
+	line, _ := r.csv.FieldPos(0)
+
+	// Without a header the widest sampled record sets the columns
+	if sampling && !r.options.Header {
+		for len(r.columns) < len(fields) {
+			r.columns = append(r.columns, ImportColumn{Name: fmt.Sprintf("column%d", len(r.columns)+1)})
+		}
+	}
+	if len(fields) > len(r.columns) {
+		return ImportRecord{}, &RowError{Line: line, Err: fmt.Errorf("%d fields, the file has %d columns", len(fields), len(r.columns))}
+	}
+
+	record := ImportRecord{Line: line, Values: make([]Value, len(r.columns))}
+	for i := range record.Values {
+		switch {
+		case i >= len(fields):
+			record.Values[i] = Value{Null: true}
+		case fields[i] == "" && r.options.EmptyNull:
+			record.Values[i] = Value{Null: true}
+		default:
+			record.Values[i] = Value{Text: fields[i]}
+		}
+	}
+	return record, nil
+}
+
+// nextJSON reads a JSON object from the next line that is not blank
+func (r *ImportReader) nextJSON(sampling bool) (ImportRecord, error) {
+	var line []byte
+	for len(bytes.TrimSpace(line)) == 0 {
+		text, err := r.lines.ReadBytes('\n')
+		if err == io.EOF && len(text) == 0 {
+			return ImportRecord{}, io.EOF
+		}
+		if err != nil && err != io.EOF {
+			return ImportRecord{}, err
+		}
+		r.line++
+		line = text
+	}
+
+	values, err := r.parseObject(line, sampling)
+	if err != nil {
+		return ImportRecord{}, &RowError{Line: r.line, Err: err}
+	}
+	return ImportRecord{Line: r.line, Values: values}, nil
+}
+
+// parseObject returns the values of a JSON object by column. Nested
+// objects and arrays are kept as JSON text.
+func (r *ImportReader) parseObject(line []byte, sampling bool) ([]Value, error) {
+	decoder := json.NewDecoder(bytes.NewReader(line))
+	decoder.UseNumber()
+
+	if token, err := decoder.Token(); err != nil || token != json.Delim('{') {
+		return nil, fmt.Errorf("not a JSON object")
+	}
+
+	values := make([]Value, len(r.columns))
+	set := make([]bool, len(r.columns))
+	for decoder.More() {
+		token, err := decoder.Token()
+		if err != nil {
+			return nil, err
+		}
+		key := token.(string)
+
+		var raw json.RawMessage
+		if err := decoder.Decode(&raw); err != nil {
+			return nil, err
+		}
+
+		column, ok := r.index[key]
+		if !ok {
+			if !sampling {
+				return nil, fmt.Errorf("key %q is not a column of the first %d lines", key, importSampleSize)
+			}
+			column = len(r.columns)
+			r.index[key] = column
+			r.columns = append(r.columns, ImportColumn{Name: key})
+			values = append(values, Value{})
+			set = append(set, false)
+		}
+		values[column], set[column] = jsonImportValue(raw), true
+	}
+	if _, err := decoder.Token(); err != nil {
+		return nil, err
+	}
+
+	for i := range values {
+		if !set[i] {
+			values[i] = Value{Null: true}
+		}
+	}
+	return values, nil
+}
+
+// jsonImportValue converts a JSON value to a text value
+func jsonImportValue(raw json.RawMessage) Value {
+	text := strings.TrimSpace(string(raw))
+	switch {
+	case text == "null":
+		return Value{Null: true}
+	case strings.HasPrefix(text, `"`):
+		var s string
+		json.Unmarshal(raw, &s)
+		return Value{Text: s}
+	case strings.HasPrefix(text, "{") || strings.HasPrefix(text, "["):
+		var compact bytes.Buffer
+		json.Compact(&compact, raw)
+		return Value{Text: compact.String()}
+	case text == "true" || text == "false":
+		return Value{Text: text}
+	}
+	return Value{Kind: ValueNumber, Text: text}
+}
+
+var (
+	integerText   = regexp.MustCompile(`^[-+]?[0-9]{1,18}$`)
+	decimalText   = regexp.MustCompile(`^[-+]?([0-9]+(\.[0-9]*)?|\.[0-9]+)([eE][-+]?[0-9]+)?$`)
+	dateText      = regexp.MustCompile(`^[0-9]{4}-[0-9]{2}-[0-9]{2}$`)
+	timestampText = regexp.MustCompile(`^[0-9]{4}-[0-9]{2}-[0-9]{2}[T ][0-9]{2}:[0-9]{2}(:[0-9]{2}(\.[0-9]+)?)?(Z|[-+][0-9]{2}(:?[0-9]{2})?)?$`)
+)
+
+// inferType returns the narrowest type matching all values of a column,
+// text if the column has no values
+func inferType(records []ImportRecord, column int) ImportType {
+	candidates := []ImportType{ImportInteger, ImportDecimal, ImportBoolean, ImportDate, ImportTimestamp, ImportJSON}
+	seen := false
+	for _, record := range records {
+		value := record.Values[column]
+		if value.Null {
+			continue
+		}
+		seen = true
+		kept := candidates[:0]
+		for _, candidate := range candidates {
+			if matchesType(candidate, value.Text) {
+				kept = append(kept, candidate)
+			}
+		}
+		if candidates = kept; len(candidates) == 0 {
+			return ImportText
+		}
+	}
+	if !seen {
+		return ImportText
+	}
+	return candidates[0]
+}
+
+// matchesType returns true if text is a value of type t
+func matchesType(t ImportType, text string) bool {
+	switch t {
+	case ImportInteger:
+		return integerText.MatchString(text)
+	case ImportDecimal:
+		return decimalText.MatchString(text)
+	case ImportBoolean:
+		lower := strings.ToLower(text)
+		return lower == "true" || lower == "false"
+	case ImportDate:
+		return dateText.MatchString(text)
+	case ImportTimestamp:
+		return timestampText.MatchString(text)
+	case ImportJSON:
+		text = strings.TrimSpace(text)
+		return (strings.HasPrefix(text, "{") || strings.HasPrefix(text, "[")) && json.Valid([]byte(text))
+	}
+	return true
+}
+
+// ImportTarget is the table imported rows are loaded into
+type ImportTarget struct {
+	Dialect Dialect
+	Schema  string   // PostgreSQL schema or database of the table, may be empty
+	Table   string   // unquoted table name
+	Columns []string // table columns receiving the values of a row, in order
+	Types   []string // column types, boolean values become 1 and 0 for integer types
+}
+
+// QuotedTable returns the quoted, possibly qualified table name
+func (t ImportTarget) QuotedTable() string {
+	return QuoteTable(t.Dialect, t.Schema, t.Table)
+}
+
+// quotedColumns returns the quoted column list
+func (t ImportTarget) quotedColumns() string {
+	names := make([]string, len(t.Columns))
+	for i, column := range t.Columns {
+		names[i] = quoteIdentifier(t.Dialect, column)
+	}
+	return strings.Join(names, ", ")
+}
+
+// CreateTableStatement returns the statement creating target with the column types
+func CreateTableStatement(target ImportTarget, types []ImportType) string {
+	columns := make([]string, len(target.Columns))
+	for i, column := range target.Columns {
+		columns[i] = fmt.Sprintf("  %s %s", quoteIdentifier(target.Dialect, column), types[i].SQLType(target.Dialect))
+	}
+	return fmt.Sprintf("CREATE TABLE %s (\n%s\n)", target.QuotedTable(), strings.Join(columns, ",\n"))
+}
+
+// args returns the values of a row as statement arguments. Outside
+// PostgreSQL boolean text is converted for integer typed columns.
+func (t ImportTarget) args(row []Value) []interface{} {
+	args := make([]interface{}, len(row))
+	for i, value := range row {
+		if value.Null {
+			continue
+		}
+		args[i] = value.Text
+		if t.Dialect != DialectPostgres && i < len(t.Types) && isIntegerType(t.Types[i]) {
+			switch strings.ToLower(value.Text) {
+			case "true":
+				args[i] = 1
+			case "false":
+				args[i] = 0
+			}
+		}
+	}
+	return args
+}
+
+// isIntegerType returns true for integer and boolean column types
+func isIntegerType(name string) bool {
+	name = strings.ToUpper(name)
+	return strings.Contains(name, "INT") || strings.Contains(name, "BOOL") || strings.HasPrefix(name, "BIT")
+}
+
+// insertRows loads rows with multi-row INSERT statements in one transaction
+func insertRows(ctx context.Context, conn *sql.DB, target ImportTarget, rows [][]Value) error {
+	if conn == nil {
+		return fmt.Errorf("not connected")
+	}
+	if len(target.Columns) == 0 {
+		return fmt.Errorf("no columns to import")
+	}
+
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+
+	placeholders := "(" + strings.TrimSuffix(strings.Repeat("?, ", len(target.Columns)), ", ") + ")"
+	perStatement := max(maxPlaceholders/len(target.Columns), 1)
+	for start := 0; start < len(rows); start += perStatement {
+		chunk := rows[start:min(start+perStatement, len(rows))]
+
+		var args []interface{}
+		tuples := make([]string, len(chunk))
+		for i, row := range chunk {
+			tuples[i] = placeholders
+			args = append(args, target.args(row)...)
+		}
+		query := fmt.Sprintf("INSERT INTO %s (%s) VALUES %s", target.QuotedTable(), target.quotedColumns(), strings.Join(tuples, ", "))
+		if _, err := tx.ExecContext(ctx, query, args...); err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// ImportReport counts the rows of an import
+type ImportReport struct {
+	Loaded int64
+	Failed int64
+	Errors []*RowError // the first failed rows
+}
+
+// fail records a row that could not be loaded
+func (r *ImportReport) fail(err *RowError) {
+	r.Failed++
+	if len(r.Errors) < maxImportErrors {
+		r.Errors = append(r.Errors, err)
+	}
+}
+
+// Import reads the records of reader and loads them into target in batches
+// of batchSize rows, each in its own transaction. columns picks the file
+// column of each target column. A batch that fails is loaded again row by
+// row to report the rows that cannot be loaded. progress is called after
+// each batch.
+func Import(ctx context.Context, driver Driver, reader *ImportReader, target ImportTarget, columns []int, batchSize int, progress func(report *ImportReport)) (*ImportReport, error) {
+	report := &ImportReport{}
+	batchSize = max(batchSize, 1)
+
+	var rows [][]Value
+	var lines []int
+	flush := func() error {
+		if len(rows) == 0 {
+			return nil
+		}
+		defer func() {
+			rows, lines = rows[:0], lines[:0]
+			if progress != nil {
+				progress(report)
+			}
+		}()
+
+		err := driver.ImportRows(ctx, target, rows)
+		if err == nil {
+			report.Loaded += int64(len(rows))
+			return nil
+		}
+		if ctx.Err() != nil || len(rows) == 1 {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			report.fail(&RowError{Line: lines[0], Err: err})
+			return nil
+		}
+
+		for i, row := range rows {
+			if err := driver.ImportRows(ctx, target, [][]Value{row}); err != nil {
+				if ctx.Err() != nil {
+					return ctx.Err()
+				}
+				report.fail(&RowError{Line: lines[i], Err: err})
+				continue
+			}
+			report.Loaded++
+		}
+		return nil
+	}
+
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		var rowErr *RowError
+		if errors.As(err, &rowErr) {
+			report.fail(rowErr)
+			continue
+		}
+		if err != nil {
+			return report, err
+		}
+
+		row := make([]Value, len(columns))
+		for i, column := range columns {
+			row[i] = record.Values[column]
+		}
+		rows = append(rows, row)
+		lines = append(lines, record.Line)
+
+		if len(rows) >= batchSize {
+			if err := flush(); err != nil {
+				return report, err
+			}
+		}
+	}
+
+	return report, flush()
+}
@@ -0,0 +1,60 @@
+package db
+
+import "time"
+
+// KVDriver defines the interface for key-value store drivers
+type KVDriver interface {
+	Connect(dsn string) error
+	Close() error
+	ScanKeys(cursor, pattern string, count int) (*KVScanResult, error)
+	GetEntry(key string) (*KVEntry, error)
+	SetValue(key, value string) error
+	SetField(key, field, value string) error
+	DeleteField(key, field string) error
+	DeleteKey(key string) error
+	Expire(key string, ttl time.Duration) error
+	GetDriverName() string
+}
+
+// Key types reported by KVEntry.Type
+const (
+	KVTypeString = "string"
+	KVTypeHash   = "hash"
+	KVTypeList   = "list"
+	KVTypeSet    = "set"
+	KVTypeZSet   = "zset"
+	KVTypeStream = "stream"
+)
+
+// KVNoExpiry is the TTL reported for keys without expiration
+const KVNoExpiry = time.Duration(-1)
+
+// KVScanResult represents one page of a key scan
+type KVScanResult struct {
+	Keys   []string
+	Cursor string // "0" when the scan is complete
+}
+
+// Done returns true when there are no more keys to scan
+func (r *KVScanResult) Done() bool {
+	return r.Cursor == "" || r.Cursor == "0"
+}
+
+// KVEntry represents a key with its type, TTL and (possibly truncated) value
+type KVEntry struct {
+	Key       string
+	Type      string
+	TTL       time.Duration
+	Length    int64     // string length in bytes or number of elements
+	Value     string    // value of string keys
+	Fields    []KVField // elements of collection keys
+	Truncated bool      // true when Fields holds fewer than Length elements
+}
+
+// KVField represents one element of a collection key.
+// Name is the hash field, list index, set/zset member or stream entry ID;
+// Value is the hash value, list item, zset score or stream entry fields.
+type KVField struct {
+	Name  string
+	Value string
+}
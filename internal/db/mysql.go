@@ -1,31 +1,53 @@
 package db
 
 import (
+	"context"
 	"database/sql"
+	"errors"
 	"fmt"
+	"io"
+	"strings"
+	"sync/atomic"
 
-	_ "github.com/go-sql-driver/mysql"
+	"github.com/go-sql-driver/mysql"
 )
 
 // MySQL implements the Driver interface for MySQL
 type MySQL struct {
-	conn *sql.DB
+	conn         *sql.DB
+	localRefused bool // the server refused LOAD DATA LOCAL, imports use INSERT
+	txn          transaction
 }
 
+// importReaders numbers the readers registered for LOAD DATA LOCAL
+var importReaders atomic.Int64
+
 // NewMySQL creates a new MySQL driver
 func NewMySQL() *MySQL {
 	return &MySQL{}
 }
 
+func init() {
+	RegisterDriver(DriverInfo{
+		Name:        "MySQL",
+		Aliases:     []string{"mariadb"},
+		DefaultPort: "3306",
+		Preset:      ConnConfig{Host: "localhost", Username: "root"},
+		Shortcut:    'M',
+		New:         func() Driver { return NewMySQL() },
+		DSN:         MySQLDSN,
+	})
+}
+
 // Connect connects to MySQL database
-func (m *MySQL) Connect(dsn string) error {
+func (m *MySQL) Connect(ctx context.Context, dsn string) error {
 	var err error
 	m.conn, err = sql.Open("mysql", dsn)
 	if err != nil {
 		return fmt.Errorf("failed to open MySQL connection: %w", err)
 	}
 
-	err = m.conn.Ping()
+	err = m.conn.PingContext(ctx)
 	if err != nil {
 		return fmt.Errorf("failed to ping MySQL: %w", err)
 	}
@@ -35,19 +57,20 @@ func (m *MySQL) Connect(dsn string) error {
 
 // Close closes the connection
 func (m *MySQL) Close() error {
+	m.txn.end(false)
 	if m.conn != nil {
 		return m.conn.Close()
 	}
 	return nil
 }
 
-// GetDatabases returns list of databases
-func (m *MySQL) GetDatabases() ([]string, error) {
+// GetDatabases returns list of databases, including the system databases
+func (m *MySQL) GetDatabases(ctx context.Context) ([]string, error) {
 	if m.conn == nil {
 		return nil, fmt.Errorf("not connected")
 	}
 
-	rows, err := m.conn.Query("SHOW DATABASES")
+	rows, err := m.conn.QueryContext(ctx, "SHOW DATABASES")
 	if err != nil {
 		return nil, err
 	}
@@ -59,23 +82,20 @@ func (m *MySQL) GetDatabases() ([]string, error) {
 		if err := rows.Scan(&db); err != nil {
 			return nil, err
 		}
-		// Filter system databases
-		if db != "information_schema" && db != "mysql" && db != "performance_schema" && db != "sys" {
-			databases = append(databases, db)
-		}
+		databases = append(databases, db)
 	}
 
 	return databases, rows.Err()
 }
 
 // GetTables returns list of tables in a database
-func (m *MySQL) GetTables(database string) ([]string, error) {
+func (m *MySQL) GetTables(ctx context.Context, database string) ([]string, error) {
 	if m.conn == nil {
 		return nil, fmt.Errorf("not connected")
 	}
 
 	query := fmt.Sprintf("SHOW TABLES FROM `%s`", database)
-	rows, err := m.conn.Query(query)
+	rows, err := m.conn.QueryContext(ctx, query)
 	if err != nil {
 		return nil, err
 	}
@@ -94,66 +114,211 @@ func (m *MySQL) GetTables(database string) ([]string, error) {
 }
 
 // ExecuteQuery executes a SQL query
-func (m *MySQL) ExecuteQuery(query string) (*QueryResult, error) {
+func (m *MySQL) ExecuteQuery(ctx context.Context, query string) (*QueryResult, error) {
+	return fetchAll(m.OpenQuery(ctx, query))
+}
+
+// OpenQuery executes a SQL query and returns a cursor for its rows
+func (m *MySQL) OpenQuery(ctx context.Context, query string, args ...any) (*QueryResult, error) {
 	if m.conn == nil {
 		return nil, fmt.Errorf("not connected")
 	}
 
-	result := &QueryResult{}
+	if tx := m.txn.runner(); tx != nil {
+		return m.txn.track(m.openStatement(ctx, tx, query, args...))
+	}
 
-	// Try as SELECT query first
-	rows, err := m.conn.Query(query)
+	// Warnings are per session, so read them on the same connection
+	conn, err := m.conn.Conn(ctx)
 	if err != nil {
-		// Try as DML/DDL statement
-		res, execErr := m.conn.Exec(query)
-		if execErr != nil {
-			result.Error = execErr
-			return result, execErr
-		}
+		return nil, err
+	}
+
+	result, err := openStatement(ctx, conn, DialectMySQL, query, args...)
+	if err != nil {
+		conn.Close()
+		return result, err
+	}
 
-		affected, _ := res.RowsAffected()
-		result.RowsAffected = affected
+	if result.Cursor == nil {
+		result.Notices = m.warnings(ctx, conn)
+		conn.Close()
 		return result, nil
 	}
-	defer rows.Close()
 
-	// Get column names
-	columns, err := rows.Columns()
+	// Keep the connection until the rows have been read
+	result.Cursor.onClose = func() []string {
+		defer conn.Close()
+		return m.warnings(context.Background(), conn)
+	}
+	return result, nil
+}
+
+// openStatement runs a statement in the open transaction and reads its
+// warnings on the pinned connection
+func (m *MySQL) openStatement(ctx context.Context, tx *sql.Tx, query string, args ...any) (*QueryResult, error) {
+	result, err := openStatement(ctx, tx, DialectMySQL, query, args...)
 	if err != nil {
-		result.Error = err
 		return result, err
 	}
-	result.Columns = columns
 
-	// Get rows
+	if result.Cursor == nil {
+		result.Notices = m.warnings(ctx, tx)
+		return result, nil
+	}
+	result.Cursor.onClose = func() []string {
+		return m.warnings(context.Background(), tx)
+	}
+	return result, nil
+}
+
+// warnings returns the warnings of the last statement run on conn
+func (m *MySQL) warnings(ctx context.Context, conn sqlRunner) []string {
+	rows, err := conn.QueryContext(ctx, "SHOW WARNINGS")
+	if err != nil {
+		return nil
+	}
+	defer rows.Close()
+
+	var warnings []string
 	for rows.Next() {
-		values := make([]interface{}, len(columns))
-		valuePtrs := make([]interface{}, len(columns))
-		for i := range values {
-			valuePtrs[i] = &values[i]
+		var level, message string
+		var code int
+		if err := rows.Scan(&level, &code, &message); err != nil {
+			return warnings
 		}
+		warnings = append(warnings, fmt.Sprintf("%s %d: %s", level, code, message))
+	}
 
-		if err := rows.Scan(valuePtrs...); err != nil {
-			result.Error = err
-			return result, err
-		}
+	return warnings
+}
+
+// ExecTransaction runs statements in one transaction
+func (m *MySQL) ExecTransaction(ctx context.Context, statements []BoundStatement, check func(statement int, affected int64) error) error {
+	if tx := m.txn.runner(); tx != nil {
+		return execSavepoint(ctx, tx, statements, check)
+	}
+	return execTransaction(ctx, m.conn, statements, check)
+}
+
+// Begin pins a connection and starts a transaction on it
+func (m *MySQL) Begin(ctx context.Context, isolation sql.IsolationLevel) error {
+	return m.txn.begin(ctx, m.conn, isolation)
+}
 
-		row := make([]string, len(columns))
-		for i, val := range values {
-			if val == nil {
-				row[i] = "NULL"
-			} else {
-				row[i] = fmt.Sprintf("%v", val)
+// Commit commits the open transaction
+func (m *MySQL) Commit() error {
+	return m.txn.end(true)
+}
+
+// Rollback rolls the open transaction back
+func (m *MySQL) Rollback() error {
+	return m.txn.end(false)
+}
+
+// Transaction returns the open transaction
+func (m *MySQL) Transaction() (TransactionInfo, bool) {
+	return m.txn.open()
+}
+
+// RunScript runs statements one after another on one connection using database
+func (m *MySQL) RunScript(ctx context.Context, database string, statements []Statement, progress func(done int)) error {
+	if _, ok := m.txn.open(); ok {
+		return errTransactionOpen
+	}
+	var setup []string
+	if database != "" {
+		setup = append(setup, "USE "+quoteIdentifier(DialectMySQL, database))
+	}
+	return runScript(ctx, m.conn, setup, statements, progress)
+}
+
+// ImportRows loads rows with LOAD DATA LOCAL, or with multi-row INSERT
+// statements if the server does not allow local files
+func (m *MySQL) ImportRows(ctx context.Context, target ImportTarget, rows [][]Value) error {
+	if m.conn == nil {
+		return fmt.Errorf("not connected")
+	}
+	if _, ok := m.txn.open(); ok {
+		return errTransactionOpen
+	}
+	if m.localRefused {
+		return insertRows(ctx, m.conn, target, rows)
+	}
+
+	err := m.loadRows(ctx, target, rows)
+	var mysqlErr *mysql.MySQLError
+	// 1148 and 3948: local files are disabled on the server
+	if errors.As(err, &mysqlErr) && (mysqlErr.Number == 1148 || mysqlErr.Number == 3948) {
+		m.localRefused = true
+		return insertRows(ctx, m.conn, target, rows)
+	}
+	return err
+}
+
+// loadRows loads rows with LOAD DATA LOCAL in one transaction. LOAD DATA
+// LOCAL turns row errors into warnings, so any warning fails the load.
+func (m *MySQL) loadRows(ctx context.Context, target ImportTarget, rows [][]Value) error {
+	name := fmt.Sprintf("gocmder-import-%d", importReaders.Add(1))
+	mysql.RegisterReaderHandler(name, func() io.Reader {
+		return strings.NewReader(loadDataText(target, rows))
+	})
+	defer mysql.DeregisterReaderHandler(name)
+
+	tx, err := m.conn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+
+	query := fmt.Sprintf("LOAD DATA LOCAL INFILE 'Reader::%s' INTO TABLE %s CHARACTER SET utf8mb4 (%s)", name, target.QuotedTable(), target.quotedColumns())
+	res, err := tx.ExecContext(ctx, query)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	if warnings := m.warnings(ctx, tx); len(warnings) > 0 {
+		tx.Rollback()
+		return fmt.Errorf("%s", warnings[0])
+	}
+	if affected, err := res.RowsAffected(); err == nil && affected != int64(len(rows)) {
+		tx.Rollback()
+		return fmt.Errorf("%d of %d rows were loaded", affected, len(rows))
+	}
+
+	return tx.Commit()
+}
+
+// loadDataText returns rows in the default format of LOAD DATA: tab
+// separated fields with backslash escapes and \N for NULL
+func loadDataText(target ImportTarget, rows [][]Value) string {
+	escape := strings.NewReplacer("\\", "\\\\", "\t", "\\t", "\n", "\\n", "\r", "\\r", "\x00", "\\0")
+
+	var text strings.Builder
+	for _, row := range rows {
+		for i, arg := range target.args(row) {
+			if i > 0 {
+				text.WriteByte('\t')
+			}
+			switch value := arg.(type) {
+			case nil:
+				text.WriteString("\\N")
+			case string:
+				text.WriteString(escape.Replace(value))
+			default:
+				fmt.Fprint(&text, value)
 			}
 		}
-		result.Rows = append(result.Rows, row)
+		text.WriteByte('\n')
 	}
-
-	result.RowsAffected = int64(len(result.Rows))
-	return result, rows.Err()
+	return text.String()
 }
 
 // GetDriverName returns the driver name
 func (m *MySQL) GetDriverName() string {
 	return "MySQL"
 }
+
+// GetDialect returns the SQL dialect
+func (m *MySQL) GetDialect() Dialect {
+	return DialectMySQL
+}
@@ -0,0 +1,354 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+)
+
+// DescribeTable returns the structure of a table in a database
+func (m *MySQL) DescribeTable(ctx context.Context, database, schema, table string) (*TableInfo, error) {
+	if m.conn == nil {
+		return nil, fmt.Errorf("not connected")
+	}
+
+	info := &TableInfo{Database: database, Name: table}
+
+	if err := m.describeColumns(ctx, info); err != nil {
+		return nil, err
+	}
+	if len(info.Columns) == 0 {
+		return nil, fmt.Errorf("table %s not found", info.QualifiedName())
+	}
+	if err := m.describeIndexes(ctx, info); err != nil {
+		return nil, err
+	}
+	if err := m.describeForeignKeys(ctx, info); err != nil {
+		return nil, err
+	}
+	// CHECK_CONSTRAINTS exists since MySQL 8.0.16 and MariaDB 10.2, older servers ignore checks
+	m.describeChecks(ctx, info)
+	if err := m.describeTriggers(ctx, info); err != nil {
+		return nil, err
+	}
+
+	ddl, err := m.showCreateTable(ctx, info)
+	if err != nil {
+		return nil, err
+	}
+	info.DDL = ddl
+
+	return info, nil
+}
+
+// describeColumns reads the columns of a table
+func (m *MySQL) describeColumns(ctx context.Context, info *TableInfo) error {
+	rows, err := m.conn.QueryContext(ctx, `SELECT COLUMN_NAME, COLUMN_TYPE, IS_NULLABLE, COLUMN_DEFAULT, EXTRA, COLUMN_COMMENT
+		FROM information_schema.COLUMNS WHERE TABLE_SCHEMA = ? AND TABLE_NAME = ? ORDER BY ORDINAL_POSITION`,
+		info.Database, info.Name)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var column ColumnInfo
+		var nullable string
+		var defaultValue sql.NullString
+		if err := rows.Scan(&column.Name, &column.Type, &nullable, &defaultValue, &column.Extra, &column.Comment); err != nil {
+			return err
+		}
+		column.Nullable = nullable == "YES"
+		column.Default, column.HasDefault = defaultValue.String, defaultValue.Valid
+		info.Columns = append(info.Columns, column)
+	}
+
+	return rows.Err()
+}
+
+// describeIndexes reads the indexes and the primary key of a table
+func (m *MySQL) describeIndexes(ctx context.Context, info *TableInfo) error {
+	rows, err := m.conn.QueryContext(ctx, `SELECT INDEX_NAME, NON_UNIQUE, COLUMN_NAME
+		FROM information_schema.STATISTICS WHERE TABLE_SCHEMA = ? AND TABLE_NAME = ? ORDER BY INDEX_NAME = 'PRIMARY' DESC, INDEX_NAME, SEQ_IN_INDEX`,
+		info.Database, info.Name)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var name string
+		var nonUnique int
+		var column sql.NullString
+		if err := rows.Scan(&name, &nonUnique, &column); err != nil {
+			return err
+		}
+		// Functional key parts have no column name
+		if !column.Valid {
+			column.String = "(expression)"
+		}
+
+		// Each column of an index is a row with the same name
+		if n := len(info.Indexes); n == 0 || info.Indexes[n-1].Name != name {
+			info.Indexes = append(info.Indexes, IndexInfo{Name: name, Unique: nonUnique == 0, Primary: name == "PRIMARY"})
+		}
+		index := &info.Indexes[len(info.Indexes)-1]
+		index.Columns = append(index.Columns, column.String)
+		if index.Primary {
+			info.PrimaryKey = append(info.PrimaryKey, column.String)
+		}
+	}
+
+	return rows.Err()
+}
+
+// describeForeignKeys reads the foreign keys of a table
+func (m *MySQL) describeForeignKeys(ctx context.Context, info *TableInfo) error {
+	rows, err := m.conn.QueryContext(ctx, `SELECT k.CONSTRAINT_NAME, k.COLUMN_NAME, k.REFERENCED_TABLE_SCHEMA, k.REFERENCED_TABLE_NAME,
+			k.REFERENCED_COLUMN_NAME, r.UPDATE_RULE, r.DELETE_RULE
+		FROM information_schema.KEY_COLUMN_USAGE k
+		JOIN information_schema.REFERENTIAL_CONSTRAINTS r
+			ON r.CONSTRAINT_SCHEMA = k.CONSTRAINT_SCHEMA AND r.CONSTRAINT_NAME = k.CONSTRAINT_NAME AND r.TABLE_NAME = k.TABLE_NAME
+		WHERE k.TABLE_SCHEMA = ? AND k.TABLE_NAME = ? AND k.REFERENCED_TABLE_NAME IS NOT NULL
+		ORDER BY k.CONSTRAINT_NAME, k.ORDINAL_POSITION`,
+		info.Database, info.Name)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var name, column, refSchema, refTable, refColumn, onUpdate, onDelete string
+		if err := rows.Scan(&name, &column, &refSchema, &refTable, &refColumn, &onUpdate, &onDelete); err != nil {
+			return err
+		}
+
+		if n := len(info.ForeignKeys); n == 0 || info.ForeignKeys[n-1].Name != name {
+			if refSchema != info.Database {
+				refTable = refSchema + "." + refTable
+			}
+			info.ForeignKeys = append(info.ForeignKeys, ForeignKeyInfo{Name: name, RefTable: refTable, OnUpdate: onUpdate, OnDelete: onDelete})
+		}
+		fk := &info.ForeignKeys[len(info.ForeignKeys)-1]
+		fk.Columns = append(fk.Columns, column)
+		fk.RefColumns = append(fk.RefColumns, refColumn)
+	}
+
+	return rows.Err()
+}
+
+// describeChecks reads the check constraints of a table
+func (m *MySQL) describeChecks(ctx context.Context, info *TableInfo) {
+	rows, err := m.conn.QueryContext(ctx, `SELECT cc.CONSTRAINT_NAME, cc.CHECK_CLAUSE
+		FROM information_schema.TABLE_CONSTRAINTS tc
+		JOIN information_schema.CHECK_CONSTRAINTS cc
+			ON cc.CONSTRAINT_SCHEMA = tc.CONSTRAINT_SCHEMA AND cc.CONSTRAINT_NAME = tc.CONSTRAINT_NAME
+		WHERE tc.TABLE_SCHEMA = ? AND tc.TABLE_NAME = ? AND tc.CONSTRAINT_TYPE = 'CHECK'
+		ORDER BY cc.CONSTRAINT_NAME`,
+		info.Database, info.Name)
+	if err != nil {
+		return
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var check CheckInfo
+		if err := rows.Scan(&check.Name, &check.Expression); err != nil {
+			return
+		}
+		info.Checks = append(info.Checks, check)
+	}
+}
+
+// describeTriggers reads the triggers of a table
+func (m *MySQL) describeTriggers(ctx context.Context, info *TableInfo) error {
+	rows, err := m.conn.QueryContext(ctx, `SELECT TRIGGER_NAME, ACTION_TIMING, EVENT_MANIPULATION, ACTION_STATEMENT
+		FROM information_schema.TRIGGERS WHERE EVENT_OBJECT_SCHEMA = ? AND EVENT_OBJECT_TABLE = ?
+		ORDER BY ACTION_TIMING, EVENT_MANIPULATION, ACTION_ORDER`,
+		info.Database, info.Name)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var trigger TriggerInfo
+		var statement string
+		if err := rows.Scan(&trigger.Name, &trigger.Timing, &trigger.Event, &statement); err != nil {
+			return err
+		}
+		trigger.Definition = fmt.Sprintf("CREATE TRIGGER %s %s %s ON %s FOR EACH ROW %s",
+			quoteIdentifier(DialectMySQL, trigger.Name), trigger.Timing, trigger.Event,
+			quoteIdentifier(DialectMySQL, info.Name), statement)
+		info.Triggers = append(info.Triggers, trigger)
+	}
+
+	return rows.Err()
+}
+
+// showCreateTable returns the CREATE TABLE statement followed by the triggers
+func (m *MySQL) showCreateTable(ctx context.Context, info *TableInfo) (string, error) {
+	query := fmt.Sprintf("SHOW CREATE TABLE %s.%s", quoteIdentifier(DialectMySQL, info.Database), quoteIdentifier(DialectMySQL, info.Name))
+
+	var name, ddl string
+	if err := m.conn.QueryRowContext(ctx, query).Scan(&name, &ddl); err != nil {
+		return "", err
+	}
+
+	script := joinStatements([]string{ddl})
+	if len(info.Triggers) == 0 {
+		return script, nil
+	}
+
+	// Trigger bodies may contain semicolons
+	var triggers strings.Builder
+	triggers.WriteString("\nDELIMITER ;;\n\n")
+	for _, trigger := range info.Triggers {
+		fmt.Fprintf(&triggers, "%s;;\n\n", trigger.Definition)
+	}
+	triggers.WriteString("DELIMITER ;\n")
+
+	return script + triggers.String(), nil
+}
+
+// DumpSchema reads the tables, triggers and views of a database. Names are
+// not qualified with the database, so the dump can be restored into another one.
+func (m *MySQL) DumpSchema(ctx context.Context, database string) (*DumpSchema, error) {
+	if m.conn == nil {
+		return nil, fmt.Errorf("not connected")
+	}
+
+	rows, err := m.conn.QueryContext(ctx, `SELECT TABLE_NAME, TABLE_TYPE = 'VIEW'
+		FROM information_schema.TABLES WHERE TABLE_SCHEMA = ? ORDER BY TABLE_NAME`, database)
+	if err != nil {
+		return nil, err
+	}
+	var tables, views []string
+	for rows.Next() {
+		var name string
+		var view bool
+		if err := rows.Scan(&name, &view); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		if view {
+			views = append(views, name)
+		} else {
+			tables = append(tables, name)
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	schema := &DumpSchema{}
+	var foreignKeys, triggers []string
+	for _, table := range tables {
+		info := &TableInfo{Database: database, Name: table}
+		if err := m.describeColumns(ctx, info); err != nil {
+			return nil, err
+		}
+		if err := m.describeTriggers(ctx, info); err != nil {
+			return nil, err
+		}
+
+		var name, ddl string
+		query := fmt.Sprintf("SHOW CREATE TABLE %s", QuoteTable(DialectMySQL, database, table))
+		if err := m.conn.QueryRowContext(ctx, query).Scan(&name, &ddl); err != nil {
+			return nil, err
+		}
+		create, keys := splitForeignKeys(ddl)
+		for _, key := range keys {
+			foreignKeys = append(foreignKeys, fmt.Sprintf("ALTER TABLE %s ADD %s", quoteIdentifier(DialectMySQL, table), key))
+		}
+		for _, trigger := range info.Triggers {
+			triggers = append(triggers, trigger.Definition)
+		}
+
+		// Generated columns cannot be inserted
+		var columns []string
+		for _, column := range info.Columns {
+			if !strings.HasSuffix(column.Extra, "GENERATED") || strings.HasPrefix(column.Extra, "DEFAULT_") {
+				columns = append(columns, quoteIdentifier(DialectMySQL, column.Name))
+			}
+		}
+		schema.Tables = append(schema.Tables, DumpTable{
+			Name:   table,
+			Create: create,
+			Select: fmt.Sprintf("SELECT %s FROM %s", strings.Join(columns, ", "), QuoteTable(DialectMySQL, database, table)),
+			Insert: fmt.Sprintf("INSERT INTO %s (%s) VALUES", quoteIdentifier(DialectMySQL, table), strings.Join(columns, ", ")),
+		})
+	}
+	schema.After = append(foreignKeys, triggers...)
+
+	definitions := make(map[string]string)
+	for _, view := range views {
+		var definition sql.NullString
+		err := m.conn.QueryRowContext(ctx, `SELECT VIEW_DEFINITION FROM information_schema.VIEWS
+			WHERE TABLE_SCHEMA = ? AND TABLE_NAME = ?`, database, view).Scan(&definition)
+		if err != nil {
+			return nil, err
+		}
+		// The server qualifies the selected columns with the database
+		definitions[view] = strings.ReplaceAll(definition.String, quoteIdentifier(DialectMySQL, database)+".", "")
+	}
+	for _, view := range orderViews(DialectMySQL, views, definitions) {
+		schema.After = append(schema.After, fmt.Sprintf("CREATE VIEW %s AS %s", quoteIdentifier(DialectMySQL, view), definitions[view]))
+	}
+
+	return schema, nil
+}
+
+// splitForeignKeys removes the foreign keys from the output of SHOW CREATE
+// TABLE, which has one column or constraint per line, and returns them
+func splitForeignKeys(ddl string) (string, []string) {
+	lines := strings.Split(ddl, "\n")
+	if len(lines) < 3 {
+		return ddl, nil
+	}
+
+	var body, keys []string
+	for _, line := range lines[1 : len(lines)-1] {
+		line = strings.TrimSuffix(strings.TrimSpace(line), ",")
+		if strings.HasPrefix(line, "CONSTRAINT ") && strings.Contains(line, " FOREIGN KEY ") {
+			keys = append(keys, line)
+			continue
+		}
+		body = append(body, "  "+line)
+	}
+	if len(keys) == 0 {
+		return ddl, nil
+	}
+	return lines[0] + "\n" + strings.Join(body, ",\n") + "\n" + lines[len(lines)-1], keys
+}
+
+// orderViews returns views so that each comes after the views its
+// definition mentions, views in a cycle keep their order
+func orderViews(dialect Dialect, views []string, definitions map[string]string) []string {
+	var ordered []string
+	added := make(map[string]bool)
+	visiting := make(map[string]bool)
+
+	var add func(view string)
+	add = func(view string) {
+		if added[view] || visiting[view] {
+			return
+		}
+		visiting[view] = true
+		for _, other := range views {
+			if other != view && strings.Contains(definitions[view], quoteIdentifier(dialect, other)) {
+				add(other)
+			}
+		}
+		visiting[view] = false
+		added[view] = true
+		ordered = append(ordered, view)
+	}
+
+	for _, view := range views {
+		add(view)
+	}
+	return ordered
+}
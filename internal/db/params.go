@@ -0,0 +1,126 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// Parameters returns the names of the :name placeholders of query in the
+// order they are first used
+func Parameters(dialect Dialect, query string) []string {
+	var names []string
+	scanParameters(dialect, query, func(name string) string {
+		for _, known := range names {
+			if known == name {
+				return ""
+			}
+		}
+		names = append(names, name)
+		return ""
+	})
+	return names
+}
+
+// BindParameters replaces the :name placeholders of query with the bind
+// placeholders of dialect, $1 for PostgreSQL and ? otherwise, and returns
+// the arguments taken from values. Placeholders in string literals, quoted
+// identifiers and comments and PostgreSQL casts (::type) are left alone.
+func BindParameters(dialect Dialect, query string, values map[string]any) (string, []any, error) {
+	var args []any
+	var missing []string
+	numbers := map[string]int{}
+
+	bound := scanParameters(dialect, query, func(name string) string {
+		value, ok := values[name]
+		if !ok {
+			missing = append(missing, name)
+			return ":" + name
+		}
+
+		// PostgreSQL refers to an argument by number, other drivers take
+		// one argument per placeholder
+		if dialect == DialectPostgres {
+			number, ok := numbers[name]
+			if !ok {
+				args = append(args, value)
+				number = len(args)
+				numbers[name] = number
+			}
+			return "$" + strconv.Itoa(number)
+		}
+		args = append(args, value)
+		return "?"
+	})
+
+	if len(missing) > 0 {
+		return "", nil, fmt.Errorf("no value for parameter :%s", strings.Join(missing, ", :"))
+	}
+	return bound, args, nil
+}
+
+// ParameterValue returns the argument bound for a value typed by the user:
+// whole numbers are bound as integers, everything else as text
+func ParameterValue(text string) any {
+	if n, err := strconv.ParseInt(text, 10, 64); err == nil && strconv.FormatInt(n, 10) == text {
+		return n
+	}
+	return text
+}
+
+// OpenStatement opens a statement of the editor with OpenQuery, binding the
+// values of its parameters if it has any
+func OpenStatement(ctx context.Context, driver Driver, statement Statement) (*QueryResult, error) {
+	if statement.Params == nil {
+		return driver.OpenQuery(ctx, statement.Text)
+	}
+
+	query, args, err := BindParameters(driver.GetDialect(), statement.Text, statement.Params)
+	if err != nil {
+		return nil, err
+	}
+	return driver.OpenQuery(ctx, query, args...)
+}
+
+// scanParameters returns query with each :name placeholder replaced by the
+// result of replace
+func scanParameters(dialect Dialect, query string, replace func(name string) string) string {
+	runes := []rune(query)
+	var out strings.Builder
+	copied := 0
+
+	for i := 0; i < len(runes); {
+		if next, kind := skipLexeme(dialect, runes, i); kind != lexemeNone {
+			i = next
+			continue
+		}
+
+		switch r := runes[i]; {
+		case r == ':' && i+1 < len(runes) && runes[i+1] == ':':
+			// PostgreSQL cast
+			i += 2
+		case r == ':' && i+1 < len(runes) && (unicode.IsLetter(runes[i+1]) || runes[i+1] == '_') &&
+			(i == 0 || !isWordRune(runes[i-1])):
+			end := i + 1
+			for end < len(runes) && isWordRune(runes[end]) {
+				end++
+			}
+			out.WriteString(string(runes[copied:i]))
+			out.WriteString(replace(string(runes[i+1 : end])))
+			copied = end
+			i = end
+		case isWordRune(r):
+			// Skip the rest of a word, e.g. "a:b" is not a placeholder
+			for i < len(runes) && isWordRune(runes[i]) {
+				i++
+			}
+		default:
+			i++
+		}
+	}
+	out.WriteString(string(runes[copied:]))
+
+	return out.String()
+}
@@ -0,0 +1,160 @@
+package db
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestBindParameters(t *testing.T) {
+	values := map[string]any{"id": int64(7), "name": "bob", "_x1": "y"}
+	tests := []struct {
+		name    string
+		dialect Dialect
+		query   string
+		want    string
+		args    []any
+	}{
+		{
+			name:    "no parameters",
+			dialect: DialectPostgres,
+			query:   "SELECT 1",
+			want:    "SELECT 1",
+		},
+		{
+			name:    "PostgreSQL numbers parameters",
+			dialect: DialectPostgres,
+			query:   "SELECT * FROM t WHERE id = :id AND name = :name",
+			want:    "SELECT * FROM t WHERE id = $1 AND name = $2",
+			args:    []any{int64(7), "bob"},
+		},
+		{
+			name:    "PostgreSQL reuses the number of a repeated parameter",
+			dialect: DialectPostgres,
+			query:   "SELECT :id, :name, :id",
+			want:    "SELECT $1, $2, $1",
+			args:    []any{int64(7), "bob"},
+		},
+		{
+			name:    "MySQL binds a repeated parameter twice",
+			dialect: DialectMySQL,
+			query:   "SELECT :id, :name, :id",
+			want:    "SELECT ?, ?, ?",
+			args:    []any{int64(7), "bob", int64(7)},
+		},
+		{
+			name:    "SQLite binds a repeated parameter twice",
+			dialect: DialectSQLite,
+			query:   "UPDATE t SET a = :id WHERE b = :id",
+			want:    "UPDATE t SET a = ? WHERE b = ?",
+			args:    []any{int64(7), int64(7)},
+		},
+		{
+			name:    "PostgreSQL casts",
+			dialect: DialectPostgres,
+			query:   "SELECT :id::text, now()::date, '1'::int, x::name",
+			want:    "SELECT $1::text, now()::date, '1'::int, x::name",
+			args:    []any{int64(7)},
+		},
+		{
+			name:    "string literals and quoted identifiers",
+			dialect: DialectPostgres,
+			query:   `SELECT ':id', E'\':id', "a:id" FROM t WHERE n = :name`,
+			want:    `SELECT ':id', E'\':id', "a:id" FROM t WHERE n = $1`,
+			args:    []any{"bob"},
+		},
+		{
+			name:    "MySQL strings and backticks",
+			dialect: DialectMySQL,
+			query:   "SELECT ':id', \"x\\\":id\", `:id` FROM t WHERE n = :name",
+			want:    "SELECT ':id', \"x\\\":id\", `:id` FROM t WHERE n = ?",
+			args:    []any{"bob"},
+		},
+		{
+			name:    "comments",
+			dialect: DialectPostgres,
+			query:   "SELECT :id -- :name\n/* :name /* :name */ :name */",
+			want:    "SELECT $1 -- :name\n/* :name /* :name */ :name */",
+			args:    []any{int64(7)},
+		},
+		{
+			name:    "MySQL hash comments",
+			dialect: DialectMySQL,
+			query:   "SELECT :id # :name",
+			want:    "SELECT ? # :name",
+			args:    []any{int64(7)},
+		},
+		{
+			name:    "dollar quotes",
+			dialect: DialectPostgres,
+			query:   "SELECT $$ :name $$, $fn$ :name $fn$, :id",
+			want:    "SELECT $$ :name $$, $fn$ :name $fn$, $1",
+			args:    []any{int64(7)},
+		},
+		{
+			name:    "names inside words and times",
+			dialect: DialectSQLite,
+			query:   "SELECT a:id, '12:30', :_x1",
+			want:    "SELECT a:id, '12:30', ?",
+			args:    []any{"y"},
+		},
+		{
+			name:    "SQLite brackets",
+			dialect: DialectSQLite,
+			query:   "SELECT [:id] FROM t WHERE id = :id",
+			want:    "SELECT [:id] FROM t WHERE id = ?",
+			args:    []any{int64(7)},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, args, err := BindParameters(tt.dialect, tt.query, values)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+			if !reflect.DeepEqual(args, tt.args) {
+				t.Errorf("got args %v, want %v", args, tt.args)
+			}
+		})
+	}
+}
+
+func TestBindParametersMissing(t *testing.T) {
+	_, _, err := BindParameters(DialectPostgres, "SELECT :id, :name, :other", map[string]any{"id": 1})
+	if err == nil {
+		t.Fatal("bound a query with missing values")
+	}
+	if want := "no value for parameter :name, :other"; !strings.Contains(err.Error(), want) {
+		t.Errorf("got %v, want %q", err, want)
+	}
+}
+
+func TestParameters(t *testing.T) {
+	got := Parameters(DialectPostgres, "SELECT :b, ':a', :a::int, :b -- :c")
+	if want := []string{"b", "a"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func TestParameterValue(t *testing.T) {
+	tests := []struct {
+		text string
+		want any
+	}{
+		{"42", int64(42)},
+		{"-1", int64(-1)},
+		{"007", "007"},
+		{"+1", "+1"},
+		{"1.5", "1.5"},
+		{"abc", "abc"},
+		{"", ""},
+	}
+	for _, tt := range tests {
+		if got := ParameterValue(tt.text); got != tt.want {
+			t.Errorf("%q: got %#v, want %#v", tt.text, got, tt.want)
+		}
+	}
+}
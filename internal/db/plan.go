@@ -0,0 +1,433 @@
+package db
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Plan is the execution plan of a statement as returned by EXPLAIN
+type Plan struct {
+	Nodes []*PlanNode // top level steps, PostgreSQL and MySQL have one
+	// Analyzed is true if the statement was run and the nodes have actual
+	// times and rows
+	Analyzed      bool
+	PlanningTime  float64 // milliseconds, PostgreSQL only
+	ExecutionTime float64 // milliseconds, set if Analyzed
+}
+
+// PlanNode is a step of a plan. Costs are in the units of the planner and
+// include the children, times are in milliseconds.
+type PlanNode struct {
+	Operation string // e.g. "Seq Scan", "Nested loop" or the SQLite plan line
+	Detail    string // relation and index, e.g. "on orders o"
+	// Properties are the conditions, keys and other details of the step
+	Properties []PlanProperty
+
+	HasCost bool
+	Cost    float64 // estimated cost
+	Rows    float64 // estimated rows
+
+	HasActual  bool
+	ActualTime float64 // time of all loops
+	ActualRows float64 // rows per loop
+	Loops      float64
+
+	Children []*PlanNode
+}
+
+// PlanProperty is a named detail of a plan node
+type PlanProperty struct {
+	Name  string
+	Value string
+}
+
+// SelfCost returns the estimated cost of the node without its children
+func (n *PlanNode) SelfCost() float64 {
+	cost := n.Cost
+	for _, child := range n.Children {
+		cost -= child.Cost
+	}
+	return max(cost, 0)
+}
+
+// SelfTime returns the actual time of the node without its children
+func (n *PlanNode) SelfTime() float64 {
+	time := n.ActualTime
+	for _, child := range n.Children {
+		time -= child.ActualTime
+	}
+	return max(time, 0)
+}
+
+// Walk calls fn for each node of the plan, parents before their children
+func (p *Plan) Walk(fn func(node *PlanNode)) {
+	var walk func(nodes []*PlanNode)
+	walk = func(nodes []*PlanNode) {
+		for _, node := range nodes {
+			fn(node)
+			walk(node.Children)
+		}
+	}
+	walk(p.Nodes)
+}
+
+// ExplainQuery returns the statement that explains query in dialect. Only
+// PostgreSQL runs the statement to analyze it.
+func ExplainQuery(dialect Dialect, query string, analyze bool) (string, error) {
+	if analyze && dialect != DialectPostgres {
+		return "", fmt.Errorf("EXPLAIN ANALYZE is only supported for PostgreSQL")
+	}
+
+	switch dialect {
+	case DialectPostgres:
+		if analyze {
+			return "EXPLAIN (ANALYZE, FORMAT JSON) " + query, nil
+		}
+		return "EXPLAIN (FORMAT JSON) " + query, nil
+	case DialectMySQL:
+		return "EXPLAIN FORMAT=JSON " + query, nil
+	default:
+		return "EXPLAIN QUERY PLAN " + query, nil
+	}
+}
+
+// Explain runs the EXPLAIN of a statement and parses its plan, the
+// parameters of the statement are bound as they are by OpenStatement
+func Explain(ctx context.Context, driver Driver, statement Statement, analyze bool) (*Plan, error) {
+	dialect := driver.GetDialect()
+	query, err := ExplainQuery(dialect, statement.Text, analyze)
+	if err != nil {
+		return nil, err
+	}
+	statement.Text = query
+
+	result, err := fetchAll(OpenStatement(ctx, driver, statement))
+	if err != nil {
+		return nil, err
+	}
+	if len(result.Rows) == 0 || len(result.Rows[0]) == 0 {
+		return nil, fmt.Errorf("EXPLAIN returned no plan")
+	}
+
+	var plan *Plan
+	switch dialect {
+	case DialectPostgres:
+		plan, err = parsePostgresPlan(result.Rows[0][0].Text)
+	case DialectMySQL:
+		plan, err = parseMySQLPlan(result.Rows[0][0].Text)
+	default:
+		plan, err = parseSQLitePlan(result)
+	}
+	if err != nil {
+		return nil, err
+	}
+	plan.Analyzed = analyze
+	return plan, nil
+}
+
+// postgresPlanProperties are the node keys shown as properties, in order
+var postgresPlanProperties = []string{
+	"Index Cond", "Recheck Cond", "Filter", "Join Filter", "Hash Cond", "Merge Cond", "Sort Key",
+	"Group Key", "Rows Removed by Filter", "Rows Removed by Index Recheck", "Rows Removed by Join Filter",
+	"Sort Method", "Sort Space Used", "Heap Fetches", "Workers Planned", "Workers Launched",
+	"Subplan Name", "Parent Relationship", "Startup Cost", "Actual Startup Time", "Output",
+}
+
+// parsePostgresPlan parses the output of EXPLAIN (FORMAT JSON)
+func parsePostgresPlan(text string) (*Plan, error) {
+	var explained []map[string]any
+	if err := json.Unmarshal([]byte(text), &explained); err != nil {
+		return nil, fmt.Errorf("failed to parse plan: %w", err)
+	}
+	if len(explained) == 0 {
+		return nil, fmt.Errorf("EXPLAIN returned no plan")
+	}
+
+	root, ok := explained[0]["Plan"].(map[string]any)
+	if !ok {
+		return nil, fmt.Errorf("failed to parse plan: no Plan node")
+	}
+	plan := &Plan{Nodes: []*PlanNode{postgresPlanNode(root)}}
+	plan.PlanningTime, _ = planNumber(explained[0]["Planning Time"])
+	plan.ExecutionTime, _ = planNumber(explained[0]["Execution Time"])
+	return plan, nil
+}
+
+// postgresPlanNode converts a node of a PostgreSQL JSON plan and its children
+func postgresPlanNode(object map[string]any) *PlanNode {
+	node := &PlanNode{Operation: planString(object["Node Type"])}
+
+	// Name the node as the text format does, e.g. "Hash Left Join"
+	switch strategy := planString(object["Strategy"]); {
+	case node.Operation == "Aggregate" && strategy == "Hashed":
+		node.Operation = "HashAggregate"
+	case node.Operation == "Aggregate" && strategy == "Sorted":
+		node.Operation = "GroupAggregate"
+	case node.Operation == "Aggregate" && strategy == "Mixed":
+		node.Operation = "MixedAggregate"
+	}
+	if join := planString(object["Join Type"]); join != "" && join != "Inner" {
+		if strings.HasSuffix(node.Operation, " Join") {
+			node.Operation = strings.TrimSuffix(node.Operation, "Join") + join + " Join"
+		} else {
+			node.Operation += " " + join + " Join"
+		}
+	}
+	if parallel, _ := object["Parallel Aware"].(bool); parallel {
+		node.Operation = "Parallel " + node.Operation
+	}
+
+	var detail []string
+	if index := planString(object["Index Name"]); index != "" {
+		detail = append(detail, "using "+index)
+	}
+	target := planString(object["Relation Name"])
+	if schema := planString(object["Schema"]); schema != "" && target != "" {
+		target = schema + "." + target
+	}
+	for _, key := range []string{"CTE Name", "Function Name"} {
+		if target == "" {
+			target = planString(object[key])
+		}
+	}
+	if target != "" {
+		if alias := planString(object["Alias"]); alias != "" && alias != planString(object["Relation Name"]) && alias != target {
+			target += " " + alias
+		}
+		detail = append(detail, "on "+target)
+	}
+	node.Detail = strings.Join(detail, " ")
+
+	for _, key := range postgresPlanProperties {
+		if value := planString(object[key]); value != "" {
+			node.Properties = append(node.Properties, PlanProperty{Name: key, Value: value})
+		}
+	}
+
+	node.Cost, node.HasCost = planNumber(object["Total Cost"])
+	node.Rows, _ = planNumber(object["Plan Rows"])
+	if loops, ok := planNumber(object["Actual Loops"]); ok {
+		node.HasActual = true
+		node.Loops = loops
+		node.ActualRows, _ = planNumber(object["Actual Rows"])
+		time, _ := planNumber(object["Actual Total Time"])
+		node.ActualTime = time * loops
+	}
+
+	children, _ := object["Plans"].([]any)
+	for _, child := range children {
+		if child, ok := child.(map[string]any); ok {
+			node.Children = append(node.Children, postgresPlanNode(child))
+		}
+	}
+	return node
+}
+
+// mysqlOperations name the operations of a MySQL JSON plan by their key
+var mysqlOperations = map[string]string{
+	"query_block":                "Query block",
+	"table":                      "Table",
+	"nested_loop":                "Nested loop",
+	"ordering_operation":         "Ordering",
+	"grouping_operation":         "Grouping",
+	"duplicates_removal":         "Duplicates removal",
+	"union_result":               "Union",
+	"materialized_from_subquery": "Materialized subquery",
+	"attached_subqueries":        "Attached subqueries",
+	"optimized_away_subqueries":  "Optimized away subqueries",
+	"windowing":                  "Windowing",
+	"buffer_result":              "Buffer result",
+}
+
+// mysqlNodeKeys are the keys shown in the operation or cost of a MySQL
+// node rather than as properties
+var mysqlNodeKeys = map[string]bool{
+	"select_id": true, "table_name": true, "access_type": true, "key": true, "cost_info": true,
+	"rows_produced_per_join": true, "rows_examined_per_scan": true,
+}
+
+// parseMySQLPlan parses the output of EXPLAIN FORMAT=JSON
+func parseMySQLPlan(text string) (*Plan, error) {
+	var explained map[string]any
+	if err := json.Unmarshal([]byte(text), &explained); err != nil {
+		return nil, fmt.Errorf("failed to parse plan: %w", err)
+	}
+
+	plan := &Plan{}
+	for _, key := range sortedKeys(explained) {
+		if object, ok := explained[key].(map[string]any); ok {
+			plan.Nodes = append(plan.Nodes, mysqlPlanNode(key, object))
+		}
+	}
+	if len(plan.Nodes) == 0 {
+		return nil, fmt.Errorf("EXPLAIN returned no plan")
+	}
+	return plan, nil
+}
+
+// mysqlPlanNode converts an operation of a MySQL JSON plan and the
+// operations nested in it
+func mysqlPlanNode(key string, object map[string]any) *PlanNode {
+	node := &PlanNode{Operation: mysqlOperations[key]}
+	if node.Operation == "" {
+		node.Operation = strings.ReplaceAll(key, "_", " ")
+	}
+
+	switch key {
+	case "query_block":
+		if id := planString(object["select_id"]); id != "" {
+			node.Operation += " #" + id
+		}
+	case "table":
+		node.Operation += " " + planString(object["table_name"])
+		var detail []string
+		if access := planString(object["access_type"]); access != "" {
+			detail = append(detail, access)
+		}
+		if index := planString(object["key"]); index != "" {
+			detail = append(detail, "using "+index)
+		}
+		node.Detail = strings.Join(detail, " ")
+	}
+
+	// Costs of the node itself, a query block has the total of its operations
+	var cost float64
+	total := false
+	if info, ok := object["cost_info"].(map[string]any); ok {
+		if queryCost, ok := planNumber(info["query_cost"]); ok {
+			cost, total, node.HasCost = queryCost, true, true
+		}
+		for _, name := range []string{"read_cost", "eval_cost", "sort_cost"} {
+			if value, ok := planNumber(info[name]); ok && !total {
+				cost += value
+				node.HasCost = true
+			}
+		}
+	}
+	if rows, ok := planNumber(object["rows_produced_per_join"]); ok {
+		node.Rows = rows
+	} else {
+		node.Rows, _ = planNumber(object["rows_examined_per_scan"])
+	}
+
+	for _, name := range sortedKeys(object) {
+		switch value := object[name].(type) {
+		case map[string]any:
+			if name != "cost_info" {
+				node.Children = append(node.Children, mysqlPlanNode(name, value))
+			}
+		case []any:
+			// Lists of operations, e.g. the tables of a nested loop, are
+			// children of a node named by the list unless it is unknown
+			parent := node
+			if _, ok := mysqlOperations[name]; ok {
+				parent = &PlanNode{Operation: mysqlOperations[name]}
+			}
+			var items []string
+			for _, item := range value {
+				if item, ok := item.(map[string]any); ok {
+					for _, itemKey := range sortedKeys(item) {
+						if child, ok := item[itemKey].(map[string]any); ok {
+							parent.Children = append(parent.Children, mysqlPlanNode(itemKey, child))
+						}
+					}
+					continue
+				}
+				items = append(items, planString(item))
+			}
+			if len(items) > 0 {
+				node.Properties = append(node.Properties, PlanProperty{Name: name, Value: strings.Join(items, ", ")})
+			}
+			if parent != node && len(parent.Children) > 0 {
+				parent.sumCosts()
+				node.Children = append(node.Children, parent)
+			}
+		default:
+			if !mysqlNodeKeys[name] {
+				node.Properties = append(node.Properties, PlanProperty{Name: name, Value: planString(value)})
+			}
+		}
+	}
+
+	node.Cost = cost
+	if !total {
+		node.sumCosts()
+	}
+	return node
+}
+
+// sumCosts adds the costs of the children to the cost of a MySQL node
+func (n *PlanNode) sumCosts() {
+	for _, child := range n.Children {
+		if child.HasCost {
+			n.Cost += child.Cost
+			n.HasCost = true
+		}
+	}
+}
+
+// parseSQLitePlan builds the plan tree from the id and parent columns of
+// EXPLAIN QUERY PLAN
+func parseSQLitePlan(result *QueryResult) (*Plan, error) {
+	if len(result.Columns) < 4 {
+		return nil, fmt.Errorf("failed to parse plan: expected 4 columns, got %d", len(result.Columns))
+	}
+
+	plan := &Plan{}
+	nodes := map[string]*PlanNode{}
+	for _, row := range result.Rows {
+		node := &PlanNode{Operation: row[3].Text}
+		nodes[row[0].Text] = node
+		if parent, ok := nodes[row[1].Text]; ok {
+			parent.Children = append(parent.Children, node)
+		} else {
+			plan.Nodes = append(plan.Nodes, node)
+		}
+	}
+	return plan, nil
+}
+
+// planString returns a JSON value of a plan as text, lists are joined with commas
+func planString(value any) string {
+	switch value := value.(type) {
+	case nil:
+		return ""
+	case string:
+		return value
+	case float64:
+		return strconv.FormatFloat(value, 'f', -1, 64)
+	case []any:
+		items := make([]string, len(value))
+		for i, item := range value {
+			items[i] = planString(item)
+		}
+		return strings.Join(items, ", ")
+	}
+	return fmt.Sprint(value)
+}
+
+// planNumber returns a number of a plan, MySQL writes costs as strings
+func planNumber(value any) (float64, bool) {
+	switch value := value.(type) {
+	case float64:
+		return value, true
+	case string:
+		number, err := strconv.ParseFloat(value, 64)
+		return number, err == nil
+	}
+	return 0, false
+}
+
+// sortedKeys returns the keys of a JSON object in order
+func sortedKeys(object map[string]any) []string {
+	keys := make([]string, 0, len(object))
+	for key := range object {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -1,15 +1,27 @@
 package db
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
+	"strings"
+	"sync"
 
-	_ "github.com/lib/pq"
+	"github.com/lib/pq"
 )
 
 // Postgres implements the Driver interface for PostgreSQL
 type Postgres struct {
-	conn *sql.DB
+	conn     *sql.DB
+	dsn      string
+	database string // connected database
+	// connMu guards conn, dsn and database, which UseDatabase swaps while
+	// other calls may run
+	connMu  sync.Mutex
+	closed  bool
+	mu      sync.Mutex
+	notices []string
+	txn     transaction
 }
 
 // NewPostgres creates a new PostgreSQL driver
@@ -17,38 +29,141 @@ func NewPostgres() *Postgres {
 	return &Postgres{}
 }
 
+func init() {
+	RegisterDriver(DriverInfo{
+		Name:        "PostgreSQL",
+		Aliases:     []string{"postgres", "pgsql", "pg"},
+		DefaultPort: "5432",
+		Preset:      ConnConfig{Host: "localhost", Username: "postgres", Database: "postgres"},
+		Shortcut:    'P',
+		New:         func() Driver { return NewPostgres() },
+		DSN:         PostgresDSN,
+	})
+}
+
 // Connect connects to PostgreSQL database
-func (p *Postgres) Connect(dsn string) error {
-	var err error
-	p.conn, err = sql.Open("postgres", dsn)
+func (p *Postgres) Connect(ctx context.Context, dsn string) error {
+	conn, database, err := p.open(ctx, dsn)
 	if err != nil {
-		return fmt.Errorf("failed to open PostgreSQL connection: %w", err)
+		return err
 	}
 
-	err = p.conn.Ping()
+	p.connMu.Lock()
+	defer p.connMu.Unlock()
+	p.conn, p.dsn, p.database = conn, dsn, database
+	return nil
+}
+
+// pool returns the connection pool of the connected database, nil before Connect
+func (p *Postgres) pool() *sql.DB {
+	p.connMu.Lock()
+	defer p.connMu.Unlock()
+	return p.conn
+}
+
+// open opens and checks a connection pool, returning the connected database
+func (p *Postgres) open(ctx context.Context, dsn string) (*sql.DB, string, error) {
+	connector, err := pq.NewConnector(dsn)
 	if err != nil {
-		return fmt.Errorf("failed to ping PostgreSQL: %w", err)
+		return nil, "", fmt.Errorf("failed to open PostgreSQL connection: %w", err)
+	}
+	conn := sql.OpenDB(pq.ConnectorWithNoticeHandler(connector, p.handleNotice))
+
+	var database string
+	if err := conn.QueryRowContext(ctx, "SELECT current_database()").Scan(&database); err != nil {
+		conn.Close()
+		return nil, "", fmt.Errorf("failed to ping PostgreSQL: %w", err)
 	}
 
+	return conn, database, nil
+}
+
+// CurrentDatabase returns the database the driver is connected to
+func (p *Postgres) CurrentDatabase() string {
+	p.connMu.Lock()
+	defer p.connMu.Unlock()
+	return p.database
+}
+
+// UseDatabase reconnects to another database of the server. A PostgreSQL
+// connection cannot change its database.
+func (p *Postgres) UseDatabase(ctx context.Context, database string) error {
+	p.connMu.Lock()
+	connected, current, dsn := p.conn != nil, p.database, p.dsn
+	p.connMu.Unlock()
+	if !connected {
+		return fmt.Errorf("not connected")
+	}
+	if database == current {
+		return nil
+	}
+	if _, ok := p.txn.open(); ok {
+		return errTransactionOpen
+	}
+
+	dsn, err := withDatabase(dsn, database)
+	if err != nil {
+		return err
+	}
+	conn, current, err := p.open(ctx, dsn)
+	if err != nil {
+		return err
+	}
+
+	p.connMu.Lock()
+	defer p.connMu.Unlock()
+	if p.closed {
+		conn.Close()
+		return fmt.Errorf("not connected")
+	}
+	p.conn.Close()
+	p.conn, p.dsn, p.database = conn, dsn, current
+	return nil
+}
+
+// checkDatabase returns an error unless database is empty or the connected database
+func (p *Postgres) checkDatabase(database string) error {
+	if current := p.CurrentDatabase(); database != "" && database != current {
+		return fmt.Errorf("connected to database %s, not %s", current, database)
+	}
 	return nil
 }
 
+// withDatabase returns a PostgreSQL DSN connecting to another database
+func withDatabase(dsn, database string) (string, error) {
+	// URLs are converted to key/value pairs
+	if strings.HasPrefix(dsn, "postgres://") || strings.HasPrefix(dsn, "postgresql://") {
+		var err error
+		if dsn, err = pq.ParseURL(dsn); err != nil {
+			return "", fmt.Errorf("invalid connection URL: %w", err)
+		}
+	}
+
+	// The last value of a repeated key wins
+	value := strings.NewReplacer(`\`, `\\`, `'`, `\'`).Replace(database)
+	return strings.TrimSpace(dsn + " dbname='" + value + "'"), nil
+}
+
 // Close closes the connection
 func (p *Postgres) Close() error {
+	p.txn.end(false)
+	p.connMu.Lock()
+	defer p.connMu.Unlock()
+	p.closed = true
 	if p.conn != nil {
 		return p.conn.Close()
 	}
 	return nil
 }
 
-// GetDatabases returns list of databases
-func (p *Postgres) GetDatabases() ([]string, error) {
-	if p.conn == nil {
+// GetDatabases returns list of databases, including the postgres maintenance database
+func (p *Postgres) GetDatabases(ctx context.Context) ([]string, error) {
+	if p.pool() == nil {
 		return nil, fmt.Errorf("not connected")
 	}
 
-	query := "SELECT datname FROM pg_database WHERE datistemplate = false"
-	rows, err := p.conn.Query(query)
+	query := "SELECT datname FROM pg_database WHERE datallowconn AND NOT datistemplate ORDER BY datname"
+	rows, err := p.pool().QueryContext(ctx, query)
 	if err != nil {
 		return nil, err
 	}
@@ -60,23 +175,27 @@ func (p *Postgres) GetDatabases() ([]string, error) {
 		if err := rows.Scan(&db); err != nil {
 			return nil, err
 		}
-		// Filter system databases
-		if db != "postgres" {
-			databases = append(databases, db)
-		}
+		databases = append(databases, db)
 	}
 
 	return databases, rows.Err()
 }
 
-// GetTables returns list of tables in a database
-func (p *Postgres) GetTables(database string) ([]string, error) {
-	if p.conn == nil {
+// GetTables returns list of tables in the user schemas of a database. Tables
+// outside the public schema are qualified with their schema.
+func (p *Postgres) GetTables(ctx context.Context, database string) ([]string, error) {
+	if p.pool() == nil {
 		return nil, fmt.Errorf("not connected")
 	}
+	if err := p.checkDatabase(database); err != nil {
+		return nil, err
+	}
 
-	query := "SELECT tablename FROM pg_catalog.pg_tables WHERE schemaname = 'public'"
-	rows, err := p.conn.Query(query)
+	query := `SELECT CASE WHEN schemaname = 'public' THEN tablename ELSE schemaname || '.' || tablename END
+		FROM pg_catalog.pg_tables
+		WHERE schemaname NOT IN ('pg_catalog', 'information_schema') AND schemaname NOT LIKE 'pg\_%'
+		ORDER BY schemaname <> 'public', schemaname, tablename`
+	rows, err := p.pool().QueryContext(ctx, query)
 	if err != nil {
 		return nil, err
 	}
@@ -95,66 +214,141 @@ func (p *Postgres) GetTables(database string) ([]string, error) {
 }
 
 // ExecuteQuery executes a SQL query
-func (p *Postgres) ExecuteQuery(query string) (*QueryResult, error) {
-	if p.conn == nil {
+func (p *Postgres) ExecuteQuery(ctx context.Context, query string) (*QueryResult, error) {
+	return fetchAll(p.OpenQuery(ctx, query))
+}
+
+// OpenQuery executes a SQL query and returns a cursor for its rows
+func (p *Postgres) OpenQuery(ctx context.Context, query string, args ...any) (*QueryResult, error) {
+	if p.pool() == nil {
 		return nil, fmt.Errorf("not connected")
 	}
 
-	result := &QueryResult{}
+	// Drop notices raised by earlier statements
+	p.takeNotices()
 
-	// Try as SELECT query first
-	rows, err := p.conn.Query(query)
-	if err != nil {
-		// Try as DML/DDL statement
-		res, execErr := p.conn.Exec(query)
-		if execErr != nil {
-			result.Error = execErr
-			return result, execErr
+	var result *QueryResult
+	var err error
+	if tx := p.txn.runner(); tx != nil {
+		result, err = p.txn.track(openStatement(ctx, tx, DialectPostgres, query, args...))
+	} else {
+		result, err = openStatement(ctx, p.pool(), DialectPostgres, query, args...)
+	}
+	if err != nil || result.Cursor == nil {
+		if result != nil {
+			result.Notices = p.takeNotices()
 		}
+		return result, err
+	}
+
+	// Notices may arrive while the rows are read
+	result.Cursor.onClose = p.takeNotices
+	return result, nil
+}
+
+// handleNotice collects a server notice
+func (p *Postgres) handleNotice(notice *pq.Error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.notices = append(p.notices, fmt.Sprintf("%s: %s", notice.Severity, notice.Message))
+}
+
+// takeNotices returns and clears the collected notices
+func (p *Postgres) takeNotices() []string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
 
-		affected, _ := res.RowsAffected()
-		result.RowsAffected = affected
-		return result, nil
+	notices := p.notices
+	p.notices = nil
+	return notices
+}
+
+// ExecTransaction runs statements in one transaction
+func (p *Postgres) ExecTransaction(ctx context.Context, statements []BoundStatement, check func(statement int, affected int64) error) error {
+	if tx := p.txn.runner(); tx != nil {
+		return execSavepoint(ctx, tx, statements, check)
 	}
-	defer rows.Close()
+	return execTransaction(ctx, p.pool(), statements, check)
+}
 
-	// Get column names
-	columns, err := rows.Columns()
-	if err != nil {
-		result.Error = err
-		return result, err
+// Begin pins a connection and starts a transaction on it
+func (p *Postgres) Begin(ctx context.Context, isolation sql.IsolationLevel) error {
+	return p.txn.begin(ctx, p.pool(), isolation)
+}
+
+// Commit commits the open transaction
+func (p *Postgres) Commit() error {
+	return p.txn.end(true)
+}
+
+// Rollback rolls the open transaction back
+func (p *Postgres) Rollback() error {
+	return p.txn.end(false)
+}
+
+// Transaction returns the open transaction
+func (p *Postgres) Transaction() (TransactionInfo, bool) {
+	return p.txn.open()
+}
+
+// RunScript runs statements one after another on one connection to the connected database
+func (p *Postgres) RunScript(ctx context.Context, database string, statements []Statement, progress func(done int)) error {
+	if err := p.checkDatabase(database); err != nil {
+		return err
+	}
+	if _, ok := p.txn.open(); ok {
+		return errTransactionOpen
 	}
-	result.Columns = columns
+	return runScript(ctx, p.pool(), nil, statements, progress)
+}
 
-	// Get rows
-	for rows.Next() {
-		values := make([]interface{}, len(columns))
-		valuePtrs := make([]interface{}, len(columns))
-		for i := range values {
-			valuePtrs[i] = &values[i]
-		}
+// ImportRows loads rows with COPY FROM STDIN
+func (p *Postgres) ImportRows(ctx context.Context, target ImportTarget, rows [][]Value) error {
+	if p.pool() == nil {
+		return fmt.Errorf("not connected")
+	}
+	if _, ok := p.txn.open(); ok {
+		return errTransactionOpen
+	}
 
-		if err := rows.Scan(valuePtrs...); err != nil {
-			result.Error = err
-			return result, err
-		}
+	tx, err := p.pool().BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
 
-		row := make([]string, len(columns))
-		for i, val := range values {
-			if val == nil {
-				row[i] = "NULL"
-			} else {
-				row[i] = fmt.Sprintf("%v", val)
-			}
+	stmt, err := tx.PrepareContext(ctx, pq.CopyInSchema(target.Schema, target.Table, target.Columns...))
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	for _, row := range rows {
+		if _, err := stmt.ExecContext(ctx, target.args(row)...); err != nil {
+			stmt.Close()
+			tx.Rollback()
+			return err
 		}
-		result.Rows = append(result.Rows, row)
+	}
+	// The rows are sent and checked when the statement is flushed
+	if _, err := stmt.ExecContext(ctx); err != nil {
+		stmt.Close()
+		tx.Rollback()
+		return err
+	}
+	if err := stmt.Close(); err != nil {
+		tx.Rollback()
+		return err
 	}
 
-	result.RowsAffected = int64(len(result.Rows))
-	return result, rows.Err()
+	return tx.Commit()
 }
 
 // GetDriverName returns the driver name
 func (p *Postgres) GetDriverName() string {
 	return "PostgreSQL"
 }
+
+// GetDialect returns the SQL dialect
+func (p *Postgres) GetDialect() Dialect {
+	return DialectPostgres
+}
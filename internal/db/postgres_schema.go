@@ -0,0 +1,502 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+
+	"github.com/lib/pq"
+)
+
+// DescribeTable returns the structure of a table in a schema of the connected database
+func (p *Postgres) DescribeTable(ctx context.Context, database, schema, table string) (*TableInfo, error) {
+	if p.pool() == nil {
+		return nil, fmt.Errorf("not connected")
+	}
+
+	if err := p.checkDatabase(database); err != nil {
+		return nil, err
+	}
+
+	if schema == "" {
+		schema = "public"
+	}
+	info, constraints, indexes, err := p.describeTable(ctx, database, schema, table)
+	if err != nil {
+		return nil, err
+	}
+
+	definitions := make([]string, len(constraints))
+	for i, constraint := range constraints {
+		definitions[i] = constraint.definition
+	}
+	info.DDL = p.createTable(info, definitions, indexes)
+	return info, nil
+}
+
+// pgConstraint is a constraint definition of a table
+type pgConstraint struct {
+	kind       string // p, u, f, c or x as in pg_constraint.contype
+	definition string // CONSTRAINT name ... as written in CREATE TABLE
+}
+
+// describeTable reads the structure of a table, its constraint definitions
+// and the CREATE INDEX statements of indexes without a constraint
+func (p *Postgres) describeTable(ctx context.Context, database, schema, table string) (*TableInfo, []pgConstraint, []string, error) {
+	info := &TableInfo{Database: database, Schema: schema, Name: table}
+
+	var oid uint32
+	err := p.pool().QueryRowContext(ctx, `SELECT c.oid FROM pg_catalog.pg_class c
+		JOIN pg_catalog.pg_namespace n ON n.oid = c.relnamespace
+		WHERE n.nspname = $1 AND c.relname = $2 AND c.relkind IN ('r', 'p', 'f')`, schema, table).Scan(&oid)
+	if err == sql.ErrNoRows {
+		return nil, nil, nil, fmt.Errorf("table %s not found", info.QualifiedName())
+	}
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	if err := p.describeColumns(ctx, oid, info); err != nil {
+		return nil, nil, nil, err
+	}
+	constraints, err := p.describeConstraints(ctx, oid, info)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	indexes, err := p.describeIndexes(ctx, oid, info)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	if err := p.describeTriggers(ctx, oid, info); err != nil {
+		return nil, nil, nil, err
+	}
+
+	return info, constraints, indexes, nil
+}
+
+// describeColumns reads the columns of a table
+func (p *Postgres) describeColumns(ctx context.Context, oid uint32, info *TableInfo) error {
+	rows, err := p.pool().QueryContext(ctx, `SELECT a.attname, pg_catalog.format_type(a.atttypid, a.atttypmod), NOT a.attnotnull,
+			pg_catalog.pg_get_expr(d.adbin, d.adrelid),
+			CASE a.attidentity WHEN 'a' THEN 'GENERATED ALWAYS AS IDENTITY' WHEN 'd' THEN 'GENERATED BY DEFAULT AS IDENTITY' ELSE '' END,
+			COALESCE(pg_catalog.col_description(a.attrelid, a.attnum), '')
+		FROM pg_catalog.pg_attribute a
+		LEFT JOIN pg_catalog.pg_attrdef d ON d.adrelid = a.attrelid AND d.adnum = a.attnum
+		WHERE a.attrelid = $1 AND a.attnum > 0 AND NOT a.attisdropped
+		ORDER BY a.attnum`, oid)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var column ColumnInfo
+		var defaultValue sql.NullString
+		if err := rows.Scan(&column.Name, &column.Type, &column.Nullable, &defaultValue, &column.Extra, &column.Comment); err != nil {
+			return err
+		}
+		column.Default, column.HasDefault = defaultValue.String, defaultValue.Valid
+		info.Columns = append(info.Columns, column)
+	}
+
+	return rows.Err()
+}
+
+// describeConstraints reads the primary key, foreign keys and checks of a
+// table and returns all constraint definitions for the DDL
+func (p *Postgres) describeConstraints(ctx context.Context, oid uint32, info *TableInfo) ([]pgConstraint, error) {
+	rows, err := p.pool().QueryContext(ctx, `SELECT c.conname, c.contype, pg_catalog.pg_get_constraintdef(c.oid, true),
+			ARRAY(SELECT a.attname::text FROM unnest(c.conkey) WITH ORDINALITY k(attnum, n)
+				JOIN pg_catalog.pg_attribute a ON a.attrelid = c.conrelid AND a.attnum = k.attnum ORDER BY k.n),
+			CASE WHEN c.confrelid = 0 THEN '' ELSE c.confrelid::regclass::text END,
+			ARRAY(SELECT a.attname::text FROM unnest(c.confkey) WITH ORDINALITY k(attnum, n)
+				JOIN pg_catalog.pg_attribute a ON a.attrelid = c.confrelid AND a.attnum = k.attnum ORDER BY k.n),
+			c.confupdtype, c.confdeltype
+		FROM pg_catalog.pg_constraint c
+		WHERE c.conrelid = $1 AND c.contype <> 'n'
+		ORDER BY CASE c.contype WHEN 'p' THEN 0 WHEN 'u' THEN 1 WHEN 'f' THEN 2 ELSE 3 END, c.conname`, oid)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var constraints []pgConstraint
+	for rows.Next() {
+		var name, kind, definition, refTable string
+		var columns, refColumns []string
+		var onUpdate, onDelete sql.NullString
+		if err := rows.Scan(&name, &kind, &definition, pq.Array(&columns), &refTable, pq.Array(&refColumns), &onUpdate, &onDelete); err != nil {
+			return nil, err
+		}
+		constraints = append(constraints, pgConstraint{
+			kind:       kind,
+			definition: fmt.Sprintf("CONSTRAINT %s %s", quoteIdentifier(DialectPostgres, name), definition),
+		})
+
+		switch kind {
+		case "p":
+			info.PrimaryKey = columns
+		case "f":
+			info.ForeignKeys = append(info.ForeignKeys, ForeignKeyInfo{
+				Name:       name,
+				Columns:    columns,
+				RefTable:   refTable,
+				RefColumns: refColumns,
+				OnUpdate:   referentialAction(onUpdate.String),
+				OnDelete:   referentialAction(onDelete.String),
+			})
+		case "c":
+			info.Checks = append(info.Checks, CheckInfo{Name: name, Expression: checkExpression(definition)})
+		}
+	}
+
+	return constraints, rows.Err()
+}
+
+// checkExpression returns the expression of a "CHECK (...)" constraint definition
+func checkExpression(definition string) string {
+	expression := strings.TrimPrefix(definition, "CHECK ")
+	if strings.HasPrefix(expression, "(") && strings.HasSuffix(expression, ")") {
+		return expression[1 : len(expression)-1]
+	}
+	return expression
+}
+
+// describeIndexes reads the indexes of a table and returns the CREATE INDEX
+// statements of indexes that do not belong to a constraint
+func (p *Postgres) describeIndexes(ctx context.Context, oid uint32, info *TableInfo) ([]string, error) {
+	rows, err := p.pool().QueryContext(ctx, `SELECT i.relname, x.indisunique, x.indisprimary, pg_catalog.pg_get_indexdef(x.indexrelid),
+			ARRAY(SELECT pg_catalog.pg_get_indexdef(x.indexrelid, k, true) FROM generate_series(1, x.indnatts) k ORDER BY k),
+			EXISTS (SELECT 1 FROM pg_catalog.pg_constraint c WHERE c.conindid = x.indexrelid AND c.conrelid = x.indrelid)
+		FROM pg_catalog.pg_index x
+		JOIN pg_catalog.pg_class i ON i.oid = x.indexrelid
+		WHERE x.indrelid = $1
+		ORDER BY x.indisprimary DESC, i.relname`, oid)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var statements []string
+	for rows.Next() {
+		var index IndexInfo
+		if err := rows.Scan(&index.Name, &index.Unique, &index.Primary, &index.Definition, pq.Array(&index.Columns), &index.Constraint); err != nil {
+			return nil, err
+		}
+		info.Indexes = append(info.Indexes, index)
+
+		if !index.Constraint {
+			statements = append(statements, index.Definition)
+		}
+	}
+
+	return statements, rows.Err()
+}
+
+// describeTriggers reads the user defined triggers of a table
+func (p *Postgres) describeTriggers(ctx context.Context, oid uint32, info *TableInfo) error {
+	rows, err := p.pool().QueryContext(ctx, `SELECT t.tgname, pg_catalog.pg_get_triggerdef(t.oid, true)
+		FROM pg_catalog.pg_trigger t
+		WHERE t.tgrelid = $1 AND NOT t.tgisinternal
+		ORDER BY t.tgname`, oid)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var trigger TriggerInfo
+		if err := rows.Scan(&trigger.Name, &trigger.Definition); err != nil {
+			return err
+		}
+		trigger.Timing, trigger.Event = triggerEvent(DialectPostgres, trigger.Definition)
+		info.Triggers = append(info.Triggers, trigger)
+	}
+
+	return rows.Err()
+}
+
+// createTable builds the CREATE TABLE statement of a table, followed by its
+// indexes and triggers. PostgreSQL has no function returning it.
+func (p *Postgres) createTable(info *TableInfo, constraints, indexes []string) string {
+	statements := []string{createTableStatement(info, constraints)}
+	statements = append(statements, indexes...)
+	for _, trigger := range info.Triggers {
+		statements = append(statements, trigger.Definition)
+	}
+
+	return joinStatements(statements)
+}
+
+// createTableStatement returns the CREATE TABLE statement of a table with constraints
+func createTableStatement(info *TableInfo, constraints []string) string {
+	var lines []string
+	for _, column := range info.Columns {
+		line := fmt.Sprintf("    %s %s", quoteIdentifier(DialectPostgres, column.Name), column.Type)
+		if column.Extra != "" {
+			line += " " + column.Extra
+		} else if column.HasDefault {
+			line += " DEFAULT " + column.Default
+		}
+		if !column.Nullable {
+			line += " NOT NULL"
+		}
+		lines = append(lines, line)
+	}
+	for _, constraint := range constraints {
+		lines = append(lines, "    "+constraint)
+	}
+
+	name := QuoteTable(DialectPostgres, info.Schema, info.Name)
+	return fmt.Sprintf("CREATE TABLE %s (\n%s\n)", name, strings.Join(lines, ",\n"))
+}
+
+// GetSchemas returns the schemas of the connected database
+func (p *Postgres) GetSchemas(ctx context.Context) ([]string, error) {
+	if p.pool() == nil {
+		return nil, fmt.Errorf("not connected")
+	}
+
+	rows, err := p.pool().QueryContext(ctx, "SELECT nspname FROM pg_catalog.pg_namespace ORDER BY nspname")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var schemas []string
+	for rows.Next() {
+		var schema string
+		if err := rows.Scan(&schema); err != nil {
+			return nil, err
+		}
+		schemas = append(schemas, schema)
+	}
+
+	return schemas, rows.Err()
+}
+
+// GetSchemaObjects returns the tables, views, materialized views, functions,
+// sequences and enum types of a schema
+func (p *Postgres) GetSchemaObjects(ctx context.Context, schema string) (*SchemaObjects, error) {
+	if p.pool() == nil {
+		return nil, fmt.Errorf("not connected")
+	}
+
+	objects := &SchemaObjects{}
+	groups := map[string]*[]string{
+		"r": &objects.Tables,
+		"p": &objects.Tables,
+		"f": &objects.Tables,
+		"v": &objects.Views,
+		"m": &objects.MaterializedViews,
+		"S": &objects.Sequences,
+		"F": &objects.Functions,
+		"E": &objects.EnumTypes,
+	}
+
+	// Partitions are listed with their parent table only
+	rows, err := p.pool().QueryContext(ctx, `SELECT c.relkind::text, c.relname
+		FROM pg_catalog.pg_class c
+		JOIN pg_catalog.pg_namespace n ON n.oid = c.relnamespace
+		WHERE n.nspname = $1 AND c.relkind IN ('r', 'p', 'f', 'v', 'm', 'S') AND NOT c.relispartition
+		UNION ALL
+		SELECT 'F', f.proname || '(' || pg_catalog.pg_get_function_identity_arguments(f.oid) || ')'
+		FROM pg_catalog.pg_proc f
+		JOIN pg_catalog.pg_namespace n ON n.oid = f.pronamespace
+		WHERE n.nspname = $1 AND f.prokind IN ('f', 'p')
+		UNION ALL
+		SELECT 'E', t.typname
+		FROM pg_catalog.pg_type t
+		JOIN pg_catalog.pg_namespace n ON n.oid = t.typnamespace
+		WHERE n.nspname = $1 AND t.typtype = 'e'
+		ORDER BY 2`, schema)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var kind, name string
+		if err := rows.Scan(&kind, &name); err != nil {
+			return nil, err
+		}
+		if group, ok := groups[kind]; ok {
+			*group = append(*group, name)
+		}
+	}
+
+	return objects, rows.Err()
+}
+
+// pgUserObjects returns the condition limiting a catalog query to objects
+// of user schemas that do not belong to an extension. n is pg_namespace and
+// oid the column with the object id.
+func pgUserObjects(oid string) string {
+	return `n.nspname NOT IN ('pg_catalog', 'information_schema') AND n.nspname NOT LIKE 'pg\_%'
+		AND NOT EXISTS (SELECT 1 FROM pg_catalog.pg_depend e WHERE e.objid = ` + oid + ` AND e.deptype = 'e')`
+}
+
+// DumpSchema reads the extensions, schemas, enum types, sequences,
+// functions, tables and views of the connected database
+func (p *Postgres) DumpSchema(ctx context.Context, database string) (*DumpSchema, error) {
+	if p.pool() == nil {
+		return nil, fmt.Errorf("not connected")
+	}
+	if err := p.checkDatabase(database); err != nil {
+		return nil, err
+	}
+
+	// Function bodies may refer to tables created later
+	schema := &DumpSchema{Before: []string{"SET check_function_bodies = false"}}
+	queries := []struct {
+		query string
+		add   func(row []string)
+	}{
+		{`SELECT extname FROM pg_catalog.pg_extension WHERE extname <> 'plpgsql' ORDER BY oid`, func(row []string) {
+			schema.Before = append(schema.Before, "CREATE EXTENSION IF NOT EXISTS "+quoteIdentifier(DialectPostgres, row[0]))
+		}},
+		{`SELECT n.nspname FROM pg_catalog.pg_namespace n
+			WHERE n.nspname <> 'public' AND ` + pgUserObjects("n.oid") + ` ORDER BY n.nspname`, func(row []string) {
+			schema.Before = append(schema.Before, "CREATE SCHEMA IF NOT EXISTS "+quoteIdentifier(DialectPostgres, row[0]))
+		}},
+		{`SELECT n.nspname, t.typname,
+				(SELECT string_agg(quote_literal(e.enumlabel), ', ' ORDER BY e.enumsortorder) FROM pg_catalog.pg_enum e WHERE e.enumtypid = t.oid)
+			FROM pg_catalog.pg_type t
+			JOIN pg_catalog.pg_namespace n ON n.oid = t.typnamespace
+			WHERE t.typtype = 'e' AND ` + pgUserObjects("t.oid") + ` ORDER BY t.oid`, func(row []string) {
+			schema.Before = append(schema.Before, fmt.Sprintf("CREATE TYPE %s AS ENUM (%s)", QuoteTable(DialectPostgres, row[0], row[1]), row[2]))
+		}},
+		// Identity columns create their own sequences
+		{`SELECT n.nspname, c.relname, pg_catalog.format_type(s.seqtypid, NULL), s.seqincrement, s.seqmin, s.seqmax, s.seqstart,
+				CASE WHEN s.seqcycle THEN ' CYCLE' ELSE '' END
+			FROM pg_catalog.pg_sequence s
+			JOIN pg_catalog.pg_class c ON c.oid = s.seqrelid
+			JOIN pg_catalog.pg_namespace n ON n.oid = c.relnamespace
+			WHERE ` + pgUserObjects("c.oid") + `
+				AND NOT EXISTS (SELECT 1 FROM pg_catalog.pg_depend d WHERE d.objid = c.oid AND d.deptype = 'i')
+			ORDER BY c.oid`, func(row []string) {
+			schema.Before = append(schema.Before, fmt.Sprintf("CREATE SEQUENCE %s AS %s INCREMENT BY %s MINVALUE %s MAXVALUE %s START WITH %s%s",
+				QuoteTable(DialectPostgres, row[0], row[1]), row[2], row[3], row[4], row[5], row[6], row[7]))
+		}},
+		{`SELECT pg_catalog.pg_get_functiondef(f.oid)
+			FROM pg_catalog.pg_proc f
+			JOIN pg_catalog.pg_namespace n ON n.oid = f.pronamespace
+			WHERE f.prokind IN ('f', 'p') AND ` + pgUserObjects("f.oid") + ` ORDER BY f.oid`, func(row []string) {
+			schema.Before = append(schema.Before, row[0])
+		}},
+		{`SELECT pg_catalog.quote_literal(quote_ident(s.schemaname) || '.' || quote_ident(s.sequencename)), s.last_value
+			FROM pg_catalog.pg_sequences s
+			WHERE s.last_value IS NOT NULL AND s.schemaname NOT IN ('pg_catalog', 'information_schema')
+			ORDER BY s.schemaname, s.sequencename`, func(row []string) {
+			schema.After = append(schema.After, fmt.Sprintf("SELECT pg_catalog.setval(%s, %s)", row[0], row[1]))
+		}},
+	}
+	for _, query := range queries {
+		if err := p.queryStrings(ctx, query.query, query.add); err != nil {
+			return nil, err
+		}
+	}
+
+	var tables [][]string
+	err := p.queryStrings(ctx, `SELECT n.nspname, c.relname
+		FROM pg_catalog.pg_class c
+		JOIN pg_catalog.pg_namespace n ON n.oid = c.relnamespace
+		WHERE c.relkind IN ('r', 'p') AND NOT c.relispartition AND `+pgUserObjects("c.oid")+`
+		ORDER BY n.nspname, c.relname`, func(row []string) {
+		tables = append(tables, row)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var foreignKeys, indexes, triggers []string
+	for _, table := range tables {
+		info, constraints, tableIndexes, err := p.describeTable(ctx, database, table[0], table[1])
+		if err != nil {
+			return nil, err
+		}
+		name := QuoteTable(DialectPostgres, info.Schema, info.Name)
+
+		// Foreign keys are added once all rows are loaded
+		var definitions []string
+		for _, constraint := range constraints {
+			if constraint.kind == "f" {
+				foreignKeys = append(foreignKeys, fmt.Sprintf("ALTER TABLE %s ADD %s", name, constraint.definition))
+				continue
+			}
+			definitions = append(definitions, constraint.definition)
+		}
+		indexes = append(indexes, tableIndexes...)
+		for _, trigger := range info.Triggers {
+			triggers = append(triggers, trigger.Definition)
+		}
+
+		columns := make([]string, len(info.Columns))
+		override := ""
+		for i, column := range info.Columns {
+			columns[i] = quoteIdentifier(DialectPostgres, column.Name)
+			if strings.HasPrefix(column.Extra, "GENERATED ALWAYS") {
+				override = " OVERRIDING SYSTEM VALUE"
+			}
+		}
+		schema.Tables = append(schema.Tables, DumpTable{
+			Name:   info.QualifiedName(),
+			Create: createTableStatement(info, definitions),
+			Select: fmt.Sprintf("SELECT %s FROM %s", strings.Join(columns, ", "), name),
+			Insert: fmt.Sprintf("INSERT INTO %s (%s)%s VALUES", name, strings.Join(columns, ", "), override),
+		})
+	}
+	schema.After = append(schema.After, foreignKeys...)
+	schema.After = append(schema.After, indexes...)
+	schema.After = append(schema.After, triggers...)
+
+	// Views are created in the order they were defined, after what they select from
+	err = p.queryStrings(ctx, `SELECT n.nspname, c.relname, c.relkind::text, pg_catalog.pg_get_viewdef(c.oid, true)
+		FROM pg_catalog.pg_class c
+		JOIN pg_catalog.pg_namespace n ON n.oid = c.relnamespace
+		WHERE c.relkind IN ('v', 'm') AND `+pgUserObjects("c.oid")+`
+		ORDER BY c.oid`, func(row []string) {
+		kind := "VIEW"
+		if row[2] == "m" {
+			kind = "MATERIALIZED VIEW"
+		}
+		definition := strings.TrimRight(strings.TrimSpace(row[3]), ";")
+		schema.After = append(schema.After, fmt.Sprintf("CREATE %s %s AS\n%s", kind, QuoteTable(DialectPostgres, row[0], row[1]), definition))
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return schema, nil
+}
+
+// queryStrings runs a catalog query and calls add with the text of each row
+func (p *Postgres) queryStrings(ctx context.Context, query string, add func(row []string)) error {
+	rows, err := p.pool().QueryContext(ctx, query)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	columns, err := rows.Columns()
+	if err != nil {
+		return err
+	}
+	for rows.Next() {
+		values := make([]sql.NullString, len(columns))
+		targets := make([]interface{}, len(values))
+		for i := range values {
+			targets[i] = &values[i]
+		}
+		if err := rows.Scan(targets...); err != nil {
+			return err
+		}
+		row := make([]string, len(values))
+		for i, value := range values {
+			row[i] = value.String
+		}
+		add(row)
+	}
+	return rows.Err()
+}
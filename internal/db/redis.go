@@ -0,0 +1,483 @@
+package db
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	redisDefaultPort = "6379"
+	redisTimeout     = 10 * time.Second
+	// redisFieldLimit caps the number of collection elements loaded per key
+	redisFieldLimit = 500
+)
+
+// Redis implements the KVDriver interface for Redis
+type Redis struct {
+	conn     *respConn
+	database int
+}
+
+// NewRedis creates a new Redis driver
+func NewRedis() *Redis {
+	return &Redis{}
+}
+
+// Connect connects to Redis, dsn format: redis://[user:password@]host:port[/db]
+func (r *Redis) Connect(dsn string) error {
+	if !strings.Contains(dsn, "://") {
+		dsn = "redis://" + dsn
+	}
+
+	u, err := url.Parse(dsn)
+	if err != nil {
+		return fmt.Errorf("invalid Redis DSN: %w", err)
+	}
+
+	host := u.Hostname()
+	if host == "" {
+		host = "localhost"
+	}
+	port := u.Port()
+	if port == "" {
+		port = redisDefaultPort
+	}
+
+	database := 0
+	if path := strings.Trim(u.Path, "/"); path != "" {
+		database, err = strconv.Atoi(path)
+		if err != nil {
+			return fmt.Errorf("invalid Redis database index: %s", path)
+		}
+	}
+
+	conn, err := dialRESP(net.JoinHostPort(host, port), redisTimeout)
+	if err != nil {
+		return fmt.Errorf("failed to connect to Redis: %w", err)
+	}
+
+	if u.User != nil {
+		password, hasPassword := u.User.Password()
+		username := u.User.Username()
+		var authErr error
+		switch {
+		case hasPassword && username != "":
+			_, authErr = conn.Do("AUTH", username, password)
+		case hasPassword:
+			_, authErr = conn.Do("AUTH", password)
+		case username != "":
+			// Password given without user part, e.g. redis://secret@host
+			_, authErr = conn.Do("AUTH", username)
+		}
+		if authErr != nil {
+			conn.Close()
+			return fmt.Errorf("failed to authenticate to Redis: %w", authErr)
+		}
+	}
+
+	if database != 0 {
+		if _, err := conn.Do("SELECT", strconv.Itoa(database)); err != nil {
+			conn.Close()
+			return fmt.Errorf("failed to select Redis database %d: %w", database, err)
+		}
+	}
+
+	if _, err := conn.Do("PING"); err != nil {
+		conn.Close()
+		return fmt.Errorf("failed to ping Redis: %w", err)
+	}
+
+	r.conn = conn
+	r.database = database
+	return nil
+}
+
+// Close closes the connection
+func (r *Redis) Close() error {
+	if r.conn != nil {
+		err := r.conn.Close()
+		r.conn = nil
+		return err
+	}
+	return nil
+}
+
+// ScanKeys returns one SCAN page of keys matching pattern
+func (r *Redis) ScanKeys(cursor, pattern string, count int) (*KVScanResult, error) {
+	if r.conn == nil {
+		return nil, fmt.Errorf("not connected")
+	}
+
+	if cursor == "" {
+		cursor = "0"
+	}
+	if pattern == "" {
+		pattern = "*"
+	}
+	if count <= 0 {
+		count = 100
+	}
+
+	reply, err := r.conn.Do("SCAN", cursor, "MATCH", pattern, "COUNT", strconv.Itoa(count))
+	if err != nil {
+		return nil, err
+	}
+
+	items, ok := reply.([]interface{})
+	if !ok || len(items) != 2 {
+		return nil, fmt.Errorf("unexpected SCAN reply")
+	}
+
+	keys, err := respStrings(items[1])
+	if err != nil {
+		return nil, err
+	}
+
+	return &KVScanResult{Keys: keys, Cursor: respString(items[0])}, nil
+}
+
+// GetEntry returns type, TTL and value of a key
+func (r *Redis) GetEntry(key string) (*KVEntry, error) {
+	if r.conn == nil {
+		return nil, fmt.Errorf("not connected")
+	}
+
+	reply, err := r.conn.Do("TYPE", key)
+	if err != nil {
+		return nil, err
+	}
+	keyType := respString(reply)
+	if keyType == "none" {
+		return nil, fmt.Errorf("key not found: %s", key)
+	}
+
+	entry := &KVEntry{Key: key, Type: keyType}
+
+	reply, err = r.conn.Do("PTTL", key)
+	if err != nil {
+		return nil, err
+	}
+	ttl, err := respInt(reply)
+	if err != nil {
+		return nil, err
+	}
+	if ttl < 0 {
+		entry.TTL = KVNoExpiry
+	} else {
+		entry.TTL = time.Duration(ttl) * time.Millisecond
+	}
+
+	switch keyType {
+	case KVTypeString:
+		err = r.loadString(entry)
+	case KVTypeHash:
+		err = r.loadScanned(entry, "HLEN", "HSCAN", true)
+	case KVTypeList:
+		err = r.loadList(entry)
+	case KVTypeSet:
+		err = r.loadScanned(entry, "SCARD", "SSCAN", false)
+	case KVTypeZSet:
+		err = r.loadZSet(entry)
+	case KVTypeStream:
+		err = r.loadStream(entry)
+	default:
+		err = fmt.Errorf("unsupported key type: %s", keyType)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	entry.Truncated = int64(len(entry.Fields)) < entry.Length && keyType != KVTypeString
+	return entry, nil
+}
+
+// loadString loads a string value
+func (r *Redis) loadString(entry *KVEntry) error {
+	reply, err := r.conn.Do("GET", entry.Key)
+	if err != nil {
+		return err
+	}
+	entry.Value = respString(reply)
+	entry.Length = int64(len(entry.Value))
+	return nil
+}
+
+// loadScanned loads hash or set elements through HSCAN/SSCAN up to redisFieldLimit
+func (r *Redis) loadScanned(entry *KVEntry, lenCmd, scanCmd string, pairs bool) error {
+	reply, err := r.conn.Do(lenCmd, entry.Key)
+	if err != nil {
+		return err
+	}
+	if entry.Length, err = respInt(reply); err != nil {
+		return err
+	}
+
+	cursor := "0"
+	for {
+		reply, err := r.conn.Do(scanCmd, entry.Key, cursor, "COUNT", "100")
+		if err != nil {
+			return err
+		}
+		items, ok := reply.([]interface{})
+		if !ok || len(items) != 2 {
+			return fmt.Errorf("unexpected %s reply", scanCmd)
+		}
+		values, err := respStrings(items[1])
+		if err != nil {
+			return err
+		}
+
+		if pairs {
+			for i := 0; i+1 < len(values); i += 2 {
+				entry.Fields = append(entry.Fields, KVField{Name: values[i], Value: values[i+1]})
+			}
+		} else {
+			for _, value := range values {
+				entry.Fields = append(entry.Fields, KVField{Name: value})
+			}
+		}
+
+		cursor = respString(items[0])
+		if cursor == "0" || len(entry.Fields) >= redisFieldLimit {
+			break
+		}
+	}
+
+	if len(entry.Fields) > redisFieldLimit {
+		entry.Fields = entry.Fields[:redisFieldLimit]
+	}
+	return nil
+}
+
+// loadList loads list items up to redisFieldLimit
+func (r *Redis) loadList(entry *KVEntry) error {
+	reply, err := r.conn.Do("LLEN", entry.Key)
+	if err != nil {
+		return err
+	}
+	if entry.Length, err = respInt(reply); err != nil {
+		return err
+	}
+
+	reply, err = r.conn.Do("LRANGE", entry.Key, "0", strconv.Itoa(redisFieldLimit-1))
+	if err != nil {
+		return err
+	}
+	items, err := respStrings(reply)
+	if err != nil {
+		return err
+	}
+	for i, item := range items {
+		entry.Fields = append(entry.Fields, KVField{Name: strconv.Itoa(i), Value: item})
+	}
+	return nil
+}
+
+// loadZSet loads sorted set members with scores up to redisFieldLimit
+func (r *Redis) loadZSet(entry *KVEntry) error {
+	reply, err := r.conn.Do("ZCARD", entry.Key)
+	if err != nil {
+		return err
+	}
+	if entry.Length, err = respInt(reply); err != nil {
+		return err
+	}
+
+	reply, err = r.conn.Do("ZRANGE", entry.Key, "0", strconv.Itoa(redisFieldLimit-1), "WITHSCORES")
+	if err != nil {
+		return err
+	}
+	items, err := respStrings(reply)
+	if err != nil {
+		return err
+	}
+	for i := 0; i+1 < len(items); i += 2 {
+		entry.Fields = append(entry.Fields, KVField{Name: items[i], Value: items[i+1]})
+	}
+	return nil
+}
+
+// loadStream loads stream entries up to redisFieldLimit
+func (r *Redis) loadStream(entry *KVEntry) error {
+	reply, err := r.conn.Do("XLEN", entry.Key)
+	if err != nil {
+		return err
+	}
+	if entry.Length, err = respInt(reply); err != nil {
+		return err
+	}
+
+	reply, err = r.conn.Do("XRANGE", entry.Key, "-", "+", "COUNT", strconv.Itoa(redisFieldLimit))
+	if err != nil {
+		return err
+	}
+	items, ok := reply.([]interface{})
+	if !ok && reply != nil {
+		return fmt.Errorf("unexpected XRANGE reply")
+	}
+	for _, item := range items {
+		parts, ok := item.([]interface{})
+		if !ok || len(parts) != 2 {
+			return fmt.Errorf("unexpected XRANGE entry")
+		}
+		values, err := respStrings(parts[1])
+		if err != nil {
+			return err
+		}
+		pairs := make([]string, 0, len(values)/2)
+		for i := 0; i+1 < len(values); i += 2 {
+			pairs = append(pairs, values[i]+"="+values[i+1])
+		}
+		entry.Fields = append(entry.Fields, KVField{Name: respString(parts[0]), Value: strings.Join(pairs, " ")})
+	}
+	return nil
+}
+
+// SetValue sets a string value, keeping the existing TTL
+func (r *Redis) SetValue(key, value string) error {
+	if r.conn == nil {
+		return fmt.Errorf("not connected")
+	}
+
+	// KEEPTTL needs Redis 6, so restore the TTL explicitly
+	reply, err := r.conn.Do("PTTL", key)
+	if err != nil {
+		return err
+	}
+	ttl, err := respInt(reply)
+	if err != nil {
+		return err
+	}
+
+	if _, err := r.conn.Do("SET", key, value); err != nil {
+		return err
+	}
+
+	if ttl > 0 {
+		_, err = r.conn.Do("PEXPIRE", key, strconv.FormatInt(ttl, 10))
+	}
+	return err
+}
+
+// SetField sets one element of a collection key.
+// For hashes field is the hash field; for lists the index, or "" to append;
+// for sets the member to replace, or "" to add; for sorted sets the member
+// with value as its score.
+func (r *Redis) SetField(key, field, value string) error {
+	if r.conn == nil {
+		return fmt.Errorf("not connected")
+	}
+
+	keyType, err := r.keyType(key)
+	if err != nil {
+		return err
+	}
+
+	switch keyType {
+	case KVTypeHash:
+		_, err = r.conn.Do("HSET", key, field, value)
+	case KVTypeList:
+		if field == "" {
+			_, err = r.conn.Do("RPUSH", key, value)
+		} else {
+			_, err = r.conn.Do("LSET", key, field, value)
+		}
+	case KVTypeSet:
+		if field != "" && field != value {
+			if _, err = r.conn.Do("SREM", key, field); err != nil {
+				return err
+			}
+		}
+		_, err = r.conn.Do("SADD", key, value)
+	case KVTypeZSet:
+		if _, parseErr := strconv.ParseFloat(value, 64); parseErr != nil {
+			return fmt.Errorf("invalid score: %s", value)
+		}
+		_, err = r.conn.Do("ZADD", key, value, field)
+	default:
+		err = fmt.Errorf("editing %s keys is not supported", keyType)
+	}
+
+	return err
+}
+
+// DeleteField removes one element of a collection key
+func (r *Redis) DeleteField(key, field string) error {
+	if r.conn == nil {
+		return fmt.Errorf("not connected")
+	}
+
+	keyType, err := r.keyType(key)
+	if err != nil {
+		return err
+	}
+
+	switch keyType {
+	case KVTypeHash:
+		_, err = r.conn.Do("HDEL", key, field)
+	case KVTypeList:
+		// Lists have no delete-by-index, mark the item and remove the marker
+		marker := "__gocmder_deleted__" + strconv.FormatInt(time.Now().UnixNano(), 10)
+		if _, err = r.conn.Do("LSET", key, field, marker); err != nil {
+			return err
+		}
+		_, err = r.conn.Do("LREM", key, "1", marker)
+	case KVTypeSet:
+		_, err = r.conn.Do("SREM", key, field)
+	case KVTypeZSet:
+		_, err = r.conn.Do("ZREM", key, field)
+	case KVTypeStream:
+		_, err = r.conn.Do("XDEL", key, field)
+	default:
+		err = fmt.Errorf("deleting elements of %s keys is not supported", keyType)
+	}
+
+	return err
+}
+
+// DeleteKey deletes a key
+func (r *Redis) DeleteKey(key string) error {
+	if r.conn == nil {
+		return fmt.Errorf("not connected")
+	}
+
+	_, err := r.conn.Do("DEL", key)
+	return err
+}
+
+// Expire sets the TTL of a key, a ttl <= 0 removes the expiration
+func (r *Redis) Expire(key string, ttl time.Duration) error {
+	if r.conn == nil {
+		return fmt.Errorf("not connected")
+	}
+
+	var err error
+	if ttl <= 0 {
+		_, err = r.conn.Do("PERSIST", key)
+	} else {
+		_, err = r.conn.Do("PEXPIRE", key, strconv.FormatInt(ttl.Milliseconds(), 10))
+	}
+	return err
+}
+
+// GetDriverName returns the driver name
+func (r *Redis) GetDriverName() string {
+	return fmt.Sprintf("Redis db%d", r.database)
+}
+
+// keyType returns the type of a key
+func (r *Redis) keyType(key string) (string, error) {
+	reply, err := r.conn.Do("TYPE", key)
+	if err != nil {
+		return "", err
+	}
+	keyType := respString(reply)
+	if keyType == "none" {
+		return "", fmt.Errorf("key not found: %s", key)
+	}
+	return keyType, nil
+}
@@ -0,0 +1,671 @@
+package db
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeValue is one key of the fake RESP server
+type fakeValue struct {
+	typ    string
+	str    string
+	hash   map[string]string
+	list   []string
+	set    map[string]bool
+	zset   map[string]float64
+	stream [][]string // entry ID followed by field value pairs
+	expiry time.Time  // zero without expiration
+}
+
+// fakeRedis is an in-process RESP server holding keys in memory and
+// answering the commands the Redis driver sends
+type fakeRedis struct {
+	listener net.Listener
+	password string
+
+	mu       sync.Mutex
+	keys     map[string]*fakeValue
+	commands []string // command names in the order they arrived
+	selected string
+}
+
+// newFakeRedis starts a fake RESP server, password is required by AUTH if set
+func newFakeRedis(t *testing.T, password string) *fakeRedis {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	f := &fakeRedis{listener: listener, password: password, keys: make(map[string]*fakeValue)}
+	go f.serve()
+	t.Cleanup(func() { listener.Close() })
+	return f
+}
+
+func (f *fakeRedis) addr() string {
+	return f.listener.Addr().String()
+}
+
+// connect returns a driver connected to the fake server
+func (f *fakeRedis) connect(t *testing.T) *Redis {
+	t.Helper()
+	r := NewRedis()
+	if err := r.Connect("redis://" + f.addr()); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { r.Close() })
+	return r
+}
+
+func (f *fakeRedis) serve() {
+	for {
+		conn, err := f.listener.Accept()
+		if err != nil {
+			return
+		}
+		go f.handle(conn)
+	}
+}
+
+// handle answers the commands of one client connection
+func (f *fakeRedis) handle(conn net.Conn) {
+	defer conn.Close()
+	reader := bufio.NewReader(conn)
+	authed := f.password == ""
+	for {
+		args, err := readCommand(reader)
+		if err != nil {
+			return
+		}
+
+		name := strings.ToUpper(args[0])
+		f.mu.Lock()
+		f.commands = append(f.commands, name)
+		var reply string
+		switch {
+		case name == "AUTH":
+			if args[len(args)-1] != f.password {
+				reply = "-WRONGPASS invalid password\r\n"
+			} else {
+				authed = true
+				reply = "+OK\r\n"
+			}
+		case !authed:
+			reply = "-NOAUTH Authentication required.\r\n"
+		default:
+			reply = f.exec(name, args[1:])
+		}
+		f.mu.Unlock()
+
+		if _, err := io.WriteString(conn, reply); err != nil {
+			return
+		}
+	}
+}
+
+// readCommand reads one command sent as an array of bulk strings
+func readCommand(reader *bufio.Reader) ([]string, error) {
+	line, err := reader.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	count, err := strconv.Atoi(strings.TrimSpace(line[1:]))
+	if err != nil || line[0] != '*' {
+		return nil, fmt.Errorf("invalid command: %q", line)
+	}
+
+	args := make([]string, count)
+	for i := range args {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			return nil, err
+		}
+		size, err := strconv.Atoi(strings.TrimSpace(line[1:]))
+		if err != nil {
+			return nil, err
+		}
+		buf := make([]byte, size+2)
+		if _, err := io.ReadFull(reader, buf); err != nil {
+			return nil, err
+		}
+		args[i] = string(buf[:size])
+	}
+	return args, nil
+}
+
+// encode encodes a reply: string as bulk string, int as integer, nil as
+// null bulk string and []string or []interface{} as array
+func encode(reply interface{}) string {
+	switch v := reply.(type) {
+	case nil:
+		return "$-1\r\n"
+	case int:
+		return fmt.Sprintf(":%d\r\n", v)
+	case string:
+		return fmt.Sprintf("$%d\r\n%s\r\n", len(v), v)
+	case []string:
+		items := make([]interface{}, len(v))
+		for i, item := range v {
+			items[i] = item
+		}
+		return encode(items)
+	case []interface{}:
+		var b strings.Builder
+		fmt.Fprintf(&b, "*%d\r\n", len(v))
+		for _, item := range v {
+			b.WriteString(encode(item))
+		}
+		return b.String()
+	}
+	panic(fmt.Sprintf("cannot encode %T", reply))
+}
+
+// lookup returns a key, creating one of typ if create is set
+func (f *fakeRedis) lookup(key, typ string, create bool) (*fakeValue, string) {
+	value, ok := f.keys[key]
+	if !ok {
+		if !create {
+			return nil, ""
+		}
+		value = &fakeValue{typ: typ, hash: map[string]string{}, set: map[string]bool{}, zset: map[string]float64{}}
+		f.keys[key] = value
+	}
+	if value.typ != typ {
+		return nil, "-WRONGTYPE Operation against a key holding the wrong kind of value\r\n"
+	}
+	return value, ""
+}
+
+// exec runs a command against the keys, caller must hold the lock
+func (f *fakeRedis) exec(name string, args []string) string {
+	switch name {
+	case "PING":
+		return "+PONG\r\n"
+	case "SELECT":
+		f.selected = args[0]
+		return "+OK\r\n"
+	case "SCAN":
+		return f.scan(args)
+	case "TYPE":
+		if value, ok := f.keys[args[0]]; ok {
+			return "+" + value.typ + "\r\n"
+		}
+		return "+none\r\n"
+	case "PTTL":
+		value, ok := f.keys[args[0]]
+		switch {
+		case !ok:
+			return encode(-2)
+		case value.expiry.IsZero():
+			return encode(-1)
+		}
+		return encode(int(time.Until(value.expiry).Milliseconds()))
+	case "PEXPIRE":
+		ms, _ := strconv.Atoi(args[1])
+		value, ok := f.keys[args[0]]
+		if !ok {
+			return encode(0)
+		}
+		value.expiry = time.Now().Add(time.Duration(ms) * time.Millisecond)
+		return encode(1)
+	case "PERSIST":
+		if value, ok := f.keys[args[0]]; ok {
+			value.expiry = time.Time{}
+		}
+		return encode(1)
+	case "DEL":
+		_, ok := f.keys[args[0]]
+		delete(f.keys, args[0])
+		if ok {
+			return encode(1)
+		}
+		return encode(0)
+	case "GET", "SET":
+		if name == "SET" {
+			f.keys[args[0]] = &fakeValue{typ: KVTypeString, str: args[1]}
+			return "+OK\r\n"
+		}
+		value, errReply := f.lookup(args[0], KVTypeString, false)
+		if errReply != "" {
+			return errReply
+		}
+		if value == nil {
+			return encode(nil)
+		}
+		return encode(value.str)
+	}
+
+	switch name {
+	case "HLEN", "HSCAN", "HSET", "HDEL":
+		return f.execHash(name, args)
+	case "LLEN", "LRANGE", "LSET", "LREM", "RPUSH":
+		return f.execList(name, args)
+	case "SCARD", "SSCAN", "SADD", "SREM":
+		return f.execSet(name, args)
+	case "ZCARD", "ZRANGE", "ZADD", "ZREM":
+		return f.execZSet(name, args)
+	case "XLEN", "XRANGE", "XDEL":
+		return f.execStream(name, args)
+	}
+	return "-ERR unknown command '" + name + "'\r\n"
+}
+
+// scan pages through the sorted key names, the cursor is the next index
+func (f *fakeRedis) scan(args []string) string {
+	cursor, _ := strconv.Atoi(args[0])
+	pattern, count := "*", 10
+	for i := 1; i+1 < len(args); i += 2 {
+		switch strings.ToUpper(args[i]) {
+		case "MATCH":
+			pattern = args[i+1]
+		case "COUNT":
+			count, _ = strconv.Atoi(args[i+1])
+		}
+	}
+
+	names := sortedNames(f.keys)
+
+	var keys []string
+	next := cursor + count
+	for _, name := range names[min(cursor, len(names)):min(next, len(names))] {
+		if ok, _ := path.Match(pattern, name); ok {
+			keys = append(keys, name)
+		}
+	}
+	if next >= len(names) {
+		next = 0
+	}
+	return encode([]interface{}{strconv.Itoa(next), keys})
+}
+
+func (f *fakeRedis) execHash(name string, args []string) string {
+	value, errReply := f.lookup(args[0], KVTypeHash, name == "HSET")
+	if errReply != "" {
+		return errReply
+	}
+	if value == nil {
+		value = &fakeValue{}
+	}
+	switch name {
+	case "HLEN":
+		return encode(len(value.hash))
+	case "HSCAN":
+		var items []string
+		for _, field := range sortedNames(value.hash) {
+			items = append(items, field, value.hash[field])
+		}
+		return encode([]interface{}{"0", items})
+	case "HSET":
+		value.hash[args[1]] = args[2]
+		return encode(1)
+	}
+	delete(value.hash, args[1])
+	return encode(1)
+}
+
+func (f *fakeRedis) execList(name string, args []string) string {
+	value, errReply := f.lookup(args[0], KVTypeList, name == "RPUSH")
+	if errReply != "" {
+		return errReply
+	}
+	if value == nil {
+		value = &fakeValue{}
+	}
+	switch name {
+	case "LLEN":
+		return encode(len(value.list))
+	case "LRANGE":
+		stop, _ := strconv.Atoi(args[2])
+		return encode(value.list[:min(stop+1, len(value.list))])
+	case "LSET":
+		index, _ := strconv.Atoi(args[1])
+		if index < 0 || index >= len(value.list) {
+			return "-ERR index out of range\r\n"
+		}
+		value.list[index] = args[2]
+		return "+OK\r\n"
+	case "RPUSH":
+		value.list = append(value.list, args[1:]...)
+		return encode(len(value.list))
+	}
+	for i, item := range value.list {
+		if item == args[2] {
+			value.list = append(value.list[:i], value.list[i+1:]...)
+			return encode(1)
+		}
+	}
+	return encode(0)
+}
+
+func (f *fakeRedis) execSet(name string, args []string) string {
+	value, errReply := f.lookup(args[0], KVTypeSet, name == "SADD")
+	if errReply != "" {
+		return errReply
+	}
+	if value == nil {
+		value = &fakeValue{}
+	}
+	switch name {
+	case "SCARD":
+		return encode(len(value.set))
+	case "SSCAN":
+		return encode([]interface{}{"0", sortedNames(value.set)})
+	case "SADD":
+		value.set[args[1]] = true
+		return encode(1)
+	}
+	delete(value.set, args[1])
+	return encode(1)
+}
+
+func (f *fakeRedis) execZSet(name string, args []string) string {
+	value, errReply := f.lookup(args[0], KVTypeZSet, name == "ZADD")
+	if errReply != "" {
+		return errReply
+	}
+	if value == nil {
+		value = &fakeValue{}
+	}
+	switch name {
+	case "ZCARD":
+		return encode(len(value.zset))
+	case "ZRANGE":
+		var members []string
+		for member := range value.zset {
+			members = append(members, member)
+		}
+		sort.Slice(members, func(i, j int) bool { return value.zset[members[i]] < value.zset[members[j]] })
+		var items []string
+		for _, member := range members {
+			items = append(items, member, strconv.FormatFloat(value.zset[member], 'f', -1, 64))
+		}
+		return encode(items)
+	case "ZADD":
+		score, _ := strconv.ParseFloat(args[1], 64)
+		value.zset[args[2]] = score
+		return encode(1)
+	}
+	delete(value.zset, args[1])
+	return encode(1)
+}
+
+func (f *fakeRedis) execStream(name string, args []string) string {
+	value, errReply := f.lookup(args[0], KVTypeStream, false)
+	if errReply != "" {
+		return errReply
+	}
+	if value == nil {
+		value = &fakeValue{}
+	}
+	switch name {
+	case "XLEN":
+		return encode(len(value.stream))
+	case "XRANGE":
+		var entries []interface{}
+		for _, entry := range value.stream {
+			entries = append(entries, []interface{}{entry[0], entry[1:]})
+		}
+		return encode(entries)
+	}
+	for i, entry := range value.stream {
+		if entry[0] == args[1] {
+			value.stream = append(value.stream[:i], value.stream[i+1:]...)
+			return encode(1)
+		}
+	}
+	return encode(0)
+}
+
+// sortedNames returns the keys of a map in order
+func sortedNames[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// seed adds keys of every type to the fake server
+func (f *fakeRedis) seed() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.keys["str"] = &fakeValue{typ: KVTypeString, str: "hello"}
+	f.keys["hash"] = &fakeValue{typ: KVTypeHash, hash: map[string]string{"a": "1"}}
+	f.keys["list"] = &fakeValue{typ: KVTypeList, list: []string{"x", "y", "z"}}
+	f.keys["set"] = &fakeValue{typ: KVTypeSet, set: map[string]bool{"m": true}}
+	f.keys["zset"] = &fakeValue{typ: KVTypeZSet, zset: map[string]float64{"low": 1, "high": 2.5}}
+	f.keys["stream"] = &fakeValue{typ: KVTypeStream, stream: [][]string{{"1-0", "f", "v"}, {"2-0", "g", "w"}}}
+}
+
+func TestRedisScanKeysPaging(t *testing.T) {
+	f := newFakeRedis(t, "")
+	for i := 0; i < 7; i++ {
+		f.keys[fmt.Sprintf("user:%d", i)] = &fakeValue{typ: KVTypeString}
+	}
+	f.keys["other"] = &fakeValue{typ: KVTypeString}
+	r := f.connect(t)
+
+	var keys []string
+	cursor, pages := "", 0
+	for {
+		result, err := r.ScanKeys(cursor, "user:*", 3)
+		if err != nil {
+			t.Fatal(err)
+		}
+		keys = append(keys, result.Keys...)
+		pages++
+		if cursor = result.Cursor; cursor == "0" {
+			break
+		}
+	}
+
+	if len(keys) != 7 {
+		t.Errorf("got keys %v, want the 7 user keys", keys)
+	}
+	if pages != 3 {
+		t.Errorf("got %d pages, want 3", pages)
+	}
+}
+
+func TestRedisGetEntry(t *testing.T) {
+	f := newFakeRedis(t, "")
+	f.seed()
+	r := f.connect(t)
+
+	tests := []struct {
+		key    string
+		typ    string
+		length int64
+		value  string
+		fields []KVField
+	}{
+		{"str", KVTypeString, 5, "hello", nil},
+		{"hash", KVTypeHash, 1, "", []KVField{{Name: "a", Value: "1"}}},
+		{"list", KVTypeList, 3, "", []KVField{{Name: "0", Value: "x"}, {Name: "1", Value: "y"}, {Name: "2", Value: "z"}}},
+		{"set", KVTypeSet, 1, "", []KVField{{Name: "m"}}},
+		{"zset", KVTypeZSet, 2, "", []KVField{{Name: "low", Value: "1"}, {Name: "high", Value: "2.5"}}},
+		{"stream", KVTypeStream, 2, "", []KVField{{Name: "1-0", Value: "f=v"}, {Name: "2-0", Value: "g=w"}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.typ, func(t *testing.T) {
+			entry, err := r.GetEntry(tt.key)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if entry.Type != tt.typ || entry.Length != tt.length || entry.Value != tt.value {
+				t.Errorf("got type %s, length %d, value %q", entry.Type, entry.Length, entry.Value)
+			}
+			if fmt.Sprint(entry.Fields) != fmt.Sprint(tt.fields) {
+				t.Errorf("got fields %v, want %v", entry.Fields, tt.fields)
+			}
+			if entry.TTL != KVNoExpiry || entry.Truncated {
+				t.Errorf("got TTL %v, truncated %v", entry.TTL, entry.Truncated)
+			}
+		})
+	}
+
+	if _, err := r.GetEntry("missing"); err == nil {
+		t.Error("expected an error for a missing key")
+	}
+}
+
+func TestRedisExpire(t *testing.T) {
+	f := newFakeRedis(t, "")
+	f.seed()
+	r := f.connect(t)
+
+	if err := r.Expire("str", time.Minute); err != nil {
+		t.Fatal(err)
+	}
+	entry, err := r.GetEntry("str")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if entry.TTL <= 59*time.Second || entry.TTL > time.Minute {
+		t.Errorf("got TTL %v, want about a minute", entry.TTL)
+	}
+
+	// SetValue keeps the TTL
+	if err := r.SetValue("str", "changed"); err != nil {
+		t.Fatal(err)
+	}
+	if entry, err = r.GetEntry("str"); err != nil {
+		t.Fatal(err)
+	}
+	if entry.Value != "changed" || entry.TTL <= 0 {
+		t.Errorf("got value %q and TTL %v after SetValue", entry.Value, entry.TTL)
+	}
+
+	if err := r.Expire("str", 0); err != nil {
+		t.Fatal(err)
+	}
+	if entry, err = r.GetEntry("str"); err != nil {
+		t.Fatal(err)
+	}
+	if entry.TTL != KVNoExpiry {
+		t.Errorf("got TTL %v after removing the expiration", entry.TTL)
+	}
+}
+
+func TestRedisEditFields(t *testing.T) {
+	f := newFakeRedis(t, "")
+	f.seed()
+	r := f.connect(t)
+
+	steps := []struct {
+		name string
+		run  func() error
+		key  string
+		want string
+	}{
+		{"hash set", func() error { return r.SetField("hash", "b", "2") }, "hash", "[{a 1} {b 2}]"},
+		{"hash delete", func() error { return r.DeleteField("hash", "a") }, "hash", "[{b 2}]"},
+		{"list set", func() error { return r.SetField("list", "1", "Y") }, "list", "[{0 x} {1 Y} {2 z}]"},
+		{"list append", func() error { return r.SetField("list", "", "w") }, "list", "[{0 x} {1 Y} {2 z} {3 w}]"},
+		{"list delete", func() error { return r.DeleteField("list", "0") }, "list", "[{0 Y} {1 z} {2 w}]"},
+		{"set replace", func() error { return r.SetField("set", "m", "n") }, "set", "[{n }]"},
+		{"set delete", func() error { return r.DeleteField("set", "n") }, "set", "[]"},
+		{"zset score", func() error { return r.SetField("zset", "low", "3") }, "zset", "[{high 2.5} {low 3}]"},
+		{"zset delete", func() error { return r.DeleteField("zset", "high") }, "zset", "[{low 3}]"},
+		{"stream delete", func() error { return r.DeleteField("stream", "1-0") }, "stream", "[{2-0 g=w}]"},
+	}
+	for _, step := range steps {
+		if err := step.run(); err != nil {
+			t.Fatalf("%s: %v", step.name, err)
+		}
+		entry, err := r.GetEntry(step.key)
+		if err != nil {
+			t.Fatalf("%s: %v", step.name, err)
+		}
+		if got := fmt.Sprint(entry.Fields); got != step.want {
+			t.Errorf("%s: got %s, want %s", step.name, got, step.want)
+		}
+	}
+
+	if err := r.SetField("zset", "low", "high"); err == nil {
+		t.Error("expected an error for an invalid score")
+	}
+	if err := r.SetField("str", "x", "y"); err == nil {
+		t.Error("expected an error editing a field of a string key")
+	}
+
+	if err := r.DeleteKey("hash"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := r.GetEntry("hash"); err == nil {
+		t.Error("expected the deleted key to be missing")
+	}
+}
+
+func TestRedisConnectAuthSelect(t *testing.T) {
+	f := newFakeRedis(t, "secret")
+
+	r := NewRedis()
+	if err := r.Connect("redis://:secret@" + f.addr() + "/3"); err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+
+	f.mu.Lock()
+	commands, selected := strings.Join(f.commands, " "), f.selected
+	f.mu.Unlock()
+	if commands != "AUTH SELECT PING" || selected != "3" {
+		t.Errorf("got commands %q selecting %q", commands, selected)
+	}
+	if name := r.GetDriverName(); name != "Redis db3" {
+		t.Errorf("got driver name %q", name)
+	}
+
+	if err := NewRedis().Connect("redis://:wrong@" + f.addr()); err == nil || !strings.Contains(err.Error(), "WRONGPASS") {
+		t.Errorf("got %v, want an authentication error", err)
+	}
+	if err := NewRedis().Connect("redis://" + f.addr()); err == nil {
+		t.Error("expected an error connecting without a password")
+	}
+}
+
+func TestRESPPartialReplyClosesConnection(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		reader := bufio.NewReader(conn)
+		readCommand(reader)
+		// Half an array, the rest arrives after the client gave up
+		io.WriteString(conn, "*2\r\n$3\r\nfoo\r\n")
+		readCommand(reader)
+		io.WriteString(conn, "$3\r\nbar\r\n+PONG\r\n")
+	}()
+
+	conn, err := dialRESP(listener.Addr().String(), 200*time.Millisecond)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	if _, err := conn.Do("HKEYS", "key"); err == nil {
+		t.Fatal("expected a timeout reading half a reply")
+	}
+	reply, err := conn.Do("PING")
+	if err == nil {
+		t.Fatalf("got reply %q from the leftover bytes, want an error", respString(reply))
+	}
+}
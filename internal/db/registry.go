@@ -0,0 +1,83 @@
+package db
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// DriverInfo registers a SQL driver for sessions and the connection dialog
+type DriverInfo struct {
+	Name    string   // shown in the dialog and stored in sessions
+	Aliases []string // further names accepted for the driver, in any case
+	// DefaultPort is used when a session sets no port, empty for file drivers
+	DefaultPort string
+	// Preset holds the values besides the port that the connection dialog
+	// fills in when the driver is chosen
+	Preset ConnConfig
+	// Shortcut chooses the driver with Alt in the connection dialog, 0 for none.
+	// S and C are taken by save and connect.
+	Shortcut rune
+	// File drivers open a local database file, without a server, password or SSH tunnel
+	File bool
+	New  func() Driver
+	DSN  func(c ConnConfig) (string, error)
+}
+
+var (
+	driversMu sync.RWMutex
+	drivers   []DriverInfo
+)
+
+// RegisterDriver adds a driver to the registry, usually from the init
+// function of its file. It panics if the name or an alias is taken.
+func RegisterDriver(info DriverInfo) {
+	driversMu.Lock()
+	defer driversMu.Unlock()
+
+	for _, name := range append([]string{info.Name}, info.Aliases...) {
+		if _, ok := lookupDriver(name); ok {
+			panic(fmt.Sprintf("db: driver %s registered twice", name))
+		}
+	}
+	drivers = append(drivers, info)
+}
+
+// LookupDriver returns the driver registered under a name or alias
+func LookupDriver(name string) (DriverInfo, bool) {
+	driversMu.RLock()
+	defer driversMu.RUnlock()
+	return lookupDriver(name)
+}
+
+// lookupDriver finds a driver, caller must hold the lock
+func lookupDriver(name string) (DriverInfo, bool) {
+	name = strings.TrimSpace(name)
+	for _, info := range drivers {
+		if strings.EqualFold(info.Name, name) {
+			return info, true
+		}
+		for _, alias := range info.Aliases {
+			if strings.EqualFold(alias, name) {
+				return info, true
+			}
+		}
+	}
+	return DriverInfo{}, false
+}
+
+// Drivers returns the registered drivers in registration order
+func Drivers() []DriverInfo {
+	driversMu.RLock()
+	defer driversMu.RUnlock()
+	return append([]DriverInfo(nil), drivers...)
+}
+
+// DriverNames returns the names of the registered drivers
+func DriverNames() []string {
+	var names []string
+	for _, info := range Drivers() {
+		names = append(names, info.Name)
+	}
+	return names
+}
@@ -0,0 +1,211 @@
+package db
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// respError is an error reply returned by a RESP server
+type respError string
+
+func (e respError) Error() string {
+	return string(e)
+}
+
+// respConn is a minimal RESP2 client connection.
+// Replies are decoded as string (simple string), int64, []byte (bulk string,
+// nil when null), []interface{} (array, nil when null) or respError.
+type respConn struct {
+	mu      sync.Mutex
+	conn    net.Conn
+	reader  *bufio.Reader
+	writer  *bufio.Writer
+	timeout time.Duration
+	// broken is set once a command fails partway, the stream is then out of
+	// step with the replies and the connection is closed
+	broken error
+}
+
+// dialRESP opens a RESP connection to addr
+func dialRESP(addr string, timeout time.Duration) (*respConn, error) {
+	conn, err := net.DialTimeout("tcp", addr, timeout)
+	if err != nil {
+		return nil, err
+	}
+
+	return &respConn{
+		conn:    conn,
+		reader:  bufio.NewReader(conn),
+		writer:  bufio.NewWriter(conn),
+		timeout: timeout,
+	}, nil
+}
+
+// Close closes the connection
+func (c *respConn) Close() error {
+	// Not locked, so that closing ends a command waiting for its reply
+	if err := c.conn.Close(); err != nil && !errors.Is(err, net.ErrClosed) {
+		return err
+	}
+	return nil
+}
+
+// Do sends a command and reads its reply
+func (c *respConn) Do(args ...string) (interface{}, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.broken != nil {
+		return nil, c.broken
+	}
+
+	if c.timeout > 0 {
+		c.conn.SetDeadline(time.Now().Add(c.timeout))
+	}
+
+	if err := c.writeCommand(args); err != nil {
+		return nil, c.fail(err)
+	}
+
+	reply, err := c.readReply()
+	if err != nil {
+		return nil, c.fail(err)
+	}
+
+	if e, ok := reply.(respError); ok {
+		return nil, e
+	}
+
+	return reply, nil
+}
+
+// fail closes the connection after a failed write or read, so that the next
+// command does not read what is left of this reply
+func (c *respConn) fail(err error) error {
+	c.conn.Close()
+	c.broken = fmt.Errorf("connection closed after a failed reply: %w", err)
+	return err
+}
+
+// writeCommand writes a command as an array of bulk strings
+func (c *respConn) writeCommand(args []string) error {
+	fmt.Fprintf(c.writer, "*%d\r\n", len(args))
+	for _, arg := range args {
+		fmt.Fprintf(c.writer, "$%d\r\n%s\r\n", len(arg), arg)
+	}
+	return c.writer.Flush()
+}
+
+// readLine reads a CRLF terminated line without the terminator
+func (c *respConn) readLine() (string, error) {
+	line, err := c.reader.ReadString('\n')
+	if err != nil {
+		return "", err
+	}
+	if len(line) < 2 || line[len(line)-2] != '\r' {
+		return "", fmt.Errorf("invalid RESP line: %q", line)
+	}
+	return line[:len(line)-2], nil
+}
+
+// readReply reads one reply from the connection
+func (c *respConn) readReply() (interface{}, error) {
+	line, err := c.readLine()
+	if err != nil {
+		return nil, err
+	}
+	if line == "" {
+		return nil, fmt.Errorf("empty RESP reply")
+	}
+
+	switch line[0] {
+	case '+':
+		return line[1:], nil
+	case '-':
+		return respError(line[1:]), nil
+	case ':':
+		return strconv.ParseInt(line[1:], 10, 64)
+	case '$':
+		size, err := strconv.Atoi(line[1:])
+		if err != nil {
+			return nil, fmt.Errorf("invalid bulk length: %q", line)
+		}
+		if size < 0 {
+			return nil, nil
+		}
+		buf := make([]byte, size+2)
+		if _, err := io.ReadFull(c.reader, buf); err != nil {
+			return nil, err
+		}
+		return buf[:size], nil
+	case '*':
+		count, err := strconv.Atoi(line[1:])
+		if err != nil {
+			return nil, fmt.Errorf("invalid array length: %q", line)
+		}
+		if count < 0 {
+			return nil, nil
+		}
+		items := make([]interface{}, count)
+		for i := range items {
+			item, err := c.readReply()
+			if err != nil {
+				return nil, err
+			}
+			items[i] = item
+		}
+		return items, nil
+	}
+
+	return nil, fmt.Errorf("unknown RESP reply type: %q", line)
+}
+
+// respString converts a reply to a string
+func respString(reply interface{}) string {
+	switch v := reply.(type) {
+	case string:
+		return v
+	case []byte:
+		return string(v)
+	case int64:
+		return strconv.FormatInt(v, 10)
+	case nil:
+		return ""
+	}
+	return fmt.Sprintf("%v", reply)
+}
+
+// respInt converts a reply to an integer
+func respInt(reply interface{}) (int64, error) {
+	switch v := reply.(type) {
+	case int64:
+		return v, nil
+	case []byte:
+		return strconv.ParseInt(string(v), 10, 64)
+	case string:
+		return strconv.ParseInt(v, 10, 64)
+	}
+	return 0, fmt.Errorf("unexpected reply type %T", reply)
+}
+
+// respStrings converts an array reply to a string slice
+func respStrings(reply interface{}) ([]string, error) {
+	if reply == nil {
+		return nil, nil
+	}
+	items, ok := reply.([]interface{})
+	if !ok {
+		return nil, fmt.Errorf("unexpected reply type %T", reply)
+	}
+	values := make([]string, len(items))
+	for i, item := range items {
+		values[i] = respString(item)
+	}
+	return values, nil
+}
@@ -0,0 +1,228 @@
+package db
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"unicode"
+)
+
+// TableInfo describes the structure of a table
+type TableInfo struct {
+	Database    string
+	Schema      string // PostgreSQL schema, empty for MySQL and SQLite
+	Name        string
+	Columns     []ColumnInfo
+	PrimaryKey  []string
+	Indexes     []IndexInfo
+	ForeignKeys []ForeignKeyInfo
+	Checks      []CheckInfo
+	Triggers    []TriggerInfo
+	DDL         string // CREATE statements of the table, its indexes and triggers
+}
+
+// ColumnInfo describes a table column
+type ColumnInfo struct {
+	Name       string
+	Type       string
+	Nullable   bool
+	Default    string
+	HasDefault bool
+	Extra      string // e.g. auto_increment or identity
+	Comment    string
+}
+
+// IndexInfo describes an index
+type IndexInfo struct {
+	Name       string
+	Columns    []string // expressions for expression indexes
+	Unique     bool
+	Primary    bool
+	Constraint bool   // the index of a PostgreSQL unique or exclusion constraint
+	Definition string // CREATE INDEX statement if the database reports one
+}
+
+// ForeignKeyInfo describes a foreign key
+type ForeignKeyInfo struct {
+	Name       string
+	Columns    []string
+	RefTable   string
+	RefColumns []string
+	OnUpdate   string
+	OnDelete   string
+}
+
+// CheckInfo describes a check constraint
+type CheckInfo struct {
+	Name       string
+	Expression string
+}
+
+// TriggerInfo describes a trigger
+type TriggerInfo struct {
+	Name       string
+	Timing     string // BEFORE, AFTER or INSTEAD OF
+	Event      string // INSERT, UPDATE, DELETE or TRUNCATE, OR-ed if several
+	Definition string
+}
+
+// QualifiedName returns the table name with its schema or database
+func (t *TableInfo) QualifiedName() string {
+	if t.Schema != "" {
+		return t.Schema + "." + t.Name
+	}
+	if t.Database != "" {
+		return t.Database + "." + t.Name
+	}
+	return t.Name
+}
+
+// extractChecks returns the CHECK constraints of a CREATE TABLE statement,
+// for databases that only keep the statement text
+func extractChecks(dialect Dialect, ddl string) []CheckInfo {
+	var checks []CheckInfo
+	runes := []rune(ddl)
+	var previous []string // words before the current one
+
+	for i := 0; i < len(runes); {
+		if next, kind := skipLexeme(dialect, runes, i); kind != lexemeNone {
+			if kind == lexemeIdentifier {
+				previous = append(previous, unquoteIdentifier(string(runes[i:next])))
+			}
+			i = next
+			continue
+		}
+
+		switch r := runes[i]; {
+		case unicode.IsLetter(r) || r == '_':
+			start := i
+			for i < len(runes) && isWordRune(runes[i]) {
+				i++
+			}
+			word := string(runes[start:i])
+			if !strings.EqualFold(word, "CHECK") {
+				previous = append(previous, word)
+				continue
+			}
+
+			// The expression is the parenthesized text after CHECK
+			open := i
+			for open < len(runes) && unicode.IsSpace(runes[open]) {
+				open++
+			}
+			if open >= len(runes) || runes[open] != '(' {
+				continue
+			}
+			end := matchParenthesis(dialect, runes, open)
+
+			check := CheckInfo{Expression: strings.TrimSpace(string(runes[open+1 : end-1]))}
+			if n := len(previous); n >= 2 && strings.EqualFold(previous[n-2], "CONSTRAINT") {
+				check.Name = previous[n-1]
+			}
+			checks = append(checks, check)
+			i = end
+		default:
+			i++
+		}
+	}
+
+	return checks
+}
+
+// matchParenthesis returns the position after the parenthesis closing the one at open
+func matchParenthesis(dialect Dialect, runes []rune, open int) int {
+	depth := 0
+	for i := open; i < len(runes); {
+		if next, kind := skipLexeme(dialect, runes, i); kind != lexemeNone {
+			i = next
+			continue
+		}
+		switch runes[i] {
+		case '(':
+			depth++
+		case ')':
+			depth--
+			if depth == 0 {
+				return i + 1
+			}
+		}
+		i++
+	}
+	return len(runes)
+}
+
+// unquoteIdentifier removes the quotes of a quoted identifier
+func unquoteIdentifier(text string) string {
+	if len(text) < 2 {
+		return text
+	}
+	closing := map[byte]byte{'"': '"', '`': '`', '[': ']'}[text[0]]
+	if closing == 0 || text[len(text)-1] != closing {
+		return text
+	}
+	inner := text[1 : len(text)-1]
+	return strings.ReplaceAll(inner, string(closing)+string(closing), string(closing))
+}
+
+// triggerEvent returns the timing and events of a CREATE TRIGGER statement
+func triggerEvent(dialect Dialect, ddl string) (timing, event string) {
+	var events []string
+	for _, word := range scanWords(dialect, ddl) {
+		switch word.text {
+		case "BEFORE", "AFTER":
+			if timing == "" {
+				timing = word.text
+			}
+		case "INSTEAD":
+			if timing == "" {
+				timing = "INSTEAD OF"
+			}
+		case "INSERT", "UPDATE", "DELETE", "TRUNCATE":
+			if timing != "" && !slices.Contains(events, word.text) {
+				events = append(events, word.text)
+			}
+		case "ON":
+			// Statements of the trigger body follow the table name
+			if timing != "" {
+				return timing, strings.Join(events, " OR ")
+			}
+		}
+	}
+	return timing, strings.Join(events, " OR ")
+}
+
+// referentialAction returns the name of a PostgreSQL foreign key action code
+func referentialAction(code string) string {
+	switch code {
+	case "r":
+		return "RESTRICT"
+	case "c":
+		return "CASCADE"
+	case "n":
+		return "SET NULL"
+	case "d":
+		return "SET DEFAULT"
+	}
+	return "NO ACTION"
+}
+
+// quoteIdentifier quotes an identifier for dialect
+func quoteIdentifier(dialect Dialect, name string) string {
+	if dialect == DialectMySQL {
+		return "`" + strings.ReplaceAll(name, "`", "``") + "`"
+	}
+	return `"` + strings.ReplaceAll(name, `"`, `""`) + `"`
+}
+
+// joinStatements joins CREATE statements into a script
+func joinStatements(statements []string) string {
+	var script strings.Builder
+	for _, statement := range statements {
+		statement = strings.TrimRight(strings.TrimSpace(statement), ";")
+		if statement == "" {
+			continue
+		}
+		fmt.Fprintf(&script, "%s;\n\n", statement)
+	}
+	return strings.TrimRight(script.String(), "\n") + "\n"
+}
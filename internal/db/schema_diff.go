@@ -0,0 +1,617 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// SchemaDiff is what has to change for the tables of one database to match
+// those of another of the same dialect, see DiffSchemas
+type SchemaDiff struct {
+	Dialect Dialect
+	Created []*TableInfo // tables of the wanted schema only
+	Dropped []*TableInfo // tables of the current schema only
+	Altered []*TableDiff
+	Notes   []string // differences the migration script leaves out
+}
+
+// TableDiff is how a table in both schemas differs. Changed indexes,
+// foreign keys and checks are dropped and added again.
+type TableDiff struct {
+	Current            *TableInfo
+	Wanted             *TableInfo
+	AddedColumns       []ColumnInfo
+	DroppedColumns     []ColumnInfo
+	ChangedColumns     []ColumnChange
+	PrimaryKey         bool // the primary key columns differ
+	AddedIndexes       []IndexInfo
+	DroppedIndexes     []IndexInfo
+	AddedForeignKeys   []ForeignKeyInfo
+	DroppedForeignKeys []ForeignKeyInfo
+	AddedChecks        []CheckInfo
+	DroppedChecks      []CheckInfo
+	rebuild            bool // SQLite creates the table again to change it
+}
+
+// ColumnChange is a column whose type, nullability, default or extra differ
+type ColumnChange struct {
+	Current ColumnInfo
+	Wanted  ColumnInfo
+}
+
+// ReadSchema describes the tables of a database for DiffSchemas
+func ReadSchema(ctx context.Context, driver Driver, database string) ([]*TableInfo, error) {
+	names, err := driver.GetTables(ctx, database)
+	if err != nil {
+		return nil, err
+	}
+
+	tables := make([]*TableInfo, 0, len(names))
+	for _, name := range names {
+		// PostgreSQL tables outside the public schema are listed with it
+		schema, table := "", name
+		if driver.GetDialect() == DialectPostgres {
+			if before, after, ok := strings.Cut(name, "."); ok {
+				schema, table = before, after
+			}
+		}
+		info, err := driver.DescribeTable(ctx, database, schema, table)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", name, err)
+		}
+		tables = append(tables, info)
+	}
+	return tables, nil
+}
+
+// DiffSchemas compares the tables of two databases, their columns, primary
+// keys, indexes, foreign keys and checks. Tables are matched by schema and
+// name, columns and indexes by name, foreign keys and checks by definition.
+func DiffSchemas(dialect Dialect, current, wanted []*TableInfo) *SchemaDiff {
+	diff := &SchemaDiff{Dialect: dialect}
+	key := func(table *TableInfo) string {
+		return table.Schema + "." + table.Name
+	}
+
+	currentTables := make(map[string]*TableInfo, len(current))
+	for _, table := range current {
+		currentTables[key(table)] = table
+	}
+	wantedTables := make(map[string]bool, len(wanted))
+	for _, table := range wanted {
+		wantedTables[key(table)] = true
+		other, ok := currentTables[key(table)]
+		if !ok {
+			diff.Created = append(diff.Created, table)
+			continue
+		}
+		if tableDiff := diff.diffTable(other, table); tableDiff != nil {
+			diff.Altered = append(diff.Altered, tableDiff)
+		}
+	}
+	for _, table := range current {
+		if !wantedTables[key(table)] {
+			diff.Dropped = append(diff.Dropped, table)
+		}
+	}
+
+	return diff
+}
+
+// Empty returns true if the schemas have the same tables
+func (d *SchemaDiff) Empty() bool {
+	return len(d.Created) == 0 && len(d.Dropped) == 0 && len(d.Altered) == 0
+}
+
+// Summary returns the number of tables to create, drop and alter
+func (d *SchemaDiff) Summary() string {
+	return fmt.Sprintf("%d tables to create, %d to drop, %d to alter", len(d.Created), len(d.Dropped), len(d.Altered))
+}
+
+// diffTable returns how a table differs, nil if it does not
+func (d *SchemaDiff) diffTable(current, wanted *TableInfo) *TableDiff {
+	diff := &TableDiff{Current: current, Wanted: wanted}
+
+	for _, column := range wanted.Columns {
+		i := slices.IndexFunc(current.Columns, func(c ColumnInfo) bool { return c.Name == column.Name })
+		switch {
+		case i < 0:
+			diff.AddedColumns = append(diff.AddedColumns, column)
+		case !sameColumn(current.Columns[i], column):
+			diff.ChangedColumns = append(diff.ChangedColumns, ColumnChange{Current: current.Columns[i], Wanted: column})
+		}
+	}
+	for _, column := range current.Columns {
+		if !slices.ContainsFunc(wanted.Columns, func(c ColumnInfo) bool { return c.Name == column.Name }) {
+			diff.DroppedColumns = append(diff.DroppedColumns, column)
+		}
+	}
+	diff.PrimaryKey = !slices.Equal(current.PrimaryKey, wanted.PrimaryKey)
+
+	currentIndexes, wantedIndexes := d.indexes(current), d.indexes(wanted)
+	for _, index := range wantedIndexes {
+		if !slices.ContainsFunc(currentIndexes, func(i IndexInfo) bool { return i.Name == index.Name && sameIndex(i, index) }) {
+			diff.AddedIndexes = append(diff.AddedIndexes, index)
+		}
+	}
+	for _, index := range currentIndexes {
+		if !slices.ContainsFunc(wantedIndexes, func(i IndexInfo) bool { return i.Name == index.Name && sameIndex(i, index) }) {
+			diff.DroppedIndexes = append(diff.DroppedIndexes, index)
+		}
+	}
+
+	for _, fk := range wanted.ForeignKeys {
+		if !slices.ContainsFunc(current.ForeignKeys, func(other ForeignKeyInfo) bool { return sameForeignKey(other, fk) }) {
+			diff.AddedForeignKeys = append(diff.AddedForeignKeys, fk)
+		}
+	}
+	for _, fk := range current.ForeignKeys {
+		if !slices.ContainsFunc(wanted.ForeignKeys, func(other ForeignKeyInfo) bool { return sameForeignKey(other, fk) }) {
+			diff.DroppedForeignKeys = append(diff.DroppedForeignKeys, fk)
+		}
+	}
+
+	for _, check := range wanted.Checks {
+		if !slices.Contains(current.Checks, check) {
+			diff.AddedChecks = append(diff.AddedChecks, check)
+		}
+	}
+	for _, check := range current.Checks {
+		if !slices.Contains(wanted.Checks, check) {
+			diff.DroppedChecks = append(diff.DroppedChecks, check)
+		}
+	}
+
+	if d.Dialect == DialectSQLite {
+		diff.rebuild = diff.needsRebuild()
+	}
+	if !diff.rebuild && len(diff.AddedColumns) == 0 && len(diff.DroppedColumns) == 0 && len(diff.ChangedColumns) == 0 &&
+		!diff.PrimaryKey && len(diff.AddedIndexes) == 0 && len(diff.DroppedIndexes) == 0 &&
+		len(diff.AddedForeignKeys) == 0 && len(diff.DroppedForeignKeys) == 0 &&
+		len(diff.AddedChecks) == 0 && len(diff.DroppedChecks) == 0 {
+		return nil
+	}
+	return diff
+}
+
+// indexes returns the indexes of a table the script creates and drops. The
+// primary key is compared on its own, the indexes SQLite creates for UNIQUE
+// constraints are part of the table. Indexes of MySQL expressions are
+// left out with a note.
+func (d *SchemaDiff) indexes(table *TableInfo) []IndexInfo {
+	var indexes []IndexInfo
+	for _, index := range table.Indexes {
+		switch {
+		case index.Primary, d.Dialect == DialectSQLite && index.Definition == "":
+		case d.Dialect == DialectMySQL && slices.Contains(index.Columns, "(expression)"):
+			d.note(fmt.Sprintf("index %s of %s has expressions and is not compared", index.Name, table.QualifiedName()))
+		default:
+			indexes = append(indexes, index)
+		}
+	}
+	return indexes
+}
+
+// note adds a note once
+func (d *SchemaDiff) note(note string) {
+	if !slices.Contains(d.Notes, note) {
+		d.Notes = append(d.Notes, note)
+	}
+}
+
+// needsRebuild returns true if SQLite cannot alter the table in place. It
+// only adds columns that can be added without a table scan.
+func (t *TableDiff) needsRebuild() bool {
+	if len(t.DroppedColumns) > 0 || len(t.ChangedColumns) > 0 || t.PrimaryKey ||
+		len(t.AddedForeignKeys) > 0 || len(t.DroppedForeignKeys) > 0 ||
+		len(t.AddedChecks) > 0 || len(t.DroppedChecks) > 0 {
+		return true
+	}
+	for _, column := range t.AddedColumns {
+		if (!column.Nullable && !column.HasDefault) || strings.Contains(column.Default, "(") ||
+			strings.HasPrefix(strings.ToUpper(column.Default), "CURRENT_") {
+			return true
+		}
+	}
+	// UNIQUE constraints are written in the table
+	return !slices.Equal(sqliteUniqueKeys(t.Current), sqliteUniqueKeys(t.Wanted))
+}
+
+// sqliteUniqueKeys returns the columns of the indexes SQLite creates for
+// the UNIQUE constraints of a table, sorted
+func sqliteUniqueKeys(table *TableInfo) []string {
+	var keys []string
+	for _, index := range table.Indexes {
+		if !index.Primary && index.Definition == "" {
+			keys = append(keys, strings.Join(index.Columns, ","))
+		}
+	}
+	slices.Sort(keys)
+	return keys
+}
+
+// sameColumn returns true if two columns of the same name are defined alike
+func sameColumn(a, b ColumnInfo) bool {
+	return strings.EqualFold(a.Type, b.Type) && a.Nullable == b.Nullable &&
+		a.HasDefault == b.HasDefault && a.Default == b.Default && strings.EqualFold(a.Extra, b.Extra)
+}
+
+// sameIndex returns true if two indexes of the same name are defined alike
+func sameIndex(a, b IndexInfo) bool {
+	if a.Unique != b.Unique || a.Constraint != b.Constraint || !slices.Equal(a.Columns, b.Columns) {
+		return false
+	}
+	return a.Definition == "" || b.Definition == "" ||
+		strings.Join(strings.Fields(a.Definition), " ") == strings.Join(strings.Fields(b.Definition), " ")
+}
+
+// sameForeignKey returns true if two foreign keys refer alike, whatever their names
+func sameForeignKey(a, b ForeignKeyInfo) bool {
+	return slices.Equal(a.Columns, b.Columns) && a.RefTable == b.RefTable && slices.Equal(a.RefColumns, b.RefColumns) &&
+		a.OnUpdate == b.OnUpdate && a.OnDelete == b.OnDelete
+}
+
+// Script returns the statements that make the current schema match the
+// wanted one: foreign keys and indexes are dropped first, then tables are
+// dropped, created and altered, and indexes and foreign keys added last.
+func (d *SchemaDiff) Script() string {
+	var drops, tables, creates, foreignKeys []string
+
+	for _, table := range d.Dropped {
+		if d.Dialect != DialectSQLite {
+			for _, fk := range table.ForeignKeys {
+				drops = append(drops, d.dropForeignKey(table, fk))
+			}
+		}
+		tables = append(tables, "DROP TABLE "+d.tableName(table))
+	}
+
+	for _, table := range d.Created {
+		tables = append(tables, d.createTable(table))
+		creates = append(creates, d.createIndexes(table, d.indexes(table))...)
+	}
+	for _, table := range d.Created {
+		if d.Dialect != DialectSQLite {
+			for _, fk := range table.ForeignKeys {
+				foreignKeys = append(foreignKeys, d.addForeignKey(table, fk))
+			}
+		}
+	}
+
+	for _, diff := range d.Altered {
+		switch {
+		case diff.rebuild:
+			tables = append(tables, d.rebuildTable(diff)...)
+			creates = append(creates, d.createIndexes(diff.Wanted, d.indexes(diff.Wanted))...)
+			for _, trigger := range diff.Current.Triggers {
+				creates = append(creates, trigger.Definition)
+			}
+			continue
+		case d.Dialect == DialectSQLite:
+			for _, column := range diff.AddedColumns {
+				tables = append(tables, fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s", d.tableName(diff.Current), d.columnDefinition(column)))
+			}
+		default:
+			for _, fk := range diff.DroppedForeignKeys {
+				drops = append(drops, d.dropForeignKey(diff.Current, fk))
+			}
+			tables = append(tables, d.alterTable(diff)...)
+			for _, fk := range diff.AddedForeignKeys {
+				foreignKeys = append(foreignKeys, d.addForeignKey(diff.Wanted, fk))
+			}
+		}
+		for _, index := range diff.DroppedIndexes {
+			drops = append(drops, d.dropIndex(diff.Current, index)...)
+		}
+		creates = append(creates, d.createIndexes(diff.Wanted, diff.AddedIndexes)...)
+	}
+	creates = append(creates, foreignKeys...)
+
+	statements := append(drops, tables...)
+	return joinStatements(append(statements, creates...))
+}
+
+// tableName returns the quoted name of a table, PostgreSQL tables are
+// qualified with their schema
+func (d *SchemaDiff) tableName(table *TableInfo) string {
+	if d.Dialect == DialectPostgres {
+		return QuoteTable(d.Dialect, table.Schema, table.Name)
+	}
+	return quoteIdentifier(d.Dialect, table.Name)
+}
+
+// columnList returns quoted column names separated by commas
+func (d *SchemaDiff) columnList(columns []string) string {
+	quoted := make([]string, len(columns))
+	for i, column := range columns {
+		quoted[i] = quoteIdentifier(d.Dialect, column)
+	}
+	return strings.Join(quoted, ", ")
+}
+
+// columnDefinition returns a column as written in CREATE and ALTER TABLE
+func (d *SchemaDiff) columnDefinition(column ColumnInfo) string {
+	definition := strings.TrimSpace(quoteIdentifier(d.Dialect, column.Name) + " " + column.Type)
+
+	if d.Dialect == DialectMySQL {
+		if column.Nullable {
+			definition += " NULL"
+		} else {
+			definition += " NOT NULL"
+		}
+		if column.HasDefault {
+			definition += " DEFAULT " + mysqlDefault(column)
+		}
+		if extra := strings.TrimSpace(strings.ReplaceAll(column.Extra, "DEFAULT_GENERATED", "")); extra != "" {
+			definition += " " + extra
+		}
+		if column.Comment != "" {
+			definition += " COMMENT " + Literal(DialectMySQL, Value{Text: column.Comment})
+		}
+		return definition
+	}
+
+	if column.Extra != "" {
+		definition += " " + column.Extra
+	} else if column.HasDefault {
+		definition += " DEFAULT " + column.Default
+	}
+	if !column.Nullable {
+		definition += " NOT NULL"
+	}
+	return definition
+}
+
+// mysqlDefault returns the default of a MySQL column as an expression, the
+// catalog has the text of literals without quotes
+func mysqlDefault(column ColumnInfo) string {
+	switch {
+	case strings.HasPrefix(strings.ToUpper(column.Default), "CURRENT_TIMESTAMP"):
+		return column.Default
+	case strings.Contains(column.Extra, "DEFAULT_GENERATED"):
+		return "(" + column.Default + ")"
+	case numberLiteral.MatchString(column.Default):
+		return column.Default
+	}
+	return Literal(DialectMySQL, Value{Text: column.Default})
+}
+
+// createTable returns the CREATE TABLE statement of a new table. Foreign
+// keys are added once all tables exist, except on SQLite, which keeps the
+// statement the table was created with.
+func (d *SchemaDiff) createTable(table *TableInfo) string {
+	if d.Dialect == DialectSQLite {
+		if statements := Split(DialectSQLite, table.DDL); len(statements) > 0 {
+			return statements[0].Text
+		}
+	}
+
+	var constraints []string
+	if len(table.PrimaryKey) > 0 {
+		constraints = append(constraints, d.primaryKey(table))
+	}
+	for _, check := range table.Checks {
+		constraints = append(constraints, d.check(check))
+	}
+	if d.Dialect == DialectPostgres {
+		return createTableStatement(table, constraints)
+	}
+
+	lines := make([]string, 0, len(table.Columns)+len(constraints))
+	for _, column := range table.Columns {
+		lines = append(lines, "    "+d.columnDefinition(column))
+	}
+	for _, constraint := range constraints {
+		lines = append(lines, "    "+constraint)
+	}
+	return fmt.Sprintf("CREATE TABLE %s (\n%s\n)", d.tableName(table), strings.Join(lines, ",\n"))
+}
+
+// primaryKey returns the primary key constraint of a table
+func (d *SchemaDiff) primaryKey(table *TableInfo) string {
+	key := fmt.Sprintf("PRIMARY KEY (%s)", d.columnList(table.PrimaryKey))
+	if d.Dialect != DialectPostgres {
+		return key
+	}
+	for _, index := range table.Indexes {
+		if index.Primary {
+			return fmt.Sprintf("CONSTRAINT %s %s", quoteIdentifier(d.Dialect, index.Name), key)
+		}
+	}
+	return key
+}
+
+// check returns a check constraint
+func (d *SchemaDiff) check(check CheckInfo) string {
+	if check.Name == "" {
+		return fmt.Sprintf("CHECK (%s)", check.Expression)
+	}
+	return fmt.Sprintf("CONSTRAINT %s CHECK (%s)", quoteIdentifier(d.Dialect, check.Name), check.Expression)
+}
+
+// alterTable returns the ALTER TABLE statements that change the columns,
+// primary key and checks of a PostgreSQL or MySQL table
+func (d *SchemaDiff) alterTable(diff *TableDiff) []string {
+	var statements []string
+	alter := func(format string, args ...any) {
+		statements = append(statements, fmt.Sprintf("ALTER TABLE %s ", d.tableName(diff.Current))+fmt.Sprintf(format, args...))
+	}
+
+	for _, check := range diff.DroppedChecks {
+		if d.Dialect == DialectMySQL {
+			alter("DROP CHECK %s", quoteIdentifier(d.Dialect, check.Name))
+		} else {
+			alter("DROP CONSTRAINT %s", quoteIdentifier(d.Dialect, check.Name))
+		}
+	}
+	if diff.PrimaryKey && len(diff.Current.PrimaryKey) > 0 {
+		if d.Dialect == DialectMySQL {
+			alter("DROP PRIMARY KEY")
+		} else if i := slices.IndexFunc(diff.Current.Indexes, func(index IndexInfo) bool { return index.Primary }); i >= 0 {
+			alter("DROP CONSTRAINT %s", quoteIdentifier(d.Dialect, diff.Current.Indexes[i].Name))
+		}
+	}
+	for _, column := range diff.DroppedColumns {
+		alter("DROP COLUMN %s", quoteIdentifier(d.Dialect, column.Name))
+	}
+	for _, column := range diff.AddedColumns {
+		alter("ADD COLUMN %s", d.columnDefinition(column))
+	}
+	for _, change := range diff.ChangedColumns {
+		if d.Dialect == DialectMySQL {
+			alter("MODIFY COLUMN %s", d.columnDefinition(change.Wanted))
+			continue
+		}
+		for _, action := range alterColumn(change.Current, change.Wanted) {
+			alter("ALTER COLUMN %s %s", quoteIdentifier(d.Dialect, change.Wanted.Name), action)
+		}
+	}
+	if diff.PrimaryKey && len(diff.Wanted.PrimaryKey) > 0 {
+		alter("ADD %s", d.primaryKey(diff.Wanted))
+	}
+	for _, check := range diff.AddedChecks {
+		alter("ADD %s", d.check(check))
+	}
+
+	return statements
+}
+
+// alterColumn returns the PostgreSQL ALTER COLUMN actions changing a column
+func alterColumn(current, wanted ColumnInfo) []string {
+	var actions []string
+	if !strings.EqualFold(current.Type, wanted.Type) {
+		actions = append(actions, "TYPE "+wanted.Type)
+	}
+	if !strings.EqualFold(current.Extra, wanted.Extra) {
+		if current.Extra != "" {
+			actions = append(actions, "DROP IDENTITY")
+		}
+		if wanted.Extra != "" {
+			actions = append(actions, "ADD "+wanted.Extra)
+		}
+	}
+	if current.HasDefault != wanted.HasDefault || current.Default != wanted.Default {
+		if wanted.HasDefault {
+			actions = append(actions, "SET DEFAULT "+wanted.Default)
+		} else {
+			actions = append(actions, "DROP DEFAULT")
+		}
+	}
+	if current.Nullable != wanted.Nullable {
+		if wanted.Nullable {
+			actions = append(actions, "DROP NOT NULL")
+		} else {
+			actions = append(actions, "SET NOT NULL")
+		}
+	}
+	return actions
+}
+
+// rebuildTable returns the statements that create a SQLite table again
+// with its wanted definition and copy the rows of the columns it keeps.
+// The indexes and triggers of the table are dropped with it.
+func (d *SchemaDiff) rebuildTable(diff *TableDiff) []string {
+	create := d.createTable(diff.Wanted)
+	temporary := "_new_" + diff.Wanted.Name
+	open := strings.IndexByte(create, '(')
+	if open < 0 {
+		d.note(fmt.Sprintf("table %s cannot be created again, change it by hand", diff.Wanted.QualifiedName()))
+		return nil
+	}
+	create = fmt.Sprintf("CREATE TABLE %s %s", quoteIdentifier(d.Dialect, temporary), create[open:])
+
+	var columns []string
+	for _, column := range diff.Wanted.Columns {
+		if slices.ContainsFunc(diff.Current.Columns, func(c ColumnInfo) bool { return c.Name == column.Name }) {
+			columns = append(columns, column.Name)
+		}
+	}
+	list := d.columnList(columns)
+
+	return []string{
+		create,
+		fmt.Sprintf("INSERT INTO %s (%s) SELECT %s FROM %s", quoteIdentifier(d.Dialect, temporary), list, list, d.tableName(diff.Current)),
+		"DROP TABLE " + d.tableName(diff.Current),
+		fmt.Sprintf("ALTER TABLE %s RENAME TO %s", quoteIdentifier(d.Dialect, temporary), d.tableName(diff.Wanted)),
+	}
+}
+
+// createIndexes returns the statements that create indexes of a table
+func (d *SchemaDiff) createIndexes(table *TableInfo, indexes []IndexInfo) []string {
+	var statements []string
+	for _, index := range indexes {
+		switch {
+		case d.Dialect == DialectMySQL:
+			unique := ""
+			if index.Unique {
+				unique = "UNIQUE "
+			}
+			statements = append(statements, fmt.Sprintf("CREATE %sINDEX %s ON %s (%s)",
+				unique, quoteIdentifier(d.Dialect, index.Name), d.tableName(table), d.columnList(index.Columns)))
+		case index.Definition == "":
+			d.note(fmt.Sprintf("index %s of %s has no definition and is not created", index.Name, table.QualifiedName()))
+		default:
+			statements = append(statements, index.Definition)
+			// A unique constraint takes over the index created for it
+			if index.Constraint && index.Unique {
+				statements = append(statements, fmt.Sprintf("ALTER TABLE %s ADD CONSTRAINT %s UNIQUE USING INDEX %s",
+					d.tableName(table), quoteIdentifier(d.Dialect, index.Name), quoteIdentifier(d.Dialect, index.Name)))
+			}
+		}
+	}
+	return statements
+}
+
+// dropIndex returns the statements that drop an index of a table
+func (d *SchemaDiff) dropIndex(table *TableInfo, index IndexInfo) []string {
+	name := quoteIdentifier(d.Dialect, index.Name)
+	switch {
+	case d.Dialect == DialectMySQL:
+		return []string{fmt.Sprintf("DROP INDEX %s ON %s", name, d.tableName(table))}
+	case d.Dialect == DialectPostgres && index.Constraint:
+		return []string{fmt.Sprintf("ALTER TABLE %s DROP CONSTRAINT %s", d.tableName(table), name)}
+	case d.Dialect == DialectPostgres:
+		return []string{"DROP INDEX " + QuoteTable(d.Dialect, table.Schema, index.Name)}
+	}
+	return []string{"DROP INDEX " + name}
+}
+
+// addForeignKey returns the statement that adds a foreign key to a table
+func (d *SchemaDiff) addForeignKey(table *TableInfo, fk ForeignKeyInfo) string {
+	refTable := fk.RefTable
+	switch d.Dialect {
+	case DialectMySQL:
+		// Tables of other databases are qualified with them
+		if database, name, ok := strings.Cut(refTable, "."); ok {
+			refTable = QuoteTable(d.Dialect, database, name)
+		} else {
+			refTable = quoteIdentifier(d.Dialect, refTable)
+		}
+	case DialectSQLite:
+		refTable = quoteIdentifier(d.Dialect, refTable)
+	}
+
+	constraint := ""
+	if fk.Name != "" {
+		constraint = "CONSTRAINT " + quoteIdentifier(d.Dialect, fk.Name) + " "
+	}
+	statement := fmt.Sprintf("ALTER TABLE %s ADD %sFOREIGN KEY (%s) REFERENCES %s (%s)",
+		d.tableName(table), constraint, d.columnList(fk.Columns), refTable, d.columnList(fk.RefColumns))
+	if fk.OnUpdate != "" && fk.OnUpdate != "NO ACTION" {
+		statement += " ON UPDATE " + fk.OnUpdate
+	}
+	if fk.OnDelete != "" && fk.OnDelete != "NO ACTION" {
+		statement += " ON DELETE " + fk.OnDelete
+	}
+	return statement
+}
+
+// dropForeignKey returns the statement that drops a foreign key of a table
+func (d *SchemaDiff) dropForeignKey(table *TableInfo, fk ForeignKeyInfo) string {
+	if d.Dialect == DialectMySQL {
+		return fmt.Sprintf("ALTER TABLE %s DROP FOREIGN KEY %s", d.tableName(table), quoteIdentifier(d.Dialect, fk.Name))
+	}
+	return fmt.Sprintf("ALTER TABLE %s DROP CONSTRAINT %s", d.tableName(table), quoteIdentifier(d.Dialect, fk.Name))
+}
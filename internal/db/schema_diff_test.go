@@ -0,0 +1,216 @@
+package db
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// scriptStatements splits a migration script into its statements
+func scriptStatements(script string) []string {
+	script = strings.TrimSuffix(strings.TrimSpace(script), ";")
+	if script == "" {
+		return nil
+	}
+	return strings.Split(script, ";\n\n")
+}
+
+// usersTable returns a users table with an id primary key and a name
+func usersTable(schema string, columns ...ColumnInfo) *TableInfo {
+	table := &TableInfo{
+		Schema:     schema,
+		Name:       "users",
+		Columns:    []ColumnInfo{{Name: "id", Type: "integer"}, {Name: "name", Type: "text", Nullable: true}},
+		PrimaryKey: []string{"id"},
+	}
+	table.Columns = append(table.Columns, columns...)
+	return table
+}
+
+func TestSchemaDiffScript(t *testing.T) {
+	tests := []struct {
+		name    string
+		dialect Dialect
+		current []*TableInfo
+		wanted  []*TableInfo
+		want    []string
+		summary string
+	}{
+		{
+			name:    "identical",
+			dialect: DialectPostgres,
+			current: []*TableInfo{usersTable("public")},
+			wanted:  []*TableInfo{usersTable("public")},
+			summary: "0 tables to create, 0 to drop, 0 to alter",
+		},
+		{
+			name:    "PostgreSQL added column",
+			dialect: DialectPostgres,
+			current: []*TableInfo{usersTable("public")},
+			wanted:  []*TableInfo{usersTable("public", ColumnInfo{Name: "age", Type: "integer", HasDefault: true, Default: "0"})},
+			want:    []string{`ALTER TABLE "public"."users" ADD COLUMN "age" integer DEFAULT 0 NOT NULL`},
+			summary: "0 tables to create, 0 to drop, 1 to alter",
+		},
+		{
+			name:    "PostgreSQL dropped column",
+			dialect: DialectPostgres,
+			current: []*TableInfo{usersTable("public", ColumnInfo{Name: "age", Type: "integer", Nullable: true})},
+			wanted:  []*TableInfo{usersTable("public")},
+			want:    []string{`ALTER TABLE "public"."users" DROP COLUMN "age"`},
+			summary: "0 tables to create, 0 to drop, 1 to alter",
+		},
+		{
+			name:    "PostgreSQL altered column",
+			dialect: DialectPostgres,
+			current: []*TableInfo{usersTable("public", ColumnInfo{Name: "age", Type: "integer", Nullable: true})},
+			wanted:  []*TableInfo{usersTable("public", ColumnInfo{Name: "age", Type: "bigint", HasDefault: true, Default: "0"})},
+			want: []string{
+				`ALTER TABLE "public"."users" ALTER COLUMN "age" TYPE bigint`,
+				`ALTER TABLE "public"."users" ALTER COLUMN "age" SET DEFAULT 0`,
+				`ALTER TABLE "public"."users" ALTER COLUMN "age" SET NOT NULL`,
+			},
+			summary: "0 tables to create, 0 to drop, 1 to alter",
+		},
+		{
+			name:    "PostgreSQL created and dropped tables",
+			dialect: DialectPostgres,
+			current: []*TableInfo{{Schema: "public", Name: "old", Columns: []ColumnInfo{{Name: "id", Type: "integer"}}}},
+			wanted:  []*TableInfo{usersTable("public")},
+			want: []string{
+				`DROP TABLE "public"."old"`,
+				"CREATE TABLE \"public\".\"users\" (\n    \"id\" integer NOT NULL,\n    \"name\" text,\n    PRIMARY KEY (\"id\")\n)",
+			},
+			summary: "1 tables to create, 1 to drop, 0 to alter",
+		},
+		{
+			name:    "MySQL added column",
+			dialect: DialectMySQL,
+			current: []*TableInfo{usersTable("")},
+			wanted:  []*TableInfo{usersTable("", ColumnInfo{Name: "city", Type: "varchar(50)", Nullable: true, HasDefault: true, Default: "Paris"})},
+			want:    []string{"ALTER TABLE `users` ADD COLUMN `city` varchar(50) NULL DEFAULT 'Paris'"},
+			summary: "0 tables to create, 0 to drop, 1 to alter",
+		},
+		{
+			name:    "MySQL dropped column",
+			dialect: DialectMySQL,
+			current: []*TableInfo{usersTable("", ColumnInfo{Name: "city", Type: "varchar(50)", Nullable: true})},
+			wanted:  []*TableInfo{usersTable("")},
+			want:    []string{"ALTER TABLE `users` DROP COLUMN `city`"},
+			summary: "0 tables to create, 0 to drop, 1 to alter",
+		},
+		{
+			name:    "MySQL altered column",
+			dialect: DialectMySQL,
+			current: []*TableInfo{usersTable("", ColumnInfo{Name: "city", Type: "varchar(50)", Nullable: true})},
+			wanted:  []*TableInfo{usersTable("", ColumnInfo{Name: "city", Type: "varchar(100)", Comment: "home town"})},
+			want:    []string{"ALTER TABLE `users` MODIFY COLUMN `city` varchar(100) NOT NULL COMMENT 'home town'"},
+			summary: "0 tables to create, 0 to drop, 1 to alter",
+		},
+		{
+			name:    "SQLite added column",
+			dialect: DialectSQLite,
+			current: []*TableInfo{usersTable("")},
+			wanted:  []*TableInfo{usersTable("", ColumnInfo{Name: "age", Type: "INTEGER", Nullable: true})},
+			want:    []string{`ALTER TABLE "users" ADD COLUMN "age" INTEGER`},
+			summary: "0 tables to create, 0 to drop, 1 to alter",
+		},
+		{
+			name:    "SQLite added NOT NULL column without a default",
+			dialect: DialectSQLite,
+			current: []*TableInfo{usersTable("")},
+			wanted:  []*TableInfo{usersTable("", ColumnInfo{Name: "age", Type: "INTEGER"})},
+			want: []string{
+				"CREATE TABLE \"_new_users\" (\n    \"id\" integer NOT NULL,\n    \"name\" text,\n    \"age\" INTEGER NOT NULL,\n    PRIMARY KEY (\"id\")\n)",
+				`INSERT INTO "_new_users" ("id", "name") SELECT "id", "name" FROM "users"`,
+				`DROP TABLE "users"`,
+				`ALTER TABLE "_new_users" RENAME TO "users"`,
+			},
+			summary: "0 tables to create, 0 to drop, 1 to alter",
+		},
+		{
+			name:    "SQLite dropped column",
+			dialect: DialectSQLite,
+			current: []*TableInfo{usersTable("", ColumnInfo{Name: "age", Type: "INTEGER", Nullable: true})},
+			wanted:  []*TableInfo{usersTable("")},
+			want: []string{
+				"CREATE TABLE \"_new_users\" (\n    \"id\" integer NOT NULL,\n    \"name\" text,\n    PRIMARY KEY (\"id\")\n)",
+				`INSERT INTO "_new_users" ("id", "name") SELECT "id", "name" FROM "users"`,
+				`DROP TABLE "users"`,
+				`ALTER TABLE "_new_users" RENAME TO "users"`,
+			},
+			summary: "0 tables to create, 0 to drop, 1 to alter",
+		},
+		{
+			name:    "SQLite altered column keeps indexes and triggers",
+			dialect: DialectSQLite,
+			current: []*TableInfo{func() *TableInfo {
+				table := usersTable("")
+				table.Indexes = []IndexInfo{{Name: "users_name", Columns: []string{"name"}, Definition: `CREATE INDEX "users_name" ON "users" ("name")`}}
+				table.Triggers = []TriggerInfo{{Name: "users_touch", Definition: `CREATE TRIGGER "users_touch" AFTER UPDATE ON "users" BEGIN SELECT 1; END`}}
+				return table
+			}()},
+			wanted: []*TableInfo{func() *TableInfo {
+				table := usersTable("")
+				table.Columns[1] = ColumnInfo{Name: "name", Type: "text", HasDefault: true, Default: "''"}
+				table.Indexes = []IndexInfo{{Name: "users_name", Columns: []string{"name"}, Definition: `CREATE INDEX "users_name" ON "users" ("name")`}}
+				return table
+			}()},
+			want: []string{
+				"CREATE TABLE \"_new_users\" (\n    \"id\" integer NOT NULL,\n    \"name\" text DEFAULT '' NOT NULL,\n    PRIMARY KEY (\"id\")\n)",
+				`INSERT INTO "_new_users" ("id", "name") SELECT "id", "name" FROM "users"`,
+				`DROP TABLE "users"`,
+				`ALTER TABLE "_new_users" RENAME TO "users"`,
+				`CREATE INDEX "users_name" ON "users" ("name")`,
+				`CREATE TRIGGER "users_touch" AFTER UPDATE ON "users" BEGIN SELECT 1; END`,
+			},
+			summary: "0 tables to create, 0 to drop, 1 to alter",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			diff := DiffSchemas(tt.dialect, tt.current, tt.wanted)
+			if got := scriptStatements(diff.Script()); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got statements\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(tt.want, "\n"))
+			}
+			if got := diff.Summary(); got != tt.summary {
+				t.Errorf("got summary %q, want %q", got, tt.summary)
+			}
+			if diff.Empty() != (tt.want == nil) {
+				t.Errorf("got Empty() %v for %d statements", diff.Empty(), len(tt.want))
+			}
+		})
+	}
+}
+
+func TestSchemaDiffColumns(t *testing.T) {
+	current := usersTable("public", ColumnInfo{Name: "age", Type: "integer", Nullable: true}, ColumnInfo{Name: "city", Type: "text"})
+	wanted := usersTable("public", ColumnInfo{Name: "age", Type: "INTEGER", Nullable: true}, ColumnInfo{Name: "email", Type: "text"})
+	wanted.Columns[1].Type = "varchar(100)"
+
+	diff := DiffSchemas(DialectPostgres, []*TableInfo{current}, []*TableInfo{wanted})
+	if len(diff.Altered) != 1 {
+		t.Fatalf("got %d altered tables, want 1", len(diff.Altered))
+	}
+	table := diff.Altered[0]
+
+	names := func(columns []ColumnInfo) []string {
+		var names []string
+		for _, column := range columns {
+			names = append(names, column.Name)
+		}
+		return names
+	}
+	if got, want := names(table.AddedColumns), []string{"email"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got added columns %v, want %v", got, want)
+	}
+	if got, want := names(table.DroppedColumns), []string{"city"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got dropped columns %v, want %v", got, want)
+	}
+	// Type names differing only in case are the same type
+	if len(table.ChangedColumns) != 1 || table.ChangedColumns[0].Wanted.Name != "name" {
+		t.Errorf("got changed columns %v, want name", table.ChangedColumns)
+	}
+	if table.PrimaryKey {
+		t.Error("unchanged primary key reported as changed")
+	}
+}
@@ -0,0 +1,177 @@
+package db
+
+import (
+	"strings"
+	"unicode"
+)
+
+// Statement is a statement of a script
+type Statement struct {
+	Text  string // statement without the trailing delimiter
+	Start int    // byte offset of the first character in the script
+	End   int    // byte offset after the delimiter
+	Line  int    // line of the first character, starting at 1
+	// Params are the values of the :name placeholders of Text, bound by
+	// OpenStatement. Statements without parameters leave it nil.
+	Params map[string]any
+}
+
+// Split splits a script into statements at semicolons outside string
+// literals, quoted identifiers, comments and PostgreSQL dollar quotes.
+// MySQL scripts may change the delimiter with a DELIMITER line and SQLite
+// trigger bodies (BEGIN ... END) are kept together. Statements with
+// nothing but comments are dropped.
+func Split(dialect Dialect, script string) []Statement {
+	runes := []rune(script)
+
+	// Byte offset of every rune, the statement offsets are used with the editor
+	offsets := make([]int, len(runes)+1)
+	offset := 0
+	for i, r := range runes {
+		offsets[i] = offset
+		offset += len(string(r))
+	}
+	offsets[len(runes)] = offset
+
+	var statements []Statement
+	delimiter := []rune(";")
+	start := 0
+	var words []string // leading words of the current statement
+	blockDepth := 0
+
+	add := func(end, next int) {
+		text := string(runes[start:end])
+		if len(scanWords(dialect, text)) > 0 {
+			lead := len(text) - len(strings.TrimLeftFunc(text, unicode.IsSpace))
+			first := offsets[start] + lead
+			statements = append(statements, Statement{
+				Text:  strings.TrimSpace(text),
+				Start: first,
+				End:   offsets[next],
+				Line:  strings.Count(script[:first], "\n") + 1,
+			})
+		}
+		start = next
+		words = nil
+		blockDepth = 0
+	}
+
+	for i := 0; i < len(runes); {
+		r := runes[i]
+
+		// A DELIMITER line may only start a statement
+		if dialect == DialectMySQL && len(words) == 0 && (i == 0 || runes[i-1] == '\n') {
+			if next, newDelimiter, ok := parseDelimiter(runes, i); ok {
+				start = i
+				add(i, next)
+				delimiter = newDelimiter
+				i = next
+				continue
+			}
+		}
+
+		if blockDepth == 0 && hasPrefix(runes, i, delimiter) {
+			next := i + len(delimiter)
+			add(i, next)
+			i = next
+			continue
+		}
+
+		if next, kind := skipLexeme(dialect, runes, i); kind != lexemeNone {
+			i = next
+			continue
+		}
+
+		switch {
+		case unicode.IsLetter(r) || r == '_':
+			wordStart := i
+			for i < len(runes) && isWordRune(runes[i]) {
+				i++
+			}
+			word := strings.ToUpper(string(runes[wordStart:i]))
+			words = append(words, word)
+
+			// Semicolons inside a SQLite trigger body do not end the statement
+			if dialect == DialectSQLite && isTrigger(words) {
+				switch word {
+				case "BEGIN", "CASE":
+					blockDepth++
+				case "END":
+					if blockDepth > 0 {
+						blockDepth--
+					}
+				}
+			}
+		default:
+			i++
+		}
+	}
+	add(len(runes), len(runes))
+
+	return statements
+}
+
+// parseDelimiter parses a MySQL client "DELIMITER x" line starting at i and
+// returns the position after the line and the new delimiter
+func parseDelimiter(runes []rune, i int) (int, []rune, bool) {
+	j := i
+	for j < len(runes) && (runes[j] == ' ' || runes[j] == '\t') {
+		j++
+	}
+
+	const keyword = "DELIMITER"
+	if j+len(keyword) >= len(runes) || !strings.EqualFold(string(runes[j:j+len(keyword)]), keyword) {
+		return 0, nil, false
+	}
+	j += len(keyword)
+	if runes[j] != ' ' && runes[j] != '\t' {
+		return 0, nil, false
+	}
+
+	end := skipLine(runes, j)
+	fields := strings.Fields(string(runes[j:end]))
+	if len(fields) == 0 {
+		return 0, nil, false
+	}
+	if end < len(runes) {
+		end++
+	}
+
+	return end, []rune(fields[0]), true
+}
+
+// hasPrefix returns true if runes continue with prefix at i
+func hasPrefix(runes []rune, i int, prefix []rune) bool {
+	if i+len(prefix) > len(runes) {
+		return false
+	}
+	for j, r := range prefix {
+		if runes[i+j] != r {
+			return false
+		}
+	}
+	return true
+}
+
+// isTrigger returns true if the leading words create a trigger
+func isTrigger(words []string) bool {
+	if len(words) < 2 || words[0] != "CREATE" {
+		return false
+	}
+	if words[1] == "TEMP" || words[1] == "TEMPORARY" {
+		return len(words) > 2 && words[2] == "TRIGGER"
+	}
+	return words[1] == "TRIGGER"
+}
+
+// StatementAt returns the index of the statement at a byte offset of the
+// script: the statement containing it, the next one if the offset is between
+// statements, or the last statement. It returns -1 if there are no statements.
+func StatementAt(statements []Statement, offset int) int {
+	for i, statement := range statements {
+		if offset <= statement.End {
+			return i
+		}
+	}
+	return len(statements) - 1
+}
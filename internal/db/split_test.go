@@ -0,0 +1,168 @@
+package db
+
+import (
+	"reflect"
+	"testing"
+)
+
+// statementTexts returns the texts of statements
+func statementTexts(statements []Statement) []string {
+	var texts []string
+	for _, statement := range statements {
+		texts = append(texts, statement.Text)
+	}
+	return texts
+}
+
+func TestSplit(t *testing.T) {
+	tests := []struct {
+		name    string
+		dialect Dialect
+		script  string
+		want    []string
+	}{
+		{
+			name:    "empty",
+			dialect: DialectPostgres,
+			script:  " ;\n; ",
+		},
+		{
+			name:    "without trailing semicolon",
+			dialect: DialectPostgres,
+			script:  "SELECT 1;\n  SELECT 2  ",
+			want:    []string{"SELECT 1", "SELECT 2"},
+		},
+		{
+			name:    "semicolons in quotes",
+			dialect: DialectPostgres,
+			script:  `SELECT 'a;b', 'it''s;'; SELECT "c;d" FROM t; SELECT E'\';'`,
+			want:    []string{`SELECT 'a;b', 'it''s;'`, `SELECT "c;d" FROM t`, `SELECT E'\';'`},
+		},
+		{
+			name:    "semicolons in dollar quotes",
+			dialect: DialectPostgres,
+			script:  "CREATE FUNCTION f() RETURNS int AS $body$ BEGIN RETURN 1; END; $body$ LANGUAGE plpgsql; SELECT $$;$$, $1",
+			want:    []string{"CREATE FUNCTION f() RETURNS int AS $body$ BEGIN RETURN 1; END; $body$ LANGUAGE plpgsql", "SELECT $$;$$, $1"},
+		},
+		{
+			name:    "MySQL backslash escapes",
+			dialect: DialectMySQL,
+			script:  `INSERT INTO t VALUES ('a\';b', "c\";d"); SELECT ` + "`e;f`",
+			want:    []string{`INSERT INTO t VALUES ('a\';b', "c\";d")`, "SELECT `e;f`"},
+		},
+		{
+			name:    "line comments",
+			dialect: DialectPostgres,
+			script:  "SELECT 1 -- not the end;\n, 2;\n-- only a comment;\n",
+			want:    []string{"SELECT 1 -- not the end;\n, 2"},
+		},
+		{
+			name:    "MySQL hash comments",
+			dialect: DialectMySQL,
+			script:  "SELECT 1 # not the end;\n;\n# only a comment;",
+			want:    []string{"SELECT 1 # not the end;"},
+		},
+		{
+			name:    "nested block comments",
+			dialect: DialectPostgres,
+			script:  "SELECT /* a /* b; */ c; */ 1; /* only; a comment */;",
+			want:    []string{"SELECT /* a /* b; */ c; */ 1"},
+		},
+		{
+			name:    "block comments do not nest in SQLite",
+			dialect: DialectSQLite,
+			script:  "SELECT /* a /* b */ 1; c */",
+			want:    []string{"SELECT /* a /* b */ 1", "c */"},
+		},
+		{
+			name:    "MySQL DELIMITER",
+			dialect: DialectMySQL,
+			script: "SELECT 1;\nDELIMITER //\nCREATE PROCEDURE p() BEGIN SELECT 1; SELECT 2; END //\n" +
+				"CALL p()//\ndelimiter ;\nSELECT 3;",
+			want: []string{"SELECT 1", "CREATE PROCEDURE p() BEGIN SELECT 1; SELECT 2; END", "CALL p()", "SELECT 3"},
+		},
+		{
+			name:    "DELIMITER only starts a statement",
+			dialect: DialectMySQL,
+			script:  "SELECT 'x'\nDELIMITER //\n;",
+			want:    []string{"SELECT 'x'\nDELIMITER //"},
+		},
+		{
+			name:    "DELIMITER is MySQL only",
+			dialect: DialectPostgres,
+			script:  "DELIMITER //\nSELECT 1;",
+			want:    []string{"DELIMITER //\nSELECT 1"},
+		},
+		{
+			name:    "SQLite trigger",
+			dialect: DialectSQLite,
+			script: "CREATE TEMP TRIGGER tr AFTER INSERT ON t BEGIN\n  UPDATE t SET n = CASE WHEN n > 0 THEN 1 ELSE 0 END;\n" +
+				"  DELETE FROM u;\nEND; SELECT 1;",
+			want: []string{
+				"CREATE TEMP TRIGGER tr AFTER INSERT ON t BEGIN\n  UPDATE t SET n = CASE WHEN n > 0 THEN 1 ELSE 0 END;\n  DELETE FROM u;\nEND",
+				"SELECT 1",
+			},
+		},
+		{
+			name:    "BEGIN outside a trigger",
+			dialect: DialectSQLite,
+			script:  "BEGIN; INSERT INTO t VALUES (1); END;",
+			want:    []string{"BEGIN", "INSERT INTO t VALUES (1)", "END"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := statementTexts(Split(tt.dialect, tt.script)); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSplitOffsets(t *testing.T) {
+	script := "SELECT 'é';\n\n  SELECT 2 ;\n-- tail\nSELECT 3"
+	statements := Split(DialectPostgres, script)
+
+	want := []Statement{
+		{Text: "SELECT 'é'", Start: 0, End: 12, Line: 1},
+		{Text: "SELECT 2", Start: 16, End: 26, Line: 3},
+		{Text: "-- tail\nSELECT 3", Start: 27, End: 43, Line: 4},
+	}
+	if !reflect.DeepEqual(statements, want) {
+		t.Fatalf("got %+v\nwant %+v", statements, want)
+	}
+	for _, statement := range statements {
+		if got := script[statement.Start : statement.Start+len(statement.Text)]; got != statement.Text {
+			t.Errorf("offset %d points at %q, want %q", statement.Start, got, statement.Text)
+		}
+	}
+}
+
+func TestStatementAt(t *testing.T) {
+	script := "SELECT 1;\n\nSELECT 2;\n"
+	statements := Split(DialectPostgres, script)
+
+	tests := []struct {
+		offset int
+		want   int
+	}{
+		{0, 0},
+		{5, 0},
+		{8, 0},  // before the semicolon
+		{9, 0},  // right after it
+		{10, 1}, // between statements
+		{11, 1},
+		{20, 1},
+		{21, 1},  // end of the script
+		{100, 1}, // past the end
+	}
+	for _, tt := range tests {
+		if got := StatementAt(statements, tt.offset); got != tt.want {
+			t.Errorf("offset %d: got statement %d, want %d", tt.offset, got, tt.want)
+		}
+	}
+
+	if got := StatementAt(nil, 0); got != -1 {
+		t.Errorf("got %d without statements, want -1", got)
+	}
+}
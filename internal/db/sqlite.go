@@ -0,0 +1,214 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+
+	_ "modernc.org/sqlite"
+)
+
+// SQLite implements the Driver interface for SQLite (pure Go, no cgo)
+type SQLite struct {
+	conn *sql.DB
+	txn  transaction
+}
+
+// NewSQLite creates a new SQLite driver
+func NewSQLite() *SQLite {
+	return &SQLite{}
+}
+
+func init() {
+	RegisterDriver(DriverInfo{
+		Name:     "SQLite",
+		Aliases:  []string{"sqlite3"},
+		Preset:   ConnConfig{Database: "./sqlite.db"},
+		Shortcut: 'L',
+		File:     true,
+		New:      func() Driver { return NewSQLite() },
+		DSN:      SQLiteDSN,
+	})
+}
+
+// Connect opens a SQLite database file, dsn is the file path
+func (s *SQLite) Connect(ctx context.Context, dsn string) error {
+	var err error
+	s.conn, err = sql.Open("sqlite", dsn)
+	if err != nil {
+		return fmt.Errorf("failed to open SQLite database: %w", err)
+	}
+
+	err = s.conn.PingContext(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to ping SQLite: %w", err)
+	}
+
+	return nil
+}
+
+// Close closes the connection
+func (s *SQLite) Close() error {
+	s.txn.end(false)
+	if s.conn != nil {
+		return s.conn.Close()
+	}
+	return nil
+}
+
+// GetDatabases returns list of attached databases (main, temp and ATTACHed files)
+func (s *SQLite) GetDatabases(ctx context.Context) ([]string, error) {
+	if s.conn == nil {
+		return nil, fmt.Errorf("not connected")
+	}
+
+	rows, err := s.conn.QueryContext(ctx, "PRAGMA database_list")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var databases []string
+	for rows.Next() {
+		var seq int
+		var name string
+		var file sql.NullString
+		if err := rows.Scan(&seq, &name, &file); err != nil {
+			return nil, err
+		}
+		databases = append(databases, name)
+	}
+
+	return databases, rows.Err()
+}
+
+// GetTables returns list of tables in an attached database
+func (s *SQLite) GetTables(ctx context.Context, database string) ([]string, error) {
+	if s.conn == nil {
+		return nil, fmt.Errorf("not connected")
+	}
+
+	if database == "" {
+		database = "main"
+	}
+
+	query := fmt.Sprintf("SELECT name FROM \"%s\".sqlite_master WHERE type = 'table' AND name NOT LIKE 'sqlite_%%' ORDER BY name",
+		strings.ReplaceAll(database, "\"", "\"\""))
+	rows, err := s.conn.QueryContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var tables []string
+	for rows.Next() {
+		var table string
+		if err := rows.Scan(&table); err != nil {
+			return nil, err
+		}
+		tables = append(tables, table)
+	}
+
+	return tables, rows.Err()
+}
+
+// ExecuteQuery executes a SQL query
+func (s *SQLite) ExecuteQuery(ctx context.Context, query string) (*QueryResult, error) {
+	return fetchAll(s.OpenQuery(ctx, query))
+}
+
+// OpenQuery executes a SQL query and returns a cursor for its rows
+func (s *SQLite) OpenQuery(ctx context.Context, query string, args ...any) (*QueryResult, error) {
+	if s.conn == nil {
+		return nil, fmt.Errorf("not connected")
+	}
+
+	if tx := s.txn.runner(); tx != nil {
+		return s.txn.track(openStatement(ctx, tx, DialectSQLite, query, args...))
+	}
+	return openStatement(ctx, s.conn, DialectSQLite, query, args...)
+}
+
+// ExecTransaction runs statements in one transaction
+func (s *SQLite) ExecTransaction(ctx context.Context, statements []BoundStatement, check func(statement int, affected int64) error) error {
+	if tx := s.txn.runner(); tx != nil {
+		return execSavepoint(ctx, tx, statements, check)
+	}
+	return execTransaction(ctx, s.conn, statements, check)
+}
+
+// Begin pins a connection and starts a transaction on it
+func (s *SQLite) Begin(ctx context.Context, isolation sql.IsolationLevel) error {
+	return s.txn.begin(ctx, s.conn, isolation)
+}
+
+// Commit commits the open transaction
+func (s *SQLite) Commit() error {
+	return s.txn.end(true)
+}
+
+// Rollback rolls the open transaction back
+func (s *SQLite) Rollback() error {
+	return s.txn.end(false)
+}
+
+// Transaction returns the open transaction
+func (s *SQLite) Transaction() (TransactionInfo, bool) {
+	return s.txn.open()
+}
+
+// RunScript runs statements one after another on one connection. Unqualified
+// names refer to the main database, so scripts cannot run in attached ones.
+func (s *SQLite) RunScript(ctx context.Context, database string, statements []Statement, progress func(done int)) error {
+	if database != "" && database != "main" {
+		return fmt.Errorf("scripts run in the main database, not in %s", database)
+	}
+	if _, ok := s.txn.open(); ok {
+		return errTransactionOpen
+	}
+	return runScript(ctx, s.conn, nil, statements, progress)
+}
+
+// ImportRows loads rows with a prepared INSERT statement in one transaction
+func (s *SQLite) ImportRows(ctx context.Context, target ImportTarget, rows [][]Value) error {
+	if s.conn == nil {
+		return fmt.Errorf("not connected")
+	}
+	if _, ok := s.txn.open(); ok {
+		return errTransactionOpen
+	}
+
+	tx, err := s.conn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+
+	placeholders := strings.TrimSuffix(strings.Repeat("?, ", len(target.Columns)), ", ")
+	query := fmt.Sprintf("INSERT INTO %s (%s) VALUES (%s)", target.QuotedTable(), target.quotedColumns(), placeholders)
+	stmt, err := tx.PrepareContext(ctx, query)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	defer stmt.Close()
+
+	for _, row := range rows {
+		if _, err := stmt.ExecContext(ctx, target.args(row)...); err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// GetDriverName returns the driver name
+func (s *SQLite) GetDriverName() string {
+	return "SQLite"
+}
+
+// GetDialect returns the SQL dialect
+func (s *SQLite) GetDialect() Dialect {
+	return DialectSQLite
+}
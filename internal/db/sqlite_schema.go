@@ -0,0 +1,288 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// DescribeTable returns the structure of a table in an attached database
+func (s *SQLite) DescribeTable(ctx context.Context, database, schema, table string) (*TableInfo, error) {
+	if s.conn == nil {
+		return nil, fmt.Errorf("not connected")
+	}
+
+	if database == "" {
+		database = "main"
+	}
+	info := &TableInfo{Database: database, Name: table}
+	master := quoteIdentifier(DialectSQLite, database) + ".sqlite_master"
+
+	var tableSQL sql.NullString
+	err := s.conn.QueryRowContext(ctx, "SELECT sql FROM "+master+" WHERE type = 'table' AND name = ?", table).Scan(&tableSQL)
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("table %s not found", info.QualifiedName())
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.describeColumns(ctx, info); err != nil {
+		return nil, err
+	}
+	if err := s.describeIndexes(ctx, info); err != nil {
+		return nil, err
+	}
+	if err := s.describeForeignKeys(ctx, info); err != nil {
+		return nil, err
+	}
+	info.Checks = extractChecks(DialectSQLite, tableSQL.String)
+
+	// Indexes created with the table have no statement of their own
+	statements := []string{tableSQL.String}
+	rows, err := s.conn.QueryContext(ctx, "SELECT type, name, sql FROM "+master+
+		" WHERE tbl_name = ? AND type IN ('index', 'trigger') AND sql IS NOT NULL ORDER BY type, name", table)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var kind, name, ddl string
+		if err := rows.Scan(&kind, &name, &ddl); err != nil {
+			return nil, err
+		}
+		statements = append(statements, ddl)
+
+		if kind == "index" {
+			for i := range info.Indexes {
+				if info.Indexes[i].Name == name {
+					info.Indexes[i].Definition = ddl
+				}
+			}
+		}
+		if kind == "trigger" {
+			timing, event := triggerEvent(DialectSQLite, ddl)
+			info.Triggers = append(info.Triggers, TriggerInfo{Name: name, Timing: timing, Event: event, Definition: ddl})
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	info.DDL = joinStatements(statements)
+	return info, nil
+}
+
+// describeColumns reads the columns and the primary key of a table
+func (s *SQLite) describeColumns(ctx context.Context, info *TableInfo) error {
+	rows, err := s.conn.QueryContext(ctx,
+		`SELECT name, type, "notnull", dflt_value, pk FROM pragma_table_info(?, ?) ORDER BY cid`, info.Name, info.Database)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	var keys []string
+	var positions, keyColumns []int
+	for rows.Next() {
+		var column ColumnInfo
+		var notNull bool
+		var defaultValue sql.NullString
+		var pk int
+		if err := rows.Scan(&column.Name, &column.Type, &notNull, &defaultValue, &pk); err != nil {
+			return err
+		}
+		column.Nullable = !notNull
+		column.Default, column.HasDefault = defaultValue.String, defaultValue.Valid
+		info.Columns = append(info.Columns, column)
+
+		if pk > 0 {
+			keys = append(keys, column.Name)
+			positions = append(positions, pk)
+			keyColumns = append(keyColumns, len(info.Columns)-1)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	// An INTEGER PRIMARY KEY is the rowid, which is never NULL
+	if len(keyColumns) == 1 && strings.EqualFold(info.Columns[keyColumns[0]].Type, "INTEGER") {
+		info.Columns[keyColumns[0]].Nullable = false
+	}
+
+	// pk is the position of the column in the primary key
+	info.PrimaryKey = make([]string, len(keys))
+	for i, position := range positions {
+		if position <= len(keys) {
+			info.PrimaryKey[position-1] = keys[i]
+		}
+	}
+
+	return nil
+}
+
+// describeIndexes reads the indexes of a table
+func (s *SQLite) describeIndexes(ctx context.Context, info *TableInfo) error {
+	rows, err := s.conn.QueryContext(ctx,
+		"SELECT name, \"unique\", origin FROM pragma_index_list(?, ?) ORDER BY name", info.Name, info.Database)
+	if err != nil {
+		return err
+	}
+
+	var indexes []IndexInfo
+	for rows.Next() {
+		var index IndexInfo
+		var origin string
+		if err := rows.Scan(&index.Name, &index.Unique, &origin); err != nil {
+			rows.Close()
+			return err
+		}
+		index.Primary = origin == "pk"
+		indexes = append(indexes, index)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	// Read the columns after closing the index list, the connection may be shared
+	for i := range indexes {
+		columns, err := s.conn.QueryContext(ctx,
+			"SELECT name FROM pragma_index_info(?, ?) ORDER BY seqno", indexes[i].Name, info.Database)
+		if err != nil {
+			return err
+		}
+		for columns.Next() {
+			var name sql.NullString
+			if err := columns.Scan(&name); err != nil {
+				columns.Close()
+				return err
+			}
+			if !name.Valid {
+				name.String = "(expression)"
+			}
+			indexes[i].Columns = append(indexes[i].Columns, name.String)
+		}
+		columns.Close()
+		if err := columns.Err(); err != nil {
+			return err
+		}
+	}
+
+	info.Indexes = indexes
+	return nil
+}
+
+// describeForeignKeys reads the foreign keys of a table
+func (s *SQLite) describeForeignKeys(ctx context.Context, info *TableInfo) error {
+	rows, err := s.conn.QueryContext(ctx,
+		`SELECT id, "table", "from", "to", on_update, on_delete FROM pragma_foreign_key_list(?, ?) ORDER BY id, seq`,
+		info.Name, info.Database)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	lastID := -1
+	for rows.Next() {
+		var id int
+		var refTable, from, onUpdate, onDelete string
+		var to sql.NullString
+		if err := rows.Scan(&id, &refTable, &from, &to, &onUpdate, &onDelete); err != nil {
+			return err
+		}
+
+		// Each column of a composite key is a row with the same id
+		if id != lastID {
+			info.ForeignKeys = append(info.ForeignKeys, ForeignKeyInfo{
+				RefTable: refTable,
+				OnUpdate: strings.ToUpper(onUpdate),
+				OnDelete: strings.ToUpper(onDelete),
+			})
+			lastID = id
+		}
+		fk := &info.ForeignKeys[len(info.ForeignKeys)-1]
+		fk.Columns = append(fk.Columns, from)
+		// A missing target column refers to the primary key
+		if to.Valid {
+			fk.RefColumns = append(fk.RefColumns, to.String)
+		}
+	}
+
+	return rows.Err()
+}
+
+// DumpSchema reads the tables, indexes, triggers and views of an attached
+// database from its sqlite_master table, in the order they were created
+func (s *SQLite) DumpSchema(ctx context.Context, database string) (*DumpSchema, error) {
+	if s.conn == nil {
+		return nil, fmt.Errorf("not connected")
+	}
+
+	if database == "" {
+		database = "main"
+	}
+	master := quoteIdentifier(DialectSQLite, database) + ".sqlite_master"
+	rows, err := s.conn.QueryContext(ctx, "SELECT type, name, sql FROM "+master+
+		" WHERE sql IS NOT NULL AND name NOT LIKE 'sqlite\\_%' ESCAPE '\\' ORDER BY rowid")
+	if err != nil {
+		return nil, err
+	}
+
+	schema := &DumpSchema{}
+	var virtual []string // shadow tables of virtual tables start with their names
+	for rows.Next() {
+		var kind, name, ddl string
+		if err := rows.Scan(&kind, &name, &ddl); err != nil {
+			rows.Close()
+			return nil, err
+		}
+
+		if kind != "table" {
+			schema.After = append(schema.After, ddl)
+			continue
+		}
+		if strings.HasPrefix(strings.ToUpper(ddl), "CREATE VIRTUAL TABLE") {
+			virtual = append(virtual, name+"_")
+		} else if slices.ContainsFunc(virtual, func(prefix string) bool { return strings.HasPrefix(name, prefix) }) {
+			continue
+		}
+		// SQLite cannot add foreign keys later, they stay in the table
+		schema.Tables = append(schema.Tables, DumpTable{Name: name, Create: ddl})
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	// Generated and hidden columns cannot be inserted
+	for i, table := range schema.Tables {
+		columns, err := s.conn.QueryContext(ctx, "SELECT name FROM pragma_table_xinfo(?, ?) WHERE hidden = 0 ORDER BY cid", table.Name, database)
+		if err != nil {
+			return nil, err
+		}
+		var names []string
+		for columns.Next() {
+			var name string
+			if err := columns.Scan(&name); err != nil {
+				columns.Close()
+				return nil, err
+			}
+			names = append(names, quoteIdentifier(DialectSQLite, name))
+		}
+		columns.Close()
+		if err := columns.Err(); err != nil {
+			return nil, err
+		}
+
+		list := strings.Join(names, ", ")
+		schema.Tables[i].Select = fmt.Sprintf("SELECT %s FROM %s", list, QuoteTable(DialectSQLite, database, table.Name))
+		schema.Tables[i].Insert = fmt.Sprintf("INSERT INTO %s (%s) VALUES", quoteIdentifier(DialectSQLite, table.Name), list)
+	}
+
+	return schema, nil
+}
@@ -0,0 +1,42 @@
+package db
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+)
+
+func TestSQLiteDescribeNullable(t *testing.T) {
+	ctx := context.Background()
+	driver := NewSQLite()
+	if err := driver.Connect(ctx, filepath.Join(t.TempDir(), "test.db")); err != nil {
+		t.Fatal(err)
+	}
+	defer driver.Close()
+
+	tests := []struct {
+		table   string
+		columns string
+		want    map[string]bool // nullable by column
+	}{
+		{"rowid", "id INTEGER PRIMARY KEY, name TEXT", map[string]bool{"id": false, "name": true}},
+		{"lower", "id integer primary key autoincrement", map[string]bool{"id": false}},
+		// Only INTEGER makes the key the rowid, other key columns may hold NULL
+		{"bigint", "id BIGINT PRIMARY KEY", map[string]bool{"id": true}},
+		{"pair", "a INTEGER, b INTEGER NOT NULL, PRIMARY KEY (a, b)", map[string]bool{"a": true, "b": false}},
+	}
+	for _, tt := range tests {
+		if _, err := driver.ExecuteQuery(ctx, "CREATE TABLE "+tt.table+" ("+tt.columns+")"); err != nil {
+			t.Fatal(err)
+		}
+		info, err := driver.DescribeTable(ctx, "", "", tt.table)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, column := range info.Columns {
+			if want := tt.want[column.Name]; column.Nullable != want {
+				t.Errorf("%s.%s: got nullable %v, want %v", tt.table, column.Name, column.Nullable, want)
+			}
+		}
+	}
+}
@@ -0,0 +1,279 @@
+package db
+
+import (
+	"slices"
+	"strings"
+	"unicode"
+)
+
+// TokenKind is the syntax class of a token
+type TokenKind int
+
+const (
+	// TokenWord is an identifier or any other word that is not a keyword
+	TokenWord TokenKind = iota
+	TokenKeyword
+	// TokenQuotedIdentifier is an identifier in double quotes, backticks or
+	// SQLite brackets
+	TokenQuotedIdentifier
+	TokenString
+	TokenNumber
+	TokenComment
+	// TokenParameter is a :name placeholder, a PostgreSQL $1, a ? or a
+	// MySQL @variable
+	TokenParameter
+	// TokenBracket is one of ( ) [ ] { }
+	TokenBracket
+	// TokenPunctuation is any other character, e.g. an operator or a comma
+	TokenPunctuation
+)
+
+// Token is a part of a script, Start and End are byte offsets
+type Token struct {
+	Kind  TokenKind
+	Start int
+	End   int
+}
+
+// commonKeywords are the keywords highlighted and completed in all dialects
+var commonKeywords = []string{
+	"ADD", "ALL", "ALTER", "AND", "ANY", "AS", "ASC", "BEGIN", "BETWEEN", "BIGINT", "BLOB", "BOOLEAN", "BY",
+	"CASCADE", "CASE", "CAST", "CHAR", "CHARACTER", "CHECK", "COLLATE", "COLUMN", "COMMIT", "CONSTRAINT",
+	"CREATE", "CROSS", "CURRENT_DATE", "CURRENT_TIME", "CURRENT_TIMESTAMP", "DATE", "DECIMAL", "DEFAULT",
+	"DELETE", "DESC", "DISTINCT", "DOUBLE", "DROP", "EACH", "ELSE", "END", "ESCAPE", "EXCEPT", "EXISTS",
+	"EXPLAIN", "FALSE", "FILTER", "FLOAT", "FOR", "FOREIGN", "FROM", "FULL", "GRANT", "GROUP", "HAVING",
+	"IF", "IN", "INDEX", "INNER", "INSERT", "INT", "INTEGER", "INTERSECT", "INTO", "IS", "JOIN", "KEY",
+	"LEFT", "LIKE", "LIMIT", "NATURAL", "NOT", "NULL", "NUMERIC", "OFFSET", "ON", "OR", "ORDER", "OUTER",
+	"OVER", "PARTITION", "PRECISION", "PRIMARY", "RANGE", "REAL", "RECURSIVE", "REFERENCES", "RELEASE",
+	"RENAME", "REPLACE", "RESTRICT", "RETURNING", "REVOKE", "RIGHT", "ROLLBACK", "ROW", "ROWS", "SAVEPOINT",
+	"SELECT", "SET", "SMALLINT", "SOME", "TABLE", "TEMP", "TEMPORARY", "TEXT", "THEN", "TIME", "TIMESTAMP",
+	"TO", "TRANSACTION", "TRIGGER", "TRUE", "TRUNCATE", "UNION", "UNIQUE", "UPDATE", "USING", "VALUES",
+	"VARCHAR", "VIEW", "WHEN", "WHERE", "WINDOW", "WITH",
+}
+
+// dialectKeywords are the keywords of one dialect only
+var dialectKeywords = map[Dialect][]string{
+	DialectPostgres: {
+		"ARRAY", "BIGSERIAL", "BYTEA", "CONCURRENTLY", "CONFLICT", "DO", "DOMAIN", "ENUM", "EXTENSION",
+		"FETCH", "FIRST", "FUNCTION", "ILIKE", "INTERVAL", "JSON", "JSONB", "LANGUAGE", "LAST", "LATERAL",
+		"MATERIALIZED", "NEXT", "NOTHING", "NULLS", "ONLY", "OWNER", "REFRESH", "RETURNS", "SCHEMA",
+		"SEQUENCE", "SERIAL", "SIMILAR", "TIMESTAMPTZ", "TYPE", "UUID", "VACUUM", "VARYING",
+	},
+	DialectMySQL: {
+		"AUTO_INCREMENT", "CALL", "CHARSET", "DATABASE", "DATABASES", "DATETIME", "DELIMITER", "DESCRIBE",
+		"DUPLICATE", "ENGINE", "ENUM", "FUNCTION", "IGNORE", "INTERVAL", "JSON", "LOCK", "LONGTEXT",
+		"MEDIUMINT", "MEDIUMTEXT", "PROCEDURE", "REGEXP", "SCHEMA", "SHOW", "STRAIGHT_JOIN", "TABLES",
+		"TINYINT", "UNLOCK", "UNSIGNED", "USE",
+	},
+	DialectSQLite: {
+		"ABORT", "ATTACH", "AUTOINCREMENT", "CONFLICT", "DATABASE", "DETACH", "DO", "FAIL", "GLOB",
+		"IGNORE", "INDEXED", "ISNULL", "NOTHING", "NOTNULL", "PRAGMA", "REGEXP", "ROWID", "STRICT",
+		"VACUUM", "VIRTUAL", "WITHOUT",
+	},
+}
+
+// keywordSets are the keywords of each dialect by upper case text
+var keywordSets = map[Dialect]map[string]bool{}
+
+func init() {
+	for _, dialect := range []Dialect{DialectPostgres, DialectMySQL, DialectSQLite} {
+		set := map[string]bool{}
+		for _, keyword := range commonKeywords {
+			set[keyword] = true
+		}
+		for _, keyword := range dialectKeywords[dialect] {
+			set[keyword] = true
+		}
+		keywordSets[dialect] = set
+	}
+}
+
+// Keywords returns the keywords of dialect in upper case, sorted
+func Keywords(dialect Dialect) []string {
+	keywords := make([]string, 0, len(keywordSets[dialect]))
+	for keyword := range keywordSets[dialect] {
+		keywords = append(keywords, keyword)
+	}
+	slices.Sort(keywords)
+	return keywords
+}
+
+// IsKeyword returns true if word is a keyword of dialect, ignoring case
+func IsKeyword(dialect Dialect, word string) bool {
+	return keywordSets[dialect][strings.ToUpper(word)]
+}
+
+// Tokenize splits a script into tokens for syntax highlighting, white space
+// between them is left out. Literals and comments that are not closed run
+// to the end of the script.
+func Tokenize(dialect Dialect, script string) []Token {
+	runes := []rune(script)
+	offsets := make([]int, len(runes)+1)
+	offset := 0
+	for i, r := range runes {
+		offsets[i] = offset
+		offset += len(string(r))
+	}
+	offsets[len(runes)] = offset
+
+	keywords := keywordSets[dialect]
+	var tokens []Token
+	for i := 0; i < len(runes); {
+		r := runes[i]
+		start := i
+		kind := TokenPunctuation
+
+		next, lexeme := skipLexeme(dialect, runes, i)
+		switch {
+		case lexeme == lexemeComment:
+			i, kind = next, TokenComment
+		case lexeme == lexemeString:
+			i, kind = next, TokenString
+		case lexeme == lexemeIdentifier:
+			i, kind = next, TokenQuotedIdentifier
+		case unicode.IsSpace(r):
+			i++
+			continue
+		case r == '$' && dialect == DialectPostgres && i+1 < len(runes) && unicode.IsDigit(runes[i+1]):
+			i++
+			for i < len(runes) && unicode.IsDigit(runes[i]) {
+				i++
+			}
+			kind = TokenParameter
+		case r == ':' && i+1 < len(runes) && runes[i+1] == ':':
+			// PostgreSQL cast
+			i += 2
+		case r == ':' && i+1 < len(runes) && (unicode.IsLetter(runes[i+1]) || runes[i+1] == '_') &&
+			(i == 0 || !isWordRune(runes[i-1])):
+			i++
+			for i < len(runes) && isWordRune(runes[i]) {
+				i++
+			}
+			kind = TokenParameter
+		case r == '?' && dialect != DialectPostgres:
+			i++
+			kind = TokenParameter
+		case r == '@' && dialect == DialectMySQL:
+			i++
+			for i < len(runes) && (isWordRune(runes[i]) || runes[i] == '@') {
+				i++
+			}
+			kind = TokenParameter
+		case unicode.IsDigit(r) || r == '.' && i+1 < len(runes) && unicode.IsDigit(runes[i+1]):
+			i = skipNumber(runes, i)
+			kind = TokenNumber
+		case unicode.IsLetter(r) || r == '_':
+			for i < len(runes) && isWordRune(runes[i]) {
+				i++
+			}
+			kind = TokenWord
+			if keywords[strings.ToUpper(string(runes[start:i]))] {
+				kind = TokenKeyword
+			}
+		case strings.ContainsRune("()[]{}", r):
+			i++
+			kind = TokenBracket
+		default:
+			i++
+		}
+
+		tokens = append(tokens, Token{Kind: kind, Start: offsets[start], End: offsets[i]})
+	}
+
+	return tokens
+}
+
+// skipNumber skips a numeric literal starting at i, e.g. 42, 1.5e-3 or 0xff
+func skipNumber(runes []rune, i int) int {
+	for i < len(runes) {
+		r := runes[i]
+		switch {
+		case unicode.IsDigit(r) || unicode.IsLetter(r) || r == '.' || r == '_':
+			i++
+		case (r == '+' || r == '-') && (runes[i-1] == 'e' || runes[i-1] == 'E'):
+			i++
+		default:
+			return i
+		}
+	}
+	return i
+}
+
+// TableReference is a table named after FROM, JOIN, UPDATE or INTO
+type TableReference struct {
+	Schema string // qualifier of the name, empty if it is not qualified
+	Table  string
+	Alias  string
+}
+
+// TableReferences returns the tables a statement refers to with their
+// aliases, e.g. "FROM orders o JOIN customers AS c". Names are unquoted.
+func TableReferences(dialect Dialect, statement string) []TableReference {
+	var tokens []Token
+	for _, token := range Tokenize(dialect, statement) {
+		if token.Kind != TokenComment {
+			tokens = append(tokens, token)
+		}
+	}
+	text := func(i int) string {
+		return statement[tokens[i].Start:tokens[i].End]
+	}
+	isName := func(i int) bool {
+		return i < len(tokens) && (tokens[i].Kind == TokenWord || tokens[i].Kind == TokenQuotedIdentifier)
+	}
+	isKeyword := func(i int, keyword string) bool {
+		return i < len(tokens) && tokens[i].Kind == TokenKeyword && strings.EqualFold(text(i), keyword)
+	}
+
+	var references []TableReference
+	for i := 0; i < len(tokens); i++ {
+		if !isKeyword(i, "FROM") && !isKeyword(i, "JOIN") && !isKeyword(i, "UPDATE") && !isKeyword(i, "INTO") {
+			continue
+		}
+		list := isKeyword(i, "FROM")
+
+		for j := i + 1; isName(j); {
+			reference := TableReference{Table: unquoteIdentifier(text(j))}
+			j++
+			if j+1 < len(tokens) && text(j) == "." && isName(j+1) {
+				reference.Schema = reference.Table
+				reference.Table = unquoteIdentifier(text(j + 1))
+				j += 2
+			}
+			if isKeyword(j, "AS") {
+				j++
+			}
+			if isName(j) {
+				reference.Alias = unquoteIdentifier(text(j))
+				j++
+			}
+			references = append(references, reference)
+
+			// FROM a, b lists several tables
+			if !list || j >= len(tokens) || text(j) != "," {
+				break
+			}
+			j++
+		}
+	}
+
+	return references
+}
+
+// QuoteName returns a name as it is written in a statement of dialect,
+// quoted only if it is a keyword, has other characters than letters,
+// digits and underscores or, in PostgreSQL, upper case letters
+func QuoteName(dialect Dialect, name string) string {
+	plain := name != "" && !IsKeyword(dialect, name) && !unicode.IsDigit([]rune(name)[0])
+	for _, r := range name {
+		if !(unicode.IsLetter(r) || unicode.IsDigit(r) || r == '_') || r > unicode.MaxASCII ||
+			dialect == DialectPostgres && unicode.IsUpper(r) {
+			plain = false
+			break
+		}
+	}
+	if plain {
+		return name
+	}
+	return quoteIdentifier(dialect, name)
+}
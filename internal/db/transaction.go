@@ -0,0 +1,192 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// Transactor is implemented by drivers that can keep a transaction open
+// across statements. While it is open OpenQuery, ExecuteQuery and
+// ExecTransaction run in it on one pinned connection, statements run on
+// their own connection such as imports and scripts are refused.
+type Transactor interface {
+	// Begin pins a connection of the pool and starts a transaction on it
+	// with isolation, one of IsolationLevels
+	Begin(ctx context.Context, isolation sql.IsolationLevel) error
+	// Commit commits the open transaction and releases its connection
+	Commit() error
+	// Rollback rolls the open transaction back and releases its connection
+	Rollback() error
+	// Transaction returns the open transaction, false if there is none
+	Transaction() (TransactionInfo, bool)
+}
+
+// TransactionInfo describes an open transaction
+type TransactionInfo struct {
+	Started   time.Time
+	Isolation sql.IsolationLevel
+}
+
+// errTransactionOpen is returned for work that needs a connection of its
+// own while a transaction is open
+var errTransactionOpen = errors.New("a transaction is open, commit or roll it back first")
+
+// errNoTransaction is returned when no transaction is open to end
+var errNoTransaction = errors.New("no transaction is open")
+
+// IsolationLevels returns the isolation levels a transaction can be started
+// with, the server default first. SQLite transactions are always serializable.
+func IsolationLevels(dialect Dialect) []sql.IsolationLevel {
+	switch dialect {
+	case DialectPostgres:
+		// PostgreSQL runs read uncommitted as read committed
+		return []sql.IsolationLevel{sql.LevelDefault, sql.LevelReadCommitted, sql.LevelRepeatableRead, sql.LevelSerializable}
+	case DialectMySQL:
+		return []sql.IsolationLevel{sql.LevelDefault, sql.LevelReadUncommitted, sql.LevelReadCommitted, sql.LevelRepeatableRead, sql.LevelSerializable}
+	default:
+		return []sql.IsolationLevel{sql.LevelDefault}
+	}
+}
+
+// transaction is the open transaction of a driver and the connection it is
+// pinned to. The zero value has no transaction open.
+type transaction struct {
+	mu     sync.Mutex
+	conn   *sql.Conn
+	tx     *sql.Tx
+	info   TransactionInfo
+	cursor *Cursor // the last result set opened in the transaction
+}
+
+// begin pins a connection of pool and starts a transaction on it
+func (t *transaction) begin(ctx context.Context, pool *sql.DB, isolation sql.IsolationLevel) error {
+	if pool == nil {
+		return fmt.Errorf("not connected")
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.tx != nil {
+		return fmt.Errorf("a transaction is already open")
+	}
+
+	conn, err := pool.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	// The transaction outlives ctx, database/sql rolls it back when its
+	// context ends
+	tx, err := conn.BeginTx(context.Background(), &sql.TxOptions{Isolation: isolation})
+	if err != nil {
+		conn.Close()
+		return err
+	}
+
+	t.conn, t.tx = conn, tx
+	t.info = TransactionInfo{Started: time.Now(), Isolation: isolation}
+	return nil
+}
+
+// end commits or rolls back the open transaction and releases its
+// connection, which is released even if that fails
+func (t *transaction) end(commit bool) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.tx == nil {
+		return errNoTransaction
+	}
+
+	// A connection reads one result set at a time and the transaction
+	// waits for it to be closed
+	t.closeCursor()
+
+	var err error
+	if commit {
+		err = t.tx.Commit()
+	} else {
+		err = t.tx.Rollback()
+	}
+	t.conn.Close()
+	t.conn, t.tx = nil, nil
+	return err
+}
+
+// open returns the open transaction and whether there is one
+func (t *transaction) open() (TransactionInfo, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	return t.info, t.tx != nil
+}
+
+// runner returns the open transaction to run a statement in, nil if none
+// is open. A result set still open in it is closed first.
+func (t *transaction) runner() *sql.Tx {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.tx != nil {
+		t.closeCursor()
+	}
+	return t.tx
+}
+
+// track remembers the cursor of a result opened in the transaction, it is
+// closed before the next statement runs in it
+func (t *transaction) track(result *QueryResult, err error) (*QueryResult, error) {
+	if result != nil && result.Cursor != nil {
+		t.mu.Lock()
+		t.cursor = result.Cursor
+		t.mu.Unlock()
+	}
+	return result, err
+}
+
+// closeCursor closes the last result set of the transaction, caller must
+// hold the lock
+func (t *transaction) closeCursor() {
+	if t.cursor != nil {
+		t.cursor.Close()
+		t.cursor = nil
+	}
+}
+
+// execSavepoint runs statements in the open transaction under a savepoint,
+// which is rolled back if a statement or check fails so the transaction can
+// go on. See Driver.ExecTransaction.
+func execSavepoint(ctx context.Context, tx *sql.Tx, statements []BoundStatement, check func(statement int, affected int64) error) error {
+	if _, err := tx.ExecContext(ctx, "SAVEPOINT gocmder_edit"); err != nil {
+		return err
+	}
+	rollback := func() {
+		tx.ExecContext(context.Background(), "ROLLBACK TO SAVEPOINT gocmder_edit")
+		tx.ExecContext(context.Background(), "RELEASE SAVEPOINT gocmder_edit")
+	}
+
+	for i, statement := range statements {
+		res, err := tx.ExecContext(ctx, statement.Query, statement.Args...)
+		if err != nil {
+			rollback()
+			return fmt.Errorf("statement %d: %w", i+1, err)
+		}
+		affected, err := res.RowsAffected()
+		if err != nil {
+			affected = -1
+		}
+		if check != nil {
+			if err := check(i, affected); err != nil {
+				rollback()
+				return err
+			}
+		}
+	}
+
+	_, err := tx.ExecContext(ctx, "RELEASE SAVEPOINT gocmder_edit")
+	return err
+}
@@ -0,0 +1,165 @@
+package db
+
+import (
+	"database/sql"
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// Column describes a result column
+type Column struct {
+	Name         string
+	DatabaseType string // upper case type name reported by the driver, may be empty
+	Nullable     bool
+	HasNullable  bool // false if the driver does not report nullability
+	Length       int64
+	HasLength    bool // true for variable length types
+	Precision    int64
+	Scale        int64
+	HasDecimal   bool // true if precision and scale are known
+}
+
+// ValueKind classifies a scanned value for display
+type ValueKind int
+
+const (
+	ValueText ValueKind = iota
+	ValueNumber
+	ValueBinary
+)
+
+// Value is a scanned column value. NULL is kept apart from the text "NULL".
+type Value struct {
+	Kind  ValueKind
+	Null  bool
+	Text  string // display text, empty for NULL and binary values
+	Bytes []byte // raw data of binary values
+}
+
+// newColumn converts driver column metadata
+func newColumn(columnType *sql.ColumnType) Column {
+	column := Column{
+		Name:         columnType.Name(),
+		DatabaseType: strings.ToUpper(columnType.DatabaseTypeName()),
+	}
+
+	column.Nullable, column.HasNullable = columnType.Nullable()
+	column.Length, column.HasLength = columnType.Length()
+	column.Precision, column.Scale, column.HasDecimal = columnType.DecimalSize()
+
+	return column
+}
+
+// baseType returns the type name without modifiers such as UNSIGNED or a length
+func (c Column) baseType() string {
+	name := strings.TrimPrefix(c.DatabaseType, "UNSIGNED ")
+	if i := strings.IndexAny(name, " ("); i >= 0 {
+		name = name[:i]
+	}
+	return name
+}
+
+// IsNumeric returns true for integer, decimal and floating point columns
+func (c Column) IsNumeric() bool {
+	switch c.baseType() {
+	case "INT", "INT2", "INT4", "INT8", "INTEGER", "TINYINT", "SMALLINT", "MEDIUMINT", "BIGINT",
+		"SERIAL", "BIGSERIAL", "SMALLSERIAL", "DECIMAL", "NUMERIC", "REAL", "FLOAT", "FLOAT4", "FLOAT8",
+		"DOUBLE", "MONEY", "OID":
+		return true
+	}
+	return false
+}
+
+// IsBinary returns true for binary string columns
+func (c Column) IsBinary() bool {
+	switch c.baseType() {
+	case "BYTEA", "BLOB", "TINYBLOB", "MEDIUMBLOB", "LONGBLOB", "BINARY", "VARBINARY", "BIT", "GEOMETRY":
+		return true
+	}
+	return false
+}
+
+// IsJSON returns true for JSON columns
+func (c Column) IsJSON() bool {
+	switch c.baseType() {
+	case "JSON", "JSONB":
+		return true
+	}
+	return false
+}
+
+// TypeString returns the column type with its size, e.g. VARCHAR(255) or NUMERIC(10,2)
+func (c Column) TypeString() string {
+	name := c.DatabaseType
+	if name == "" {
+		name = "unknown"
+	}
+
+	switch {
+	case c.HasDecimal && c.Precision > 0:
+		return fmt.Sprintf("%s(%d,%d)", name, c.Precision, c.Scale)
+	case c.HasLength && c.Length > 0 && c.Length < 1<<31-1:
+		return fmt.Sprintf("%s(%d)", name, c.Length)
+	}
+	return name
+}
+
+// String returns the display text of a value, "NULL" for NULL and hex for binary data
+func (v Value) String() string {
+	switch {
+	case v.Null:
+		return "NULL"
+	case v.Kind == ValueBinary:
+		return "0x" + hex.EncodeToString(v.Bytes)
+	}
+	return v.Text
+}
+
+// newValue converts a scanned value using the column metadata
+func newValue(column Column, val interface{}) Value {
+	switch v := val.(type) {
+	case nil:
+		return Value{Null: true}
+	case []byte:
+		// MySQL returns every text and numeric column as bytes, SQLite
+		// expressions have no declared type
+		if column.IsBinary() || (column.DatabaseType == "" && !utf8.Valid(v)) {
+			return Value{Kind: ValueBinary, Bytes: append([]byte(nil), v...)}
+		}
+		return textValue(column, string(v))
+	case string:
+		return textValue(column, v)
+	case int64:
+		return Value{Kind: ValueNumber, Text: strconv.FormatInt(v, 10)}
+	case float64:
+		return Value{Kind: ValueNumber, Text: strconv.FormatFloat(v, 'f', -1, 64)}
+	case float32:
+		return Value{Kind: ValueNumber, Text: strconv.FormatFloat(float64(v), 'f', -1, 32)}
+	case bool:
+		return Value{Text: strconv.FormatBool(v)}
+	case time.Time:
+		return Value{Text: v.Format(time.RFC3339Nano)}
+	}
+	return textValue(column, fmt.Sprintf("%v", val))
+}
+
+// textValue returns a text value, numeric if the column is numeric
+func textValue(column Column, text string) Value {
+	if column.IsNumeric() {
+		return Value{Kind: ValueNumber, Text: text}
+	}
+	return Value{Text: text}
+}
+
+// ColumnNames returns the names of columns
+func ColumnNames(columns []Column) []string {
+	names := make([]string, len(columns))
+	for i, column := range columns {
+		names[i] = column.Name
+	}
+	return names
+}
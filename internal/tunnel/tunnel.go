@@ -0,0 +1,301 @@
+package tunnel
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"os/user"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/shangyanjin/gocmder/internal/config"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/agent"
+	"golang.org/x/crypto/ssh/knownhosts"
+)
+
+// DefaultPort is the SSH port used when a session sets none
+const DefaultPort = "22"
+
+// defaultKeyFiles are tried in ~/.ssh when a session sets no key file and no
+// SSH agent is running
+var defaultKeyFiles = []string{"id_ed25519", "id_ecdsa", "id_rsa"}
+
+// Tunnel forwards a local port to the database host through an SSH server
+type Tunnel struct {
+	client   *ssh.Client
+	listener net.Listener
+	remote   string
+	wg       sync.WaitGroup
+
+	mu    sync.Mutex
+	conns map[net.Conn]struct{}
+}
+
+// Open connects to the SSH server of a session and listens on a local port
+// that forwards to the database host and port of the session, as seen from
+// the SSH server
+func Open(ctx context.Context, session config.Session) (*Tunnel, error) {
+	auth, agentConn, err := authMethods(session.SSHKeyFile)
+	if err != nil {
+		return nil, err
+	}
+	if agentConn != nil {
+		// The agent signs during the handshake only
+		defer agentConn.Close()
+	}
+	hostKey := ssh.InsecureIgnoreHostKey()
+	if !session.SSHInsecure {
+		if hostKey, err = knownHostsCallback(); err != nil {
+			return nil, err
+		}
+	}
+
+	username := session.SSHUser
+	if username == "" {
+		if current, err := user.Current(); err == nil {
+			username = current.Username
+		}
+	}
+	port := session.SSHPort
+	if port == "" {
+		port = DefaultPort
+	}
+	address := net.JoinHostPort(session.SSHHost, port)
+
+	client, err := dial(ctx, address, &ssh.ClientConfig{
+		User:            username,
+		Auth:            auth,
+		HostKeyCallback: hostKey,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		client.Close()
+		return nil, fmt.Errorf("failed to listen on a local port: %w", err)
+	}
+
+	t := &Tunnel{
+		client:   client,
+		listener: listener,
+		remote:   net.JoinHostPort(session.Host, session.Port),
+		conns:    make(map[net.Conn]struct{}),
+	}
+	t.wg.Add(1)
+	go t.accept()
+	return t, nil
+}
+
+// dial connects and authenticates to an SSH server within the context
+func dial(ctx context.Context, address string, clientConfig *ssh.ClientConfig) (*ssh.Client, error) {
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "tcp", address)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to SSH server %s: %w", address, err)
+	}
+
+	// The handshake has no context of its own, closing the connection ends it
+	stop := context.AfterFunc(ctx, func() {
+		conn.Close()
+	})
+	sshConn, channels, requests, err := ssh.NewClientConn(conn, address, clientConfig)
+	if !stop() {
+		if err == nil {
+			sshConn.Close()
+		}
+		return nil, fmt.Errorf("SSH server %s: %w", address, ctx.Err())
+	}
+	if err != nil {
+		conn.Close()
+		return nil, sshError(address, err)
+	}
+
+	return ssh.NewClient(sshConn, channels, requests), nil
+}
+
+// sshError explains a failed SSH handshake
+func sshError(address string, err error) error {
+	var keyErr *knownhosts.KeyError
+	if errors.As(err, &keyErr) {
+		if len(keyErr.Want) == 0 {
+			return fmt.Errorf("SSH server %s is not in known_hosts, connect once with ssh to add its key", address)
+		}
+		return fmt.Errorf("SSH server %s presented a host key that does not match known_hosts line %d",
+			address, keyErr.Want[0].Line)
+	}
+	return fmt.Errorf("SSH server %s: %w", address, err)
+}
+
+// authMethods returns the private key of keyFile, or the keys of the SSH
+// agent and else the default key files without one. The connection to the
+// agent is returned for the caller to close after the handshake.
+func authMethods(keyFile string) ([]ssh.AuthMethod, io.Closer, error) {
+	if keyFile != "" {
+		signer, err := readKey(keyFile)
+		if err != nil {
+			return nil, nil, err
+		}
+		return []ssh.AuthMethod{ssh.PublicKeys(signer)}, nil, nil
+	}
+
+	if socket := os.Getenv("SSH_AUTH_SOCK"); socket != "" {
+		if conn, err := net.Dial("unix", socket); err == nil {
+			// An agent without keys falls back to the key files
+			if signers, err := agent.NewClient(conn).Signers(); err == nil && len(signers) > 0 {
+				return []ssh.AuthMethod{ssh.PublicKeys(signers...)}, conn, nil
+			}
+			conn.Close()
+		}
+	}
+
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return nil, nil, fmt.Errorf("no SSH agent key is available and no key file is set")
+	}
+	var signers []ssh.Signer
+	for _, name := range defaultKeyFiles {
+		if signer, err := readKey(filepath.Join(home, ".ssh", name)); err == nil {
+			signers = append(signers, signer)
+		}
+	}
+	if len(signers) == 0 {
+		return nil, nil, fmt.Errorf("no SSH agent key is available and no key file is set")
+	}
+	return []ssh.AuthMethod{ssh.PublicKeys(signers...)}, nil, nil
+}
+
+// readKey reads an unencrypted private key, ~ stands for the home directory
+func readKey(path string) (ssh.Signer, error) {
+	path = expandHome(path)
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read SSH key: %w", err)
+	}
+
+	signer, err := ssh.ParsePrivateKey(data)
+	var missing *ssh.PassphraseMissingError
+	if errors.As(err, &missing) {
+		return nil, fmt.Errorf("SSH key %s is protected by a passphrase, add it to the SSH agent and leave the key file empty", path)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse SSH key %s: %w", path, err)
+	}
+	return signer, nil
+}
+
+// knownHostsCallback checks host keys against ~/.ssh/known_hosts
+func knownHostsCallback() (ssh.HostKeyCallback, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return nil, fmt.Errorf("failed to find known_hosts: %w", err)
+	}
+
+	path := filepath.Join(home, ".ssh", "known_hosts")
+	callback, err := knownhosts.New(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("%s does not exist, connect once with ssh to add the server key", path)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read known_hosts: %w", err)
+	}
+	return callback, nil
+}
+
+// expandHome replaces a leading ~ with the home directory
+func expandHome(path string) string {
+	if path != "~" && !strings.HasPrefix(path, "~/") {
+		return path
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return path
+	}
+	return filepath.Join(home, path[1:])
+}
+
+// Addr returns the local address that forwards to the database
+func (t *Tunnel) Addr() string {
+	return t.listener.Addr().String()
+}
+
+// Close stops listening, ends the forwarded connections and disconnects
+// from the SSH server
+func (t *Tunnel) Close() error {
+	err := t.listener.Close()
+	t.mu.Lock()
+	for conn := range t.conns {
+		conn.Close()
+	}
+	t.conns = nil
+	t.mu.Unlock()
+	if closeErr := t.client.Close(); err == nil {
+		err = closeErr
+	}
+	t.wg.Wait()
+	return err
+}
+
+// accept forwards each local connection until the listener is closed
+func (t *Tunnel) accept() {
+	defer t.wg.Done()
+	for {
+		local, err := t.listener.Accept()
+		if err != nil {
+			return
+		}
+		t.wg.Add(1)
+		go t.forward(local)
+	}
+}
+
+// forward copies a local connection to the database host and back
+func (t *Tunnel) forward(local net.Conn) {
+	defer t.wg.Done()
+	if !t.track(local, true) {
+		local.Close()
+		return
+	}
+	defer t.track(local, false)
+	defer local.Close()
+
+	remote, err := t.client.Dial("tcp", t.remote)
+	if err != nil {
+		return
+	}
+	defer remote.Close()
+
+	done := make(chan struct{}, 2)
+	go func() {
+		io.Copy(remote, local)
+		done <- struct{}{}
+	}()
+	go func() {
+		io.Copy(local, remote)
+		done <- struct{}{}
+	}()
+	// Either side ending ends the forward, the deferred closes stop the other copy
+	<-done
+}
+
+// track adds or removes an open local connection, it refuses to add one
+// once the tunnel is closing
+func (t *Tunnel) track(conn net.Conn, open bool) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if !open {
+		delete(t.conns, conn)
+		return true
+	}
+	if t.conns == nil {
+		return false
+	}
+	t.conns[conn] = struct{}{}
+	return true
+}
@@ -0,0 +1,399 @@
+package tunnel
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/pem"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/shangyanjin/gocmder/internal/config"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/agent"
+	"golang.org/x/crypto/ssh/knownhosts"
+)
+
+// testServer is an in-process SSH server forwarding direct-tcpip channels
+// to an echo server
+type testServer struct {
+	addr      string
+	hostKey   ssh.Signer
+	clientKey ed25519.PrivateKey
+	echo      string
+	home      string
+}
+
+// newTestServer starts an echo server and an SSH server accepting the
+// client key, HOME points to a directory without known_hosts and keys
+func newTestServer(t *testing.T) *testServer {
+	t.Helper()
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("SSH_AUTH_SOCK", "")
+	if err := os.Mkdir(filepath.Join(home, ".ssh"), 0700); err != nil {
+		t.Fatal(err)
+	}
+
+	_, hostPriv, _ := ed25519.GenerateKey(rand.Reader)
+	hostKey, err := ssh.NewSignerFromKey(hostPriv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, clientKey, _ := ed25519.GenerateKey(rand.Reader)
+	clientPub, err := ssh.NewPublicKey(clientKey.Public())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	serverConfig := &ssh.ServerConfig{
+		PublicKeyCallback: func(meta ssh.ConnMetadata, key ssh.PublicKey) (*ssh.Permissions, error) {
+			if meta.User() == "deploy" && string(key.Marshal()) == string(clientPub.Marshal()) {
+				return nil, nil
+			}
+			return nil, io.EOF
+		},
+	}
+	serverConfig.AddHostKey(hostKey)
+
+	s := &testServer{hostKey: hostKey, clientKey: clientKey, home: home}
+	s.echo = listen(t, func(conn net.Conn) {
+		io.Copy(conn, conn)
+	})
+	s.addr = listen(t, func(conn net.Conn) {
+		serverConn, channels, requests, err := ssh.NewServerConn(conn, serverConfig)
+		if err != nil {
+			return
+		}
+		defer serverConn.Close()
+		go ssh.DiscardRequests(requests)
+		for newChannel := range channels {
+			go forwardChannel(newChannel)
+		}
+	})
+	return s
+}
+
+// listen serves each connection of a local listener with handle
+func listen(t *testing.T, handle func(conn net.Conn)) string {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { listener.Close() })
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				handle(conn)
+			}()
+		}
+	}()
+	return listener.Addr().String()
+}
+
+// forwardChannel connects a direct-tcpip channel to its target address
+func forwardChannel(newChannel ssh.NewChannel) {
+	if newChannel.ChannelType() != "direct-tcpip" {
+		newChannel.Reject(ssh.UnknownChannelType, "unsupported channel")
+		return
+	}
+	var target struct {
+		Host     string
+		Port     uint32
+		OrigHost string
+		OrigPort uint32
+	}
+	if err := ssh.Unmarshal(newChannel.ExtraData(), &target); err != nil {
+		newChannel.Reject(ssh.ConnectionFailed, err.Error())
+		return
+	}
+	remote, err := net.Dial("tcp", net.JoinHostPort(target.Host, strconv.Itoa(int(target.Port))))
+	if err != nil {
+		newChannel.Reject(ssh.ConnectionFailed, err.Error())
+		return
+	}
+	channel, requests, err := newChannel.Accept()
+	if err != nil {
+		remote.Close()
+		return
+	}
+	go ssh.DiscardRequests(requests)
+	go func() {
+		io.Copy(channel, remote)
+		channel.Close()
+	}()
+	io.Copy(remote, channel)
+	remote.Close()
+}
+
+// writeKnownHosts writes a known_hosts line for the server with key
+func (s *testServer) writeKnownHosts(t *testing.T, key ssh.PublicKey) {
+	t.Helper()
+	line := knownhosts.Line([]string{knownhosts.Normalize(s.addr)}, key)
+	if err := os.WriteFile(filepath.Join(s.home, ".ssh", "known_hosts"), []byte(line+"\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// writeClientKey writes the client key to a file in ~/.ssh
+func (s *testServer) writeClientKey(t *testing.T, name string) string {
+	t.Helper()
+	block, err := ssh.MarshalPrivateKey(s.clientKey, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(s.home, ".ssh", name)
+	if err := os.WriteFile(path, pem.EncodeToMemory(block), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// session returns a session reaching the echo server through the SSH server
+func (s *testServer) session(keyFile string) config.Session {
+	sshHost, sshPort, _ := net.SplitHostPort(s.addr)
+	host, port, _ := net.SplitHostPort(s.echo)
+	return config.Session{
+		Driver:     "PostgreSQL",
+		Host:       host,
+		Port:       port,
+		SSHHost:    sshHost,
+		SSHPort:    sshPort,
+		SSHUser:    "deploy",
+		SSHKeyFile: keyFile,
+	}
+}
+
+// open opens a tunnel that the test closes when done
+func open(t *testing.T, session config.Session) *Tunnel {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	tun, err := Open(ctx, session)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { tun.Close() })
+	return tun
+}
+
+// echo sends text through conn and checks that it comes back
+func echo(t *testing.T, conn net.Conn, text string) {
+	t.Helper()
+	conn.SetDeadline(time.Now().Add(5 * time.Second))
+	if _, err := io.WriteString(conn, text); err != nil {
+		t.Fatal(err)
+	}
+	buf := make([]byte, len(text))
+	if _, err := io.ReadFull(conn, buf); err != nil {
+		t.Fatal(err)
+	}
+	if string(buf) != text {
+		t.Fatalf("got %q back, want %q", buf, text)
+	}
+}
+
+func TestTunnelForwards(t *testing.T) {
+	s := newTestServer(t)
+	s.writeKnownHosts(t, s.hostKey.PublicKey())
+	tun := open(t, s.session(s.writeClientKey(t, "deploy_key")))
+
+	for _, text := range []string{"first", "second"} {
+		conn, err := net.Dial("tcp", tun.Addr())
+		if err != nil {
+			t.Fatal(err)
+		}
+		echo(t, conn, text)
+		conn.Close()
+	}
+}
+
+func TestTunnelHostKey(t *testing.T) {
+	s := newTestServer(t)
+	keyFile := s.writeClientKey(t, "deploy_key")
+	_, otherPriv, _ := ed25519.GenerateKey(rand.Reader)
+	otherKey, _ := ssh.NewSignerFromKey(otherPriv)
+
+	tests := []struct {
+		name     string
+		known    ssh.PublicKey
+		insecure bool
+		err      string
+	}{
+		{name: "no known_hosts", err: "does not exist"},
+		{name: "unknown host", known: otherKey.PublicKey(), err: "does not match"},
+		{name: "insecure", known: otherKey.PublicKey(), insecure: true},
+		{name: "known host", known: s.hostKey.PublicKey()},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.known != nil {
+				s.writeKnownHosts(t, tt.known)
+			}
+			session := s.session(keyFile)
+			session.SSHInsecure = tt.insecure
+
+			tun, err := Open(context.Background(), session)
+			if tt.err == "" {
+				if err != nil {
+					t.Fatal(err)
+				}
+				tun.Close()
+				return
+			}
+			if err == nil {
+				tun.Close()
+				t.Fatalf("got no error, want %q", tt.err)
+			}
+			if !strings.Contains(err.Error(), tt.err) {
+				t.Errorf("got %v, want %q", err, tt.err)
+			}
+		})
+	}
+
+	// A host missing from an existing known_hosts file
+	line := knownhosts.Line([]string{"other.example"}, s.hostKey.PublicKey())
+	os.WriteFile(filepath.Join(s.home, ".ssh", "known_hosts"), []byte(line+"\n"), 0600)
+	if _, err := Open(context.Background(), s.session(keyFile)); err == nil || !strings.Contains(err.Error(), "not in known_hosts") {
+		t.Errorf("got %v, want an unknown host error", err)
+	}
+}
+
+func TestTunnelCloseEndsConnections(t *testing.T) {
+	s := newTestServer(t)
+	s.writeKnownHosts(t, s.hostKey.PublicKey())
+	tun := open(t, s.session(s.writeClientKey(t, "deploy_key")))
+
+	conn, err := net.Dial("tcp", tun.Addr())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	echo(t, conn, "open")
+
+	done := make(chan error, 1)
+	go func() { done <- tun.Close() }()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Close did not return while a connection was forwarded")
+	}
+
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	if _, err := conn.Read(make([]byte, 1)); err != io.EOF && !strings.Contains(err.Error(), "reset") {
+		t.Errorf("got %v reading a closed forward, want EOF", err)
+	}
+	if conn, err := net.Dial("tcp", tun.Addr()); err == nil {
+		conn.Close()
+		t.Error("the tunnel still accepts connections after Close")
+	}
+}
+
+// startAgent serves an SSH agent holding keys on a socket in SSH_AUTH_SOCK
+// and returns the number of open agent connections
+func startAgent(t *testing.T, keys ...ed25519.PrivateKey) *atomic.Int32 {
+	t.Helper()
+	// Socket paths are limited to about 100 bytes, TempDir can be longer
+	dir, err := os.MkdirTemp("", "agent")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+	socket := filepath.Join(dir, "sock")
+	listener, err := net.Listen("unix", socket)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { listener.Close() })
+
+	keyring := agent.NewKeyring()
+	for _, key := range keys {
+		if err := keyring.Add(agent.AddedKey{PrivateKey: key}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	open := new(atomic.Int32)
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			open.Add(1)
+			go func() {
+				agent.ServeAgent(keyring, conn)
+				conn.Close()
+				open.Add(-1)
+			}()
+		}
+	}()
+	t.Setenv("SSH_AUTH_SOCK", socket)
+	return open
+}
+
+// waitClosed waits until no agent connection is open
+func waitClosed(t *testing.T, open *atomic.Int32) {
+	t.Helper()
+	for deadline := time.Now().Add(5 * time.Second); open.Load() != 0; {
+		if time.Now().After(deadline) {
+			t.Fatalf("%d agent connections are still open", open.Load())
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestTunnelAgent(t *testing.T) {
+	s := newTestServer(t)
+	s.writeKnownHosts(t, s.hostKey.PublicKey())
+
+	t.Run("agent key", func(t *testing.T) {
+		agentConns := startAgent(t, s.clientKey)
+		tun := open(t, s.session(""))
+		waitClosed(t, agentConns)
+		conn, err := net.Dial("tcp", tun.Addr())
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer conn.Close()
+		echo(t, conn, "agent")
+	})
+
+	t.Run("empty agent falls back to key files", func(t *testing.T) {
+		agentConns := startAgent(t)
+		s.writeClientKey(t, "id_ed25519")
+		defer os.Remove(filepath.Join(s.home, ".ssh", "id_ed25519"))
+		open(t, s.session(""))
+		waitClosed(t, agentConns)
+	})
+
+	t.Run("empty agent and no key files", func(t *testing.T) {
+		agentConns := startAgent(t)
+		if _, err := Open(context.Background(), s.session("")); err == nil {
+			t.Fatal("got no error without any key")
+		}
+		waitClosed(t, agentConns)
+	})
+
+	t.Run("failed handshake closes the agent connection", func(t *testing.T) {
+		_, otherKey, _ := ed25519.GenerateKey(rand.Reader)
+		agentConns := startAgent(t, otherKey)
+		if _, err := Open(context.Background(), s.session("")); err == nil {
+			t.Fatal("got no error with a key the server does not accept")
+		}
+		waitClosed(t, agentConns)
+	})
+}
@@ -93,6 +93,7 @@ func (d *SimpleInputDialog) IsDisplay() bool {
 func (d *SimpleInputDialog) Hide() {
 	d.display = false
 	d.inputField.SetText("")
+	d.inputField.SetMaskCharacter(0)
 	d.focusElement = inputFieldFocus
 }
 
@@ -107,6 +108,11 @@ func (d *SimpleInputDialog) SetLabel(label string) {
 	d.inputField.SetLabel(label)
 }
 
+// SetMaskCharacter masks the input with mask until the dialog is hidden, 0 disables masking
+func (d *SimpleInputDialog) SetMaskCharacter(mask rune) {
+	d.inputField.SetMaskCharacter(mask)
+}
+
 // GetText returns the input field text
 func (d *SimpleInputDialog) GetText() string {
 	return d.inputField.GetText()
@@ -25,6 +25,7 @@ type ProgressDialog struct {
 	display     bool
 	progress    int
 	maxProgress int
+	cancelFunc  func()
 }
 
 // NewProgressDialog returns a new progress dialog primitive
@@ -71,10 +72,12 @@ func (d *ProgressDialog) IsDisplay() bool {
 	return d.display
 }
 
-// Hide stops displaying this primitive
+// Hide stops displaying this primitive, the cancel handler is dropped
 func (d *ProgressDialog) Hide() {
 	d.display = false
 	d.progress = 0
+	d.maxProgress = 100
+	d.cancelFunc = nil
 	d.textView.Clear()
 	d.progressBar.Clear()
 }
@@ -91,7 +94,13 @@ func (d *ProgressDialog) SetText(text string) {
 	fmt.Fprintf(d.textView, "%s", text)
 }
 
-// SetProgress sets the current progress value
+// SetCancelFunc sets the handler called when ESC is pressed, the dialog
+// cannot be cancelled without one
+func (d *ProgressDialog) SetCancelFunc(handler func()) {
+	d.cancelFunc = handler
+}
+
+// SetProgress sets the current progress value, a max of zero or less hides the bar
 func (d *ProgressDialog) SetProgress(current, max int) {
 	d.progress = current
 	d.maxProgress = max
@@ -133,6 +142,15 @@ func (d *ProgressDialog) Focus(delegate func(p tview.Primitive)) {
 	delegate(d.Box)
 }
 
+// InputHandler returns input handler function for this primitive
+func (d *ProgressDialog) InputHandler() func(event *tcell.EventKey, setFocus func(p tview.Primitive)) {
+	return d.WrapInputHandler(func(event *tcell.EventKey, setFocus func(p tview.Primitive)) {
+		if event.Key() == utils.CloseDialogKey.Key && d.cancelFunc != nil {
+			d.cancelFunc()
+		}
+	})
+}
+
 // SetRect sets rects for this primitive
 func (d *ProgressDialog) SetRect(x, y, width, height int) {
 	ws := (width - progressDialogWidth) / 2
@@ -0,0 +1,173 @@
+package dialogs
+
+import (
+	"github.com/gdamore/tcell/v2"
+	"github.com/rivo/tview"
+	"github.com/shangyanjin/gocmder/internal/ui/style"
+	"github.com/shangyanjin/gocmder/internal/ui/utils"
+)
+
+// TextDialog is a scrollable dialog for long read-only text
+type TextDialog struct {
+	*tview.Box
+
+	layout        *tview.Flex
+	textView      *tview.TextView
+	hint          *tview.TextView
+	display       bool
+	doneHandler   func()
+	acceptHandler func()
+}
+
+// NewTextDialog returns a new text dialog primitive
+func NewTextDialog() *TextDialog {
+	bgColor := style.DialogBgColor
+
+	textView := tview.NewTextView()
+	textView.SetBackgroundColor(bgColor)
+	textView.SetTextColor(style.DialogFgColor)
+	textView.SetScrollable(true)
+	textView.SetWrap(false)
+
+	hint := tview.NewTextView()
+	hint.SetBackgroundColor(bgColor)
+	hint.SetTextColor(style.FgColor)
+	hint.SetDynamicColors(true)
+
+	layout := tview.NewFlex().SetDirection(tview.FlexRow)
+	layout.AddItem(textView, 0, 1, true)
+	layout.AddItem(hint, 1, 0, false)
+	layout.SetBorder(true)
+	layout.SetTitleColor(style.FgColor)
+	layout.SetBorderColor(style.DialogBorderColor)
+	layout.SetBackgroundColor(bgColor)
+
+	dialog := &TextDialog{
+		Box:      tview.NewBox(),
+		layout:   layout,
+		textView: textView,
+		hint:     hint,
+		display:  false,
+	}
+	dialog.updateHint()
+
+	return dialog
+}
+
+// updateHint shows the keys of the dialog
+func (d *TextDialog) updateHint() {
+	highlightColor := style.GetColorHex(style.StatusInstalledColor)
+	if d.acceptHandler != nil {
+		d.hint.SetText(" [" + highlightColor + "]↑/↓/PgUp/PgDn[-] Scroll | [" + highlightColor + "]Ctrl+S[-] Apply | [" + highlightColor + "]ESC[-] Cancel")
+		return
+	}
+	d.hint.SetText(" [" + highlightColor + "]↑/↓/PgUp/PgDn[-] Scroll | [" + highlightColor + "]ESC/Enter[-] Close")
+}
+
+// Display displays this primitive
+func (d *TextDialog) Display() {
+	d.display = true
+}
+
+// IsDisplay returns true if primitive is shown
+func (d *TextDialog) IsDisplay() bool {
+	return d.display
+}
+
+// Hide stops displaying this primitive, the dialog is read-only again when shown next
+func (d *TextDialog) Hide() {
+	d.display = false
+	if d.acceptHandler != nil {
+		d.acceptHandler = nil
+		d.updateHint()
+	}
+}
+
+// SetTitle sets text dialog title
+func (d *TextDialog) SetTitle(title string) {
+	d.layout.SetTitle(" " + title + " ")
+}
+
+// SetText sets the dialog text and scrolls to the top
+func (d *TextDialog) SetText(text string) {
+	d.textView.SetText(text)
+	d.textView.ScrollToBeginning()
+}
+
+// SetWrap sets whether long lines are wrapped
+func (d *TextDialog) SetWrap(wrap bool) {
+	d.textView.SetWrap(wrap)
+}
+
+// HasFocus returns whether or not this primitive has focus
+func (d *TextDialog) HasFocus() bool {
+	return d.display && (d.textView.HasFocus() || d.Box.HasFocus())
+}
+
+// Focus is called when this primitive receives focus
+func (d *TextDialog) Focus(delegate func(p tview.Primitive)) {
+	delegate(d.textView)
+}
+
+// InputHandler returns input handler function for this primitive
+func (d *TextDialog) InputHandler() func(event *tcell.EventKey, setFocus func(p tview.Primitive)) {
+	return d.WrapInputHandler(func(event *tcell.EventKey, setFocus func(p tview.Primitive)) {
+		if event.Key() == tcell.KeyCtrlS && d.acceptHandler != nil {
+			d.acceptHandler()
+			return
+		}
+		// Enter does not cancel a dialog waiting for Ctrl+S
+		if event.Key() == utils.CloseDialogKey.Key || (event.Key() == tcell.KeyEnter && d.acceptHandler == nil) {
+			if d.doneHandler != nil {
+				d.doneHandler()
+			}
+			return
+		}
+
+		if handler := d.textView.InputHandler(); handler != nil {
+			handler(event, setFocus)
+		}
+	})
+}
+
+// SetDoneFunc sets the handler called when the dialog is closed
+func (d *TextDialog) SetDoneFunc(handler func()) *TextDialog {
+	d.doneHandler = handler
+	return d
+}
+
+// SetAcceptFunc turns the dialog into a confirmation, Ctrl+S calls handler
+// and ESC closes the dialog with the done handler
+func (d *TextDialog) SetAcceptFunc(handler func()) *TextDialog {
+	d.acceptHandler = handler
+	d.updateHint()
+	return d
+}
+
+// SetRect sets rects for this primitive, the dialog takes most of the given area
+func (d *TextDialog) SetRect(x, y, width, height int) {
+	bWidth := width * 4 / 5
+	bHeight := height * 4 / 5
+
+	if bWidth < MinDialogWidth {
+		bWidth = min(MinDialogWidth, width-1)
+	}
+	if bHeight < MinDialogHeight {
+		bHeight = min(MinDialogHeight, height-1)
+	}
+
+	d.Box.SetRect(x+(width-bWidth)/2, y+(height-bHeight)/2, bWidth, bHeight)
+
+	x, y, width, height = d.GetInnerRect()
+	d.layout.SetRect(x, y, width, height)
+}
+
+// Draw draws this primitive onto the screen
+func (d *TextDialog) Draw(screen tcell.Screen) {
+	if !d.display {
+		return
+	}
+
+	d.DrawForSubclass(screen, d)
+	d.layout.Draw(screen)
+}
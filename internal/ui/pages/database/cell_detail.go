@@ -0,0 +1,81 @@
+package database
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/shangyanjin/gocmder/internal/db"
+)
+
+// maxHexDumpBytes limits the binary data shown in the cell detail popup
+const maxHexDumpBytes = 64 * 1024
+
+// showCellDetail shows the full value of a result cell in a popup
+func (d *Database) showCellDetail(row, column int) {
+	if d.grid == nil {
+		return
+	}
+	value, ok := d.grid.value(row-1, column)
+	if !ok {
+		return
+	}
+
+	col := d.grid.columns[column]
+
+	nullable := "unknown"
+	if col.HasNullable {
+		nullable = "no"
+		if col.Nullable {
+			nullable = "yes"
+		}
+	}
+
+	var text strings.Builder
+	fmt.Fprintf(&text, "Type: %s | Nullable: %s\n\n", col.TypeString(), nullable)
+	text.WriteString(formatDetail(col, value))
+
+	d.textDialog.SetTitle(fmt.Sprintf("%s (row %d)", col.Name, row))
+	d.textDialog.SetText(text.String())
+	d.textDialog.Display()
+	if d.appFocusHandler != nil {
+		d.appFocusHandler()
+	}
+}
+
+// formatDetail returns the full text of a value: a hex dump for binary
+// data and indented JSON for JSON documents
+func formatDetail(column db.Column, value db.Value) string {
+	switch {
+	case value.Null:
+		return "NULL"
+	case value.Kind == db.ValueBinary:
+		data := value.Bytes
+		suffix := ""
+		if len(data) > maxHexDumpBytes {
+			data = data[:maxHexDumpBytes]
+			suffix = fmt.Sprintf("... %d more bytes\n", len(value.Bytes)-maxHexDumpBytes)
+		}
+		return fmt.Sprintf("%d bytes\n\n%s%s", len(value.Bytes), hex.Dump(data), suffix)
+	}
+
+	if column.IsJSON() || looksLikeJSON(value.Text) {
+		var indented bytes.Buffer
+		if err := json.Indent(&indented, []byte(value.Text), "", "  "); err == nil {
+			return indented.String()
+		}
+	}
+
+	return value.Text
+}
+
+// looksLikeJSON returns true if text is a JSON object or array
+func looksLikeJSON(text string) bool {
+	text = strings.TrimSpace(text)
+	if text == "" || (text[0] != '{' && text[0] != '[') {
+		return false
+	}
+	return json.Valid([]byte(text))
+}
@@ -0,0 +1,305 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gdamore/tcell/v2"
+	"github.com/rivo/tview"
+	"github.com/shangyanjin/gocmder/internal/config"
+	"github.com/shangyanjin/gocmder/internal/db"
+	"github.com/shangyanjin/gocmder/internal/ui/style"
+)
+
+// compareSide is one of the two results of a comparison
+type compareSide struct {
+	label   string // names the side in changed rows
+	only    string // marks the rows found on this side only
+	columns []db.Column
+	rows    [][]db.Value
+	// read reads the rows in the background if they are not known yet
+	read func(ctx context.Context) ([]db.Column, [][]db.Value, error)
+}
+
+// diffMark tells how a row of a comparison grid is highlighted
+type diffMark struct {
+	color   tcell.Color
+	changed []bool // columns with changed values, nil to color the whole row
+}
+
+// comparedTab returns the shown result tab if it can be compared
+func (d *Database) comparedTab() (*resultTab, bool) {
+	if d.grid == nil || d.activeTab >= len(d.tabs) || d.tabs[d.activeTab].grid != d.grid {
+		d.updateStatusBar("Run a query to compare its result")
+		return nil, false
+	}
+	tab := d.tabs[d.activeTab]
+	if tab.diff != nil {
+		d.updateStatusBar("Choose the result of a query to compare, not a comparison")
+		return nil, false
+	}
+	return tab, true
+}
+
+// showCompare opens the compare dialog for the shown result. The primary
+// key of the table the query reads is suggested as key, or else the first column.
+func (d *Database) showCompare() {
+	if !d.canExecute() {
+		return
+	}
+	tab, ok := d.comparedTab()
+	if !ok {
+		return
+	}
+
+	grid := tab.grid
+	d.loadEditTarget(tab.statement.Text, grid, func(target *db.EditTarget, err error) {
+		var key []string
+		if err == nil {
+			for _, i := range target.Key {
+				key = append(key, grid.columns[i].Name)
+			}
+		} else if len(grid.columns) > 0 {
+			key = []string{grid.columns[0].Name}
+		}
+
+		d.compareDialog.Show(d.compareSessions(), key)
+	})
+}
+
+// compareSessions returns the other saved sessions of the connected driver
+func (d *Database) compareSessions() []string {
+	if d.sessions == nil {
+		return nil
+	}
+	current, ok := d.sessions.Get(d.currentSession)
+	if !ok {
+		return nil
+	}
+
+	driver := driverName(current.Driver)
+	var names []string
+	for _, session := range d.sessions.List() {
+		if driverName(session.Driver) == driver && session.Name != current.Name {
+			names = append(names, session.Name)
+		}
+	}
+	return names
+}
+
+// handleCompare compares the shown result as chosen in the compare dialog.
+// The query runs again in this session, and in the other session if one
+// was chosen; without one the shown rows are compared with the new ones.
+func (d *Database) handleCompare(request compareRequest) {
+	if !d.canExecute() {
+		return
+	}
+	tab, ok := d.comparedTab()
+	if !ok {
+		return
+	}
+	statement := tab.statement
+	read := d.compareReader(statement)
+	driver := d.driver
+	current := func(ctx context.Context) ([]db.Column, [][]db.Value, error) {
+		return read(ctx, driver)
+	}
+
+	if request.session == "" {
+		grid := tab.grid
+		if grid.hasMore() || grid.limited || grid.err != nil {
+			d.showError("Only part of the shown result was fetched. Scroll to its end, or raise the row limit of the session, to compare all rows.")
+			return
+		}
+		before := compareSide{label: "before", only: "removed", columns: grid.columns, rows: grid.rows}
+		after := compareSide{label: "after", only: "added", read: current}
+		d.runCompare(statement, request.key, before, after)
+		return
+	}
+
+	session, ok := d.sessions.Get(request.session)
+	if !ok {
+		d.showError(fmt.Sprintf("Session not found: %s", request.session))
+		return
+	}
+	compare := func(password string) {
+		here := compareSide{label: d.currentSession, only: "only " + d.currentSession, read: current}
+		there := compareSide{label: session.Name, only: "only " + session.Name, read: sessionReader(session, password, read)}
+		d.runCompare(statement, request.key, here, there)
+	}
+	if fileDriver(session.Driver) {
+		compare("")
+		return
+	}
+	d.resolvePassword(session, func(password string, ok bool) {
+		if ok {
+			compare(password)
+			return
+		}
+		d.promptSecret("Compare", fmt.Sprintf("Password for %s: ", session.Name), compare)
+	})
+}
+
+// compareReader returns a reader of the rows of statement, which fails if
+// the statement returns no rows or more than the row limit
+func (d *Database) compareReader(statement db.Statement) func(ctx context.Context, driver db.Driver) ([]db.Column, [][]db.Value, error) {
+	timeout, rowLimit := d.timeout, d.rowLimit
+	return func(ctx context.Context, driver db.Driver) ([]db.Column, [][]db.Value, error) {
+		tab := runScriptStatement(ctx, driver, statement, timeout, rowLimit)
+		switch {
+		case tab.err != nil:
+			return nil, nil, tab.err
+		case tab.grid == nil:
+			return nil, nil, fmt.Errorf("the statement returns no rows")
+		case tab.grid.limited:
+			return nil, nil, fmt.Errorf("more than %d rows, raise the row limit of the session or narrow the query", rowLimit)
+		}
+		return tab.grid.columns, tab.grid.rows, nil
+	}
+}
+
+// sessionReader returns a reader of rows in another session, it connects
+// for the comparison only
+func sessionReader(session config.Session, password string, read func(ctx context.Context, driver db.Driver) ([]db.Column, [][]db.Value, error)) func(ctx context.Context) ([]db.Column, [][]db.Value, error) {
+	return func(ctx context.Context) ([]db.Column, [][]db.Value, error) {
+		driver, err := newDriver(session.Driver)
+		if err != nil {
+			return nil, nil, err
+		}
+		tun, err := connectDriver(ctx, driver, session, password)
+		if err != nil {
+			return nil, nil, fmt.Errorf("connection failed: %w", err)
+		}
+		defer closeDriver(driver, tun)
+
+		return read(ctx, driver)
+	}
+}
+
+// runCompare reads the rows of both sides that are not known in the
+// background and shows their differences in a new result tab
+func (d *Database) runCompare(statement db.Statement, key []string, old, new compareSide) {
+	ctx, cancel := context.WithCancel(context.Background())
+	run := &runningQuery{
+		ctx:     ctx,
+		cancel:  cancel,
+		started: time.Now(),
+		done:    make(chan struct{}),
+		total:   1,
+	}
+	d.running = run
+	d.updateStatusBar("Comparing results... (Esc/Ctrl+C to cancel)")
+
+	go func() {
+		var wg sync.WaitGroup
+		errs := make([]error, 2)
+		for i, side := range []*compareSide{&old, &new} {
+			if side.read == nil {
+				continue
+			}
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				if side.columns, side.rows, errs[i] = side.read(ctx); errs[i] != nil {
+					errs[i] = fmt.Errorf("%s: %w", side.label, errs[i])
+				}
+			}()
+		}
+		wg.Wait()
+
+		var diff *db.ResultDiff
+		err := errs[0]
+		if err == nil {
+			err = errs[1]
+		}
+		if err == nil {
+			diff, err = db.DiffResults(old.columns, old.rows, new.columns, new.rows, key)
+		}
+
+		d.queueUpdateDraw(func() {
+			d.finishCompare(run, statement, old, new, diff, err)
+		})
+	}()
+	go d.showElapsed(run)
+}
+
+// finishCompare shows the differences of a comparison with a summary
+func (d *Database) finishCompare(run *runningQuery, statement db.Statement, old, new compareSide, diff *db.ResultDiff, err error) {
+	close(run.done)
+	run.cancel()
+	if d.running != run {
+		return
+	}
+	d.running = nil
+
+	elapsed := time.Since(run.started).Round(time.Millisecond)
+	if err != nil {
+		if run.canceled {
+			d.updateStatusBar(fmt.Sprintf("Comparison cancelled after %s", elapsed))
+			return
+		}
+		d.showError(fmt.Sprintf("Comparison failed: %v", err))
+		if d.errorDialog.IsDisplay() && d.HasFocus() && d.appFocusHandler != nil {
+			d.appFocusHandler()
+		}
+		return
+	}
+
+	grid := newDiffGrid(diff, old, new)
+	status := fmt.Sprintf("Compared in %s: %s", elapsed, diff.Summary())
+	if skipped := append(diff.OnlyOld, diff.OnlyNew...); len(skipped) > 0 {
+		status += " | not in both: " + tview.Escape(strings.Join(skipped, ", "))
+	}
+	d.addTab(&resultTab{
+		statement: statement,
+		result:    &db.QueryResult{Kind: db.StatementQuery, Columns: grid.columns, Rows: grid.rows},
+		grid:      grid,
+		elapsed:   elapsed,
+		status:    status,
+		diff:      diff,
+	})
+}
+
+// newDiffGrid returns a read-only grid of the rows that differ, with the
+// change in the first column. A changed row is shown twice, with the old
+// values and with the new ones, and the changed values are highlighted.
+func newDiffGrid(diff *db.ResultDiff, old, new compareSide) *resultGrid {
+	columns := append([]db.Column{{Name: "change"}}, diff.Columns...)
+	var rows [][]db.Value
+	var marks []diffMark
+	add := func(label string, values []db.Value, mark diffMark) {
+		rows = append(rows, append([]db.Value{{Text: label}}, values...))
+		marks = append(marks, mark)
+	}
+
+	for _, row := range diff.Rows {
+		switch row.Kind {
+		case db.DiffAdded:
+			add(new.only, row.New, diffMark{color: style.StatusInstalledColor})
+		case db.DiffRemoved:
+			add(old.only, row.Old, diffMark{color: style.StatusErrorColor})
+		case db.DiffChanged:
+			add(old.label, row.Old, diffMark{color: style.StatusErrorColor, changed: row.Changed})
+			add(new.label, row.New, diffMark{color: style.StatusInstalledColor, changed: row.Changed})
+		}
+	}
+
+	grid := newResultGrid(&db.QueryResult{Columns: columns}, rows, nil, len(rows))
+	grid.readOnly = "a comparison cannot be edited"
+	grid.styleCell = func(index, column int, cell *tview.TableCell) {
+		mark := marks[index]
+		switch {
+		case mark.changed == nil:
+			cell.SetTextColor(mark.color)
+		case column == 0:
+			cell.SetTextColor(style.StatusNotInstalledColor)
+		case mark.changed[column-1]:
+			cell.SetTextColor(mark.color)
+			cell.SetAttributes(tcell.AttrBold)
+		}
+	}
+	return grid
+}
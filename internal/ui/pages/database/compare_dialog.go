@@ -0,0 +1,228 @@
+package database
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/gdamore/tcell/v2"
+	"github.com/rivo/tview"
+	"github.com/shangyanjin/gocmder/internal/ui/style"
+	"github.com/shangyanjin/gocmder/internal/ui/utils"
+)
+
+const (
+	compareDialogWidth  = 64
+	compareDialogHeight = 10
+)
+
+// comparePrevious is the label of the shown result in the compare dialog
+const comparePrevious = "This session (run the query again)"
+
+// compareRequest is a comparison chosen in the compare dialog
+type compareRequest struct {
+	session string   // session the query runs in too, empty to run it again here
+	key     []string // columns that identify a row
+}
+
+// CompareDialog asks what the shown result is compared with and by which key columns
+type CompareDialog struct {
+	*tview.Box
+
+	layout          *tview.Flex
+	form            *tview.Form
+	display         bool
+	targets         []string // comparePrevious and the sessions of the same driver
+	target          int
+	key             string
+	compareFunc     func(request compareRequest)
+	appFocusHandler func()
+}
+
+// NewCompareDialog creates a new compare dialog
+func NewCompareDialog(compareFunc func(request compareRequest)) *CompareDialog {
+	bgColor := style.DialogBgColor
+
+	dialog := &CompareDialog{
+		Box:         tview.NewBox(),
+		compareFunc: compareFunc,
+	}
+
+	dialog.form = tview.NewForm()
+	dialog.form.SetBackgroundColor(bgColor)
+	dialog.form.SetButtonBackgroundColor(style.ButtonBgColor)
+	dialog.form.SetFieldBackgroundColor(style.BgColor)
+	dialog.form.SetLabelColor(style.FgColor)
+	dialog.form.SetFieldTextColor(style.FgColor)
+	dialog.form.SetButtonsAlign(tview.AlignCenter)
+
+	highlightColor := style.GetColorHex(style.StatusInstalledColor)
+	shortcutsHint := tview.NewTextView()
+	shortcutsHint.SetBackgroundColor(bgColor)
+	shortcutsHint.SetTextColor(style.FgColor)
+	shortcutsHint.SetDynamicColors(true)
+	shortcutsHint.SetText(" [" + highlightColor + "]Tab[-] Next field | [" + highlightColor + "]Enter[-] Select | [" + highlightColor + "]ESC[-] Cancel")
+
+	dialog.layout = tview.NewFlex().SetDirection(tview.FlexRow)
+	dialog.layout.AddItem(dialog.form, 0, 1, true)
+	dialog.layout.AddItem(shortcutsHint, 1, 0, false)
+	dialog.layout.SetBorder(true)
+	dialog.layout.SetTitle(" Compare Result ")
+	dialog.layout.SetTitleColor(style.FgColor)
+	dialog.layout.SetBorderColor(style.DialogBorderColor)
+	dialog.layout.SetBackgroundColor(bgColor)
+
+	return dialog
+}
+
+// Show shows the dialog for the sessions the result can be compared with
+// and the suggested key columns
+func (d *CompareDialog) Show(sessions []string, key []string) {
+	previous := ""
+	if d.target > 0 && d.target < len(d.targets) {
+		previous = d.targets[d.target]
+	}
+
+	d.targets = append([]string{comparePrevious}, sessions...)
+	d.target = 0
+	for i, target := range d.targets {
+		if target == previous {
+			d.target = i
+		}
+	}
+	d.key = strings.Join(key, ", ")
+
+	d.form.Clear(true)
+	d.form.AddDropDown("Compare with", d.targets, d.target, func(_ string, index int) {
+		if index >= 0 {
+			d.target = index
+		}
+	})
+	d.form.AddInputField("Key columns", d.key, 40, nil, func(text string) {
+		d.key = text
+	})
+	d.form.AddButton("Compare", d.handleCompare)
+	d.form.AddButton("Cancel", d.close)
+	d.form.SetFocus(0)
+	d.display = true
+}
+
+// handleCompare checks the form and hands the request to the compare handler
+func (d *CompareDialog) handleCompare() {
+	request := compareRequest{}
+	if d.target > 0 {
+		request.session = d.targets[d.target]
+	}
+	for _, name := range strings.Split(d.key, ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			request.key = append(request.key, name)
+		}
+	}
+	if len(request.key) == 0 {
+		d.fail(fmt.Errorf("enter the key columns that identify a row"))
+		return
+	}
+
+	d.Hide()
+	if d.compareFunc != nil {
+		d.compareFunc(request)
+	}
+	if d.appFocusHandler != nil {
+		d.appFocusHandler()
+	}
+}
+
+// fail reports an invalid field in the dialog title
+func (d *CompareDialog) fail(err error) {
+	d.layout.SetTitle(" " + err.Error() + " ")
+	d.layout.SetTitleColor(style.StatusErrorColor)
+}
+
+// close hides the dialog and restores the page focus
+func (d *CompareDialog) close() {
+	d.Hide()
+	if d.appFocusHandler != nil {
+		d.appFocusHandler()
+	}
+}
+
+// Display displays this primitive
+func (d *CompareDialog) Display() {
+	d.display = true
+}
+
+// IsDisplay returns true if primitive is shown
+func (d *CompareDialog) IsDisplay() bool {
+	return d.display
+}
+
+// Hide stops displaying this primitive
+func (d *CompareDialog) Hide() {
+	d.display = false
+	d.layout.SetTitle(" Compare Result ")
+	d.layout.SetTitleColor(style.FgColor)
+}
+
+// HasFocus returns whether or not this primitive has focus
+func (d *CompareDialog) HasFocus() bool {
+	return d.display && (d.form.HasFocus() || d.Box.HasFocus())
+}
+
+// Focus is called when this primitive receives focus
+func (d *CompareDialog) Focus(delegate func(p tview.Primitive)) {
+	delegate(d.form)
+}
+
+// SetAppFocusHandler sets the app focus handler
+func (d *CompareDialog) SetAppFocusHandler(handler func()) {
+	d.appFocusHandler = handler
+}
+
+// InputHandler returns input handler function for this primitive
+func (d *CompareDialog) InputHandler() func(event *tcell.EventKey, setFocus func(p tview.Primitive)) {
+	return d.WrapInputHandler(func(event *tcell.EventKey, setFocus func(p tview.Primitive)) {
+		// ESC closes an open drop-down list before the dialog
+		dropDown, _ := d.form.GetFormItem(0).(*tview.DropDown)
+		if event.Key() == utils.CloseDialogKey.Key && (dropDown == nil || !dropDown.IsOpen()) {
+			d.close()
+			return
+		}
+
+		if handler := d.form.InputHandler(); handler != nil {
+			handler(event, setFocus)
+		}
+	})
+}
+
+// SetRect sets rects for this primitive
+func (d *CompareDialog) SetRect(x, y, width, height int) {
+	ws := (width - compareDialogWidth) / 2
+	hs := (height - compareDialogHeight) / 2
+	dy := y + hs
+	bWidth := compareDialogWidth
+	bHeight := compareDialogHeight
+
+	if compareDialogWidth > width {
+		ws = 0
+		bWidth = width - 1
+	}
+
+	if compareDialogHeight >= height {
+		dy = y + 1
+		bHeight = height - 1
+	}
+
+	d.Box.SetRect(x+ws, dy, bWidth, bHeight)
+
+	x, y, width, height = d.GetInnerRect()
+	d.layout.SetRect(x, y, width, height)
+}
+
+// Draw draws this primitive onto the screen
+func (d *CompareDialog) Draw(screen tcell.Screen) {
+	if !d.display {
+		return
+	}
+
+	d.DrawForSubclass(screen, d)
+	d.layout.Draw(screen)
+}
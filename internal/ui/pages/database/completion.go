@@ -0,0 +1,210 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/rivo/tview"
+	"github.com/shangyanjin/gocmder/internal/db"
+)
+
+// metadataTimeout limits loading the tables and columns offered by completion
+const metadataTimeout = 10 * time.Second
+
+// schemaMetadata holds the table and column names of a database offered by
+// completion. It is only used on the UI goroutine.
+type schemaMetadata struct {
+	tables  []string            // as listed by the driver, nil until loaded
+	columns map[string][]string // by lower case table name
+	loading map[string]bool     // tables whose columns are loading, "" for the table list
+}
+
+// metadataKey returns the key of the database queries run against in the
+// metadata cache
+func (d *Database) metadataKey() string {
+	return d.currentSession + "\x00" + d.currentDatabase
+}
+
+// currentMetadata returns the cached metadata of the current database, the
+// table list is loaded on first use
+func (d *Database) currentMetadata() *schemaMetadata {
+	key := d.metadataKey()
+	metadata, ok := d.metadata[key]
+	if !ok {
+		metadata = &schemaMetadata{columns: map[string][]string{}, loading: map[string]bool{}}
+		d.metadata[key] = metadata
+		d.loadMetadata(metadata, "")
+	}
+	return metadata
+}
+
+// invalidateMetadata drops the cached metadata of the current database, it
+// is loaded again when it is needed
+func (d *Database) invalidateMetadata() {
+	delete(d.metadata, d.metadataKey())
+}
+
+// loadMetadata loads the table list, or the columns of table, in the
+// background and updates the completions once they are there
+func (d *Database) loadMetadata(metadata *schemaMetadata, table string) {
+	if metadata.loading[table] {
+		return
+	}
+	metadata.loading[table] = true
+	driver, database := d.driver, d.currentDatabase
+
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), metadataTimeout)
+		defer cancel()
+
+		var tables, columns []string
+		var err error
+		if table == "" {
+			tables, err = driver.GetTables(ctx, database)
+		} else {
+			schema, name := splitTableName(driver.GetDialect(), table)
+			var info *db.TableInfo
+			if info, err = driver.DescribeTable(ctx, database, schema, name); err == nil {
+				for _, column := range info.Columns {
+					columns = append(columns, column.Name)
+				}
+			}
+		}
+
+		d.queueUpdateDraw(func() {
+			delete(metadata.loading, table)
+			if err != nil {
+				d.updateStatusBar(fmt.Sprintf("Failed to load completions: %s", tview.Escape(err.Error())))
+			}
+
+			// Names that failed to load are not asked for again until the
+			// metadata is invalidated
+			if table == "" {
+				metadata.tables = append([]string{}, tables...)
+			} else {
+				metadata.columns[strings.ToLower(table)] = columns
+			}
+			d.sqlEditor.RefreshCompletions()
+		})
+	}()
+}
+
+// splitTableName splits a table name listed by a driver into its schema and
+// name, only PostgreSQL qualifies tables outside the public schema
+func splitTableName(dialect db.Dialect, table string) (string, string) {
+	if dialect == db.DialectPostgres {
+		if schema, name, ok := strings.Cut(table, "."); ok {
+			return schema, name
+		}
+	}
+	return "", table
+}
+
+// table returns the name of a table as listed by the driver, empty if it
+// is not known. A name qualified with the default schema is found without it.
+func (m *schemaMetadata) table(schema, name string) string {
+	for _, table := range m.tables {
+		if schema != "" && strings.EqualFold(table, schema+"."+name) {
+			return table
+		}
+	}
+	for _, table := range m.tables {
+		if strings.EqualFold(table, name) {
+			return table
+		}
+	}
+	return ""
+}
+
+// completions returns the columns, tables and keywords offered for a word of
+// statement. Columns are those of the tables the statement refers to, a
+// qualifier limits them to one table or alias, or lists the tables of a
+// PostgreSQL schema. Nothing is offered while the names are loading, the
+// editor completes the word once they are there.
+func (d *Database) completions(statement, qualifier string) []completion {
+	dialect := d.dialect()
+
+	// MySQL tables are listed once a database is chosen in the tree
+	var completions []completion
+	if d.connected && d.driver != nil && (d.currentDatabase != "" || dialect != db.DialectMySQL) {
+		metadata := d.currentMetadata()
+		if metadata.tables == nil {
+			return nil
+		}
+		references := db.TableReferences(dialect, statement)
+		if qualifier != "" {
+			return d.qualifiedCompletions(metadata, references, qualifier)
+		}
+
+		for _, reference := range references {
+			if table := metadata.table(reference.Schema, reference.Table); table != "" {
+				columns, ok := d.columnCompletions(metadata, table)
+				if !ok {
+					return nil
+				}
+				completions = append(completions, columns...)
+			}
+		}
+		for _, table := range metadata.tables {
+			schema, name := splitTableName(dialect, table)
+			text := db.QuoteName(dialect, name)
+			if schema != "" {
+				text = db.QuoteName(dialect, schema) + "." + text
+			}
+			completions = append(completions, completion{label: table, text: text, kind: completeTable})
+		}
+	}
+	if qualifier != "" {
+		return nil
+	}
+
+	for _, keyword := range db.Keywords(dialect) {
+		completions = append(completions, completion{label: keyword, text: keyword, kind: completeKeyword})
+	}
+	return completions
+}
+
+// qualifiedCompletions returns the columns of the table or alias named by
+// qualifier, or the tables of the schema it names
+func (d *Database) qualifiedCompletions(metadata *schemaMetadata, references []db.TableReference, qualifier string) []completion {
+	for _, reference := range references {
+		if strings.EqualFold(reference.Alias, qualifier) || reference.Alias == "" && strings.EqualFold(reference.Table, qualifier) {
+			if table := metadata.table(reference.Schema, reference.Table); table != "" {
+				columns, _ := d.columnCompletions(metadata, table)
+				return columns
+			}
+		}
+	}
+	if table := metadata.table("", qualifier); table != "" {
+		columns, _ := d.columnCompletions(metadata, table)
+		return columns
+	}
+
+	dialect := d.dialect()
+	var completions []completion
+	for _, table := range metadata.tables {
+		if schema, name := splitTableName(dialect, table); schema != "" && strings.EqualFold(schema, qualifier) {
+			completions = append(completions, completion{label: name, text: db.QuoteName(dialect, name), kind: completeTable})
+		}
+	}
+	return completions
+}
+
+// columnCompletions returns the columns of a table, which are loaded in the
+// background the first time. It returns false while they are loading.
+func (d *Database) columnCompletions(metadata *schemaMetadata, table string) ([]completion, bool) {
+	columns, ok := metadata.columns[strings.ToLower(table)]
+	if !ok {
+		d.loadMetadata(metadata, table)
+		return nil, false
+	}
+
+	dialect := d.dialect()
+	completions := make([]completion, len(columns))
+	for i, column := range columns {
+		completions[i] = completion{label: column, text: db.QuoteName(dialect, column), kind: completeColumn}
+	}
+	return completions, true
+}
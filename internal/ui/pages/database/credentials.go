@@ -0,0 +1,167 @@
+package database
+
+import (
+	"fmt"
+
+	"github.com/shangyanjin/gocmder/internal/config"
+	"github.com/shangyanjin/gocmder/internal/vault"
+)
+
+// withVault runs action once the credential vault is unlocked, asking for the master passphrase if needed
+func (d *Database) withVault(action func()) {
+	if d.vault == nil {
+		return
+	}
+
+	if d.vault.IsUnlocked() {
+		action()
+		return
+	}
+
+	if d.vault.Exists() {
+		d.promptSecret("Unlock Vault", "Passphrase: ", func(passphrase string) {
+			if err := d.vault.Unlock(passphrase); err != nil {
+				d.showError(fmt.Sprintf("Failed to unlock vault: %v", err))
+				return
+			}
+			action()
+		})
+		return
+	}
+
+	d.promptSecret("Create Vault", "New passphrase: ", func(passphrase string) {
+		d.promptSecret("Create Vault", "Repeat passphrase: ", func(repeated string) {
+			if passphrase != repeated {
+				d.showError("Passphrases do not match")
+				return
+			}
+			if err := d.vault.Unlock(passphrase); err != nil {
+				d.showError(fmt.Sprintf("Failed to create vault: %v", err))
+				return
+			}
+			action()
+		})
+	})
+}
+
+// lookupPassword finds a password for session outside the vault, see
+// vault.LookupPassword, which expects the registered driver name
+func lookupPassword(session config.Session) (string, string, bool) {
+	session.Driver = driverName(session.Driver)
+	return vault.LookupPassword(session)
+}
+
+// knownPassword returns a password for session without prompting, used to pre-fill the connection dialog
+func (d *Database) knownPassword(session config.Session) string {
+	if d.vault != nil && d.vault.IsUnlocked() {
+		if password, ok, err := d.vault.Get(session.Name); err == nil && ok {
+			return password
+		}
+	}
+
+	password, _, _ := lookupPassword(session)
+	return password
+}
+
+// resolvePassword finds the password of a session in the vault or the fallback
+// lookups and passes it to handler, ok is false if none was found
+func (d *Database) resolvePassword(session config.Session, handler func(password string, ok bool)) {
+	fallback := func() {
+		password, _, ok := lookupPassword(session)
+		handler(password, ok)
+	}
+
+	if d.vault == nil || !d.vault.Exists() {
+		fallback()
+		return
+	}
+
+	d.withVault(func() {
+		password, ok, err := d.vault.Get(session.Name)
+		if err != nil || !ok {
+			fallback()
+			return
+		}
+		handler(password, true)
+	})
+}
+
+// storePassword saves the password of a session in the vault
+func (d *Database) storePassword(session config.Session, password string) {
+	if d.vault == nil || session.Name == "" {
+		return
+	}
+
+	// Nothing to store if the fallback lookup already provides it
+	if known, _, ok := lookupPassword(session); ok && known == password {
+		return
+	}
+	if password == "" && !d.vault.Exists() {
+		return
+	}
+
+	d.withVault(func() {
+		var err error
+		if password == "" {
+			err = d.vault.Delete(session.Name)
+		} else {
+			err = d.vault.Set(session.Name, password)
+		}
+		if err != nil {
+			d.showError(fmt.Sprintf("Failed to store password: %v", err))
+		}
+	})
+}
+
+// renameStoredPassword moves a stored password to a renamed session
+func (d *Database) renameStoredPassword(oldName, newName string) {
+	if d.vault == nil || !d.vault.Exists() {
+		return
+	}
+
+	d.withVault(func() {
+		if err := d.vault.Rename(oldName, newName); err != nil {
+			d.showError(fmt.Sprintf("Failed to rename stored password: %v", err))
+		}
+	})
+}
+
+// copyStoredPassword copies a stored password to a duplicated session
+func (d *Database) copyStoredPassword(name, copyName string) {
+	if d.vault == nil || !d.vault.Exists() {
+		return
+	}
+
+	d.withVault(func() {
+		password, ok, err := d.vault.Get(name)
+		if err == nil && ok {
+			err = d.vault.Set(copyName, password)
+		}
+		if err != nil {
+			d.showError(fmt.Sprintf("Failed to copy stored password: %v", err))
+		}
+	})
+}
+
+// deleteStoredPassword removes the stored password of a deleted session
+func (d *Database) deleteStoredPassword(name string) {
+	if d.vault == nil || !d.vault.Exists() {
+		return
+	}
+
+	d.withVault(func() {
+		if err := d.vault.Delete(name); err != nil {
+			d.showError(fmt.Sprintf("Failed to delete stored password: %v", err))
+		}
+	})
+}
+
+// lockVault locks the credential vault
+func (d *Database) lockVault() {
+	if d.vault == nil || !d.vault.IsUnlocked() {
+		return
+	}
+
+	d.vault.Lock()
+	d.updateStatusBar("Credential vault locked")
+}
@@ -1,37 +1,78 @@
 package database
 
 import (
+	"context"
+	"database/sql"
 	"fmt"
-	"strings"
+	"net"
 	"sync"
+	"time"
 
 	"github.com/gdamore/tcell/v2"
 	"github.com/rivo/tview"
+	"github.com/shangyanjin/gocmder/internal/config"
 	"github.com/shangyanjin/gocmder/internal/db"
+	"github.com/shangyanjin/gocmder/internal/tunnel"
 	"github.com/shangyanjin/gocmder/internal/ui/components/dialogs"
 	"github.com/shangyanjin/gocmder/internal/ui/style"
+	"github.com/shangyanjin/gocmder/internal/vault"
 )
 
 // Database implements the database management page primitive
 type Database struct {
 	*tview.Box
 
-	title           string
-	mainFlex        *tview.Flex
-	leftPanel       *tview.TreeView
-	rightPanel      *tview.Flex
-	sqlEditor       *tview.TextArea
-	resultTable     *tview.Table
-	statusBar       *tview.TextView
-	errorDialog     *dialogs.ErrorDialog
-	messageDialog   *dialogs.MessageDialog
-	connDialog      *ConnectionDialog
-	driver          db.Driver
-	connected       bool
-	currentDatabase string
-	mu              sync.Mutex
-	focusedElement  int // 0=tree, 1=editor, 2=result
-	appFocusHandler func()
+	title               string
+	mainFlex            *tview.Flex
+	leftPanel           *tview.TreeView
+	rightPanel          *tview.Flex
+	sqlEditor           *SQLEditor
+	resultTable         *tview.Table
+	tabBar              *tview.TextView
+	statusBar           *tview.TextView
+	statusMessage       string
+	errorDialog         *dialogs.ErrorDialog
+	messageDialog       *dialogs.MessageDialog
+	textDialog          *dialogs.TextDialog
+	confirmDialog       *dialogs.ConfirmDialog
+	inputDialog         *dialogs.SimpleInputDialog
+	progressDialog      *dialogs.ProgressDialog
+	connDialog          *ConnectionDialog
+	exportDialog        *ExportDialog
+	compareDialog       *CompareDialog
+	schemaCompareDialog *SchemaCompareDialog
+	importDialog        *ImportDialog
+	inspector           *TableInspector
+	planViewer          *PlanViewer
+	history             *HistoryPanel
+	snippets            *SnippetPanel
+	sessions            *config.SessionStore
+	historyStore        *config.HistoryStore
+	snippetStore        *config.SnippetStore
+	parameters          map[string]string          // last values of query parameters by name
+	metadata            map[string]*schemaMetadata // completion metadata by session and database
+	driver              db.Driver
+	tunnel              *tunnel.Tunnel // SSH tunnel the driver connects through
+	connected           bool
+	currentSession      string
+	currentDatabase     string
+	timeout             time.Duration
+	isolation           sql.IsolationLevel // isolation level of the next transaction
+	transactionDone     chan struct{}      // closed when the open transaction ends
+	running             *runningQuery
+	grid                *resultGrid
+	tabs                []*resultTab
+	activeTab           int
+	stopOnError         bool
+	showSystem          bool // show system databases and schemas in the tree
+	rowLimit            int
+	queueUpdateDraw     func(f func())
+	vault               *vault.Vault
+	confirmAction       func()
+	mu                  sync.Mutex
+	focusedElement      int // 0=tree, 1=editor, 2=result
+	appFocusHandler     func()
+	screen              tcell.Screen // last drawn screen, used to copy to the terminal clipboard
 }
 
 const (
@@ -47,7 +88,19 @@ func NewDatabase() *Database {
 		title:          "database",
 		errorDialog:    dialogs.NewErrorDialog(),
 		messageDialog:  dialogs.NewMessageDialog(""),
+		textDialog:     dialogs.NewTextDialog(),
+		confirmDialog:  dialogs.NewConfirmDialog(),
+		inputDialog:    dialogs.NewSimpleInputDialog(""),
+		progressDialog: dialogs.NewProgressDialog(),
+		inspector:      NewTableInspector(),
+		planViewer:     NewPlanViewer(),
+		parameters:     map[string]string{},
+		metadata:       map[string]*schemaMetadata{},
+		queueUpdateDraw: func(f func()) {
+			f()
+		},
 		connected:      false,
+		stopOnError:    true,
 		focusedElement: focusTree,
 	}
 
@@ -60,15 +113,32 @@ func NewDatabase() *Database {
 	database.leftPanel.SetBackgroundColor(style.BgColor)
 	database.leftPanel.SetGraphicsColor(style.StatusInstalledColor)
 
-	rootNode := tview.NewTreeNode("Not Connected")
-	rootNode.SetColor(style.StatusNotInstalledColor)
-	database.leftPanel.SetRoot(rootNode)
-	database.leftPanel.SetCurrentNode(rootNode)
+	// Load saved connection sessions
+	sessions, err := config.NewSessionStore()
+	if sessions != nil {
+		database.sessions = sessions
+	}
+	database.buildSessionTree()
+
+	// Load the query history
+	history, historyErr := config.NewHistoryStore()
+	if history != nil {
+		database.historyStore = history
+	}
+
+	// Snippet files are read each time the snippets are opened
+	if snippets, _ := config.NewSnippetStore(); snippets != nil {
+		database.snippetStore = snippets
+	}
+
+	// Open the credential vault, it stays locked until a password is needed
+	if credentials, err := vault.NewDefault(); err == nil {
+		database.vault = credentials
+	}
 
 	// Create SQL editor
-	database.sqlEditor = tview.NewTextArea()
+	database.sqlEditor = NewSQLEditor(database.completions)
 	database.sqlEditor.SetBorder(true)
-	database.sqlEditor.SetTitle(" SQL Editor (Ctrl+R: Execute) ")
 	database.sqlEditor.SetTitleColor(style.FgColor)
 	database.sqlEditor.SetBorderColor(style.BorderColor)
 	database.sqlEditor.SetBackgroundColor(style.DialogBgColor)
@@ -76,6 +146,7 @@ func NewDatabase() *Database {
 	database.sqlEditor.SetTextStyle(tcell.StyleDefault.
 		Foreground(style.FgColor).
 		Background(style.DialogBgColor))
+	database.updateEditorTitle()
 
 	// Create result table
 	database.resultTable = tview.NewTable()
@@ -84,19 +155,34 @@ func NewDatabase() *Database {
 	database.resultTable.SetTitleColor(style.FgColor)
 	database.resultTable.SetBorderColor(style.BorderColor)
 	database.resultTable.SetBackgroundColor(style.BgColor)
-	database.resultTable.SetSelectable(true, false)
+	database.resultTable.SetSelectable(true, true)
 	database.resultTable.SetFixed(1, 0)
 
+	// Create result tab bar, shown when a script has more than one result
+	database.tabBar = tview.NewTextView()
+	database.tabBar.SetBackgroundColor(style.InfoBarBgColor)
+	database.tabBar.SetTextColor(style.FgColor)
+	database.tabBar.SetDynamicColors(true)
+	database.tabBar.SetRegions(true)
+	database.tabBar.SetWrap(false)
+
 	// Create status bar
 	database.statusBar = tview.NewTextView()
 	database.statusBar.SetBackgroundColor(style.InfoBarBgColor)
 	database.statusBar.SetTextColor(style.InfoBarFgColor)
 	database.statusBar.SetDynamicColors(true)
 	database.updateStatusBar("Ready. Press Ctrl+N to connect")
+	if err != nil {
+		database.updateStatusBar(fmt.Sprintf("Failed to load sessions: %v", err))
+	}
+	if historyErr != nil {
+		database.updateStatusBar(fmt.Sprintf("Failed to load query history: %v", historyErr))
+	}
 
 	// Create right panel layout
 	database.rightPanel = tview.NewFlex().SetDirection(tview.FlexRow)
 	database.rightPanel.AddItem(database.sqlEditor, 0, 1, true)
+	database.rightPanel.AddItem(database.tabBar, 0, 0, false)
 	database.rightPanel.AddItem(database.resultTable, 0, 2, false)
 
 	// Create main layout
@@ -111,6 +197,26 @@ func NewDatabase() *Database {
 
 	// Create connection dialog
 	database.connDialog = NewConnectionDialog(database.handleConnect)
+	database.connDialog.SetSaveFunc(database.saveSession)
+	database.connDialog.SetPasswordFunc(database.knownPassword)
+
+	// Create export dialog
+	database.exportDialog = NewExportDialog(database.handleExport)
+
+	// Create compare dialog
+	database.compareDialog = NewCompareDialog(database.handleCompare)
+
+	// Create schema compare dialog
+	database.schemaCompareDialog = NewSchemaCompareDialog(database.handleSchemaCompare)
+
+	// Create import dialog
+	database.importDialog = NewImportDialog(database.readImportFile, database.startImport, database.renameImportColumn)
+
+	// Create query history panel
+	database.history = NewHistoryPanel(database.loadHistoryEntry, database.runHistoryEntry)
+
+	// Create snippet panel
+	database.snippets = NewSnippetPanel(database.runSnippet, database.loadSnippet, database.saveSnippet, database.deleteSnippet)
 
 	// Set dialog handlers with focus restoration
 	database.errorDialog.SetDoneFunc(func() {
@@ -119,12 +225,67 @@ func NewDatabase() *Database {
 			database.appFocusHandler()
 		}
 	})
+	database.textDialog.SetDoneFunc(func() {
+		database.textDialog.Hide()
+		if database.appFocusHandler != nil {
+			database.appFocusHandler()
+		}
+	})
+	database.inspector.SetDoneFunc(func() {
+		database.inspector.Hide()
+		if database.appFocusHandler != nil {
+			database.appFocusHandler()
+		}
+	})
+	database.planViewer.SetDoneFunc(func() {
+		database.planViewer.Hide()
+		if database.appFocusHandler != nil {
+			database.appFocusHandler()
+		}
+	})
+	database.planViewer.SetAnalyzeFunc(database.analyzeStatement)
+	database.history.SetDoneFunc(func() {
+		database.history.Hide()
+		if database.appFocusHandler != nil {
+			database.appFocusHandler()
+		}
+	})
+	database.snippets.SetDoneFunc(func() {
+		database.snippets.Hide()
+		if database.appFocusHandler != nil {
+			database.appFocusHandler()
+		}
+	})
 	database.messageDialog.SetCancelFunc(func() {
 		database.messageDialog.Hide()
 		if database.appFocusHandler != nil {
 			database.appFocusHandler()
 		}
 	})
+	database.confirmDialog.SetSelectedFunc(func() {
+		database.confirmDialog.Hide()
+		// The action may ask for another confirmation
+		if action := database.confirmAction; action != nil {
+			database.confirmAction = nil
+			action()
+		}
+		if database.appFocusHandler != nil {
+			database.appFocusHandler()
+		}
+	})
+	database.confirmDialog.SetCancelFunc(func() {
+		database.confirmDialog.Hide()
+		database.confirmAction = nil
+		if database.appFocusHandler != nil {
+			database.appFocusHandler()
+		}
+	})
+	database.inputDialog.SetCancelFunc(func() {
+		database.inputDialog.Hide()
+		if database.appFocusHandler != nil {
+			database.appFocusHandler()
+		}
+	})
 
 	// Set connection dialog app focus handler to restore focus after closing
 	database.connDialog.SetAppFocusHandler(func() {
@@ -132,10 +293,33 @@ func NewDatabase() *Database {
 			database.appFocusHandler()
 		}
 	})
+	database.exportDialog.SetAppFocusHandler(func() {
+		if database.appFocusHandler != nil {
+			database.appFocusHandler()
+		}
+	})
+	database.compareDialog.SetAppFocusHandler(func() {
+		if database.appFocusHandler != nil {
+			database.appFocusHandler()
+		}
+	})
+	database.schemaCompareDialog.SetAppFocusHandler(func() {
+		if database.appFocusHandler != nil {
+			database.appFocusHandler()
+		}
+	})
+	database.importDialog.SetAppFocusHandler(func() {
+		if database.appFocusHandler != nil {
+			database.appFocusHandler()
+		}
+	})
 
 	// Set tree selection handler
 	database.leftPanel.SetSelectedFunc(database.handleTreeSelection)
 
+	// Enter on a result cell shows the full value
+	database.resultTable.SetSelectedFunc(database.showCellDetail)
+
 	return database
 }
 
@@ -146,8 +330,7 @@ func (d *Database) GetTitle() string {
 
 // HasFocus returns whether or not this primitive has focus
 func (d *Database) HasFocus() bool {
-	return d.mainFlex.HasFocus() || d.errorDialog.HasFocus() ||
-		d.messageDialog.HasFocus() || d.connDialog.HasFocus() || d.Box.HasFocus()
+	return d.mainFlex.HasFocus() || d.SubDialogHasFocus() || d.Box.HasFocus()
 }
 
 // Focus is called when this primitive receives focus
@@ -156,14 +339,62 @@ func (d *Database) Focus(delegate func(p tview.Primitive)) {
 		delegate(d.errorDialog)
 		return
 	}
+	if d.textDialog.IsDisplay() {
+		delegate(d.textDialog)
+		return
+	}
 	if d.messageDialog.IsDisplay() {
 		delegate(d.messageDialog)
 		return
 	}
+	if d.confirmDialog.IsDisplay() {
+		delegate(d.confirmDialog)
+		return
+	}
+	if d.inputDialog.IsDisplay() {
+		delegate(d.inputDialog)
+		return
+	}
 	if d.connDialog.IsDisplay() {
 		delegate(d.connDialog)
 		return
 	}
+	if d.exportDialog.IsDisplay() {
+		delegate(d.exportDialog)
+		return
+	}
+	if d.compareDialog.IsDisplay() {
+		delegate(d.compareDialog)
+		return
+	}
+	if d.schemaCompareDialog.IsDisplay() {
+		delegate(d.schemaCompareDialog)
+		return
+	}
+	if d.importDialog.IsDisplay() {
+		delegate(d.importDialog)
+		return
+	}
+	if d.progressDialog.IsDisplay() {
+		delegate(d.progressDialog)
+		return
+	}
+	if d.snippets.IsDisplay() {
+		delegate(d.snippets)
+		return
+	}
+	if d.history.IsDisplay() {
+		delegate(d.history)
+		return
+	}
+	if d.planViewer.IsDisplay() {
+		delegate(d.planViewer)
+		return
+	}
+	if d.inspector.IsDisplay() {
+		delegate(d.inspector)
+		return
+	}
 
 	// Focus based on current element
 	switch d.focusedElement {
@@ -183,6 +414,34 @@ func (d *Database) SetAppFocusHandler(handler func()) {
 	d.appFocusHandler = handler
 }
 
+// SetQueueUpdateDraw sets the function used to apply background results on the UI goroutine
+func (d *Database) SetQueueUpdateDraw(handler func(f func())) {
+	d.queueUpdateDraw = handler
+}
+
+// CapturesKey returns true if the page handles a global key itself
+func (d *Database) CapturesKey(event *tcell.EventKey) bool {
+	if d.cancelsQuery(event) {
+		return true
+	}
+
+	// Typing in the editor must not quit or switch pages
+	return d.focusedElement == focusEditor && d.sqlEditor.HasFocus() && d.sqlEditor.CapturesKey(event)
+}
+
+// cancelsQuery returns true if a key cancels the running query: Esc and
+// Ctrl+C cancel it instead of leaving the page or quitting, unless Esc
+// closes the completion list of the editor
+func (d *Database) cancelsQuery(event *tcell.EventKey) bool {
+	if d.running == nil {
+		return false
+	}
+	if event.Key() == tcell.KeyEscape {
+		return !d.sqlEditor.HasFocus() || !d.sqlEditor.Completing()
+	}
+	return event.Key() == tcell.KeyCtrlC
+}
+
 // HideAllDialogs hides all sub dialogs
 func (d *Database) HideAllDialogs() {
 	if d.errorDialog.IsDisplay() {
@@ -191,86 +450,285 @@ func (d *Database) HideAllDialogs() {
 	if d.messageDialog.IsDisplay() {
 		d.messageDialog.Hide()
 	}
+	if d.textDialog.IsDisplay() {
+		d.textDialog.Hide()
+	}
+	if d.confirmDialog.IsDisplay() {
+		d.confirmDialog.Hide()
+	}
+	if d.inputDialog.IsDisplay() {
+		d.inputDialog.Hide()
+	}
 	if d.connDialog.IsDisplay() {
 		d.connDialog.Hide()
 	}
+	if d.exportDialog.IsDisplay() {
+		d.exportDialog.Hide()
+	}
+	if d.compareDialog.IsDisplay() {
+		d.compareDialog.Hide()
+	}
+	if d.schemaCompareDialog.IsDisplay() {
+		d.schemaCompareDialog.Hide()
+	}
+	if d.importDialog.IsDisplay() {
+		d.importDialog.Hide()
+	}
+	if d.inspector.IsDisplay() {
+		d.inspector.Hide()
+	}
+	if d.planViewer.IsDisplay() {
+		d.planViewer.Hide()
+	}
+	if d.history.IsDisplay() {
+		d.history.Hide()
+	}
+	if d.snippets.IsDisplay() {
+		d.snippets.Hide()
+	}
 }
 
 // SubDialogHasFocus returns whether or not sub dialog primitive has focus
 func (d *Database) SubDialogHasFocus() bool {
-	return d.errorDialog.HasFocus() || d.messageDialog.HasFocus() || d.connDialog.HasFocus()
+	return d.errorDialog.HasFocus() || d.messageDialog.HasFocus() || d.textDialog.HasFocus() ||
+		d.confirmDialog.HasFocus() || d.inputDialog.HasFocus() || d.connDialog.HasFocus() ||
+		d.exportDialog.HasFocus() || d.compareDialog.HasFocus() || d.schemaCompareDialog.HasFocus() ||
+		d.importDialog.HasFocus() || d.progressDialog.HasFocus() ||
+		d.inspector.HasFocus() || d.planViewer.HasFocus() || d.history.HasFocus() || d.snippets.HasFocus()
 }
 
 // updateStatusBar updates the status bar
 func (d *Database) updateStatusBar(message string) {
+	d.statusMessage = message
+	d.renderStatusBar()
+}
+
+// renderStatusBar shows the status message after the open transaction indicator
+func (d *Database) renderStatusBar() {
 	highlightColor := style.GetColorHex(style.StatusInstalledColor)
-	d.statusBar.SetText(fmt.Sprintf(" [%s]Status:[-] %s", highlightColor, message))
+	d.statusBar.SetText(fmt.Sprintf("%s [%s]Status:[-] %s", d.transactionStatus(), highlightColor, d.statusMessage))
 }
 
 // handleConnect handles database connection
-func (d *Database) handleConnect(driverType, dsn string) {
+func (d *Database) handleConnect(session config.Session, password string) {
+	if d.confirmEndTransaction(func() { d.handleConnect(session, password) }) {
+		return
+	}
+
 	d.mu.Lock()
 	defer d.mu.Unlock()
 
 	// Close existing connection
+	d.abortQuery()
+	d.closeTabs()
+	d.inspector.Hide()
+	d.planViewer.Hide()
+	d.stopTransactionAge()
 	if d.driver != nil {
 		d.driver.Close()
+		d.driver = nil
+		d.closeTunnel()
+		d.connected = false
+		d.currentSession = ""
+		d.currentDatabase = ""
+		d.timeout = 0
 	}
 
 	// Create new driver
-	var driver db.Driver
-	switch driverType {
-	case "MySQL":
-		driver = db.NewMySQL()
-	case "PostgreSQL":
-		driver = db.NewPostgres()
-	default:
-		d.showError("Invalid driver type: " + driverType)
+	driver, err := newDriver(session.Driver)
+	if err != nil {
+		d.showError(err.Error())
+		d.buildSessionTree()
 		return
 	}
 
-	// Connect
-	err := driver.Connect(dsn)
-	if err != nil {
-		d.showError(fmt.Sprintf("Connection failed: %v", err))
-		return
+	// Connect in the background, through the SSH tunnel of the session if
+	// it has one. connectDriver applies the connect timeout.
+	d.buildSessionTree()
+	if node := d.findSessionNode(session.Name); node != nil {
+		node.AddChild(loadingNode())
+		node.SetExpanded(true)
+	}
+
+	var tun *tunnel.Tunnel
+	var connectErr error
+	ctx, cancel := context.WithCancel(context.Background())
+	run := d.runTask(ctx, cancel, fmt.Sprintf("Connecting to %s...", tview.Escape(session.Name)), func(ctx context.Context) error {
+		tun, connectErr = connectDriver(ctx, driver, session, password)
+		return connectErr
+	}, func(err error) {
+		if err != nil {
+			d.showError(fmt.Sprintf("Connection failed: %v", err))
+			d.buildSessionTree()
+			return
+		}
+
+		d.mu.Lock()
+		defer d.mu.Unlock()
+
+		d.driver = driver
+		d.tunnel = tun
+		d.connected = true
+		d.currentSession = session.Name
+		d.metadata = map[string]*schemaMetadata{}
+		d.isolation = sql.LevelDefault
+		d.sqlEditor.SetDialect(driver.GetDialect())
+		d.timeout = session.Timeout()
+		d.rowLimit = session.MaxRows()
+		d.buildSessionTree()
+		d.loadDatabases(fmt.Sprintf("Connected to %s (%s)", driver.GetDriverName(), session.Name))
+	})
+	// A connection made after the task was cancelled or replaced is closed again
+	run.discard = func() {
+		if connectErr == nil {
+			closeDriver(driver, tun)
+		}
+		if !d.connected && d.running == nil {
+			d.buildSessionTree()
+		}
 	}
+}
 
-	d.driver = driver
-	d.connected = true
-	d.updateStatusBar(fmt.Sprintf("Connected to %s", driver.GetDriverName()))
+// newDriver creates the driver registered under a session driver name
+func newDriver(name string) (db.Driver, error) {
+	info, ok := db.LookupDriver(name)
+	if !ok {
+		return nil, fmt.Errorf("Invalid driver type: %s", name)
+	}
+	return info.New(), nil
+}
 
-	// Load databases
-	d.loadDatabases()
+// driverName returns the registered name of a session driver, which may be
+// saved under an alias
+func driverName(name string) string {
+	if info, ok := db.LookupDriver(name); ok {
+		return info.Name
+	}
+	return name
 }
 
-// loadDatabases loads database list into tree
-func (d *Database) loadDatabases() {
-	if !d.connected || d.driver == nil {
-		return
+// fileDriver returns true if a driver opens a local database file, which
+// needs no password and no SSH tunnel
+func fileDriver(name string) bool {
+	info, ok := db.LookupDriver(name)
+	return ok && info.File
+}
+
+// tunneled returns true if a session connects through an SSH tunnel
+func tunneled(session config.Session) bool {
+	return session.SSHHost != "" && !fileDriver(session.Driver)
+}
+
+// connectDriver connects a driver to a session within its connect timeout.
+// A session with an SSH host is reached through a local port forward, which
+// the DSN points to; the caller closes the returned tunnel after the driver.
+func connectDriver(ctx context.Context, driver db.Driver, session config.Session, password string) (*tunnel.Tunnel, error) {
+	if info, ok := db.LookupDriver(session.Driver); ok && session.Port == "" {
+		session.Port = info.DefaultPort
+	}
+	conn := connConfig(session, password)
+	conn.ConnectTimeout = connectTimeout
+	if session.ConnectTimeout > 0 {
+		conn.ConnectTimeout = time.Duration(session.ConnectTimeout) * time.Second
+	}
+	ctx, cancel := context.WithTimeout(ctx, conn.ConnectTimeout)
+	defer cancel()
+
+	var tun *tunnel.Tunnel
+	if tunneled(session) {
+		var err error
+		if tun, err = tunnel.Open(ctx, session); err != nil {
+			driver.Close()
+			return nil, err
+		}
+		// The server certificate is still issued for the database host
+		conn.TLSServerName = conn.Host
+		conn.Host, conn.Port, _ = net.SplitHostPort(tun.Addr())
 	}
 
-	databases, err := d.driver.GetDatabases()
+	dsn, err := buildDSN(session.Driver, conn)
+	if err == nil {
+		err = driver.Connect(ctx, dsn)
+	}
 	if err != nil {
-		d.showError(fmt.Sprintf("Failed to load databases: %v", err))
+		driver.Close()
+		if tun != nil {
+			tun.Close()
+		}
+		return nil, err
+	}
+	return tun, nil
+}
+
+// closeDriver closes a driver connected for one task and its tunnel
+func closeDriver(driver db.Driver, tun *tunnel.Tunnel) {
+	driver.Close()
+	if tun != nil {
+		tun.Close()
+	}
+}
+
+// closeTunnel closes the SSH tunnel of the closed connection
+func (d *Database) closeTunnel() {
+	if d.tunnel != nil {
+		d.tunnel.Close()
+		d.tunnel = nil
+	}
+}
+
+// loadDatabases loads the database list into the tree in the background,
+// status is shown once it is loaded
+func (d *Database) loadDatabases(status string) {
+	if !d.canExecute() {
 		return
 	}
+	sessionNode := d.activeSessionNode()
+	if sessionNode == nil {
+		return
+	}
+
+	var databases []string
+	ctx, cancel := d.statementContext()
+	d.loadTreeNode(ctx, cancel, sessionNode, "Loading databases...", func(ctx context.Context) error {
+		var err error
+		databases, err = d.driver.GetDatabases(ctx)
+		return err
+	}, func(err error) {
+		if err != nil {
+			d.showError(fmt.Sprintf("Failed to load databases: %v", err))
+			return
+		}
+		d.showDatabases(sessionNode, databases)
+		d.updateStatusBar(status)
+	})
+}
+
+// showDatabases lists the databases under the active session node
+func (d *Database) showDatabases(sessionNode *tview.TreeNode, databases []string) {
+	// Drivers bound to one database report which one
+	if switcher, ok := d.driver.(db.DatabaseSwitcher); ok {
+		d.currentDatabase = switcher.CurrentDatabase()
+	}
 
-	// Build tree
-	rootNode := tview.NewTreeNode(d.driver.GetDriverName())
-	rootNode.SetColor(style.StatusInstalledColor)
-	rootNode.SetExpanded(true)
+	sessionNode.ClearChildren()
+	sessionNode.AddChild(d.systemToggleNode())
 
+	dialect := d.driver.GetDialect()
 	for _, dbName := range databases {
+		// The connected database is shown even if it is a system database
+		if !d.showSystem && db.IsSystemDatabase(dialect, dbName) && dbName != d.currentDatabase {
+			continue
+		}
 		dbNode := tview.NewTreeNode(dbName)
 		dbNode.SetColor(style.FgColor)
 		dbNode.SetReference(map[string]string{"type": "database", "name": dbName})
 		dbNode.SetSelectable(true)
-		rootNode.AddChild(dbNode)
+		sessionNode.AddChild(dbNode)
 	}
+	d.markCurrentDatabase()
 
-	d.leftPanel.SetRoot(rootNode)
-	d.leftPanel.SetCurrentNode(rootNode)
+	sessionNode.SetExpanded(true)
+	d.leftPanel.SetCurrentNode(sessionNode)
 }
 
 // handleTreeSelection handles tree node selection
@@ -286,117 +744,116 @@ func (d *Database) handleTreeSelection(node *tview.TreeNode) {
 	}
 
 	switch data["type"] {
+	case "session":
+		if d.connected && data["name"] == d.currentSession {
+			// The databases are loaded again if that was cancelled
+			if len(node.GetChildren()) == 0 {
+				d.loadDatabases(fmt.Sprintf("Loaded the databases of %s", tview.Escape(d.currentSession)))
+				return
+			}
+			node.SetExpanded(!node.IsExpanded())
+			return
+		}
+		d.connectSession(data["name"])
 	case "database":
-		d.loadTables(data["name"])
+		if browser, ok := d.driver.(db.SchemaBrowser); ok {
+			d.loadSchemas(node, browser, data["name"])
+			return
+		}
+		d.loadTables(node, data["name"])
+	case "schema":
+		if browser, ok := d.driver.(db.SchemaBrowser); ok {
+			d.loadSchemaObjects(node, browser, data["database"], data["name"])
+		}
+	case "group":
+		node.SetExpanded(!node.IsExpanded())
+	case "system":
+		d.toggleSystemObjects()
 	case "table":
-		// Could show table structure here
-		d.updateStatusBar(fmt.Sprintf("Selected table: %s.%s", data["database"], data["name"]))
+		d.inspectTable(data["database"], data["schema"], data["name"])
+	case "view", "matview", "function", "sequence", "enum":
+		d.updateStatusBar(fmt.Sprintf("%s %s.%s in %s", objectKinds[data["type"]], tview.Escape(data["schema"]),
+			tview.Escape(data["name"]), tview.Escape(data["database"])))
 	}
 }
 
-// loadTables loads tables for a database
-func (d *Database) loadTables(dbName string) {
-	if !d.connected || d.driver == nil {
+// loadTables loads the tables of a database under its tree node in the background
+func (d *Database) loadTables(node *tview.TreeNode, dbName string) {
+	if !d.canExecute() {
 		return
 	}
 
 	d.currentDatabase = dbName
-	d.updateStatusBar(fmt.Sprintf("Loading tables from %s...", dbName))
-
-	tables, err := d.driver.GetTables(dbName)
-	if err != nil {
-		d.showError(fmt.Sprintf("Failed to load tables: %v", err))
-		return
-	}
-
-	// Find database node and add tables
-	root := d.leftPanel.GetRoot()
-	for _, child := range root.GetChildren() {
-		ref := child.GetReference()
-		if data, ok := ref.(map[string]string); ok && data["name"] == dbName {
-			// Clear existing children
-			child.ClearChildren()
-
-			// Add tables
-			for _, tableName := range tables {
-				tableNode := tview.NewTreeNode("  " + tableName)
-				tableNode.SetColor(style.StatusSelectedColor)
-				tableNode.SetReference(map[string]string{
-					"type":     "table",
-					"database": dbName,
-					"name":     tableName,
-				})
-				tableNode.SetSelectable(true)
-				child.AddChild(tableNode)
-			}
-			child.SetExpanded(true)
-			break
+	d.markCurrentDatabase()
+	d.invalidateMetadata()
+
+	var tables []string
+	ctx, cancel := d.statementContext()
+	d.loadTreeNode(ctx, cancel, node, fmt.Sprintf("Loading tables from %s...", tview.Escape(dbName)), func(ctx context.Context) error {
+		var err error
+		tables, err = d.driver.GetTables(ctx, dbName)
+		return err
+	}, func(err error) {
+		if err != nil {
+			d.showError(fmt.Sprintf("Failed to load tables: %v", err))
+			return
 		}
-	}
-
-	d.updateStatusBar(fmt.Sprintf("Loaded %d tables from %s", len(tables), dbName))
-}
-
-// executeQuery executes the SQL query
-func (d *Database) executeQuery() {
-	if !d.connected || d.driver == nil {
-		d.showError("Not connected to database")
-		return
-	}
-
-	query := strings.TrimSpace(d.sqlEditor.GetText())
-	if query == "" {
-		return
-	}
 
-	d.updateStatusBar("Executing query...")
-
-	result, err := d.driver.ExecuteQuery(query)
-	if err != nil {
-		d.showError(fmt.Sprintf("Query error: %v", err))
-		d.updateStatusBar("Query failed")
-		return
-	}
+		for _, tableName := range tables {
+			tableNode := tview.NewTreeNode("  " + tableName)
+			tableNode.SetColor(style.StatusSelectedColor)
+			tableNode.SetReference(map[string]string{
+				"type":     "table",
+				"database": dbName,
+				"name":     tableName,
+			})
+			tableNode.SetSelectable(true)
+			node.AddChild(tableNode)
+		}
+		node.SetExpanded(true)
 
-	// Display results
-	d.displayResult(result)
-	d.updateStatusBar(fmt.Sprintf("Query executed. Rows: %d", result.RowsAffected))
+		d.updateStatusBar(fmt.Sprintf("Loaded %d tables from %s", len(tables), dbName))
+	})
 }
 
 // displayResult displays query results in table
 func (d *Database) displayResult(result *db.QueryResult) {
-	d.resultTable.Clear()
+	d.grid = nil
+	d.resultTable.SetContent(nil)
+	d.updateGridTitle()
 
 	if len(result.Columns) == 0 {
 		// DML/DDL result
 		cell := tview.NewTableCell(fmt.Sprintf("Rows affected: %d", result.RowsAffected))
 		cell.SetTextColor(style.StatusInstalledColor)
 		d.resultTable.SetCell(0, 0, cell)
-		return
-	}
 
-	// Add headers
-	for i, col := range result.Columns {
-		cell := tview.NewTableCell(strings.ToUpper(col))
-		cell.SetExpansion(1)
-		cell.SetBackgroundColor(style.PageHeaderBgColor)
-		cell.SetTextColor(style.PageHeaderFgColor)
-		cell.SetAlign(tview.AlignLeft)
-		cell.SetSelectable(false)
-		d.resultTable.SetCell(0, i, cell)
-	}
+		if result.LastInsertID > 0 {
+			cell := tview.NewTableCell(fmt.Sprintf("Last insert ID: %d", result.LastInsertID))
+			cell.SetTextColor(style.StatusInstalledColor)
+			d.resultTable.SetCell(d.resultTable.GetRowCount(), 0, cell)
+		}
 
-	// Add rows
-	for rowIdx, row := range result.Rows {
-		for colIdx, value := range row {
-			cell := tview.NewTableCell(value)
-			cell.SetTextColor(style.FgColor)
-			cell.SetAlign(tview.AlignLeft)
-			d.resultTable.SetCell(rowIdx+1, colIdx, cell)
+		// Server notices and warnings
+		for _, notice := range result.Notices {
+			cell := tview.NewTableCell(tview.Escape(notice))
+			cell.SetTextColor(style.StatusNotInstalledColor)
+			d.resultTable.SetCell(d.resultTable.GetRowCount(), 0, cell)
 		}
+		return
 	}
 
-	d.resultTable.ScrollToBeginning()
+	// Fully fetched rows are shown in a grid without cursor
+	d.displayGrid(newResultGrid(result, result.Rows, nil, len(result.Rows)))
+}
+
+// updateEditorTitle shows the run keys and the script error mode in the editor title
+func (d *Database) updateEditorTitle() {
+	onError := "continue"
+	if d.stopOnError {
+		onError = "stop"
+	}
+	d.sqlEditor.SetTitle(fmt.Sprintf(" SQL Editor (Ctrl+R: Run | Ctrl+G: All | On error: %s) ", onError))
 }
 
 // showError shows an error dialog
@@ -420,14 +877,68 @@ func (d *Database) InputHandler() func(event *tcell.EventKey, setFocus func(p tv
 				if handler := d.errorDialog.InputHandler(); handler != nil {
 					handler(event, setFocus)
 				}
+			} else if d.textDialog.HasFocus() {
+				if handler := d.textDialog.InputHandler(); handler != nil {
+					handler(event, setFocus)
+				}
 			} else if d.messageDialog.HasFocus() {
 				if handler := d.messageDialog.InputHandler(); handler != nil {
 					handler(event, setFocus)
 				}
+			} else if d.confirmDialog.HasFocus() {
+				if handler := d.confirmDialog.InputHandler(); handler != nil {
+					handler(event, setFocus)
+				}
+			} else if d.inputDialog.HasFocus() {
+				if handler := d.inputDialog.InputHandler(); handler != nil {
+					handler(event, setFocus)
+				}
+			} else if d.exportDialog.HasFocus() {
+				if handler := d.exportDialog.InputHandler(); handler != nil {
+					handler(event, setFocus)
+				}
+			} else if d.compareDialog.HasFocus() {
+				if handler := d.compareDialog.InputHandler(); handler != nil {
+					handler(event, setFocus)
+				}
+			} else if d.schemaCompareDialog.HasFocus() {
+				if handler := d.schemaCompareDialog.InputHandler(); handler != nil {
+					handler(event, setFocus)
+				}
+			} else if d.importDialog.HasFocus() {
+				if handler := d.importDialog.InputHandler(); handler != nil {
+					handler(event, setFocus)
+				}
+			} else if d.progressDialog.HasFocus() {
+				if handler := d.progressDialog.InputHandler(); handler != nil {
+					handler(event, setFocus)
+				}
+			} else if d.inspector.HasFocus() {
+				if handler := d.inspector.InputHandler(); handler != nil {
+					handler(event, setFocus)
+				}
+			} else if d.planViewer.HasFocus() {
+				if handler := d.planViewer.InputHandler(); handler != nil {
+					handler(event, setFocus)
+				}
+			} else if d.history.HasFocus() {
+				if handler := d.history.InputHandler(); handler != nil {
+					handler(event, setFocus)
+				}
+			} else if d.snippets.HasFocus() {
+				if handler := d.snippets.InputHandler(); handler != nil {
+					handler(event, setFocus)
+				}
 			}
 			return
 		}
 
+		// Esc or Ctrl+C to cancel the running query
+		if d.cancelsQuery(event) {
+			d.cancelQuery()
+			return
+		}
+
 		// Ctrl+N to open connection dialog
 		if event.Key() == tcell.KeyCtrlN {
 			d.connDialog.Display()
@@ -438,15 +949,86 @@ func (d *Database) InputHandler() func(event *tcell.EventKey, setFocus func(p tv
 		// Ctrl+D to disconnect
 		if event.Key() == tcell.KeyCtrlD && d.connected {
 			d.disconnect()
+			d.Focus(setFocus)
 			return
 		}
 
-		// Ctrl+R to execute query
+		// Ctrl+R to execute the selection or the statement under the cursor
 		if event.Key() == tcell.KeyCtrlR {
 			d.executeQuery()
 			return
 		}
 
+		// Ctrl+G to execute all statements
+		if event.Key() == tcell.KeyCtrlG {
+			d.executeScript()
+			return
+		}
+
+		// Alt+X to explain the selection or the statement under the cursor
+		if event.Key() == tcell.KeyRune && event.Rune() == 'x' && event.Modifiers() == tcell.ModAlt {
+			d.explainQuery()
+			d.Focus(setFocus)
+			return
+		}
+
+		// Alt+T to begin a transaction, Alt+C to commit it and Alt+R to roll it back
+		if event.Key() == tcell.KeyRune && event.Modifiers() == tcell.ModAlt {
+			switch event.Rune() {
+			case 't':
+				d.beginTransaction()
+				d.Focus(setFocus)
+				return
+			case 'c':
+				d.endTransaction(true)
+				d.Focus(setFocus)
+				return
+			case 'r':
+				d.endTransaction(false)
+				d.Focus(setFocus)
+				return
+			case 'i':
+				d.cycleIsolation()
+				d.Focus(setFocus)
+				return
+			}
+		}
+
+		// Alt+D to compare the shown result with a new run or another session
+		if event.Key() == tcell.KeyRune && event.Rune() == 'd' && event.Modifiers() == tcell.ModAlt {
+			d.showCompare()
+			d.Focus(setFocus)
+			return
+		}
+
+		// Alt+S to compare the schema with the database of another session
+		if event.Key() == tcell.KeyRune && event.Rune() == 's' && event.Modifiers() == tcell.ModAlt {
+			d.showSchemaCompare()
+			d.Focus(setFocus)
+			return
+		}
+
+		// Ctrl+P to open the query history
+		if event.Key() == tcell.KeyCtrlP {
+			d.showHistory()
+			d.Focus(setFocus)
+			return
+		}
+
+		// Ctrl+T to open the snippets
+		if event.Key() == tcell.KeyCtrlT {
+			d.showSnippets()
+			d.Focus(setFocus)
+			return
+		}
+
+		// Ctrl+O to choose whether a script stops at the first error
+		if event.Key() == tcell.KeyCtrlO {
+			d.stopOnError = !d.stopOnError
+			d.updateEditorTitle()
+			return
+		}
+
 		// Ctrl+Left/Right to switch panels
 		if event.Key() == tcell.KeyLeft && event.Modifiers() == tcell.ModCtrl {
 			d.focusedElement = (d.focusedElement - 1 + 3) % 3
@@ -463,6 +1045,10 @@ func (d *Database) InputHandler() func(event *tcell.EventKey, setFocus func(p tv
 		switch d.focusedElement {
 		case focusTree:
 			if d.leftPanel.HasFocus() {
+				if d.handleSessionKey(event) {
+					d.Focus(setFocus)
+					return
+				}
 				if handler := d.leftPanel.InputHandler(); handler != nil {
 					handler(event, setFocus)
 				}
@@ -475,6 +1061,13 @@ func (d *Database) InputHandler() func(event *tcell.EventKey, setFocus func(p tv
 			}
 		case focusResult:
 			if d.resultTable.HasFocus() {
+				if d.handleResultKey(event) {
+					return
+				}
+				if d.handleEditKey(event) || d.handleExportKey(event) {
+					d.Focus(setFocus)
+					return
+				}
 				if handler := d.resultTable.InputHandler(); handler != nil {
 					handler(event, setFocus)
 				}
@@ -485,44 +1078,107 @@ func (d *Database) InputHandler() func(event *tcell.EventKey, setFocus func(p tv
 
 // disconnect disconnects from database
 func (d *Database) disconnect() {
+	if d.confirmEndTransaction(d.disconnect) {
+		return
+	}
+
 	d.mu.Lock()
 	defer d.mu.Unlock()
 
+	d.abortQuery()
+	d.closeTabs()
+	d.inspector.Hide()
+	d.planViewer.Hide()
+	d.stopTransactionAge()
+
 	if d.driver != nil {
 		d.driver.Close()
 		d.driver = nil
 	}
+	d.closeTunnel()
 
 	d.connected = false
+	d.currentSession = ""
 	d.currentDatabase = ""
+	d.timeout = 0
 
-	rootNode := tview.NewTreeNode("Not Connected")
-	rootNode.SetColor(style.StatusNotInstalledColor)
-	d.leftPanel.SetRoot(rootNode)
-	d.leftPanel.SetCurrentNode(rootNode)
+	d.buildSessionTree()
 
 	d.updateStatusBar("Disconnected. Press Ctrl+N to connect")
 }
 
 // Draw draws this primitive onto the screen
 func (d *Database) Draw(screen tcell.Screen) {
+	d.screen = screen
 	d.Box.DrawForSubclass(screen, d)
 	x, y, width, height := d.GetInnerRect()
 
 	d.mainFlex.SetRect(x, y, width, height)
 	d.mainFlex.Draw(screen)
+	d.sqlEditor.DrawCompletions(screen)
 
-	// Draw dialogs
-	if d.errorDialog.IsDisplay() {
-		d.errorDialog.SetRect(x, y, width, height)
-		d.errorDialog.Draw(screen)
+	// The table inspector, the plan viewer, the query history and the
+	// snippets cover the editor and the results
+	if d.inspector.IsDisplay() {
+		d.inspector.SetRect(d.rightPanel.GetRect())
+		d.inspector.Draw(screen)
 	}
-	if d.messageDialog.IsDisplay() {
-		d.messageDialog.SetRect(x, y, width, height)
-		d.messageDialog.Draw(screen)
+	if d.planViewer.IsDisplay() {
+		d.planViewer.SetRect(d.rightPanel.GetRect())
+		d.planViewer.Draw(screen)
+	}
+	if d.history.IsDisplay() {
+		d.history.SetRect(d.rightPanel.GetRect())
+		d.history.Draw(screen)
 	}
+	if d.snippets.IsDisplay() {
+		d.snippets.SetRect(d.rightPanel.GetRect())
+		d.snippets.Draw(screen)
+	}
+
+	// Draw dialogs, error dialog last so it stays on top
 	if d.connDialog.IsDisplay() {
 		d.connDialog.SetRect(x, y, width, height)
 		d.connDialog.Draw(screen)
 	}
+	if d.exportDialog.IsDisplay() {
+		d.exportDialog.SetRect(x, y, width, height)
+		d.exportDialog.Draw(screen)
+	}
+	if d.compareDialog.IsDisplay() {
+		d.compareDialog.SetRect(x, y, width, height)
+		d.compareDialog.Draw(screen)
+	}
+	if d.schemaCompareDialog.IsDisplay() {
+		d.schemaCompareDialog.SetRect(x, y, width, height)
+		d.schemaCompareDialog.Draw(screen)
+	}
+	if d.importDialog.IsDisplay() {
+		d.importDialog.SetRect(x, y, width, height)
+		d.importDialog.Draw(screen)
+	}
+	if d.progressDialog.IsDisplay() {
+		d.progressDialog.SetRect(x, y, width, height)
+		d.progressDialog.Draw(screen)
+	}
+	if d.inputDialog.IsDisplay() {
+		d.inputDialog.SetRect(x, y, width, height)
+		d.inputDialog.Draw(screen)
+	}
+	if d.confirmDialog.IsDisplay() {
+		d.confirmDialog.SetRect(x, y, width, height)
+		d.confirmDialog.Draw(screen)
+	}
+	if d.textDialog.IsDisplay() {
+		d.textDialog.SetRect(x, y, width, height)
+		d.textDialog.Draw(screen)
+	}
+	if d.messageDialog.IsDisplay() {
+		d.messageDialog.SetRect(x, y, width, height)
+		d.messageDialog.Draw(screen)
+	}
+	if d.errorDialog.IsDisplay() {
+		d.errorDialog.SetRect(x, y, width, height)
+		d.errorDialog.Draw(screen)
+	}
 }
@@ -0,0 +1,299 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/rivo/tview"
+	"github.com/shangyanjin/gocmder/internal/db"
+)
+
+// dumpProgressInterval limits how often the progress dialog is updated
+const dumpProgressInterval = 200 * time.Millisecond
+
+// selectedDatabase returns the database of the database or schema selected
+// in the tree
+func (d *Database) selectedDatabase() string {
+	node := d.leftPanel.GetCurrentNode()
+	if node == nil {
+		return ""
+	}
+	data, ok := node.GetReference().(map[string]string)
+	if !ok {
+		return ""
+	}
+
+	switch data["type"] {
+	case "database":
+		return data["name"]
+	case "schema":
+		return data["database"]
+	}
+	return ""
+}
+
+// showDump asks for the file the selected database is dumped to
+func (d *Database) showDump() {
+	database := d.selectedDatabase()
+	if database == "" {
+		d.updateStatusBar("Select a database to dump")
+		return
+	}
+
+	d.prompt("Dump database "+database, "File: ", database+".sql", func(value string) {
+		if value == "" {
+			return
+		}
+		file := expandHome(value)
+		if _, err := os.Stat(file); err == nil {
+			d.confirm("Overwrite file", fmt.Sprintf("%s already exists. Overwrite it?", file), func() {
+				d.startDump(database, file)
+			})
+			return
+		}
+		d.startDump(database, file)
+	})
+}
+
+// startDump writes the schema and rows of database to file in the
+// background with a progress dialog
+func (d *Database) startDump(database, file string) {
+	if !d.canExecute() {
+		return
+	}
+	d.useDatabase(database, func() {
+		d.runDump(database, file)
+	})
+}
+
+// runDump writes the dump of the connected database
+func (d *Database) runDump(database, file string) {
+	output, err := os.Create(file)
+	if err != nil {
+		d.showError(fmt.Sprintf("Dump failed: %v", err))
+		return
+	}
+
+	// The dump uses the connection like a query, there is no statement
+	// timeout as large databases take a while
+	ctx, cancel := context.WithCancel(context.Background())
+	run := &runningQuery{
+		ctx:     ctx,
+		cancel:  cancel,
+		started: time.Now(),
+		done:    make(chan struct{}),
+		total:   1,
+	}
+	d.running = run
+
+	d.progressDialog.SetTitle("Dump database")
+	d.progressDialog.SetText(fmt.Sprintf("Reading the schema of %s...\n\nESC to cancel", tview.Escape(database)))
+	d.progressDialog.SetProgress(0, 0)
+	d.progressDialog.SetCancelFunc(func() {
+		run.canceled = true
+		cancel()
+		d.progressDialog.SetText("Cancelling dump...")
+	})
+	d.progressDialog.Display()
+
+	driver := d.driver
+	go func() {
+		var reported time.Time
+		state, err := db.Dump(ctx, driver, database, output, func(state db.DumpProgress) {
+			if time.Since(reported) < dumpProgressInterval {
+				return
+			}
+			reported = time.Now()
+			d.queueUpdateDraw(func() {
+				if !d.progressDialog.IsDisplay() || run.canceled {
+					return
+				}
+				d.progressDialog.SetText(fmt.Sprintf("Dumping %s to %s...\nTable %s, %d rows written\nESC to cancel",
+					tview.Escape(database), tview.Escape(file), tview.Escape(state.Table), state.Rows))
+				d.progressDialog.SetProgress(state.Tables, state.Total)
+			})
+		})
+		if closeErr := output.Close(); err == nil {
+			err = closeErr
+		}
+		canceled := ctx.Err() != nil
+		cancel()
+		if err != nil || canceled {
+			// Do not leave a partial dump behind
+			os.Remove(file)
+		}
+
+		d.queueUpdateDraw(func() {
+			d.finishDump(run, database, file, state, canceled, err)
+		})
+	}()
+}
+
+// finishDump reports the result of a dump
+func (d *Database) finishDump(run *runningQuery, database, file string, state db.DumpProgress, canceled bool, err error) {
+	close(run.done)
+	if d.running == run {
+		d.running = nil
+	}
+	// Give the focus back unless the user left the page meanwhile
+	focused := d.HasFocus()
+	d.progressDialog.Hide()
+
+	elapsed := time.Since(run.started).Round(time.Millisecond)
+	switch {
+	case canceled:
+		d.updateStatusBar(fmt.Sprintf("Dump cancelled after %s, %s was removed", elapsed, tview.Escape(file)))
+	case err != nil:
+		d.showError(fmt.Sprintf("Dump of %s failed: %v", database, err))
+	default:
+		d.updateStatusBar(fmt.Sprintf("Dumped %d tables, %d rows of %s to %s in %s",
+			state.Tables, state.Rows, tview.Escape(database), tview.Escape(file), elapsed))
+	}
+
+	if focused && d.appFocusHandler != nil {
+		d.appFocusHandler()
+	}
+}
+
+// showRestore asks for the SQL file run against the selected database
+func (d *Database) showRestore() {
+	database := d.selectedDatabase()
+	if database == "" {
+		d.updateStatusBar("Select a database to restore into")
+		return
+	}
+
+	d.prompt("Restore into "+database, "File: ", database+".sql", func(value string) {
+		if value == "" {
+			return
+		}
+		file := expandHome(value)
+		text, err := os.ReadFile(file)
+		if err != nil {
+			d.showError(fmt.Sprintf("Restore failed: %v", err))
+			return
+		}
+
+		statements := db.Split(d.driver.GetDialect(), string(text))
+		if len(statements) == 0 {
+			d.updateStatusBar(fmt.Sprintf("No statements in %s", tview.Escape(file)))
+			return
+		}
+		d.confirm("Restore database", fmt.Sprintf("Run %d statements from %s in database %s?", len(statements), file, database), func() {
+			d.startRestore(database, file, statements)
+		})
+	})
+}
+
+// startRestore runs the statements of a file one after another in the
+// background with a progress dialog, stopping at the first error
+func (d *Database) startRestore(database, file string, statements []db.Statement) {
+	if !d.canExecute() {
+		return
+	}
+	d.useDatabase(database, func() {
+		d.runRestore(database, file, statements)
+	})
+}
+
+// runRestore runs the statements of a restore in the connected database
+func (d *Database) runRestore(database, file string, statements []db.Statement) {
+	ctx, cancel := context.WithCancel(context.Background())
+	run := &runningQuery{
+		ctx:     ctx,
+		cancel:  cancel,
+		started: time.Now(),
+		done:    make(chan struct{}),
+		total:   1,
+	}
+	d.running = run
+
+	d.progressDialog.SetTitle("Restore database")
+	d.progressDialog.SetText(fmt.Sprintf("Running %s in %s...\n\nESC to cancel", tview.Escape(file), tview.Escape(database)))
+	d.progressDialog.SetProgress(0, len(statements))
+	d.progressDialog.SetCancelFunc(func() {
+		run.canceled = true
+		cancel()
+		d.progressDialog.SetText("Cancelling restore...")
+	})
+	d.progressDialog.Display()
+
+	driver := d.driver
+	go func() {
+		var reported time.Time
+		done := 0
+		err := driver.RunScript(ctx, database, statements, func(count int) {
+			done = count
+			if time.Since(reported) < dumpProgressInterval {
+				return
+			}
+			reported = time.Now()
+			d.queueUpdateDraw(func() {
+				if !d.progressDialog.IsDisplay() || run.canceled {
+					return
+				}
+				d.progressDialog.SetText(fmt.Sprintf("Running %s in %s...\n%d of %d statements\nESC to cancel",
+					tview.Escape(file), tview.Escape(database), count, len(statements)))
+				d.progressDialog.SetProgress(count, len(statements))
+			})
+		})
+		canceled := ctx.Err() != nil
+		cancel()
+
+		d.queueUpdateDraw(func() {
+			d.finishRestore(run, database, file, done, len(statements), canceled, err)
+		})
+	}()
+}
+
+// finishRestore reports the result of a restore and reloads the tree
+func (d *Database) finishRestore(run *runningQuery, database, file string, done, total int, canceled bool, err error) {
+	close(run.done)
+	if d.running == run {
+		d.running = nil
+	}
+	focused := d.HasFocus()
+	d.progressDialog.Hide()
+
+	// The restore may have added or removed tables
+	d.reloadDatabaseNode(database)
+
+	elapsed := time.Since(run.started).Round(time.Millisecond)
+	var scriptErr *db.ScriptError
+	switch {
+	case canceled:
+		d.updateStatusBar(fmt.Sprintf("Restore cancelled after %s, %d of %d statements were run", elapsed, done, total))
+	case errors.As(err, &scriptErr):
+		line, code := scriptErr.Code()
+		d.showError(fmt.Sprintf("Restore of %s stopped at line %d after %d of %d statements:\n\n%s\n\n%v",
+			file, line, scriptErr.Index, total, tview.Escape(code), scriptErr.Err))
+	case err != nil:
+		d.showError(fmt.Sprintf("Restore failed: %v", err))
+	default:
+		d.updateStatusBar(fmt.Sprintf("Ran %d statements from %s in %s in %s",
+			total, tview.Escape(file), tview.Escape(database), elapsed))
+	}
+
+	if focused && d.appFocusHandler != nil {
+		d.appFocusHandler()
+	}
+}
+
+// reloadDatabaseNode loads the objects under the tree node of database
+// again, keeping the selected node
+func (d *Database) reloadDatabaseNode(database string) {
+	sessionNode := d.activeSessionNode()
+	if sessionNode == nil {
+		return
+	}
+	for _, child := range sessionNode.GetChildren() {
+		data, ok := child.GetReference().(map[string]string)
+		if ok && data["type"] == "database" && data["name"] == database {
+			d.handleTreeSelection(child)
+			return
+		}
+	}
+}
@@ -0,0 +1,337 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/gdamore/tcell/v2"
+	"github.com/rivo/tview"
+	"github.com/shangyanjin/gocmder/internal/db"
+	"github.com/shangyanjin/gocmder/internal/ui/utils"
+)
+
+const (
+	// exportPageSize is the number of rows read and written at once
+	exportPageSize = 1000
+	// exportProgressInterval limits how often the progress dialog is updated
+	exportProgressInterval = 200 * time.Millisecond
+)
+
+// handleExportKey exports or copies the shown result, it returns true if the key was handled
+func (d *Database) handleExportKey(event *tcell.EventKey) bool {
+	if d.grid == nil {
+		return false
+	}
+
+	switch {
+	case event.Key() == tcell.KeyCtrlE:
+		d.showExport()
+	case event.Key() == tcell.KeyRune && event.Rune() == 'y':
+		d.showCopy()
+	default:
+		return false
+	}
+	return true
+}
+
+// exportTable returns the quoted source table of the shown result, the
+// default table of INSERT statements
+func (d *Database) exportTable() string {
+	if d.activeTab >= len(d.tabs) || d.driver == nil {
+		return ""
+	}
+
+	dialect := d.driver.GetDialect()
+	source, err := db.ParseSelectSource(dialect, d.tabs[d.activeTab].statement.Text)
+	if err != nil {
+		return ""
+	}
+	return db.QuoteTable(dialect, source.Qualifier, source.Table)
+}
+
+// showExport opens the export dialog for the shown result
+func (d *Database) showExport() {
+	grid := d.grid
+	complete := grid.cursor == nil || (grid.cursor.Done() && !grid.limited && grid.err == nil)
+	d.exportDialog.ShowExport(d.exportTable(), len(grid.rows), complete)
+}
+
+// showCopy opens the copy dialog for the shown result
+func (d *Database) showCopy() {
+	if len(d.grid.rows) == 0 {
+		d.updateStatusBar("No rows to copy")
+		return
+	}
+	d.exportDialog.ShowCopy(d.exportTable())
+}
+
+// handleExport runs an export or copy chosen in the export dialog
+func (d *Database) handleExport(request exportRequest) {
+	if d.grid == nil {
+		return
+	}
+	if d.driver != nil {
+		request.options.Dialect = d.driver.GetDialect()
+	}
+
+	if request.copy {
+		d.copyResult(request)
+		return
+	}
+
+	file := expandHome(request.file)
+	if _, err := os.Stat(file); err == nil {
+		d.confirm("Overwrite file", fmt.Sprintf("%s already exists. Overwrite it?", file), func() {
+			d.startExport(request, file)
+		})
+		return
+	}
+	d.startExport(request, file)
+}
+
+// expandHome replaces a leading ~ with the home directory
+func expandHome(path string) string {
+	rest, ok := strings.CutPrefix(path, "~")
+	if !ok || (rest != "" && rest[0] != '/' && rest[0] != filepath.Separator) {
+		return path
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return path
+	}
+	return filepath.Join(home, rest)
+}
+
+// startExport writes the fetched rows, or all rows of the statement run
+// again, to file in the background with a progress dialog
+func (d *Database) startExport(request exportRequest, file string) {
+	grid := d.grid
+	if grid == nil || d.activeTab >= len(d.tabs) {
+		return
+	}
+	statement := d.tabs[d.activeTab].statement
+
+	// Running the statement again uses the connection like a query, there
+	// is no statement timeout as long exports are expected
+	var run *runningQuery
+	ctx, cancel := context.WithCancel(context.Background())
+	if request.rerun {
+		if !d.canExecute() {
+			cancel()
+			return
+		}
+		run = &runningQuery{
+			ctx:     ctx,
+			cancel:  cancel,
+			started: time.Now(),
+			done:    make(chan struct{}),
+			total:   1,
+		}
+		d.running = run
+	}
+
+	output, err := os.Create(file)
+	if err != nil {
+		cancel()
+		d.running = nil
+		d.showError(fmt.Sprintf("Export failed: %v", err))
+		return
+	}
+
+	source := "fetched rows"
+	if request.rerun {
+		source = "all rows"
+	}
+	d.progressDialog.SetTitle("Export " + request.options.Format.String())
+	d.progressDialog.SetText(fmt.Sprintf("Writing %s to %s...\n\nESC to cancel", source, tview.Escape(file)))
+	if !request.rerun {
+		d.progressDialog.SetProgress(0, len(grid.rows))
+	}
+	d.progressDialog.SetCancelFunc(func() {
+		if run != nil {
+			run.canceled = true
+		}
+		cancel()
+		d.progressDialog.SetText("Cancelling export...")
+	})
+	d.progressDialog.Display()
+
+	started := time.Now()
+	rows := grid.rows
+	driver := d.driver
+	go func() {
+		written, err := d.writeExport(ctx, driver, statement, rows, grid.columns, request, output)
+		if closeErr := output.Close(); err == nil {
+			err = closeErr
+		}
+		if err == nil && ctx.Err() != nil {
+			err = ctx.Err()
+		}
+		canceled := ctx.Err() != nil
+		cancel()
+		if err != nil {
+			// Do not leave a partial export behind
+			os.Remove(file)
+		}
+
+		d.queueUpdateDraw(func() {
+			d.finishExport(run, file, written, time.Since(started), canceled, err)
+		})
+	}()
+}
+
+// writeExport writes the rows of an export, re-running the statement if requested
+func (d *Database) writeExport(ctx context.Context, driver db.Driver, statement db.Statement, rows [][]db.Value, columns []db.Column, request exportRequest, output *os.File) (int64, error) {
+	var cursor *db.Cursor
+	if request.rerun {
+		result, err := db.OpenStatement(ctx, driver, statement)
+		if err != nil {
+			return 0, err
+		}
+		if result.Cursor == nil {
+			return 0, fmt.Errorf("the statement no longer returns rows")
+		}
+		cursor = result.Cursor
+		defer cursor.Close()
+		columns = cursor.Columns()
+	}
+
+	exporter, err := db.NewExporter(output, columns, request.options)
+	if err != nil {
+		return 0, err
+	}
+
+	var reported time.Time
+	for offset := 0; ctx.Err() == nil; {
+		var page [][]db.Value
+		if cursor != nil {
+			if page, err = cursor.Fetch(exportPageSize); err != nil {
+				return exporter.Rows(), err
+			}
+		} else {
+			end := min(offset+exportPageSize, len(rows))
+			page, offset = rows[offset:end], end
+		}
+		if len(page) == 0 {
+			break
+		}
+		if err := exporter.Write(page); err != nil {
+			return exporter.Rows(), err
+		}
+
+		if time.Since(reported) >= exportProgressInterval {
+			reported = time.Now()
+			written := exporter.Rows()
+			d.queueUpdateDraw(func() {
+				if !d.progressDialog.IsDisplay() {
+					return
+				}
+				if cursor != nil {
+					d.progressDialog.SetText(fmt.Sprintf("Writing all rows to %s...\n%d rows written\nESC to cancel", tview.Escape(output.Name()), written))
+					d.progressDialog.SetProgress(0, 0)
+					return
+				}
+				d.progressDialog.SetProgress(int(written), len(rows))
+			})
+		}
+	}
+
+	return exporter.Rows(), exporter.Close()
+}
+
+// finishExport reports the result of an export
+func (d *Database) finishExport(run *runningQuery, file string, written int64, elapsed time.Duration, canceled bool, err error) {
+	if run != nil {
+		close(run.done)
+		if d.running == run {
+			d.running = nil
+		}
+	}
+	// Give the focus back unless the user left the page meanwhile
+	focused := d.HasFocus()
+	d.progressDialog.Hide()
+
+	elapsed = elapsed.Round(time.Millisecond)
+	switch {
+	case canceled:
+		d.updateStatusBar(fmt.Sprintf("Export cancelled after %s, %s was removed", elapsed, tview.Escape(file)))
+	case err != nil:
+		d.showError(fmt.Sprintf("Export failed: %v", err))
+	default:
+		d.updateStatusBar(fmt.Sprintf("Exported %d rows to %s in %s", written, tview.Escape(file), elapsed))
+	}
+
+	if focused && d.appFocusHandler != nil {
+		d.appFocusHandler()
+	}
+}
+
+// copyResult copies the selected cell, row or column or the fetched rows to the clipboard
+func (d *Database) copyResult(request exportRequest) {
+	grid := d.grid
+	row, column := d.resultTable.GetSelection()
+	index := row - 1
+	if index < 0 || index >= len(grid.rows) {
+		index = 0
+	}
+	column = min(max(column, 0), len(grid.columns)-1)
+
+	columns, rows := grid.columns, grid.rows
+	switch request.scope {
+	case copyCell:
+		columns, rows = columns[column:column+1], [][]db.Value{{rows[index][column]}}
+	case copyRow:
+		rows = rows[index : index+1]
+	case copyColumn:
+		columns = columns[column : column+1]
+		values := make([][]db.Value, len(rows))
+		for i, row := range rows {
+			values[i] = []db.Value{row[column]}
+		}
+		rows = values
+	}
+
+	text, err := copyText(columns, rows, request)
+	if err != nil {
+		d.showError(err.Error())
+		return
+	}
+
+	method, err := utils.WriteClipboard(d.screen, text)
+	if err != nil {
+		d.showError(fmt.Sprintf("Copy failed: %v", err))
+		return
+	}
+	copied := strings.ToLower(copyScopes[request.scope])
+	if request.scope == copyColumn || request.scope == copyRows {
+		copied += fmt.Sprintf(" (%d rows)", len(rows))
+	}
+	d.updateStatusBar(fmt.Sprintf("Copied %s to the clipboard (%s)", copied, method))
+}
+
+// copyText returns rows in the requested format. Plain text is a single
+// value for one cell and tab separated values otherwise.
+func copyText(columns []db.Column, rows [][]db.Value, request exportRequest) (string, error) {
+	if request.text {
+		if len(rows) == 1 && len(columns) == 1 {
+			if rows[0][0].Null {
+				return "", nil
+			}
+			return rows[0][0].String(), nil
+		}
+		request.options = db.ExportOptions{Format: db.ExportCSV, Delimiter: '\t'}
+	}
+
+	var text strings.Builder
+	exporter, err := db.NewExporter(&text, columns, request.options)
+	if err != nil {
+		return "", err
+	}
+	exporter.Write(rows)
+	exporter.Close()
+	return strings.TrimSuffix(text.String(), "\n"), nil
+}
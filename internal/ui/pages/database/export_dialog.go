@@ -0,0 +1,369 @@
+package database
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/gdamore/tcell/v2"
+	"github.com/rivo/tview"
+	"github.com/shangyanjin/gocmder/internal/db"
+	"github.com/shangyanjin/gocmder/internal/ui/style"
+	"github.com/shangyanjin/gocmder/internal/ui/utils"
+)
+
+const (
+	exportDialogWidth  = 72
+	exportDialogHeight = 20
+)
+
+// copyScope is the part of a result copied to the clipboard
+type copyScope int
+
+const (
+	copyCell copyScope = iota
+	copyRow
+	copyColumn
+	copyRows
+)
+
+// copyScopes are the labels of the copy scopes
+var copyScopes = []string{"Cell", "Row", "Column", "Fetched rows"}
+
+// exportRequest is an export or copy chosen in the export dialog
+type exportRequest struct {
+	options db.ExportOptions
+	copy    bool      // copy to the clipboard instead of writing a file
+	text    bool      // copy values as plain text instead of an export format
+	file    string    // file written by an export
+	rerun   bool      // run the statement again to export all of its rows
+	scope   copyScope // part of the result copied
+}
+
+// ExportDialog asks how result rows are exported to a file or copied to the clipboard
+type ExportDialog struct {
+	*tview.Box
+
+	layout          *tview.Flex
+	form            *tview.Form
+	display         bool
+	copy            bool // copy to the clipboard instead of writing a file
+	format          int  // selected format, copies offer plain text first
+	rerun           bool
+	scope           copyScope
+	file            string
+	delimiter       string
+	quoteAll        bool
+	header          bool
+	table           string
+	fetched         string // label of the fetched rows option
+	exportFunc      func(request exportRequest)
+	appFocusHandler func()
+}
+
+// NewExportDialog creates a new export dialog
+func NewExportDialog(exportFunc func(request exportRequest)) *ExportDialog {
+	bgColor := style.DialogBgColor
+
+	dialog := &ExportDialog{
+		Box:        tview.NewBox(),
+		exportFunc: exportFunc,
+		delimiter:  ",",
+		header:     true,
+	}
+
+	dialog.form = tview.NewForm()
+	dialog.form.SetBackgroundColor(bgColor)
+	dialog.form.SetButtonBackgroundColor(style.ButtonBgColor)
+	dialog.form.SetFieldBackgroundColor(style.BgColor)
+	dialog.form.SetLabelColor(style.FgColor)
+	dialog.form.SetFieldTextColor(style.FgColor)
+	dialog.form.SetButtonsAlign(tview.AlignCenter)
+
+	highlightColor := style.GetColorHex(style.StatusInstalledColor)
+	shortcutsHint := tview.NewTextView()
+	shortcutsHint.SetBackgroundColor(bgColor)
+	shortcutsHint.SetTextColor(style.FgColor)
+	shortcutsHint.SetDynamicColors(true)
+	shortcutsHint.SetText(" [" + highlightColor + "]Tab[-] Next field | [" + highlightColor + "]Enter[-] Select | [" + highlightColor + "]ESC[-] Cancel")
+
+	dialog.layout = tview.NewFlex().SetDirection(tview.FlexRow)
+	dialog.layout.AddItem(dialog.form, 0, 1, true)
+	dialog.layout.AddItem(shortcutsHint, 1, 0, false)
+	dialog.layout.SetBorder(true)
+	dialog.layout.SetTitleColor(style.FgColor)
+	dialog.layout.SetBorderColor(style.DialogBorderColor)
+	dialog.layout.SetBackgroundColor(bgColor)
+
+	return dialog
+}
+
+// ShowExport shows the dialog for an export to a file. fetched is the
+// number of rows fetched so far, complete tells if they are all rows.
+func (d *ExportDialog) ShowExport(table string, fetched int, complete bool) {
+	if d.copy {
+		d.copy = false
+		d.format = max(d.format-1, 0)
+	}
+	d.table = table
+	d.rerun = !complete
+	d.fetched = fmt.Sprintf("Fetched rows (%d)", fetched)
+	if d.file == "" {
+		d.file = "export" + d.exportFormat().Extension()
+	}
+
+	d.layout.SetTitle(" Export Result ")
+	d.build()
+	d.display = true
+}
+
+// ShowCopy shows the dialog for copying part of a result to the clipboard
+func (d *ExportDialog) ShowCopy(table string) {
+	if !d.copy {
+		d.copy = true
+		d.format++
+	}
+	d.table = table
+
+	d.layout.SetTitle(" Copy to Clipboard ")
+	d.build()
+	d.display = true
+}
+
+// formats returns the format labels offered by the dialog
+func (d *ExportDialog) formats() []string {
+	var labels []string
+	if d.copy {
+		labels = append(labels, "Text")
+	}
+	for _, format := range db.ExportFormats {
+		labels = append(labels, format.String())
+	}
+	return labels
+}
+
+// exportFormat returns the selected export format, CSV for plain text
+func (d *ExportDialog) exportFormat() db.ExportFormat {
+	index := d.format
+	if d.copy {
+		index--
+	}
+	if index < 0 {
+		return db.ExportCSV
+	}
+	return db.ExportFormats[index]
+}
+
+// build adds the form fields of the dialog mode
+func (d *ExportDialog) build() {
+	d.form.Clear(true)
+
+	d.form.AddDropDown("Format", d.formats(), d.format, func(_ string, index int) {
+		if index < 0 || index == d.format {
+			return
+		}
+		// A file name with the extension of the previous format follows the format
+		previous := d.exportFormat().Extension()
+		d.format = index
+		if !d.copy && strings.HasSuffix(d.file, previous) {
+			d.file = strings.TrimSuffix(d.file, previous) + d.exportFormat().Extension()
+			if item := d.form.GetFormItemByLabel("File"); item != nil {
+				item.(*tview.InputField).SetText(d.file)
+			}
+		}
+	})
+
+	if d.copy {
+		d.form.AddDropDown("Copy", copyScopes, int(d.scope), func(_ string, index int) {
+			if index >= 0 {
+				d.scope = copyScope(index)
+			}
+		})
+	} else {
+		rows := 0
+		if d.rerun {
+			rows = 1
+		}
+		d.form.AddDropDown("Rows", []string{d.fetched, "All rows (run query again)"}, rows, func(_ string, index int) {
+			d.rerun = index == 1
+		})
+		d.form.AddInputField("File", d.file, 50, nil, func(text string) {
+			d.file = text
+		})
+	}
+
+	d.form.AddInputField("CSV Delimiter", d.delimiter, 5, nil, func(text string) {
+		d.delimiter = text
+	})
+	d.form.AddCheckbox("CSV Quote All", d.quoteAll, func(checked bool) {
+		d.quoteAll = checked
+	})
+	d.form.AddCheckbox("CSV Header", d.header, func(checked bool) {
+		d.header = checked
+	})
+	d.form.AddInputField("INSERT Table", d.table, 40, nil, func(text string) {
+		d.table = text
+	})
+
+	label := "Export"
+	if d.copy {
+		label = "Copy"
+	}
+	d.form.AddButton(label, d.handleExport)
+	d.form.AddButton("Cancel", d.close)
+	d.form.SetFocus(0)
+}
+
+// parseDelimiter returns the CSV delimiter entered as a character or as "tab"
+func parseDelimiter(text string) (rune, error) {
+	switch strings.ToLower(text) {
+	case "", ",":
+		return ',', nil
+	case "tab", `\t`:
+		return '\t', nil
+	}
+	runes := []rune(text)
+	if len(runes) != 1 {
+		return 0, fmt.Errorf("the CSV delimiter is a single character or tab")
+	}
+	return runes[0], nil
+}
+
+// handleExport checks the form and hands the request to the export handler
+func (d *ExportDialog) handleExport() {
+	delimiter, err := parseDelimiter(d.delimiter)
+	if err != nil {
+		d.fail(err)
+		return
+	}
+
+	request := exportRequest{
+		options: db.ExportOptions{
+			Format:    d.exportFormat(),
+			Delimiter: delimiter,
+			QuoteAll:  d.quoteAll,
+			Header:    d.header,
+			Table:     d.table,
+		},
+		copy:  d.copy,
+		text:  d.copy && d.format == 0,
+		file:  strings.TrimSpace(d.file),
+		rerun: d.rerun && !d.copy,
+		scope: d.scope,
+	}
+	if !d.copy && request.file == "" {
+		d.fail(fmt.Errorf("enter the file to write"))
+		return
+	}
+
+	d.Hide()
+	if d.exportFunc != nil {
+		d.exportFunc(request)
+	}
+	if d.appFocusHandler != nil {
+		d.appFocusHandler()
+	}
+}
+
+// fail reports an invalid field in the dialog title
+func (d *ExportDialog) fail(err error) {
+	d.layout.SetTitle(" " + err.Error() + " ")
+	d.layout.SetTitleColor(style.StatusErrorColor)
+}
+
+// close hides the dialog and restores the page focus
+func (d *ExportDialog) close() {
+	d.Hide()
+	if d.appFocusHandler != nil {
+		d.appFocusHandler()
+	}
+}
+
+// Display displays this primitive
+func (d *ExportDialog) Display() {
+	d.display = true
+}
+
+// IsDisplay returns true if primitive is shown
+func (d *ExportDialog) IsDisplay() bool {
+	return d.display
+}
+
+// Hide stops displaying this primitive
+func (d *ExportDialog) Hide() {
+	d.display = false
+	d.layout.SetTitleColor(style.FgColor)
+}
+
+// HasFocus returns whether or not this primitive has focus
+func (d *ExportDialog) HasFocus() bool {
+	return d.display && (d.form.HasFocus() || d.Box.HasFocus())
+}
+
+// Focus is called when this primitive receives focus
+func (d *ExportDialog) Focus(delegate func(p tview.Primitive)) {
+	delegate(d.form)
+}
+
+// SetAppFocusHandler sets the app focus handler
+func (d *ExportDialog) SetAppFocusHandler(handler func()) {
+	d.appFocusHandler = handler
+}
+
+// InputHandler returns input handler function for this primitive
+func (d *ExportDialog) InputHandler() func(event *tcell.EventKey, setFocus func(p tview.Primitive)) {
+	return d.WrapInputHandler(func(event *tcell.EventKey, setFocus func(p tview.Primitive)) {
+		// ESC closes an open drop-down list before the dialog
+		if event.Key() == utils.CloseDialogKey.Key && !d.dropDownOpen() {
+			d.close()
+			return
+		}
+
+		if handler := d.form.InputHandler(); handler != nil {
+			handler(event, setFocus)
+		}
+	})
+}
+
+// dropDownOpen returns true if the list of a drop-down field is shown
+func (d *ExportDialog) dropDownOpen() bool {
+	for i := 0; i < d.form.GetFormItemCount(); i++ {
+		if dropDown, ok := d.form.GetFormItem(i).(*tview.DropDown); ok && dropDown.IsOpen() {
+			return true
+		}
+	}
+	return false
+}
+
+// SetRect sets rects for this primitive
+func (d *ExportDialog) SetRect(x, y, width, height int) {
+	ws := (width - exportDialogWidth) / 2
+	hs := (height - exportDialogHeight) / 2
+	dy := y + hs
+	bWidth := exportDialogWidth
+	bHeight := exportDialogHeight
+
+	if exportDialogWidth > width {
+		ws = 0
+		bWidth = width - 1
+	}
+
+	if exportDialogHeight >= height {
+		dy = y + 1
+		bHeight = height - 1
+	}
+
+	d.Box.SetRect(x+ws, dy, bWidth, bHeight)
+
+	x, y, width, height = d.GetInnerRect()
+	d.layout.SetRect(x, y, width, height)
+}
+
+// Draw draws this primitive onto the screen
+func (d *ExportDialog) Draw(screen tcell.Screen) {
+	if !d.display {
+		return
+	}
+
+	d.DrawForSubclass(screen, d)
+	d.layout.Draw(screen)
+}
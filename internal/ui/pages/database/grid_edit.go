@@ -0,0 +1,526 @@
+package database
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/gdamore/tcell/v2"
+	"github.com/rivo/tview"
+	"github.com/shangyanjin/gocmder/internal/db"
+	"github.com/shangyanjin/gocmder/internal/ui/style"
+)
+
+// gridEdits are the pending changes of a result read from a single table
+type gridEdits struct {
+	target  *db.EditTarget
+	updates map[int]map[int]db.Value // changed values by row and column
+	deletes map[int]bool             // rows marked for deletion
+	inserts []map[int]db.Value       // values set in new rows
+}
+
+// newGridEdits returns an empty change set for target
+func newGridEdits(target *db.EditTarget) *gridEdits {
+	return &gridEdits{
+		target:  target,
+		updates: map[int]map[int]db.Value{},
+		deletes: map[int]bool{},
+	}
+}
+
+// pending returns the number of changed rows
+func (e *gridEdits) pending() int {
+	if e == nil {
+		return 0
+	}
+	return len(e.updates) + len(e.deletes) + len(e.inserts)
+}
+
+// set changes the value of a cell, a fetched row that gets its original
+// value back is no longer changed
+func (e *gridEdits) set(rows [][]db.Value, index, column int, value db.Value) {
+	if index >= len(rows) {
+		e.inserts[index-len(rows)][column] = value
+		return
+	}
+
+	if sameValue(rows[index][column], value) {
+		delete(e.updates[index], column)
+		if len(e.updates[index]) == 0 {
+			delete(e.updates, index)
+		}
+		return
+	}
+	if e.updates[index] == nil {
+		e.updates[index] = map[int]db.Value{}
+	}
+	e.updates[index][column] = value
+}
+
+// revert drops the changes of a row, inserted rows are removed
+func (e *gridEdits) revert(rows [][]db.Value, index int) {
+	if index >= len(rows) {
+		insert := index - len(rows)
+		e.inserts = append(e.inserts[:insert], e.inserts[insert+1:]...)
+		return
+	}
+	delete(e.updates, index)
+	delete(e.deletes, index)
+}
+
+// changes returns the pending changes in the order they are applied:
+// deletions first so that inserted rows may reuse their keys
+func (e *gridEdits) changes(rows [][]db.Value) []db.RowChange {
+	var changes []db.RowChange
+	for _, index := range sortedRows(e.deletes) {
+		changes = append(changes, db.RowChange{Kind: db.ChangeDelete, Row: rows[index]})
+	}
+	for _, index := range sortedRows(e.updates) {
+		changes = append(changes, db.RowChange{Kind: db.ChangeUpdate, Row: rows[index], Values: e.updates[index]})
+	}
+	for _, values := range e.inserts {
+		changes = append(changes, db.RowChange{Kind: db.ChangeInsert, Values: values})
+	}
+	return changes
+}
+
+// sortedRows returns the row indexes of a map in ascending order
+func sortedRows[V any](rows map[int]V) []int {
+	indexes := make([]int, 0, len(rows))
+	for index := range rows {
+		indexes = append(indexes, index)
+	}
+	sort.Ints(indexes)
+	return indexes
+}
+
+// rowCell returns the cell of a fetched row with its pending change
+func (e *gridEdits) rowCell(column db.Column, original db.Value, index, col int) *tview.TableCell {
+	if e.deletes[index] {
+		cell := valueCell(column, original)
+		cell.SetTextColor(style.StatusErrorColor)
+		cell.SetAttributes(tcell.AttrStrikeThrough)
+		return cell
+	}
+	if value, ok := e.updates[index][col]; ok {
+		cell := valueCell(column, value)
+		cell.SetTextColor(style.StatusNotInstalledColor)
+		return cell
+	}
+	return valueCell(column, original)
+}
+
+// insertedCell returns the cell of an inserted row, unset columns get their default
+func (e *gridEdits) insertedCell(column db.Column, insert, col int) *tview.TableCell {
+	value, ok := e.inserts[insert][col]
+	if !ok {
+		cell := tview.NewTableCell("DEFAULT")
+		cell.SetTextColor(style.BorderColor)
+		cell.SetAttributes(tcell.AttrItalic)
+		return cell
+	}
+
+	cell := valueCell(column, value)
+	cell.SetTextColor(style.StatusInstalledColor)
+	return cell
+}
+
+// sameValue returns true if two values are equal
+func sameValue(a, b db.Value) bool {
+	return a.Null == b.Null && a.Kind == b.Kind && a.Text == b.Text && bytes.Equal(a.Bytes, b.Bytes)
+}
+
+// handleEditKey edits the result grid, it returns true if the key was handled
+func (d *Database) handleEditKey(event *tcell.EventKey) bool {
+	if d.grid == nil || d.running != nil {
+		return false
+	}
+
+	switch {
+	case event.Key() == tcell.KeyCtrlS:
+		d.previewChanges()
+	case event.Key() == tcell.KeyDelete:
+		d.toggleDelete()
+	case event.Key() != tcell.KeyRune:
+		return false
+	case event.Rune() == 'e':
+		d.editCell()
+	case event.Rune() == 'n':
+		d.setCell(db.Value{Null: true})
+	case event.Rune() == 'i':
+		d.insertRow()
+	case event.Rune() == 'x':
+		d.toggleDelete()
+	case event.Rune() == 'u':
+		d.revertRow()
+	case event.Rune() == 'U':
+		d.discardChanges()
+	default:
+		return false
+	}
+	return true
+}
+
+// gridEditor returns the pending changes of the shown result. The first
+// call checks in the background that the result maps to a table with a
+// primary key and returns nil, retry runs again once the check is done.
+func (d *Database) gridEditor(retry func()) *gridEdits {
+	grid := d.grid
+	if grid == nil || d.activeTab >= len(d.tabs) || d.tabs[d.activeTab].grid != grid {
+		return nil
+	}
+	if grid.edits != nil {
+		return grid.edits
+	}
+	if grid.readOnly != "" {
+		d.updateStatusBar("Result is read-only: " + tview.Escape(grid.readOnly))
+		return nil
+	}
+
+	d.loadEditTarget(d.tabs[d.activeTab].statement.Text, grid, func(target *db.EditTarget, err error) {
+		if err != nil {
+			grid.readOnly = err.Error()
+		} else {
+			grid.edits = newGridEdits(target)
+		}
+		if d.grid == grid {
+			retry()
+		}
+	})
+	return nil
+}
+
+// loadEditTarget maps the columns of a result grid to the table the
+// statement reads in the background and calls done on the UI goroutine. It
+// is refused while rows are fetched, and in a transaction while the rows of
+// the grid are still read from it, as the lookup would close their cursor.
+func (d *Database) loadEditTarget(query string, grid *resultGrid, done func(target *db.EditTarget, err error)) {
+	if !d.canExecute() {
+		return
+	}
+	if d.fetchingRows() {
+		d.updateStatusBar("Rows are still being fetched. Wait for them before editing or comparing the result")
+		return
+	}
+	if _, ok := d.transaction(); ok && grid.hasMore() {
+		d.updateStatusBar("The result is still read in the transaction. Scroll to its end first")
+		return
+	}
+
+	dialect := d.driver.GetDialect()
+	source, err := db.ParseSelectSource(dialect, query)
+	if err != nil {
+		done(nil, err)
+		return
+	}
+
+	driver := d.driver
+	database, schema := source.Qualifier, ""
+	if dialect == db.DialectPostgres {
+		database, schema = d.currentDatabase, source.Qualifier
+	}
+
+	var info *db.TableInfo
+	ctx, cancel := d.statementContext()
+	d.runTask(ctx, cancel, fmt.Sprintf("Reading the structure of %s...", tview.Escape(source.Table)), func(ctx context.Context) error {
+		// Unqualified MySQL tables are in the default database of the connection
+		if dialect == db.DialectMySQL && database == "" {
+			result, err := driver.ExecuteQuery(ctx, "SELECT DATABASE()")
+			if err != nil {
+				return err
+			}
+			if len(result.Rows) == 0 || result.Rows[0][0].Null {
+				return fmt.Errorf("no database selected, qualify the table with its database")
+			}
+			database = result.Rows[0][0].Text
+		}
+
+		var err error
+		info, err = driver.DescribeTable(ctx, database, schema, source.Table)
+		return err
+	}, func(err error) {
+		if err != nil {
+			done(nil, err)
+			return
+		}
+		done(db.NewEditTarget(dialect, source, info, grid.columns))
+	})
+}
+
+// editableCell returns the changes and the selected cell if it can be
+// edited, retry runs again once the result is checked
+func (d *Database) editableCell(retry func()) (*gridEdits, int, int, bool) {
+	edits := d.gridEditor(retry)
+	if edits == nil {
+		return nil, 0, 0, false
+	}
+
+	row, column := d.resultTable.GetSelection()
+	index := row - 1
+	if index < 0 || index >= len(d.grid.rows)+len(edits.inserts) {
+		return nil, 0, 0, false
+	}
+	if !edits.target.Editable(column) {
+		d.updateStatusBar(fmt.Sprintf("Column %s is not a column of %s", tview.Escape(d.grid.columns[column].Name), tview.Escape(edits.target.Table)))
+		return nil, 0, 0, false
+	}
+	if edits.deletes[index] {
+		d.updateStatusBar("Row is marked for deletion, press u to keep it")
+		return nil, 0, 0, false
+	}
+	return edits, index, column, true
+}
+
+// editCell asks for the new value of the selected cell
+func (d *Database) editCell() {
+	edits, index, column, ok := d.editableCell(d.editCell)
+	if !ok {
+		return
+	}
+
+	col := d.grid.columns[column]
+	current, _ := d.grid.value(index, column)
+	text := current.Text
+	if current.Kind == db.ValueBinary || col.IsBinary() {
+		text = "0x" + hex.EncodeToString(current.Bytes)
+	}
+	if current.Null {
+		text = ""
+	}
+
+	grid := d.grid
+	d.prompt(fmt.Sprintf("Edit %s", col.Name), "Value: ", text, func(text string) {
+		value, err := parseCellValue(col, text)
+		if err != nil {
+			d.showError(err.Error())
+			return
+		}
+		edits.set(grid.rows, index, column, value)
+		d.updateGridTitle()
+	})
+}
+
+// parseCellValue converts the text entered for a cell, binary columns take hex digits after 0x
+func parseCellValue(column db.Column, text string) (db.Value, error) {
+	switch {
+	case column.IsBinary():
+		digits, ok := strings.CutPrefix(text, "0x")
+		data, err := hex.DecodeString(digits)
+		if !ok || err != nil {
+			return db.Value{}, fmt.Errorf("binary values are entered as hex digits after 0x")
+		}
+		return db.Value{Kind: db.ValueBinary, Bytes: data}, nil
+	case column.IsNumeric():
+		return db.Value{Kind: db.ValueNumber, Text: text}, nil
+	}
+	return db.Value{Kind: db.ValueText, Text: text}, nil
+}
+
+// setCell sets the selected cell to value
+func (d *Database) setCell(value db.Value) {
+	edits, index, column, ok := d.editableCell(func() { d.setCell(value) })
+	if !ok {
+		return
+	}
+
+	edits.set(d.grid.rows, index, column, value)
+	d.updateGridTitle()
+}
+
+// insertRow adds an empty row after the fetched rows and selects it
+func (d *Database) insertRow() {
+	edits := d.gridEditor(d.insertRow)
+	if edits == nil {
+		return
+	}
+
+	edits.inserts = append(edits.inserts, map[int]db.Value{})
+	_, column := d.resultTable.GetSelection()
+	d.resultTable.Select(len(d.grid.rows)+len(edits.inserts), column)
+	d.updateGridTitle()
+	d.updateStatusBar("Row added, press e to set its values. Unset columns get their default")
+}
+
+// toggleDelete marks or unmarks the selected row for deletion, inserted rows are removed
+func (d *Database) toggleDelete() {
+	edits := d.gridEditor(d.toggleDelete)
+	if edits == nil {
+		return
+	}
+
+	row, _ := d.resultTable.GetSelection()
+	index := row - 1
+	switch {
+	case index < 0 || index >= len(d.grid.rows)+len(edits.inserts):
+		return
+	case index >= len(d.grid.rows):
+		edits.revert(d.grid.rows, index)
+	case edits.deletes[index]:
+		delete(edits.deletes, index)
+	default:
+		delete(edits.updates, index)
+		edits.deletes[index] = true
+	}
+	d.updateGridTitle()
+}
+
+// revertRow drops the pending changes of the selected row
+func (d *Database) revertRow() {
+	edits := d.gridEditor(d.revertRow)
+	if edits == nil {
+		return
+	}
+
+	row, _ := d.resultTable.GetSelection()
+	if index := row - 1; index >= 0 && index < len(d.grid.rows)+len(edits.inserts) {
+		edits.revert(d.grid.rows, index)
+	}
+	d.updateGridTitle()
+}
+
+// discardChanges drops all pending changes of the shown result
+func (d *Database) discardChanges() {
+	if d.grid == nil || d.grid.edits.pending() == 0 {
+		return
+	}
+
+	d.grid.edits = newGridEdits(d.grid.edits.target)
+	d.updateGridTitle()
+	d.updateStatusBar("Pending changes discarded")
+}
+
+// confirmDiscard asks before results with pending changes are replaced. It
+// returns true if the user is asked, retry runs once changes are discarded.
+func (d *Database) confirmDiscard(retry func()) bool {
+	pending := 0
+	for _, tab := range d.tabs {
+		if tab.grid != nil {
+			pending += tab.grid.edits.pending()
+		}
+	}
+	if pending == 0 {
+		return false
+	}
+
+	d.confirm("Discard changes", fmt.Sprintf("%d pending change(s) have not been applied. Discard them?", pending), func() {
+		for _, tab := range d.tabs {
+			if tab.grid != nil {
+				tab.grid.edits = nil
+			}
+		}
+		retry()
+	})
+	return true
+}
+
+// previewChanges shows the statements of the pending changes, Ctrl+S applies them
+func (d *Database) previewChanges() {
+	edits := d.gridEditor(d.previewChanges)
+	if edits == nil {
+		return
+	}
+	if edits.pending() == 0 {
+		d.updateStatusBar("No pending changes. Use e, n, i and x to change rows")
+		return
+	}
+
+	// The preview writes the values as literals, the statements bind them
+	var statements []db.BoundStatement
+	var preview []string
+	for _, change := range edits.changes(d.grid.rows) {
+		statements = append(statements, edits.target.Statement(change))
+		preview = append(preview, edits.target.Preview(change))
+	}
+
+	grid := d.grid
+	statement := d.tabs[d.activeTab].statement
+	d.textDialog.SetTitle(fmt.Sprintf("Apply %d change(s) in one transaction", len(statements)))
+	d.textDialog.SetText(strings.Join(preview, ";\n") + ";")
+	d.textDialog.SetAcceptFunc(func() {
+		d.textDialog.Hide()
+		d.applyChanges(grid, statement, statements)
+		if d.appFocusHandler != nil {
+			d.appFocusHandler()
+		}
+	})
+	d.textDialog.Display()
+	if d.appFocusHandler != nil {
+		d.appFocusHandler()
+	}
+}
+
+// applyChanges runs the statements of the pending changes in one
+// transaction in the background and reloads the result when they succeed
+func (d *Database) applyChanges(grid *resultGrid, statement db.Statement, statements []db.BoundStatement) {
+	if !d.canExecute() {
+		return
+	}
+
+	ctx, cancel := d.statementContext()
+	run := &runningQuery{
+		ctx:     ctx,
+		cancel:  cancel,
+		started: time.Now(),
+		done:    make(chan struct{}),
+		total:   1,
+	}
+	d.running = run
+	d.updateStatusBar(fmt.Sprintf("Applying %d change(s)... (Esc/Ctrl+C to cancel)", len(statements)))
+
+	driver := d.driver
+	go func() {
+		// UPDATE and DELETE statements select rows by primary key
+		unmatched := 0
+		err := driver.ExecTransaction(ctx, statements, func(i int, affected int64) error {
+			if affected > 1 {
+				return fmt.Errorf("statement %d changed %d rows instead of one", i+1, affected)
+			}
+			if affected == 0 {
+				unmatched++
+			}
+			return nil
+		})
+		cancel()
+
+		d.queueUpdateDraw(func() {
+			d.finishApply(run, grid, statement, len(statements), unmatched, err)
+		})
+	}()
+	go d.showElapsed(run)
+}
+
+// finishApply reports the result of applying changes
+func (d *Database) finishApply(run *runningQuery, grid *resultGrid, statement db.Statement, count, unmatched int, err error) {
+	close(run.done)
+	if d.running != run {
+		return
+	}
+	d.running = nil
+
+	elapsed := time.Since(run.started).Round(time.Millisecond)
+	if err != nil {
+		if run.canceled {
+			d.updateStatusBar(fmt.Sprintf("Applying changes cancelled after %s, nothing was changed", elapsed))
+			return
+		}
+		d.showError(fmt.Sprintf("Changes rolled back: %v", err))
+		if d.errorDialog.IsDisplay() && d.HasFocus() && d.appFocusHandler != nil {
+			d.appFocusHandler()
+		}
+		return
+	}
+
+	note := fmt.Sprintf("Applied %d change(s) in %s", count, elapsed)
+	if unmatched > 0 {
+		note += fmt.Sprintf(" (%d changed no row)", unmatched)
+	}
+
+	// Reload the result to show the stored rows with generated values
+	grid.edits = nil
+	d.closeTabs()
+	d.runQuery(statement, note+". ")
+}
@@ -0,0 +1,383 @@
+package database
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/gdamore/tcell/v2"
+	"github.com/rivo/tview"
+	"github.com/shangyanjin/gocmder/internal/config"
+	"github.com/shangyanjin/gocmder/internal/ui/style"
+	"github.com/shangyanjin/gocmder/internal/ui/utils"
+)
+
+// historyQueryWidth limits the width of the query column of the history
+const historyQueryWidth = 200
+
+// historyHeaders are the columns of the history list
+var historyHeaders = []string{"TIME", "SESSION", "DURATION", "ROWS", "STATUS", "QUERY"}
+
+// historyContent is a virtual table content over the matching history entries
+type historyContent struct {
+	tview.TableContentReadOnly
+
+	entries []config.HistoryEntry
+}
+
+// GetCell returns the cell at the given position
+func (h *historyContent) GetCell(row, column int) *tview.TableCell {
+	if row == 0 {
+		if column >= len(historyHeaders) {
+			return nil
+		}
+		cell := tview.NewTableCell(historyHeaders[column])
+		cell.SetBackgroundColor(style.PageHeaderBgColor)
+		cell.SetTextColor(style.PageHeaderFgColor)
+		cell.SetSelectable(false)
+		return cell
+	}
+
+	if row > len(h.entries) || column >= len(historyHeaders) {
+		return nil
+	}
+	entry := h.entries[row-1]
+
+	var text string
+	color := style.DialogFgColor
+	switch column {
+	case 0:
+		text = entry.Time.Local().Format("2006-01-02 15:04:05")
+	case 1:
+		text = entry.Session
+		if entry.Database != "" {
+			text += "/" + entry.Database
+		}
+	case 2:
+		text = entry.Elapsed().String()
+	case 3:
+		text = fmt.Sprint(entry.Rows)
+	case 4:
+		text = "OK"
+		color = style.StatusInstalledColor
+		if entry.Error != "" {
+			text = "Error"
+			color = style.StatusErrorColor
+		}
+	case 5:
+		text = strings.Join(strings.Fields(entry.Query), " ")
+	}
+
+	cell := tview.NewTableCell(tview.Escape(text))
+	cell.SetTextColor(color)
+	if column == 5 {
+		cell.SetMaxWidth(historyQueryWidth)
+		cell.SetExpansion(1)
+	}
+	return cell
+}
+
+// GetRowCount returns the number of entries plus the header
+func (h *historyContent) GetRowCount() int {
+	return len(h.entries) + 1
+}
+
+// GetColumnCount returns the number of columns
+func (h *historyContent) GetColumnCount() int {
+	return len(historyHeaders)
+}
+
+// HistoryPanel lists executed statements with incremental search and a
+// filter by session
+type HistoryPanel struct {
+	*tview.Box
+
+	layout      *tview.Flex
+	search      *tview.InputField
+	filterBar   *tview.TextView
+	table       *tview.Table
+	preview     *tview.TextView
+	content     *historyContent
+	store       *config.HistoryStore
+	sessions    []string // session filters, the first one matches all sessions
+	session     int
+	display     bool
+	doneHandler func()
+	loadHandler func(entry config.HistoryEntry)
+	runHandler  func(entry config.HistoryEntry)
+}
+
+// NewHistoryPanel returns a new history panel primitive. loadFunc loads an
+// entry into the editor and runFunc runs it again.
+func NewHistoryPanel(loadFunc, runFunc func(entry config.HistoryEntry)) *HistoryPanel {
+	bgColor := style.DialogBgColor
+	panel := &HistoryPanel{
+		Box:         tview.NewBox(),
+		content:     &historyContent{},
+		loadHandler: loadFunc,
+		runHandler:  runFunc,
+	}
+
+	panel.search = tview.NewInputField()
+	panel.search.SetLabel(" Search: ")
+	panel.search.SetLabelColor(style.FgColor)
+	panel.search.SetFieldBackgroundColor(style.BgColor)
+	panel.search.SetFieldTextColor(style.FgColor)
+	panel.search.SetBackgroundColor(bgColor)
+	panel.search.SetPlaceholder("words of the query")
+	panel.search.SetChangedFunc(func(string) {
+		panel.refresh()
+	})
+
+	panel.filterBar = tview.NewTextView()
+	panel.filterBar.SetBackgroundColor(bgColor)
+	panel.filterBar.SetTextColor(style.FgColor)
+	panel.filterBar.SetDynamicColors(true)
+	panel.filterBar.SetWrap(false)
+
+	panel.table = tview.NewTable()
+	panel.table.SetBackgroundColor(bgColor)
+	panel.table.SetSelectable(true, false)
+	panel.table.SetFixed(1, 0)
+	panel.table.SetContent(panel.content)
+	panel.table.SetSelectionChangedFunc(func(row, column int) {
+		panel.showPreview()
+	})
+
+	panel.preview = tview.NewTextView()
+	panel.preview.SetBackgroundColor(style.BgColor)
+	panel.preview.SetTextColor(style.DialogFgColor)
+	panel.preview.SetDynamicColors(true)
+	panel.preview.SetWrap(true)
+
+	highlightColor := style.GetColorHex(style.StatusInstalledColor)
+	hint := tview.NewTextView()
+	hint.SetBackgroundColor(bgColor)
+	hint.SetTextColor(style.FgColor)
+	hint.SetDynamicColors(true)
+	hint.SetText(" [" + highlightColor + "]Type[-] Search | [" + highlightColor + "]Tab[-] Session | [" + highlightColor +
+		"]Enter[-] Load into editor | [" + highlightColor + "]Ctrl+R[-] Run again | [" + highlightColor + "]ESC[-] Close")
+
+	header := tview.NewFlex().SetDirection(tview.FlexColumn)
+	header.AddItem(panel.search, 0, 1, true)
+	header.AddItem(panel.filterBar, 0, 1, false)
+
+	panel.layout = tview.NewFlex().SetDirection(tview.FlexRow)
+	panel.layout.AddItem(header, 1, 0, true)
+	panel.layout.AddItem(panel.table, 0, 3, false)
+	panel.layout.AddItem(panel.preview, 0, 1, false)
+	panel.layout.AddItem(hint, 1, 0, false)
+	panel.layout.SetBorder(true)
+	panel.layout.SetTitle(" Query History ")
+	panel.layout.SetTitleColor(style.FgColor)
+	panel.layout.SetBorderColor(style.DialogBorderColor)
+	panel.layout.SetBackgroundColor(bgColor)
+
+	return panel
+}
+
+// Show lists the history of store, filtered by session if it has entries
+func (h *HistoryPanel) Show(store *config.HistoryStore, session string) {
+	h.store = store
+	h.sessions = append([]string{""}, store.Sessions()...)
+	h.session = 0
+	for i, name := range h.sessions {
+		if name != "" && name == session {
+			h.session = i
+		}
+	}
+
+	h.search.SetText("")
+	h.refresh()
+	h.display = true
+}
+
+// refresh lists the entries matching the search text and session
+func (h *HistoryPanel) refresh() {
+	if h.store == nil {
+		return
+	}
+
+	h.content.entries = h.store.Search(h.search.GetText(), h.sessions[h.session])
+	h.table.Select(1, 0)
+	h.table.ScrollToBeginning()
+	h.updateFilterBar()
+	h.showPreview()
+}
+
+// updateFilterBar shows the session filter and the number of matches
+func (h *HistoryPanel) updateFilterBar() {
+	highlightColor := style.GetColorHex(style.StatusInstalledColor)
+	session := h.sessions[h.session]
+	if session == "" {
+		session = "all sessions"
+	}
+	h.filterBar.SetText(fmt.Sprintf("Session: [%s]%s[-] | %d entries ", highlightColor, tview.Escape(session), len(h.content.entries)))
+}
+
+// showPreview shows the full query and error of the selected entry
+func (h *HistoryPanel) showPreview() {
+	entry, ok := h.selected()
+	if !ok {
+		h.preview.SetText(" No matching queries")
+		return
+	}
+
+	text := tview.Escape(entry.Query)
+	if entry.Error != "" {
+		errorColor := style.GetColorHex(style.StatusErrorColor)
+		text += fmt.Sprintf("\n\n[%s]%s[-]", errorColor, tview.Escape(entry.Error))
+	}
+	h.preview.SetText(text)
+	h.preview.ScrollToBeginning()
+}
+
+// selected returns the selected entry
+func (h *HistoryPanel) selected() (config.HistoryEntry, bool) {
+	row, _ := h.table.GetSelection()
+	if row < 1 || row > len(h.content.entries) {
+		return config.HistoryEntry{}, false
+	}
+	return h.content.entries[row-1], true
+}
+
+// Display displays this primitive
+func (h *HistoryPanel) Display() {
+	h.display = true
+}
+
+// IsDisplay returns true if primitive is shown
+func (h *HistoryPanel) IsDisplay() bool {
+	return h.display
+}
+
+// Hide stops displaying this primitive
+func (h *HistoryPanel) Hide() {
+	h.display = false
+}
+
+// HasFocus returns whether or not this primitive has focus
+func (h *HistoryPanel) HasFocus() bool {
+	return h.display && (h.layout.HasFocus() || h.Box.HasFocus())
+}
+
+// Focus is called when this primitive receives focus, typing always searches
+func (h *HistoryPanel) Focus(delegate func(p tview.Primitive)) {
+	delegate(h.search)
+}
+
+// InputHandler returns input handler function for this primitive
+func (h *HistoryPanel) InputHandler() func(event *tcell.EventKey, setFocus func(p tview.Primitive)) {
+	return h.WrapInputHandler(func(event *tcell.EventKey, setFocus func(p tview.Primitive)) {
+		switch event.Key() {
+		case utils.CloseDialogKey.Key:
+			if h.doneHandler != nil {
+				h.doneHandler()
+			}
+		case tcell.KeyTab, tcell.KeyBacktab:
+			step := 1
+			if event.Key() == tcell.KeyBacktab {
+				step = len(h.sessions) - 1
+			}
+			h.session = (h.session + step) % len(h.sessions)
+			h.refresh()
+		case tcell.KeyUp, tcell.KeyDown, tcell.KeyPgUp, tcell.KeyPgDn:
+			if handler := h.table.InputHandler(); handler != nil {
+				handler(event, setFocus)
+			}
+		case tcell.KeyEnter, tcell.KeyCtrlR:
+			entry, ok := h.selected()
+			if !ok {
+				return
+			}
+			if event.Key() == tcell.KeyEnter {
+				h.loadHandler(entry)
+			} else {
+				h.runHandler(entry)
+			}
+		default:
+			if handler := h.search.InputHandler(); handler != nil {
+				handler(event, setFocus)
+			}
+		}
+	})
+}
+
+// SetDoneFunc sets the handler called when the panel is closed
+func (h *HistoryPanel) SetDoneFunc(handler func()) *HistoryPanel {
+	h.doneHandler = handler
+	return h
+}
+
+// SetRect sets rects for this primitive, the panel covers the given area
+func (h *HistoryPanel) SetRect(x, y, width, height int) {
+	h.Box.SetRect(x, y, width, height)
+	h.layout.SetRect(x, y, width, height)
+}
+
+// Draw draws this primitive onto the screen
+func (h *HistoryPanel) Draw(screen tcell.Screen) {
+	if !h.display {
+		return
+	}
+
+	h.layout.Draw(screen)
+}
+
+// showHistory opens the query history, filtered by the connected session
+func (d *Database) showHistory() {
+	if d.history == nil {
+		d.showError("The query history could not be opened")
+		return
+	}
+
+	d.history.Show(d.historyStore, d.currentSession)
+}
+
+// recordHistory adds an executed statement to the query history
+func (d *Database) recordHistory(tab *resultTab) {
+	if d.historyStore == nil {
+		return
+	}
+
+	entry := config.HistoryEntry{
+		Time:     time.Now().Add(-tab.elapsed),
+		Session:  d.currentSession,
+		Database: d.currentDatabase,
+		Query:    tab.statement.Text,
+		Duration: tab.elapsed.Milliseconds(),
+	}
+	switch {
+	case tab.err != nil:
+		entry.Error = tab.err.Error()
+	case tab.grid != nil:
+		entry.Rows = int64(len(tab.grid.rows))
+	case tab.result != nil:
+		entry.Rows = tab.result.RowsAffected
+	}
+
+	if err := d.historyStore.Add(entry); err != nil {
+		d.updateStatusBar(fmt.Sprintf("Failed to save query history: %s", tview.Escape(err.Error())))
+	}
+}
+
+// loadHistoryEntry replaces the editor text with a query of the history,
+// the previous text can be restored with undo
+func (d *Database) loadHistoryEntry(entry config.HistoryEntry) {
+	d.history.Hide()
+	d.sqlEditor.Replace(0, len(d.sqlEditor.GetText()), entry.Query)
+	d.focusedElement = focusEditor
+	d.updateStatusBar(fmt.Sprintf("Loaded query of %s from the history (Ctrl+Z restores the previous text)",
+		entry.Time.Local().Format("2006-01-02 15:04:05")))
+
+	if d.appFocusHandler != nil {
+		d.appFocusHandler()
+	}
+}
+
+// runHistoryEntry loads a query of the history into the editor and runs it
+// on the current connection
+func (d *Database) runHistoryEntry(entry config.HistoryEntry) {
+	d.loadHistoryEntry(entry)
+	d.executeScript()
+}
@@ -0,0 +1,296 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/rivo/tview"
+	"github.com/shangyanjin/gocmder/internal/db"
+)
+
+// importProgressInterval limits how often the progress dialog is updated
+const importProgressInterval = 200 * time.Millisecond
+
+// countingReader counts the bytes read from a file for the import progress
+type countingReader struct {
+	io.Reader
+	read atomic.Int64
+}
+
+// Read reads from the file and counts the bytes
+func (r *countingReader) Read(p []byte) (int, error) {
+	n, err := r.Reader.Read(p)
+	r.read.Add(int64(n))
+	return n, err
+}
+
+// showImport opens the import dialog for the table, database or schema
+// selected in the tree
+func (d *Database) showImport() {
+	if !d.connected || d.driver == nil {
+		return
+	}
+
+	node := d.leftPanel.GetCurrentNode()
+	if node == nil {
+		return
+	}
+	data, ok := node.GetReference().(map[string]string)
+	if !ok {
+		return
+	}
+
+	switch data["type"] {
+	case "table":
+		d.importDialog.ShowSource(data["database"], data["schema"], data["name"])
+	case "database":
+		d.importDialog.ShowSource(data["name"], "", "")
+	case "schema":
+		d.importDialog.ShowSource(data["database"], data["name"], "")
+	case "group":
+		d.importDialog.ShowSource(data["database"], data["schema"], "")
+	default:
+		d.updateStatusBar("Select a table, database or schema to import into")
+	}
+}
+
+// readImportFile reads the columns and sample of the file chosen in the
+// import dialog and shows the column mapping
+func (d *Database) readImportFile(source importSource) {
+	if !d.connected || d.driver == nil {
+		d.showError("Not connected to database")
+		return
+	}
+
+	file, err := os.Open(expandHome(source.file))
+	if err != nil {
+		d.showError(fmt.Sprintf("Import failed: %v", err))
+		return
+	}
+	defer file.Close()
+
+	reader, err := db.NewImportReader(file, source.options)
+	if err != nil {
+		d.showError(fmt.Sprintf("Failed to read %s: %v", source.file, err))
+		return
+	}
+	if source.create {
+		d.showImportMapping(source, reader, nil)
+		return
+	}
+
+	// An existing table is described in its database
+	if !d.canExecute() {
+		return
+	}
+	d.useDatabase(source.database, func() {
+		var table *db.TableInfo
+		ctx, cancel := d.statementContext()
+		d.runTask(ctx, cancel, fmt.Sprintf("Loading structure of %s...", tview.Escape(source.table)), func(ctx context.Context) error {
+			var err error
+			table, err = d.driver.DescribeTable(ctx, source.database, source.schema, source.table)
+			return err
+		}, func(err error) {
+			if err != nil {
+				d.showError(fmt.Sprintf("Failed to describe table: %v", err))
+				return
+			}
+			d.showImportMapping(source, reader, table)
+		})
+	})
+}
+
+// showImportMapping shows the column mapping of the file of an import,
+// table is the structure of an existing target table
+func (d *Database) showImportMapping(source importSource, reader *db.ImportReader, table *db.TableInfo) {
+	d.importDialog.ShowMapping(d.driver.GetDialect(), reader.Columns(), reader.Sample(), table)
+	d.updateStatusBar(fmt.Sprintf("Read %d columns from %s", len(reader.Columns()), tview.Escape(source.file)))
+}
+
+// renameImportColumn asks for the name of a column of a new table
+func (d *Database) renameImportColumn(name string, done func(name string)) {
+	d.prompt("Column name", "Name: ", name, done)
+}
+
+// importTarget returns the target of an import, the file column of each
+// target column and the inferred types of a new table
+func (d *Database) importTarget(source importSource, columns []importColumn) (db.ImportTarget, []int, []db.ImportType) {
+	dialect := d.driver.GetDialect()
+	target := db.ImportTarget{Dialect: dialect, Schema: source.database, Table: source.table}
+	if dialect == db.DialectPostgres {
+		target.Schema = source.schema
+	}
+
+	var indexes []int
+	var types []db.ImportType
+	for i, column := range columns {
+		if column.target == "" {
+			continue
+		}
+		target.Columns = append(target.Columns, column.target)
+		indexes = append(indexes, i)
+		types = append(types, column.Type)
+
+		// Column types decide how boolean values are loaded
+		sqlType := column.Type.SQLType(dialect)
+		if source.info != nil {
+			for _, info := range source.info.Columns {
+				if info.Name == column.target {
+					sqlType = info.Type
+				}
+			}
+		}
+		target.Types = append(target.Types, sqlType)
+	}
+	return target, indexes, types
+}
+
+// startImport creates the table if requested and loads the file in the
+// background with a progress dialog
+func (d *Database) startImport(source importSource, columns []importColumn) {
+	if !d.canExecute() {
+		return
+	}
+	d.useDatabase(source.database, func() {
+		d.runImport(source, columns)
+	})
+}
+
+// runImport loads the file into the table in the connected database
+func (d *Database) runImport(source importSource, columns []importColumn) {
+	target, indexes, types := d.importTarget(source, columns)
+
+	file, err := os.Open(expandHome(source.file))
+	if err != nil {
+		d.showError(fmt.Sprintf("Import failed: %v", err))
+		return
+	}
+	var size int64
+	if stat, err := file.Stat(); err == nil {
+		size = stat.Size()
+	}
+	counter := &countingReader{Reader: file}
+	reader, err := db.NewImportReader(counter, source.options)
+	if err != nil {
+		file.Close()
+		d.showError(fmt.Sprintf("Failed to read %s: %v", source.file, err))
+		return
+	}
+
+	if source.create {
+		ctx, cancel := d.statementContext()
+		_, err := d.driver.ExecuteQuery(ctx, db.CreateTableStatement(target, types))
+		cancel()
+		if err != nil {
+			d.showError(fmt.Sprintf("Failed to create table %s: %v", source.table, err))
+			file.Close()
+			return
+		}
+	}
+
+	// The import uses the connection like a query, committed batches stay
+	// when it is cancelled
+	ctx, cancel := context.WithCancel(context.Background())
+	run := &runningQuery{
+		ctx:     ctx,
+		cancel:  cancel,
+		started: time.Now(),
+		done:    make(chan struct{}),
+		total:   1,
+	}
+	d.running = run
+
+	d.progressDialog.SetTitle("Import " + source.options.Format.String())
+	d.progressDialog.SetText(fmt.Sprintf("Loading %s into %s...\n\nESC to cancel", tview.Escape(source.file), tview.Escape(target.QuotedTable())))
+	d.progressDialog.SetProgress(0, int(size))
+	d.progressDialog.SetCancelFunc(func() {
+		run.canceled = true
+		cancel()
+		d.progressDialog.SetText("Cancelling import...")
+	})
+	d.progressDialog.Display()
+
+	driver := d.driver
+	go func() {
+		var reported time.Time
+		report, err := db.Import(ctx, driver, reader, target, indexes, source.batch, func(report *db.ImportReport) {
+			if time.Since(reported) < importProgressInterval {
+				return
+			}
+			reported = time.Now()
+			loaded, failed, read := report.Loaded, report.Failed, counter.read.Load()
+			d.queueUpdateDraw(func() {
+				if !d.progressDialog.IsDisplay() || run.canceled {
+					return
+				}
+				d.progressDialog.SetText(fmt.Sprintf("Loading %s into %s...\n%d rows loaded, %d failed\nESC to cancel",
+					tview.Escape(source.file), tview.Escape(target.QuotedTable()), loaded, failed))
+				d.progressDialog.SetProgress(int(min(read, size)), int(size))
+			})
+		})
+		file.Close()
+		canceled := ctx.Err() != nil
+		cancel()
+
+		d.queueUpdateDraw(func() {
+			d.finishImport(run, source, report, canceled, err)
+		})
+	}()
+}
+
+// finishImport reports the loaded rows and the rows that failed
+func (d *Database) finishImport(run *runningQuery, source importSource, report *db.ImportReport, canceled bool, err error) {
+	close(run.done)
+	if d.running == run {
+		d.running = nil
+	}
+	// Give the focus back unless the user left the page meanwhile
+	focused := d.HasFocus()
+	d.progressDialog.Hide()
+
+	elapsed := time.Since(run.started).Round(time.Millisecond)
+	summary := fmt.Sprintf("Imported %d rows into %s in %s", report.Loaded, tview.Escape(source.table), elapsed)
+	switch {
+	case canceled:
+		summary = fmt.Sprintf("Import cancelled after %s, %d rows were loaded into %s", elapsed, report.Loaded, tview.Escape(source.table))
+	case err != nil:
+		summary = fmt.Sprintf("Import stopped after %d rows: %v", report.Loaded, err)
+	}
+
+	if report.Failed == 0 {
+		if err != nil && !canceled {
+			d.showError(summary)
+		} else {
+			d.updateStatusBar(summary)
+		}
+	} else {
+		d.updateStatusBar(fmt.Sprintf("%s, %d rows failed", summary, report.Failed))
+		d.showImportReport(source, report, summary)
+	}
+
+	if focused && d.appFocusHandler != nil {
+		d.appFocusHandler()
+	}
+}
+
+// showImportReport lists the rows of an import that could not be loaded
+func (d *Database) showImportReport(source importSource, report *db.ImportReport, summary string) {
+	var text strings.Builder
+	fmt.Fprintf(&text, "%s.\n%d rows of %s failed", summary, report.Failed, tview.Escape(source.file))
+	if int64(len(report.Errors)) < report.Failed {
+		fmt.Fprintf(&text, ", the first %d are listed", len(report.Errors))
+	}
+	text.WriteString(":\n\n")
+	for _, rowErr := range report.Errors {
+		fmt.Fprintf(&text, "Line %d: %s\n", rowErr.Line, tview.Escape(rowErr.Err.Error()))
+	}
+
+	d.textDialog.SetTitle("Import report")
+	d.textDialog.SetText(text.String())
+	d.textDialog.Display()
+}
@@ -0,0 +1,576 @@
+package database
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/gdamore/tcell/v2"
+	"github.com/rivo/tview"
+	"github.com/shangyanjin/gocmder/internal/db"
+	"github.com/shangyanjin/gocmder/internal/ui/style"
+	"github.com/shangyanjin/gocmder/internal/ui/utils"
+)
+
+const (
+	// defaultImportBatch is the number of rows loaded in one transaction
+	defaultImportBatch = 1000
+	// importSampleText limits the sample value shown for a file column
+	importSampleText = 30
+)
+
+// importSource is the file and table chosen on the first page of the import dialog
+type importSource struct {
+	file     string
+	options  db.ImportOptions
+	database string
+	schema   string
+	table    string
+	create   bool // create the table from the file columns
+	batch    int
+	info     *db.TableInfo // structure of an existing table
+}
+
+// importColumn maps a file column to a table column
+type importColumn struct {
+	db.ImportColumn
+	target string // table column, empty to skip the file column
+	sample string
+}
+
+// ImportDialog is a two page wizard: the file and table, then the mapping
+// of file columns to table columns
+type ImportDialog struct {
+	*tview.Box
+
+	layout          *tview.Flex
+	pages           *tview.Pages
+	form            *tview.Form
+	mapping         *tview.Table
+	info            *tview.TextView
+	hint            *tview.TextView
+	display         bool
+	source          importSource
+	delimiter       string
+	batch           string
+	dialect         db.Dialect
+	columns         []importColumn
+	tableColumns    []string // columns of an existing table
+	nextFunc        func(source importSource)
+	importFunc      func(source importSource, columns []importColumn)
+	renameFunc      func(name string, done func(name string))
+	appFocusHandler func()
+}
+
+// NewImportDialog creates a new import dialog. nextFunc reads the file of
+// the first page, importFunc loads it with the chosen mapping and
+// renameFunc asks for the name of a new table column.
+func NewImportDialog(nextFunc func(source importSource), importFunc func(source importSource, columns []importColumn), renameFunc func(name string, done func(name string))) *ImportDialog {
+	bgColor := style.DialogBgColor
+
+	dialog := &ImportDialog{
+		Box:        tview.NewBox(),
+		nextFunc:   nextFunc,
+		importFunc: importFunc,
+		renameFunc: renameFunc,
+		delimiter:  ",",
+		batch:      strconv.Itoa(defaultImportBatch),
+		source: importSource{
+			options: db.ImportOptions{Header: true, EmptyNull: true},
+		},
+	}
+
+	dialog.form = tview.NewForm()
+	dialog.form.SetBackgroundColor(bgColor)
+	dialog.form.SetButtonBackgroundColor(style.ButtonBgColor)
+	dialog.form.SetFieldBackgroundColor(style.BgColor)
+	dialog.form.SetLabelColor(style.FgColor)
+	dialog.form.SetFieldTextColor(style.FgColor)
+	dialog.form.SetButtonsAlign(tview.AlignCenter)
+
+	dialog.mapping = tview.NewTable()
+	dialog.mapping.SetBackgroundColor(bgColor)
+	dialog.mapping.SetSelectable(true, false)
+	dialog.mapping.SetFixed(1, 0)
+
+	dialog.info = tview.NewTextView()
+	dialog.info.SetBackgroundColor(bgColor)
+	dialog.info.SetTextColor(style.DialogFgColor)
+	dialog.info.SetDynamicColors(true)
+
+	mappingPage := tview.NewFlex().SetDirection(tview.FlexRow)
+	mappingPage.AddItem(dialog.mapping, 0, 1, true)
+	mappingPage.AddItem(dialog.info, 2, 0, false)
+
+	dialog.pages = tview.NewPages()
+	dialog.pages.AddPage("source", dialog.form, true, true)
+	dialog.pages.AddPage("mapping", mappingPage, true, false)
+
+	dialog.hint = tview.NewTextView()
+	dialog.hint.SetBackgroundColor(bgColor)
+	dialog.hint.SetTextColor(style.FgColor)
+	dialog.hint.SetDynamicColors(true)
+
+	dialog.layout = tview.NewFlex().SetDirection(tview.FlexRow)
+	dialog.layout.AddItem(dialog.pages, 0, 1, true)
+	dialog.layout.AddItem(dialog.hint, 1, 0, false)
+	dialog.layout.SetBorder(true)
+	dialog.layout.SetTitleColor(style.FgColor)
+	dialog.layout.SetBorderColor(style.DialogBorderColor)
+	dialog.layout.SetBackgroundColor(bgColor)
+
+	return dialog
+}
+
+// ShowSource shows the first page for an import into table of database and
+// schema, an empty table name creates a new table
+func (d *ImportDialog) ShowSource(database, schema, table string) {
+	d.source.database = database
+	d.source.schema = schema
+	d.source.table = table
+	d.source.create = table == ""
+	d.showSource()
+	d.display = true
+}
+
+// showSource builds the form of the first page
+func (d *ImportDialog) showSource() {
+	target := d.source.database
+	if d.source.schema != "" {
+		target += "." + d.source.schema
+	}
+	d.layout.SetTitle(fmt.Sprintf(" Import into %s (1/2) ", tview.Escape(target)))
+	d.layout.SetTitleColor(style.FgColor)
+
+	formats := []string{db.ImportCSV.String(), db.ImportJSONLines.String()}
+	d.form.Clear(true)
+	d.form.AddInputField("File", d.source.file, 50, nil, func(text string) {
+		d.source.file = text
+		// The format follows the file extension
+		lower := strings.ToLower(text)
+		format := d.form.GetFormItemByLabel("Format").(*tview.DropDown)
+		switch {
+		case strings.HasSuffix(lower, ".jsonl") || strings.HasSuffix(lower, ".ndjson") || strings.HasSuffix(lower, ".json"):
+			format.SetCurrentOption(int(db.ImportJSONLines))
+		case strings.HasSuffix(lower, ".csv") || strings.HasSuffix(lower, ".tsv"):
+			format.SetCurrentOption(int(db.ImportCSV))
+		}
+	})
+	d.form.AddDropDown("Format", formats, int(d.source.options.Format), func(_ string, index int) {
+		if index >= 0 {
+			d.source.options.Format = db.ImportFormat(index)
+		}
+	})
+	d.form.AddInputField("CSV Delimiter", d.delimiter, 5, nil, func(text string) {
+		d.delimiter = text
+	})
+	d.form.AddCheckbox("CSV Header", d.source.options.Header, func(checked bool) {
+		d.source.options.Header = checked
+	})
+	d.form.AddCheckbox("Empty is NULL", d.source.options.EmptyNull, func(checked bool) {
+		d.source.options.EmptyNull = checked
+	})
+	d.form.AddInputField("Table", d.source.table, 40, nil, func(text string) {
+		d.source.table = text
+	})
+	d.form.AddCheckbox("Create Table", d.source.create, func(checked bool) {
+		d.source.create = checked
+	})
+	d.form.AddInputField("Batch Size", d.batch, 10, tview.InputFieldInteger, func(text string) {
+		d.batch = text
+	})
+	d.form.AddButton("Next", d.handleNext)
+	d.form.AddButton("Cancel", d.close)
+	d.form.SetFocus(0)
+
+	highlightColor := style.GetColorHex(style.StatusInstalledColor)
+	d.hint.SetText(" [" + highlightColor + "]Tab[-] Next field | [" + highlightColor + "]Enter[-] Select | [" + highlightColor + "]ESC[-] Cancel")
+	d.pages.SwitchToPage("source")
+}
+
+// handleNext checks the first page and asks for the file to be read
+func (d *ImportDialog) handleNext() {
+	delimiter, err := parseDelimiter(d.delimiter)
+	if err != nil {
+		d.fail(err)
+		return
+	}
+	batch, err := strconv.Atoi(d.batch)
+	if err != nil || batch < 1 {
+		d.fail(fmt.Errorf("the batch size is a positive number"))
+		return
+	}
+
+	d.source.file = strings.TrimSpace(d.source.file)
+	d.source.table = strings.TrimSpace(d.source.table)
+	d.source.options.Delimiter = delimiter
+	d.source.batch = batch
+	switch {
+	case d.source.file == "":
+		d.fail(fmt.Errorf("enter the file to import"))
+		return
+	case d.source.table == "":
+		d.fail(fmt.Errorf("enter the table to load"))
+		return
+	}
+
+	if d.nextFunc != nil {
+		d.nextFunc(d.source)
+	}
+	// The mapping page or an error takes the focus
+	if d.appFocusHandler != nil {
+		d.appFocusHandler()
+	}
+}
+
+// ShowMapping shows the second page for the columns read from the file.
+// table is the structure of an existing table, nil for a new table.
+func (d *ImportDialog) ShowMapping(dialect db.Dialect, columns []db.ImportColumn, sample []db.ImportRecord, table *db.TableInfo) {
+	d.dialect = dialect
+	d.source.info = table
+	d.tableColumns = nil
+	if table != nil {
+		for _, column := range table.Columns {
+			d.tableColumns = append(d.tableColumns, column.Name)
+		}
+	}
+	d.columns = make([]importColumn, len(columns))
+	for i, column := range columns {
+		d.columns[i] = importColumn{ImportColumn: column, sample: sampleText(sample, i)}
+		if table == nil {
+			d.columns[i].target = column.Name
+			continue
+		}
+		// File columns map to the table column of the same name
+		d.columns[i].target = d.matchColumn(column.Name)
+	}
+
+	action := "load into"
+	if d.source.create {
+		action = "create"
+	}
+	d.layout.SetTitle(fmt.Sprintf(" Import %s: %s %s (2/2) ", tview.Escape(d.source.file), action, tview.Escape(d.source.table)))
+	d.layout.SetTitleColor(style.FgColor)
+
+	highlightColor := style.GetColorHex(style.StatusInstalledColor)
+	change := "Table column"
+	if d.source.create {
+		change = "Type | [" + highlightColor + "]Enter[-] Rename"
+	}
+	d.hint.SetText(" [" + highlightColor + "]←/→[-] " + change + " | [" + highlightColor + "]Space[-] Skip | [" + highlightColor + "]Ctrl+S[-] Import | [" + highlightColor + "]ESC[-] Back")
+
+	d.updateMapping()
+	d.mapping.Select(1, 0)
+	d.mapping.ScrollToBeginning()
+	d.pages.SwitchToPage("mapping")
+}
+
+// sampleText returns the first sampled value of a column
+func sampleText(sample []db.ImportRecord, column int) string {
+	for _, record := range sample {
+		if value := record.Values[column]; !value.Null {
+			return utils.TruncateString(strings.ReplaceAll(value.Text, "\n", " "), importSampleText)
+		}
+	}
+	return ""
+}
+
+// updateMapping fills the mapping table
+func (d *ImportDialog) updateMapping() {
+	d.mapping.Clear()
+
+	headers := []string{"FILE COLUMN", "INFERRED", "SAMPLE", "TABLE COLUMN"}
+	if d.source.create {
+		headers[3] = "NEW COLUMN"
+	}
+	for i, header := range headers {
+		cell := tview.NewTableCell(header)
+		cell.SetBackgroundColor(style.PageHeaderBgColor)
+		cell.SetTextColor(style.PageHeaderFgColor)
+		cell.SetSelectable(false)
+		cell.SetExpansion(1)
+		d.mapping.SetCell(0, i, cell)
+	}
+
+	mapped := 0
+	for i, column := range d.columns {
+		target := column.target
+		switch {
+		case target == "":
+			target = "(skip)"
+		case d.source.create:
+			target += " " + column.Type.SQLType(d.dialect)
+		}
+		if column.target != "" {
+			mapped++
+		}
+
+		d.mapping.SetCell(i+1, 0, tview.NewTableCell(tview.Escape(column.Name)).SetTextColor(style.FgColor))
+		d.mapping.SetCell(i+1, 1, tview.NewTableCell(column.Type.String()).SetTextColor(style.BorderColor))
+		d.mapping.SetCell(i+1, 2, tview.NewTableCell(tview.Escape(column.sample)).SetTextColor(style.BorderColor))
+		cell := tview.NewTableCell(tview.Escape(target))
+		if column.target == "" {
+			cell.SetTextColor(style.StatusErrorColor)
+		} else {
+			cell.SetTextColor(style.StatusInstalledColor)
+		}
+		d.mapping.SetCell(i+1, 3, cell)
+	}
+
+	d.info.SetText(fmt.Sprintf(" %d of %d file columns mapped, %d rows per transaction.\n Types are inferred from the first 1000 records.", mapped, len(d.columns), d.source.batch))
+}
+
+// matchColumn returns the table column with the name of a file column
+func (d *ImportDialog) matchColumn(name string) string {
+	for _, column := range d.tableColumns {
+		if strings.EqualFold(column, name) {
+			return column
+		}
+	}
+	return ""
+}
+
+// selectedColumn returns the file column selected in the mapping table
+func (d *ImportDialog) selectedColumn() *importColumn {
+	row, _ := d.mapping.GetSelection()
+	if row < 1 || row > len(d.columns) {
+		return nil
+	}
+	return &d.columns[row-1]
+}
+
+// cycle changes the type of a new table column or the table column of the
+// selected file column by step
+func (d *ImportDialog) cycle(step int) {
+	column := d.selectedColumn()
+	if column == nil {
+		return
+	}
+
+	if d.source.create {
+		index := 0
+		for i, t := range db.ImportTypes {
+			if t == column.Type {
+				index = i
+			}
+		}
+		column.Type = db.ImportTypes[(index+step+len(db.ImportTypes))%len(db.ImportTypes)]
+		if column.target == "" {
+			column.target = column.Name
+		}
+		d.updateMapping()
+		return
+	}
+
+	// The choices are skipping the column and each table column
+	choices := append([]string{""}, d.tableColumns...)
+	index := 0
+	for i, choice := range choices {
+		if choice == column.target {
+			index = i
+		}
+	}
+	column.target = choices[(index+step+len(choices))%len(choices)]
+	d.updateMapping()
+}
+
+// toggleSkip skips the selected file column or maps it again
+func (d *ImportDialog) toggleSkip() {
+	column := d.selectedColumn()
+	if column == nil {
+		return
+	}
+
+	switch {
+	case column.target != "":
+		column.target = ""
+	case d.source.create:
+		column.target = column.Name
+	default:
+		column.target = d.matchColumn(column.Name)
+		if column.target == "" && len(d.tableColumns) > 0 {
+			column.target = d.tableColumns[0]
+		}
+	}
+	d.updateMapping()
+}
+
+// rename asks for the name of the new table column of the selected file column
+func (d *ImportDialog) rename() {
+	column := d.selectedColumn()
+	if column == nil || !d.source.create || d.renameFunc == nil {
+		return
+	}
+
+	name := column.target
+	if name == "" {
+		name = column.Name
+	}
+	d.renameFunc(name, func(name string) {
+		column.target = strings.TrimSpace(name)
+		d.updateMapping()
+	})
+	if d.appFocusHandler != nil {
+		d.appFocusHandler()
+	}
+}
+
+// handleImport checks the mapping and hands it to the import handler
+func (d *ImportDialog) handleImport() {
+	mapped := 0
+	targets := make(map[string]bool)
+	for _, column := range d.columns {
+		if column.target == "" {
+			continue
+		}
+		key := strings.ToLower(column.target)
+		if targets[key] {
+			d.fail(fmt.Errorf("column %s is mapped twice", column.target))
+			return
+		}
+		targets[key] = true
+		mapped++
+	}
+	if mapped == 0 {
+		d.fail(fmt.Errorf("map at least one column"))
+		return
+	}
+
+	d.Hide()
+	if d.importFunc != nil {
+		d.importFunc(d.source, d.columns)
+	}
+	if d.appFocusHandler != nil {
+		d.appFocusHandler()
+	}
+}
+
+// fail reports an invalid field or mapping in the dialog title
+func (d *ImportDialog) fail(err error) {
+	d.layout.SetTitle(" " + err.Error() + " ")
+	d.layout.SetTitleColor(style.StatusErrorColor)
+}
+
+// close hides the dialog and restores the page focus
+func (d *ImportDialog) close() {
+	d.Hide()
+	if d.appFocusHandler != nil {
+		d.appFocusHandler()
+	}
+}
+
+// mappingShown returns true if the second page is shown
+func (d *ImportDialog) mappingShown() bool {
+	name, _ := d.pages.GetFrontPage()
+	return name == "mapping"
+}
+
+// Display displays this primitive
+func (d *ImportDialog) Display() {
+	d.display = true
+}
+
+// IsDisplay returns true if primitive is shown
+func (d *ImportDialog) IsDisplay() bool {
+	return d.display
+}
+
+// Hide stops displaying this primitive
+func (d *ImportDialog) Hide() {
+	d.display = false
+	d.layout.SetTitleColor(style.FgColor)
+}
+
+// HasFocus returns whether or not this primitive has focus
+func (d *ImportDialog) HasFocus() bool {
+	return d.display && (d.form.HasFocus() || d.mapping.HasFocus() || d.Box.HasFocus())
+}
+
+// Focus is called when this primitive receives focus
+func (d *ImportDialog) Focus(delegate func(p tview.Primitive)) {
+	if d.mappingShown() {
+		delegate(d.mapping)
+		return
+	}
+	delegate(d.form)
+}
+
+// SetAppFocusHandler sets the app focus handler
+func (d *ImportDialog) SetAppFocusHandler(handler func()) {
+	d.appFocusHandler = handler
+}
+
+// InputHandler returns input handler function for this primitive
+func (d *ImportDialog) InputHandler() func(event *tcell.EventKey, setFocus func(p tview.Primitive)) {
+	return d.WrapInputHandler(func(event *tcell.EventKey, setFocus func(p tview.Primitive)) {
+		if !d.mappingShown() {
+			// ESC closes an open drop-down list before the dialog
+			if event.Key() == utils.CloseDialogKey.Key && !d.dropDownOpen() {
+				d.close()
+				return
+			}
+			if handler := d.form.InputHandler(); handler != nil {
+				handler(event, setFocus)
+			}
+			return
+		}
+
+		switch {
+		case event.Key() == utils.CloseDialogKey.Key:
+			d.showSource()
+			setFocus(d.form)
+		case event.Key() == tcell.KeyLeft:
+			d.cycle(-1)
+		case event.Key() == tcell.KeyRight:
+			d.cycle(1)
+		case event.Key() == tcell.KeyRune && event.Rune() == ' ':
+			d.toggleSkip()
+		case event.Key() == tcell.KeyEnter:
+			d.rename()
+		case event.Key() == tcell.KeyCtrlS:
+			d.handleImport()
+		default:
+			if handler := d.mapping.InputHandler(); handler != nil {
+				handler(event, setFocus)
+			}
+		}
+	})
+}
+
+// dropDownOpen returns true if the list of a drop-down field is shown
+func (d *ImportDialog) dropDownOpen() bool {
+	for i := 0; i < d.form.GetFormItemCount(); i++ {
+		if dropDown, ok := d.form.GetFormItem(i).(*tview.DropDown); ok && dropDown.IsOpen() {
+			return true
+		}
+	}
+	return false
+}
+
+// SetRect sets rects for this primitive, the dialog takes most of the page
+func (d *ImportDialog) SetRect(x, y, width, height int) {
+	bWidth := width * 4 / 5
+	bHeight := height * 4 / 5
+
+	if bWidth < exportDialogWidth {
+		bWidth = min(exportDialogWidth, width-1)
+	}
+	if bHeight < exportDialogHeight {
+		bHeight = min(exportDialogHeight, height-1)
+	}
+
+	d.Box.SetRect(x+(width-bWidth)/2, y+(height-bHeight)/2, bWidth, bHeight)
+
+	x, y, width, height = d.GetInnerRect()
+	d.layout.SetRect(x, y, width, height)
+}
+
+// Draw draws this primitive onto the screen
+func (d *ImportDialog) Draw(screen tcell.Screen) {
+	if !d.display {
+		return
+	}
+
+	d.DrawForSubclass(screen, d)
+	d.layout.Draw(screen)
+}
@@ -0,0 +1,394 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/gdamore/tcell/v2"
+	"github.com/rivo/tview"
+	"github.com/shangyanjin/gocmder/internal/db"
+	"github.com/shangyanjin/gocmder/internal/ui/style"
+	"github.com/shangyanjin/gocmder/internal/ui/utils"
+)
+
+// Inspector tabs
+const (
+	inspectColumns = iota
+	inspectIndexes
+	inspectForeignKeys
+	inspectChecks
+	inspectTriggers
+	inspectDDL
+	inspectTabCount
+)
+
+// TableInspector is a tabbed pane showing the structure of a table
+type TableInspector struct {
+	*tview.Box
+
+	layout      *tview.Flex
+	tabBar      *tview.TextView
+	table       *tview.Table
+	ddl         *tview.TextView
+	pages       *tview.Pages
+	info        *db.TableInfo
+	activeTab   int
+	display     bool
+	doneHandler func()
+}
+
+// NewTableInspector returns a new table inspector primitive
+func NewTableInspector() *TableInspector {
+	bgColor := style.DialogBgColor
+
+	tabBar := tview.NewTextView()
+	tabBar.SetBackgroundColor(style.InfoBarBgColor)
+	tabBar.SetTextColor(style.FgColor)
+	tabBar.SetDynamicColors(true)
+	tabBar.SetRegions(true)
+	tabBar.SetWrap(false)
+
+	table := tview.NewTable()
+	table.SetBackgroundColor(bgColor)
+	table.SetSelectable(true, false)
+	table.SetFixed(1, 0)
+
+	ddl := tview.NewTextView()
+	ddl.SetBackgroundColor(bgColor)
+	ddl.SetTextColor(style.DialogFgColor)
+	ddl.SetScrollable(true)
+	ddl.SetWrap(false)
+
+	pages := tview.NewPages()
+	pages.AddPage("table", table, true, true)
+	pages.AddPage("ddl", ddl, true, false)
+
+	highlightColor := style.GetColorHex(style.StatusInstalledColor)
+	hint := tview.NewTextView()
+	hint.SetBackgroundColor(bgColor)
+	hint.SetTextColor(style.FgColor)
+	hint.SetDynamicColors(true)
+	hint.SetText(" [" + highlightColor + "]Tab/[ ][-] Switch | [" + highlightColor + "]1-6[-] Go to tab | [" + highlightColor + "]ESC[-] Close")
+
+	layout := tview.NewFlex().SetDirection(tview.FlexRow)
+	layout.AddItem(tabBar, 1, 0, false)
+	layout.AddItem(pages, 0, 1, true)
+	layout.AddItem(hint, 1, 0, false)
+	layout.SetBorder(true)
+	layout.SetTitleColor(style.FgColor)
+	layout.SetBorderColor(style.DialogBorderColor)
+	layout.SetBackgroundColor(bgColor)
+
+	return &TableInspector{
+		Box:     tview.NewBox(),
+		layout:  layout,
+		tabBar:  tabBar,
+		table:   table,
+		ddl:     ddl,
+		pages:   pages,
+		display: false,
+	}
+}
+
+// Display displays this primitive
+func (t *TableInspector) Display() {
+	t.display = true
+}
+
+// IsDisplay returns true if primitive is shown
+func (t *TableInspector) IsDisplay() bool {
+	return t.display
+}
+
+// Hide stops displaying this primitive
+func (t *TableInspector) Hide() {
+	t.display = false
+}
+
+// SetTable shows the structure of a table, starting with its columns
+func (t *TableInspector) SetTable(info *db.TableInfo) {
+	t.info = info
+	t.layout.SetTitle(fmt.Sprintf(" Table: %s ", tview.Escape(info.QualifiedName())))
+	t.ddl.SetText(info.DDL)
+	t.showTab(inspectColumns)
+}
+
+// showTab shows one of the inspector tabs
+func (t *TableInspector) showTab(tab int) {
+	t.activeTab = tab
+	t.updateTabBar()
+
+	if tab == inspectDDL {
+		t.ddl.ScrollToBeginning()
+		t.pages.SwitchToPage("ddl")
+		return
+	}
+
+	t.table.Clear()
+	switch tab {
+	case inspectColumns:
+		t.showColumns()
+	case inspectIndexes:
+		t.showIndexes()
+	case inspectForeignKeys:
+		t.showForeignKeys()
+	case inspectChecks:
+		t.showChecks()
+	case inspectTriggers:
+		t.showTriggers()
+	}
+	t.table.Select(1, 0)
+	t.table.ScrollToBeginning()
+	t.pages.SwitchToPage("table")
+}
+
+// updateTabBar shows the tab names with the number of entries
+func (t *TableInspector) updateTabBar() {
+	info := t.info
+	names := []string{
+		fmt.Sprintf("Columns %d", len(info.Columns)),
+		fmt.Sprintf("Indexes %d", len(info.Indexes)),
+		fmt.Sprintf("FKs %d", len(info.ForeignKeys)),
+		fmt.Sprintf("Checks %d", len(info.Checks)),
+		fmt.Sprintf("Triggers %d", len(info.Triggers)),
+		"DDL",
+	}
+
+	var text strings.Builder
+	for i, name := range names {
+		fmt.Fprintf(&text, `["%d"] %s [""]`, i, name)
+		if i < len(names)-1 {
+			text.WriteString("│")
+		}
+	}
+	t.tabBar.SetText(text.String())
+	t.tabBar.Highlight(fmt.Sprint(t.activeTab))
+}
+
+// setRows fills the table with a header and rows of text
+func (t *TableInspector) setRows(headers []string, rows [][]string) {
+	for column, header := range headers {
+		cell := tview.NewTableCell(header)
+		cell.SetBackgroundColor(style.PageHeaderBgColor)
+		cell.SetTextColor(style.PageHeaderFgColor)
+		cell.SetSelectable(false)
+		cell.SetExpansion(1)
+		t.table.SetCell(0, column, cell)
+	}
+
+	if len(rows) == 0 {
+		cell := tview.NewTableCell("(none)")
+		cell.SetTextColor(style.BorderColor)
+		t.table.SetCell(1, 0, cell)
+		return
+	}
+
+	for row, values := range rows {
+		for column, value := range values {
+			cell := tview.NewTableCell(tview.Escape(strings.Join(strings.Fields(value), " ")))
+			cell.SetTextColor(style.DialogFgColor)
+			cell.SetMaxWidth(maxCellWidth)
+			t.table.SetCell(row+1, column, cell)
+		}
+	}
+}
+
+// showColumns shows the columns with their key membership
+func (t *TableInspector) showColumns() {
+	info := t.info
+
+	foreignKeys := map[string]bool{}
+	for _, fk := range info.ForeignKeys {
+		for _, column := range fk.Columns {
+			foreignKeys[column] = true
+		}
+	}
+
+	var rows [][]string
+	for i, column := range info.Columns {
+		var keys []string
+		for position, name := range info.PrimaryKey {
+			if name == column.Name {
+				keys = append(keys, "PK")
+				if len(info.PrimaryKey) > 1 {
+					keys[len(keys)-1] += fmt.Sprint(position + 1)
+				}
+			}
+		}
+		if foreignKeys[column.Name] {
+			keys = append(keys, "FK")
+		}
+
+		nullable := "NOT NULL"
+		if column.Nullable {
+			nullable = "NULL"
+		}
+		defaultValue := ""
+		if column.HasDefault {
+			defaultValue = column.Default
+		}
+
+		rows = append(rows, []string{fmt.Sprint(i + 1), column.Name, column.Type, nullable, defaultValue,
+			strings.Join(keys, ","), column.Extra, column.Comment})
+	}
+
+	t.setRows([]string{"#", "NAME", "TYPE", "NULL", "DEFAULT", "KEY", "EXTRA", "COMMENT"}, rows)
+}
+
+// showIndexes shows the indexes
+func (t *TableInspector) showIndexes() {
+	var rows [][]string
+	for _, index := range t.info.Indexes {
+		kind := "INDEX"
+		switch {
+		case index.Primary:
+			kind = "PRIMARY"
+		case index.Unique:
+			kind = "UNIQUE"
+		}
+		rows = append(rows, []string{index.Name, kind, strings.Join(index.Columns, ", ")})
+	}
+
+	t.setRows([]string{"NAME", "KIND", "COLUMNS"}, rows)
+}
+
+// showForeignKeys shows the foreign keys
+func (t *TableInspector) showForeignKeys() {
+	var rows [][]string
+	for _, fk := range t.info.ForeignKeys {
+		reference := fk.RefTable
+		if len(fk.RefColumns) > 0 {
+			reference += "(" + strings.Join(fk.RefColumns, ", ") + ")"
+		}
+		rows = append(rows, []string{fk.Name, strings.Join(fk.Columns, ", "), reference, fk.OnUpdate, fk.OnDelete})
+	}
+
+	t.setRows([]string{"NAME", "COLUMNS", "REFERENCES", "ON UPDATE", "ON DELETE"}, rows)
+}
+
+// showChecks shows the check constraints
+func (t *TableInspector) showChecks() {
+	var rows [][]string
+	for _, check := range t.info.Checks {
+		rows = append(rows, []string{check.Name, check.Expression})
+	}
+
+	t.setRows([]string{"NAME", "EXPRESSION"}, rows)
+}
+
+// showTriggers shows the triggers
+func (t *TableInspector) showTriggers() {
+	var rows [][]string
+	for _, trigger := range t.info.Triggers {
+		rows = append(rows, []string{trigger.Name, trigger.Timing, trigger.Event, trigger.Definition})
+	}
+
+	t.setRows([]string{"NAME", "TIMING", "EVENT", "DEFINITION"}, rows)
+}
+
+// HasFocus returns whether or not this primitive has focus
+func (t *TableInspector) HasFocus() bool {
+	return t.display && (t.layout.HasFocus() || t.Box.HasFocus())
+}
+
+// Focus is called when this primitive receives focus
+func (t *TableInspector) Focus(delegate func(p tview.Primitive)) {
+	if t.activeTab == inspectDDL {
+		delegate(t.ddl)
+		return
+	}
+	delegate(t.table)
+}
+
+// InputHandler returns input handler function for this primitive
+func (t *TableInspector) InputHandler() func(event *tcell.EventKey, setFocus func(p tview.Primitive)) {
+	return t.WrapInputHandler(func(event *tcell.EventKey, setFocus func(p tview.Primitive)) {
+		if event.Key() == utils.CloseDialogKey.Key {
+			if t.doneHandler != nil {
+				t.doneHandler()
+			}
+			return
+		}
+
+		tab := -1
+		switch {
+		case event.Key() == tcell.KeyTab || event.Rune() == ']':
+			tab = (t.activeTab + 1) % inspectTabCount
+		case event.Key() == tcell.KeyBacktab || event.Rune() == '[':
+			tab = (t.activeTab - 1 + inspectTabCount) % inspectTabCount
+		case event.Rune() >= '1' && event.Rune() < '1'+inspectTabCount:
+			tab = int(event.Rune() - '1')
+		}
+		if tab >= 0 {
+			t.showTab(tab)
+			t.Focus(setFocus)
+			return
+		}
+
+		if t.activeTab == inspectDDL {
+			if handler := t.ddl.InputHandler(); handler != nil {
+				handler(event, setFocus)
+			}
+			return
+		}
+		if handler := t.table.InputHandler(); handler != nil {
+			handler(event, setFocus)
+		}
+	})
+}
+
+// SetDoneFunc sets the handler called when the inspector is closed
+func (t *TableInspector) SetDoneFunc(handler func()) *TableInspector {
+	t.doneHandler = handler
+	return t
+}
+
+// SetRect sets rects for this primitive, the inspector covers the given area
+func (t *TableInspector) SetRect(x, y, width, height int) {
+	t.Box.SetRect(x, y, width, height)
+	t.layout.SetRect(x, y, width, height)
+}
+
+// Draw draws this primitive onto the screen
+func (t *TableInspector) Draw(screen tcell.Screen) {
+	if !t.display {
+		return
+	}
+
+	t.layout.Draw(screen)
+}
+
+// inspectTable shows the structure of a table selected in the tree
+func (d *Database) inspectTable(database, schema, table string) {
+	if !d.canExecute() {
+		return
+	}
+	d.useDatabase(database, func() {
+		d.describeTable(database, schema, table)
+	})
+}
+
+// describeTable loads the structure of a table in the background and shows
+// it in the inspector, which takes the focus
+func (d *Database) describeTable(database, schema, table string) {
+	var info *db.TableInfo
+	ctx, cancel := d.statementContext()
+	d.runTask(ctx, cancel, fmt.Sprintf("Loading structure of %s...", tview.Escape(table)), func(ctx context.Context) error {
+		var err error
+		info, err = d.driver.DescribeTable(ctx, database, schema, table)
+		return err
+	}, func(err error) {
+		if err != nil {
+			d.showError(fmt.Sprintf("Failed to describe table: %v", err))
+			return
+		}
+
+		d.inspector.SetTable(info)
+		d.inspector.Display()
+		if d.appFocusHandler != nil {
+			d.appFocusHandler()
+		}
+		d.updateStatusBar(fmt.Sprintf("Table %s: %d columns, %d indexes", tview.Escape(info.QualifiedName()), len(info.Columns), len(info.Indexes)))
+	})
+}
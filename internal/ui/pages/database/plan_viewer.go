@@ -0,0 +1,389 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/gdamore/tcell/v2"
+	"github.com/rivo/tview"
+	"github.com/shangyanjin/gocmder/internal/db"
+	"github.com/shangyanjin/gocmder/internal/ui/style"
+	"github.com/shangyanjin/gocmder/internal/ui/utils"
+)
+
+const (
+	// costlyShare is the share of the plan cost or time from which a node
+	// is highlighted
+	costlyShare = 0.1
+	// planDetailsHeight is the height of the properties of the selected node
+	planDetailsHeight = 6
+)
+
+// PlanViewer shows the plan of a statement as a collapsible tree. The
+// nodes taking the largest share of the cost, or of the time if the plan
+// was analyzed, are highlighted.
+type PlanViewer struct {
+	*tview.Box
+
+	layout         *tview.Flex
+	tree           *tview.TreeView
+	details        *tview.TextView
+	hint           *tview.TextView
+	statement      db.Statement
+	dialect        db.Dialect
+	display        bool
+	doneHandler    func()
+	analyzeHandler func(statement db.Statement)
+}
+
+// NewPlanViewer returns a new plan viewer primitive
+func NewPlanViewer() *PlanViewer {
+	bgColor := style.DialogBgColor
+
+	tree := tview.NewTreeView()
+	tree.SetBackgroundColor(bgColor)
+	tree.SetGraphicsColor(style.BorderColor)
+
+	details := tview.NewTextView()
+	details.SetBackgroundColor(style.InfoBarBgColor)
+	details.SetTextColor(style.FgColor)
+	details.SetDynamicColors(true)
+	details.SetScrollable(true)
+
+	hint := tview.NewTextView()
+	hint.SetBackgroundColor(bgColor)
+	hint.SetTextColor(style.FgColor)
+	hint.SetDynamicColors(true)
+
+	layout := tview.NewFlex().SetDirection(tview.FlexRow)
+	layout.AddItem(tree, 0, 1, true)
+	layout.AddItem(details, planDetailsHeight, 0, false)
+	layout.AddItem(hint, 1, 0, false)
+	layout.SetBorder(true)
+	layout.SetTitleColor(style.FgColor)
+	layout.SetBorderColor(style.DialogBorderColor)
+	layout.SetBackgroundColor(bgColor)
+
+	viewer := &PlanViewer{
+		Box:     tview.NewBox(),
+		layout:  layout,
+		tree:    tree,
+		details: details,
+		hint:    hint,
+		display: false,
+	}
+
+	tree.SetSelectedFunc(func(node *tview.TreeNode) {
+		node.SetExpanded(!node.IsExpanded())
+	})
+	tree.SetChangedFunc(viewer.showDetails)
+
+	return viewer
+}
+
+// Display displays this primitive
+func (p *PlanViewer) Display() {
+	p.display = true
+}
+
+// IsDisplay returns true if primitive is shown
+func (p *PlanViewer) IsDisplay() bool {
+	return p.display
+}
+
+// Hide stops displaying this primitive
+func (p *PlanViewer) Hide() {
+	p.display = false
+}
+
+// SetPlan shows the plan of a statement
+func (p *PlanViewer) SetPlan(statement db.Statement, dialect db.Dialect, plan *db.Plan) {
+	p.statement = statement
+	p.dialect = dialect
+
+	title := " Query Plan "
+	if plan.Analyzed {
+		title = " Query Plan (ANALYZE) "
+	}
+	p.layout.SetTitle(title)
+
+	highlightColor := style.GetColorHex(style.StatusInstalledColor)
+	hint := " [" + highlightColor + "]Enter[-] Expand/collapse"
+	if dialect == db.DialectPostgres {
+		hint += " | [" + highlightColor + "]a[-] Analyze"
+	}
+	p.hint.SetText(hint + " | [" + highlightColor + "]ESC[-] Close")
+
+	// The share of a node is its own time if the statement was run,
+	// otherwise its own estimated cost
+	weight := (*db.PlanNode).SelfCost
+	if plan.Analyzed {
+		weight = (*db.PlanNode).SelfTime
+	}
+	var total, costliest float64
+	plan.Walk(func(node *db.PlanNode) {
+		total += weight(node)
+		costliest = max(costliest, weight(node))
+	})
+
+	var summary []string
+	if len(plan.Nodes) == 1 && plan.Nodes[0].HasCost {
+		summary = append(summary, fmt.Sprintf("cost %.2f", plan.Nodes[0].Cost))
+	}
+	if plan.PlanningTime > 0 {
+		summary = append(summary, fmt.Sprintf("planning %.3f ms", plan.PlanningTime))
+	}
+	if plan.Analyzed {
+		summary = append(summary, fmt.Sprintf("execution %.3f ms", plan.ExecutionTime))
+	}
+	rootText := "Plan"
+	if len(summary) > 0 {
+		rootText += ": " + strings.Join(summary, ", ")
+	}
+	root := tview.NewTreeNode(rootText)
+	root.SetColor(style.PageHeaderFgColor)
+
+	var add func(parent *tview.TreeNode, nodes []*db.PlanNode)
+	add = func(parent *tview.TreeNode, nodes []*db.PlanNode) {
+		for _, node := range nodes {
+			share := 0.0
+			if total > 0 {
+				share = weight(node) / total
+			}
+			item := tview.NewTreeNode(planNodeText(node, share))
+			item.SetReference(node)
+			item.SetColor(style.DialogFgColor)
+			switch {
+			case share >= costlyShare && weight(node) == costliest:
+				item.SetColor(style.StatusErrorColor)
+			case share >= costlyShare:
+				item.SetColor(style.StatusNotInstalledColor)
+			}
+			parent.AddChild(item)
+			add(item, node.Children)
+		}
+	}
+	add(root, plan.Nodes)
+
+	p.tree.SetRoot(root)
+	p.tree.SetCurrentNode(root)
+	p.showDetails(root)
+}
+
+// planNodeText returns the line of a plan node with its estimates, the
+// actual figures and its share of the plan
+func planNodeText(node *db.PlanNode, share float64) string {
+	text := tview.Escape(node.Operation)
+	if node.Detail != "" {
+		text += " " + tview.Escape(node.Detail)
+	}
+
+	var figures []string
+	if node.HasCost {
+		figures = append(figures, fmt.Sprintf("cost %.2f", node.Cost))
+		figures = append(figures, fmt.Sprintf("rows %.0f", node.Rows))
+	}
+	if node.HasActual {
+		actual := fmt.Sprintf("actual %.3f ms, rows %.0f", node.ActualTime, node.ActualRows)
+		if node.Loops != 1 {
+			actual += fmt.Sprintf(" x %.0f loops", node.Loops)
+		}
+		figures = append(figures, actual)
+	}
+	if share > 0 {
+		figures = append(figures, fmt.Sprintf("%.0f%%", share*100))
+	}
+	if len(figures) > 0 {
+		text += "  [" + style.GetColorHex(style.DialogBorderColor) + "]" + strings.Join(figures, " | ") + "[-]"
+	}
+	return text
+}
+
+// showDetails shows the properties of the selected node
+func (p *PlanViewer) showDetails(item *tview.TreeNode) {
+	node, ok := item.GetReference().(*db.PlanNode)
+	if !ok {
+		p.details.SetText(" " + tview.Escape(strings.Join(strings.Fields(p.statement.Text), " ")))
+		return
+	}
+
+	highlightColor := style.GetColorHex(style.StatusSelectedColor)
+	var text strings.Builder
+	if len(node.Properties) == 0 {
+		text.WriteString(" (no details)")
+	}
+	for _, property := range node.Properties {
+		fmt.Fprintf(&text, " [%s]%s:[-] %s\n", highlightColor, tview.Escape(property.Name),
+			tview.Escape(strings.Join(strings.Fields(property.Value), " ")))
+	}
+	p.details.SetText(strings.TrimSuffix(text.String(), "\n"))
+	p.details.ScrollToBeginning()
+}
+
+// HasFocus returns whether or not this primitive has focus
+func (p *PlanViewer) HasFocus() bool {
+	return p.display && (p.layout.HasFocus() || p.Box.HasFocus())
+}
+
+// Focus is called when this primitive receives focus
+func (p *PlanViewer) Focus(delegate func(p tview.Primitive)) {
+	delegate(p.tree)
+}
+
+// InputHandler returns input handler function for this primitive
+func (p *PlanViewer) InputHandler() func(event *tcell.EventKey, setFocus func(p tview.Primitive)) {
+	return p.WrapInputHandler(func(event *tcell.EventKey, setFocus func(p tview.Primitive)) {
+		if event.Key() == utils.CloseDialogKey.Key {
+			if p.doneHandler != nil {
+				p.doneHandler()
+			}
+			return
+		}
+
+		if event.Key() == tcell.KeyRune && event.Rune() == 'a' && p.dialect == db.DialectPostgres {
+			if p.analyzeHandler != nil {
+				p.analyzeHandler(p.statement)
+			}
+			return
+		}
+
+		if handler := p.tree.InputHandler(); handler != nil {
+			handler(event, setFocus)
+		}
+	})
+}
+
+// SetDoneFunc sets the handler called when the viewer is closed
+func (p *PlanViewer) SetDoneFunc(handler func()) *PlanViewer {
+	p.doneHandler = handler
+	return p
+}
+
+// SetAnalyzeFunc sets the handler called to explain the statement again
+// with ANALYZE
+func (p *PlanViewer) SetAnalyzeFunc(handler func(statement db.Statement)) *PlanViewer {
+	p.analyzeHandler = handler
+	return p
+}
+
+// SetRect sets rects for this primitive, the viewer covers the given area
+func (p *PlanViewer) SetRect(x, y, width, height int) {
+	p.Box.SetRect(x, y, width, height)
+	p.layout.SetRect(x, y, width, height)
+}
+
+// Draw draws this primitive onto the screen
+func (p *PlanViewer) Draw(screen tcell.Screen) {
+	if !p.display {
+		return
+	}
+
+	p.layout.Draw(screen)
+}
+
+// explainQuery shows the plan of the selected statement, or of the
+// statement under the cursor
+func (d *Database) explainQuery() {
+	if !d.canExecute() {
+		return
+	}
+
+	statements := d.editorStatements()
+	switch {
+	case len(statements) == 0:
+		d.updateStatusBar("Nothing to explain")
+	case len(statements) > 1:
+		d.updateStatusBar("Select a single statement to explain")
+	default:
+		d.explainStatement(statements[0], false)
+	}
+}
+
+// analyzeStatement explains a statement again with ANALYZE, which runs it.
+// Statements other than queries are confirmed first.
+func (d *Database) analyzeStatement(statement db.Statement) {
+	if !d.canExecute() {
+		return
+	}
+
+	dialect := d.driver.GetDialect()
+	if db.Classify(dialect, statement.Text) == db.StatementQuery {
+		d.explainStatement(statement, true)
+		return
+	}
+	d.confirm("Explain analyze", "EXPLAIN ANALYZE runs the statement and keeps its changes. Continue?", func() {
+		d.explainStatement(statement, true)
+	})
+}
+
+// explainStatement reads the plan of a statement in the background and
+// shows it in the plan viewer
+func (d *Database) explainStatement(statement db.Statement, analyze bool) {
+	ctx, cancel := d.statementContext()
+	run := &runningQuery{
+		ctx:     ctx,
+		cancel:  cancel,
+		started: time.Now(),
+		done:    make(chan struct{}),
+		total:   1,
+	}
+	d.running = run
+	d.updateStatusBar("Explaining query... (Esc/Ctrl+C to cancel)")
+
+	driver := d.driver
+	go func() {
+		plan, err := db.Explain(ctx, driver, statement, analyze)
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			run.timedOut.Store(true)
+		}
+
+		d.queueUpdateDraw(func() {
+			d.finishExplain(run, statement, driver.GetDialect(), plan, err)
+		})
+	}()
+	go d.showElapsed(run)
+}
+
+// finishExplain shows the plan read by explainStatement
+func (d *Database) finishExplain(run *runningQuery, statement db.Statement, dialect db.Dialect, plan *db.Plan, err error) {
+	close(run.done)
+	run.cancel()
+
+	// The query was replaced by a reconnect or disconnect
+	if d.running != run {
+		return
+	}
+	d.running = nil
+
+	elapsed := time.Since(run.started).Round(time.Millisecond)
+	if err != nil {
+		switch {
+		case run.canceled:
+			d.updateStatusBar(fmt.Sprintf("Explain cancelled after %s", elapsed))
+		case run.timedOut.Load():
+			d.updateStatusBar(fmt.Sprintf("Explain timed out after %s", elapsed))
+			d.showError(fmt.Sprintf("Explain timed out after %s", d.timeout))
+		default:
+			d.updateStatusBar(fmt.Sprintf("Explain failed after %s", elapsed))
+			d.showError(fmt.Sprintf("Explain error: %v", err))
+		}
+		if d.errorDialog.IsDisplay() && d.HasFocus() && d.appFocusHandler != nil {
+			d.appFocusHandler()
+		}
+		return
+	}
+
+	steps := 0
+	plan.Walk(func(*db.PlanNode) {
+		steps++
+	})
+	d.planViewer.SetPlan(statement, dialect, plan)
+	d.planViewer.Display()
+	d.updateStatusBar(fmt.Sprintf("Plan of %d steps read in %s", steps, elapsed))
+	if d.HasFocus() && d.appFocusHandler != nil {
+		d.appFocusHandler()
+	}
+}
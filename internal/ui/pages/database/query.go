@@ -0,0 +1,316 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/rivo/tview"
+	"github.com/shangyanjin/gocmder/internal/db"
+)
+
+const (
+	// connectTimeout limits opening and pinging a connection
+	connectTimeout = 15 * time.Second
+	// elapsedInterval is how often the status bar shows the running query time
+	elapsedInterval = 100 * time.Millisecond
+)
+
+// runningQuery is a query executing in the background
+type runningQuery struct {
+	ctx      context.Context
+	cancel   context.CancelFunc
+	started  time.Time
+	done     chan struct{}
+	canceled bool
+	timedOut atomic.Bool
+	total    int          // number of statements
+	current  atomic.Int32 // statement being executed, starting at 1
+	note     string       // shown before the status of the result
+	// discard runs on the UI goroutine instead of the done callback of a
+	// task that is cancelled or discarded, see runTask
+	discard func()
+}
+
+// statementContext returns a context limited by the session statement timeout
+func (d *Database) statementContext() (context.Context, context.CancelFunc) {
+	if d.timeout > 0 {
+		return context.WithTimeout(context.Background(), d.timeout)
+	}
+	return context.WithCancel(context.Background())
+}
+
+// executeQuery runs the selected text, or the statement under the cursor if
+// nothing is selected
+func (d *Database) executeQuery() {
+	if !d.canExecute() || d.confirmDiscard(d.executeQuery) {
+		return
+	}
+
+	d.runStatements(d.editorStatements())
+}
+
+// editorStatements returns the statements of the selected text, or the
+// statement under the cursor if nothing is selected
+func (d *Database) editorStatements() []db.Statement {
+	dialect := d.driver.GetDialect()
+	selection, start, _ := d.sqlEditor.GetSelection()
+	if strings.TrimSpace(selection) != "" {
+		return db.Split(dialect, selection)
+	}
+
+	statements := db.Split(dialect, d.sqlEditor.GetText())
+	if i := db.StatementAt(statements, start); i >= 0 {
+		statements = statements[i : i+1]
+	}
+	return statements
+}
+
+// executeScript runs all statements of the editor one after another
+func (d *Database) executeScript() {
+	if !d.canExecute() || d.confirmDiscard(d.executeScript) {
+		return
+	}
+
+	d.runStatements(db.Split(d.driver.GetDialect(), d.sqlEditor.GetText()))
+}
+
+// canExecute returns true if a statement can be run, otherwise it tells the user why not
+func (d *Database) canExecute() bool {
+	if !d.connected || d.driver == nil {
+		d.showError("Not connected to database")
+		return false
+	}
+
+	if d.running != nil {
+		d.updateStatusBar("A query is already running. Press Esc or Ctrl+C to cancel it")
+		return false
+	}
+	return true
+}
+
+// runStatements runs statements in the background, a single statement
+// streams its rows while a script shows each result in its own tab
+func (d *Database) runStatements(statements []db.Statement) {
+	if len(statements) == 0 {
+		d.updateStatusBar("Nothing to execute")
+		return
+	}
+
+	// The rows of the previous results are no longer fetched
+	d.closeTabs()
+
+	if len(statements) > 1 {
+		d.runScript(statements)
+		return
+	}
+
+	d.runQuery(statements[0], "")
+}
+
+// runQuery runs a single statement in the background and streams its rows,
+// note is shown before the status of the result
+func (d *Database) runQuery(statement db.Statement, note string) {
+	// The context lives as long as the result cursor, so the statement
+	// timeout only applies until the first page has been fetched
+	ctx, cancel := context.WithCancel(context.Background())
+	run := &runningQuery{
+		ctx:     ctx,
+		cancel:  cancel,
+		started: time.Now(),
+		done:    make(chan struct{}),
+		total:   1,
+		note:    note,
+	}
+	d.running = run
+	d.updateStatusBar("Executing query... (Esc/Ctrl+C to cancel)")
+
+	var timer *time.Timer
+	if d.timeout > 0 {
+		timer = time.AfterFunc(d.timeout, func() {
+			run.timedOut.Store(true)
+			cancel()
+		})
+	}
+
+	driver := d.driver
+	pageSize := min(gridPageSize, d.rowLimit)
+	go func() {
+		var rows [][]db.Value
+		result, err := db.OpenStatement(ctx, driver, statement)
+		if err == nil && result.Cursor != nil {
+			rows, err = result.Cursor.Fetch(pageSize)
+		}
+		if timer != nil {
+			timer.Stop()
+		}
+
+		d.queueUpdateDraw(func() {
+			d.finishQuery(run, statement, result, rows, err)
+		})
+	}()
+	go d.showElapsed(run)
+}
+
+// showElapsed updates the status bar with the running time until the query ends
+func (d *Database) showElapsed(run *runningQuery) {
+	ticker := time.NewTicker(elapsedInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-run.done:
+			return
+		case <-ticker.C:
+			d.queueUpdateDraw(func() {
+				if d.running != run {
+					return
+				}
+				elapsed := time.Since(run.started).Truncate(elapsedInterval)
+				if run.total > 1 {
+					d.updateStatusBar(fmt.Sprintf("Executing statement %d/%d... %s (Esc/Ctrl+C to cancel)", run.current.Load(), run.total, elapsed))
+					return
+				}
+				d.updateStatusBar(fmt.Sprintf("Executing query... %s (Esc/Ctrl+C to cancel)", elapsed))
+			})
+		}
+	}
+}
+
+// finishQuery displays the first page of a background query
+func (d *Database) finishQuery(run *runningQuery, statement db.Statement, result *db.QueryResult, rows [][]db.Value, err error) {
+	close(run.done)
+
+	// The query was replaced by a reconnect or disconnect
+	if d.running != run {
+		run.cancel()
+		if result != nil && result.Cursor != nil {
+			go result.Cursor.Close()
+		}
+		return
+	}
+	d.running = nil
+
+	elapsed := time.Since(run.started).Round(time.Millisecond)
+	tab := &resultTab{statement: statement, result: result, elapsed: elapsed}
+	if err != nil {
+		run.cancel()
+		if result != nil && result.Cursor != nil {
+			go result.Cursor.Close()
+		}
+
+		switch {
+		case run.canceled:
+			tab.err = fmt.Errorf("cancelled after %s", elapsed)
+			tab.status = fmt.Sprintf("Query cancelled after %s", elapsed)
+		case run.timedOut.Load():
+			tab.err = fmt.Errorf("timed out after %s", d.timeout)
+			tab.status = fmt.Sprintf("Query timed out after %s", elapsed)
+			d.showError(fmt.Sprintf("Query timed out after %s", d.timeout))
+		default:
+			tab.err = err
+			tab.status = fmt.Sprintf("Query failed after %s", elapsed)
+			d.showError(fmt.Sprintf("Query error: %v", err))
+		}
+		d.addTab(tab)
+		d.recordHistory(tab)
+
+		// Move focus to the error dialog unless the user left the page meanwhile
+		if d.errorDialog.IsDisplay() && d.HasFocus() && d.appFocusHandler != nil {
+			d.appFocusHandler()
+		}
+		return
+	}
+
+	if result.Cursor == nil {
+		run.cancel()
+		tab.status = run.note + resultStatus(fmt.Sprintf("Query executed in %s. Rows: %d", elapsed, result.RowsAffected), result.Notices)
+		d.addTab(tab)
+		d.recordHistory(tab)
+		return
+	}
+
+	tab.grid = newResultGrid(result, rows, run.cancel, d.rowLimit)
+	tab.grid.fetchMore = d.fetchMore
+	tab.status = run.note + resultStatus(fmt.Sprintf("Query executed in %s. Rows: %s", elapsed, tab.grid.status()), tab.grid.cursor.Notices())
+	d.addTab(tab)
+	d.recordHistory(tab)
+}
+
+// fetchMore fetches the next page of a grid in the background
+func (d *Database) fetchMore(grid *resultGrid) {
+	cursor := grid.cursor
+	size := grid.pageSize()
+
+	go func() {
+		rows, err := cursor.Fetch(size)
+		d.queueUpdateDraw(func() {
+			if d.grid != grid {
+				return
+			}
+
+			grid.append(rows, err)
+			d.updateGridTitle()
+			if err != nil {
+				d.updateStatusBar(fmt.Sprintf("Failed to fetch rows: %s", tview.Escape(err.Error())))
+				return
+			}
+			if cursor.Done() {
+				d.updateStatusBar(resultStatus(fmt.Sprintf("Fetched all %d rows", len(grid.rows)), cursor.Notices()))
+			}
+		})
+	}()
+}
+
+// resultStatus appends the last notice of a result to a status message
+func resultStatus(message string, notices []string) string {
+	if n := len(notices); n > 0 {
+		message += fmt.Sprintf(" | %d notice(s): %s", n, tview.Escape(notices[n-1]))
+	}
+	return message
+}
+
+// displayGrid shows a paged result in the result table
+func (d *Database) displayGrid(grid *resultGrid) {
+	d.grid = grid
+	d.resultTable.SetContent(grid)
+	d.resultTable.Select(1, 0)
+	d.resultTable.ScrollToBeginning()
+	d.updateGridTitle()
+}
+
+// updateGridTitle shows the fetch indicator in the result table title
+func (d *Database) updateGridTitle() {
+	if d.grid == nil {
+		d.resultTable.SetTitle(" Query Results ")
+		return
+	}
+	if pending := d.grid.edits.pending(); pending > 0 {
+		d.resultTable.SetTitle(fmt.Sprintf(" Query Results (%s | %d pending, Ctrl+S: apply) ", d.grid.status(), pending))
+		return
+	}
+	d.resultTable.SetTitle(fmt.Sprintf(" Query Results (%s) ", d.grid.status()))
+}
+
+// cancelQuery cancels the running query
+func (d *Database) cancelQuery() {
+	if d.running == nil {
+		return
+	}
+
+	d.running.canceled = true
+	d.running.cancel()
+	d.updateStatusBar("Cancelling query...")
+}
+
+// abortQuery cancels the running query and discards its result
+func (d *Database) abortQuery() {
+	if d.running == nil {
+		return
+	}
+
+	d.running.cancel()
+	d.running = nil
+}
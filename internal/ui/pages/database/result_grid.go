@@ -0,0 +1,266 @@
+package database
+
+import (
+	"context"
+	"encoding/hex"
+	"fmt"
+	"strings"
+
+	"github.com/gdamore/tcell/v2"
+	"github.com/rivo/tview"
+	"github.com/shangyanjin/gocmder/internal/db"
+	"github.com/shangyanjin/gocmder/internal/ui/style"
+)
+
+const (
+	// gridPageSize is the number of rows fetched at once
+	gridPageSize = 200
+	// gridPrefetchRows starts fetching the next page this close to the last fetched row
+	gridPrefetchRows = 50
+	// maxCellWidth limits the width of a result column, the detail popup shows full values
+	maxCellWidth = 60
+	// binaryPreviewBytes is the number of bytes shown for binary values
+	binaryPreviewBytes = 8
+)
+
+// resultGrid is a virtual table content that fetches the rows of a cursor
+// page by page as the table scrolls towards the end
+type resultGrid struct {
+	tview.TableContentReadOnly
+
+	columns  []db.Column
+	headers  []*tview.TableCell
+	rows     [][]db.Value
+	cursor   *db.Cursor
+	cancel   context.CancelFunc
+	limit    int
+	limited  bool
+	fetching bool
+	err      error
+	edits    *gridEdits // pending changes, nil until the first edit
+	readOnly string     // why the rows cannot be edited, once checked
+
+	// styleCell colors the cells of generated rows, e.g. of a comparison
+	styleCell func(index, column int, cell *tview.TableCell)
+
+	// fetchMore is called on the UI goroutine when the next page is needed
+	fetchMore func(g *resultGrid)
+}
+
+// newResultGrid creates a grid over the first page of an opened result
+func newResultGrid(result *db.QueryResult, rows [][]db.Value, cancel context.CancelFunc, limit int) *resultGrid {
+	grid := &resultGrid{
+		columns: result.Columns,
+		cursor:  result.Cursor,
+		cancel:  cancel,
+		limit:   limit,
+	}
+
+	for _, column := range result.Columns {
+		grid.headers = append(grid.headers, headerCell(column))
+	}
+
+	grid.append(rows, nil)
+	return grid
+}
+
+// GetCell returns the cell at the given position
+func (g *resultGrid) GetCell(row, column int) *tview.TableCell {
+	if row == 0 {
+		if column < len(g.headers) {
+			return g.headers[column]
+		}
+		return nil
+	}
+
+	index := row - 1
+	if column >= len(g.columns) {
+		return nil
+	}
+	if index >= len(g.rows) {
+		if g.edits != nil && index < len(g.rows)+len(g.edits.inserts) {
+			return g.edits.insertedCell(g.columns[column], index-len(g.rows), column)
+		}
+		return nil
+	}
+
+	// Fetch the next page before the user reaches the end
+	if index >= len(g.rows)-gridPrefetchRows {
+		g.requestMore()
+	}
+
+	if g.edits != nil {
+		return g.edits.rowCell(g.columns[column], g.rows[index][column], index, column)
+	}
+	cell := valueCell(g.columns[column], g.rows[index][column])
+	if g.styleCell != nil {
+		g.styleCell(index, column, cell)
+	}
+	return cell
+}
+
+// GetRowCount returns the number of fetched and inserted rows plus the header
+func (g *resultGrid) GetRowCount() int {
+	if g.edits != nil {
+		return len(g.rows) + len(g.edits.inserts) + 1
+	}
+	return len(g.rows) + 1
+}
+
+// value returns the current value of a cell, including pending changes.
+// Unset values of inserted rows are not known.
+func (g *resultGrid) value(index, column int) (db.Value, bool) {
+	if index < 0 || column < 0 || column >= len(g.columns) {
+		return db.Value{}, false
+	}
+	if index < len(g.rows) {
+		if g.edits != nil {
+			if value, ok := g.edits.updates[index][column]; ok {
+				return value, true
+			}
+		}
+		return g.rows[index][column], true
+	}
+	if g.edits != nil && index < len(g.rows)+len(g.edits.inserts) {
+		value, ok := g.edits.inserts[index-len(g.rows)][column]
+		return value, ok
+	}
+	return db.Value{}, false
+}
+
+// GetColumnCount returns the number of columns
+func (g *resultGrid) GetColumnCount() int {
+	return len(g.columns)
+}
+
+// hasMore returns true if more rows can be fetched
+func (g *resultGrid) hasMore() bool {
+	return g.cursor != nil && !g.cursor.Done() && !g.limited && g.err == nil
+}
+
+// requestMore asks for the next page unless one is being fetched
+func (g *resultGrid) requestMore() {
+	if g.fetching || !g.hasMore() || g.fetchMore == nil {
+		return
+	}
+
+	g.fetching = true
+	g.fetchMore(g)
+}
+
+// pageSize returns the number of rows to fetch next without exceeding the limit
+func (g *resultGrid) pageSize() int {
+	if remaining := g.limit - len(g.rows); remaining < gridPageSize {
+		return remaining
+	}
+	return gridPageSize
+}
+
+// append adds a fetched page, closing the cursor once the row limit is reached
+func (g *resultGrid) append(rows [][]db.Value, err error) {
+	g.fetching = false
+	g.rows = append(g.rows, rows...)
+	if err != nil {
+		g.err = err
+	}
+
+	if g.cursor == nil {
+		return
+	}
+	if len(g.rows) >= g.limit && !g.cursor.Done() {
+		g.limited = true
+		g.close()
+	}
+	if g.cursor.Done() && g.cancel != nil {
+		g.cancel()
+	}
+}
+
+// close stops the query and releases the cursor without blocking the UI
+func (g *resultGrid) close() {
+	if g.cancel != nil {
+		g.cancel()
+	}
+	if g.cursor != nil {
+		go g.cursor.Close()
+	}
+}
+
+// status returns the fetch indicator shown in the result title
+func (g *resultGrid) status() string {
+	switch {
+	case g.err != nil:
+		return fmt.Sprintf("fetched %d / fetch failed", len(g.rows))
+	case g.limited:
+		return fmt.Sprintf("fetched %d / row limit reached", len(g.rows))
+	case g.hasMore():
+		return fmt.Sprintf("fetched %d / more available", len(g.rows))
+	}
+	return fmt.Sprintf("%d rows", len(g.rows))
+}
+
+// headerCell returns the header cell of a result column
+func headerCell(column db.Column) *tview.TableCell {
+	cell := tview.NewTableCell(strings.ToUpper(column.Name))
+	cell.SetExpansion(1)
+	cell.SetBackgroundColor(style.PageHeaderBgColor)
+	cell.SetTextColor(style.PageHeaderFgColor)
+	cell.SetAlign(tview.AlignLeft)
+	cell.SetSelectable(false)
+	if column.IsNumeric() {
+		cell.SetAlign(tview.AlignRight)
+	}
+	return cell
+}
+
+// valueCell returns the table cell of a result value. NULL is dimmed,
+// binary data is shown as a hex prefix with its size and numbers are right aligned.
+func valueCell(column db.Column, value db.Value) *tview.TableCell {
+	cell := tview.NewTableCell("")
+	cell.SetTextColor(style.FgColor)
+	cell.SetAlign(tview.AlignLeft)
+	cell.SetMaxWidth(maxCellWidth)
+
+	switch {
+	case value.Null:
+		cell.SetText("NULL")
+		cell.SetTextColor(style.BorderColor)
+		cell.SetAttributes(tcell.AttrItalic)
+	case value.Kind == db.ValueBinary:
+		cell.SetText(tview.Escape(binaryPreview(value.Bytes)))
+		cell.SetTextColor(style.StatusSelectedColor)
+	case value.Kind == db.ValueNumber:
+		cell.SetText(value.Text)
+		cell.SetAlign(tview.AlignRight)
+	default:
+		// Keep multi-line values on one row
+		cell.SetText(tview.Escape(strings.NewReplacer("\r\n", "↵", "\n", "↵", "\t", " ").Replace(value.Text)))
+	}
+
+	return cell
+}
+
+// binaryPreview returns the first bytes of binary data as hex with a size badge
+func binaryPreview(data []byte) string {
+	preview := data
+	if len(preview) > binaryPreviewBytes {
+		preview = preview[:binaryPreviewBytes]
+	}
+
+	text := "0x" + hex.EncodeToString(preview)
+	if len(data) > binaryPreviewBytes {
+		text += "…"
+	}
+	return fmt.Sprintf("%s [%s]", text, formatSize(len(data)))
+}
+
+// formatSize formats a byte count as B, KB or MB
+func formatSize(size int) string {
+	switch {
+	case size >= 1<<20:
+		return fmt.Sprintf("%.1f MB", float64(size)/(1<<20))
+	case size >= 1<<10:
+		return fmt.Sprintf("%.1f KB", float64(size)/(1<<10))
+	}
+	return fmt.Sprintf("%d B", size)
+}
@@ -0,0 +1,178 @@
+package database
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gdamore/tcell/v2"
+	"github.com/rivo/tview"
+	"github.com/shangyanjin/gocmder/internal/db"
+	"github.com/shangyanjin/gocmder/internal/ui/style"
+)
+
+const (
+	// tabLabelLength limits the statement text shown in a result tab
+	tabLabelLength = 24
+	// errorStatementLines limits the statement lines shown below an error
+	errorStatementLines = 20
+)
+
+// resultTab is the result or error of one executed statement
+type resultTab struct {
+	statement db.Statement
+	result    *db.QueryResult
+	grid      *resultGrid
+	err       error
+	elapsed   time.Duration
+	status    string         // status bar message shown with the tab
+	diff      *db.ResultDiff // set for the comparison of two results of statement
+}
+
+// addTab adds the result of a statement and shows it
+func (d *Database) addTab(tab *resultTab) {
+	// Completion offers the new tables and columns after a schema change
+	if d.driver != nil && db.ChangesSchema(d.driver.GetDialect(), tab.statement.Text) {
+		d.invalidateMetadata()
+	}
+
+	d.tabs = append(d.tabs, tab)
+	d.showTab(len(d.tabs) - 1)
+}
+
+// showTab shows the result tab at index
+func (d *Database) showTab(index int) {
+	if index < 0 || index >= len(d.tabs) {
+		return
+	}
+
+	tab := d.tabs[index]
+	d.activeTab = index
+
+	switch {
+	case tab.grid != nil:
+		d.displayGrid(tab.grid)
+	case tab.err != nil:
+		d.displayError(tab.statement, tab.err)
+	default:
+		d.displayResult(tab.result)
+	}
+
+	d.updateTabBar()
+	d.updateStatusBar(tab.status)
+}
+
+// closeTabs stops fetching the rows of all results and removes their tabs
+func (d *Database) closeTabs() {
+	for _, tab := range d.tabs {
+		if tab.grid != nil {
+			tab.grid.close()
+		}
+	}
+
+	d.tabs = nil
+	d.activeTab = 0
+	d.grid = nil
+	d.updateTabBar()
+}
+
+// updateTabBar shows the result tabs above the result table if a script produced more than one
+func (d *Database) updateTabBar() {
+	if len(d.tabs) < 2 {
+		d.tabBar.Clear()
+		d.rightPanel.ResizeItem(d.tabBar, 0, 0)
+		return
+	}
+
+	okColor := style.GetColorHex(style.StatusInstalledColor)
+	errorColor := style.GetColorHex(style.StatusErrorColor)
+
+	labels := make([]string, len(d.tabs))
+	for i, tab := range d.tabs {
+		label := tabLabel(tab.statement.Text)
+		if tab.diff != nil {
+			label = "diff " + label
+		}
+		labels[i] = fmt.Sprintf(" %d %s ", i+1, label)
+	}
+
+	// Start with the tabs before the active one that fit next to it
+	_, _, width, _ := d.tabBar.GetInnerRect()
+	first := d.activeTab
+	used := len([]rune(labels[first]))
+	for first > 0 && used+len([]rune(labels[first-1]))+1 < width {
+		first--
+		used += len([]rune(labels[first])) + 1
+	}
+
+	var text strings.Builder
+	if first > 0 {
+		text.WriteString("…")
+	}
+	for i := first; i < len(d.tabs); i++ {
+		color := okColor
+		if d.tabs[i].err != nil {
+			color = errorColor
+		}
+		fmt.Fprintf(&text, `["%d"][%s]%s[-][""]`, i, color, tview.Escape(labels[i]))
+		if i < len(d.tabs)-1 {
+			text.WriteString("│")
+		}
+	}
+
+	d.rightPanel.ResizeItem(d.tabBar, 1, 0)
+	d.tabBar.SetText(text.String())
+	d.tabBar.Highlight(strconv.Itoa(d.activeTab))
+}
+
+// tabLabel returns the beginning of a statement on one line
+func tabLabel(text string) string {
+	label := []rune(strings.Join(strings.Fields(text), " "))
+	if len(label) > tabLabelLength {
+		return string(label[:tabLabelLength-1]) + "…"
+	}
+	return string(label)
+}
+
+// displayError shows the error of a statement in the result table
+func (d *Database) displayError(statement db.Statement, err error) {
+	d.grid = nil
+	d.resultTable.SetContent(nil)
+	d.updateGridTitle()
+
+	cell := tview.NewTableCell(tview.Escape(fmt.Sprintf("Error: %v", err)))
+	cell.SetTextColor(style.StatusErrorColor)
+	d.resultTable.SetCell(0, 0, cell)
+
+	cell = tview.NewTableCell(fmt.Sprintf("Statement at line %d:", statement.Line))
+	cell.SetTextColor(style.BorderColor)
+	d.resultTable.SetCell(2, 0, cell)
+
+	lines := strings.Split(statement.Text, "\n")
+	if len(lines) > errorStatementLines {
+		lines = append(lines[:errorStatementLines], "...")
+	}
+	for _, line := range lines {
+		cell := tview.NewTableCell(tview.Escape(line))
+		cell.SetTextColor(style.FgColor)
+		d.resultTable.SetCell(d.resultTable.GetRowCount(), 0, cell)
+	}
+}
+
+// handleResultKey switches result tabs, it returns true if the key was handled
+func (d *Database) handleResultKey(event *tcell.EventKey) bool {
+	if event.Key() != tcell.KeyRune || len(d.tabs) < 2 {
+		return false
+	}
+
+	switch event.Rune() {
+	case '[':
+		d.showTab((d.activeTab - 1 + len(d.tabs)) % len(d.tabs))
+		return true
+	case ']':
+		d.showTab((d.activeTab + 1) % len(d.tabs))
+		return true
+	}
+	return false
+}
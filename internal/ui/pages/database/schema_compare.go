@@ -0,0 +1,202 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/rivo/tview"
+	"github.com/shangyanjin/gocmder/internal/config"
+	"github.com/shangyanjin/gocmder/internal/db"
+)
+
+// schemaSide is one of the two databases of a schema comparison
+type schemaSide struct {
+	label  string // database and session, for the script header
+	tables []*db.TableInfo
+}
+
+// showSchemaCompare opens the schema compare dialog for the current database
+func (d *Database) showSchemaCompare() {
+	if !d.canExecute() {
+		return
+	}
+	if d.currentDatabase == "" && d.driver.GetDialect() != db.DialectSQLite {
+		d.updateStatusBar("Select the database to compare in the tree first")
+		return
+	}
+
+	sessions := d.compareSessions()
+	if len(sessions) == 0 {
+		d.updateStatusBar(fmt.Sprintf("Save a session of the other %s database to compare the schema with", d.driver.GetDriverName()))
+		return
+	}
+	d.schemaCompareDialog.Show(sessions)
+}
+
+// handleSchemaCompare compares the schema of the current database with
+// that of another session as chosen in the schema compare dialog
+func (d *Database) handleSchemaCompare(request schemaCompareRequest) {
+	if !d.canExecute() {
+		return
+	}
+	session, ok := d.sessions.Get(request.session)
+	if !ok {
+		d.showError(fmt.Sprintf("Session not found: %s", request.session))
+		return
+	}
+	if session.Database == "" && driverName(session.Driver) == "MySQL" {
+		d.showError(fmt.Sprintf("Session %s has no database to compare with", session.Name))
+		return
+	}
+
+	if fileDriver(session.Driver) {
+		d.runSchemaCompare(session, "", request.reverse)
+		return
+	}
+	d.resolvePassword(session, func(password string, ok bool) {
+		if ok {
+			d.runSchemaCompare(session, password, request.reverse)
+			return
+		}
+		d.promptSecret("Compare Schema", fmt.Sprintf("Password for %s: ", session.Name), func(password string) {
+			d.runSchemaCompare(session, password, request.reverse)
+		})
+	})
+}
+
+// runSchemaCompare reads the tables of both databases from the catalogs in
+// the background and loads the migration script into the editor
+func (d *Database) runSchemaCompare(session config.Session, password string, reverse bool) {
+	ctx, cancel := context.WithCancel(context.Background())
+	run := &runningQuery{
+		ctx:     ctx,
+		cancel:  cancel,
+		started: time.Now(),
+		done:    make(chan struct{}),
+		total:   1,
+	}
+	d.running = run
+	d.updateStatusBar("Reading schemas... (Esc/Ctrl+C to cancel)")
+
+	driver, database := d.driver, d.currentDatabase
+	here := &schemaSide{label: schemaLabel(database, d.currentSession)}
+	there := &schemaSide{}
+
+	go func() {
+		errs := make(chan error, 2)
+		go func() {
+			var err error
+			if here.tables, err = db.ReadSchema(ctx, driver, database); err != nil {
+				err = fmt.Errorf("%s: %w", here.label, err)
+			}
+			errs <- err
+		}()
+		go func() {
+			errs <- readSessionSchema(ctx, session, password, there)
+		}()
+
+		err := <-errs
+		if other := <-errs; err == nil {
+			err = other
+		}
+
+		d.queueUpdateDraw(func() {
+			current, wanted := here, there
+			if reverse {
+				current, wanted = there, here
+			}
+			d.finishSchemaCompare(run, driver.GetDialect(), current, wanted, reverse, err)
+		})
+	}()
+	go d.showElapsed(run)
+}
+
+// readSessionSchema connects to a session for the comparison only and reads
+// the tables of its database
+func readSessionSchema(ctx context.Context, session config.Session, password string, side *schemaSide) error {
+	driver, err := newDriver(session.Driver)
+	if err != nil {
+		return err
+	}
+	tun, err := connectDriver(ctx, driver, session, password)
+	if err != nil {
+		return fmt.Errorf("%s: connection failed: %w", session.Name, err)
+	}
+	defer closeDriver(driver, tun)
+
+	// PostgreSQL connects to the default database of the user without one
+	database := session.Database
+	if switcher, ok := driver.(db.DatabaseSwitcher); ok {
+		database = switcher.CurrentDatabase()
+	} else if driver.GetDialect() == db.DialectSQLite {
+		database = "main"
+	}
+	side.label = schemaLabel(database, session.Name)
+
+	if side.tables, err = db.ReadSchema(ctx, driver, database); err != nil {
+		return fmt.Errorf("%s: %w", side.label, err)
+	}
+	return nil
+}
+
+// schemaLabel names a database of a session
+func schemaLabel(database, session string) string {
+	if database == "" || database == "main" {
+		return session
+	}
+	return database + " (" + session + ")"
+}
+
+// finishSchemaCompare loads the script migrating current to wanted into the
+// editor, headed by what it does and what it leaves out. reverse tells that
+// current is the other database.
+func (d *Database) finishSchemaCompare(run *runningQuery, dialect db.Dialect, current, wanted *schemaSide, reverse bool, err error) {
+	close(run.done)
+	run.cancel()
+	if d.running != run {
+		return
+	}
+	d.running = nil
+
+	elapsed := time.Since(run.started).Round(time.Millisecond)
+	if err != nil {
+		if run.canceled {
+			d.updateStatusBar(fmt.Sprintf("Schema comparison cancelled after %s", elapsed))
+			return
+		}
+		d.showError(fmt.Sprintf("Schema comparison failed: %v", err))
+		if d.errorDialog.IsDisplay() && d.HasFocus() && d.appFocusHandler != nil {
+			d.appFocusHandler()
+		}
+		return
+	}
+
+	diff := db.DiffSchemas(dialect, current.tables, wanted.tables)
+	if diff.Empty() {
+		d.updateStatusBar(fmt.Sprintf("The schemas of %s and %s match (%d tables)",
+			tview.Escape(current.label), tview.Escape(wanted.label), len(wanted.tables)))
+		return
+	}
+
+	script := diff.Script()
+	var header strings.Builder
+	fmt.Fprintf(&header, "-- Migrates %s to match %s\n", current.label, wanted.label)
+	fmt.Fprintf(&header, "-- %s\n", diff.Summary())
+	header.WriteString("-- Views, sequences, functions and triggers are not compared\n")
+	for _, note := range diff.Notes {
+		fmt.Fprintf(&header, "-- Note: %s\n", note)
+	}
+
+	d.sqlEditor.Replace(0, len(d.sqlEditor.GetText()), header.String()+"\n"+script)
+	d.focusedElement = focusEditor
+	if reverse {
+		d.updateStatusBar(fmt.Sprintf("Migration script for %s loaded, run it there: %s", tview.Escape(current.label), diff.Summary()))
+	} else {
+		d.updateStatusBar(fmt.Sprintf("Migration script loaded: %s (Ctrl+Z restores the previous text)", diff.Summary()))
+	}
+	if d.HasFocus() && d.appFocusHandler != nil {
+		d.appFocusHandler()
+	}
+}
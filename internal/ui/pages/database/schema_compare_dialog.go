@@ -0,0 +1,217 @@
+package database
+
+import (
+	"fmt"
+	"slices"
+
+	"github.com/gdamore/tcell/v2"
+	"github.com/rivo/tview"
+	"github.com/shangyanjin/gocmder/internal/ui/style"
+	"github.com/shangyanjin/gocmder/internal/ui/utils"
+)
+
+const (
+	schemaSchemaCompareDialogWidth  = 64
+	schemaSchemaCompareDialogHeight = 10
+)
+
+// schemaDirections are the ways a schema comparison can migrate
+var schemaDirections = []string{"This database to match the other", "The other database to match this one"}
+
+// schemaCompareRequest is a schema comparison chosen in the schema compare dialog
+type schemaCompareRequest struct {
+	session string // session of the other database
+	reverse bool   // migrate the other database instead of this one
+}
+
+// SchemaCompareDialog asks which database the schema of the current one is
+// compared with and which of them the migration script is for
+type SchemaCompareDialog struct {
+	*tview.Box
+
+	layout          *tview.Flex
+	form            *tview.Form
+	display         bool
+	sessions        []string // sessions of the same driver
+	session         int
+	direction       int
+	compareFunc     func(request schemaCompareRequest)
+	appFocusHandler func()
+}
+
+// NewSchemaCompareDialog creates a new schema compare dialog
+func NewSchemaCompareDialog(compareFunc func(request schemaCompareRequest)) *SchemaCompareDialog {
+	bgColor := style.DialogBgColor
+
+	dialog := &SchemaCompareDialog{
+		Box:         tview.NewBox(),
+		compareFunc: compareFunc,
+	}
+
+	dialog.form = tview.NewForm()
+	dialog.form.SetBackgroundColor(bgColor)
+	dialog.form.SetButtonBackgroundColor(style.ButtonBgColor)
+	dialog.form.SetFieldBackgroundColor(style.BgColor)
+	dialog.form.SetLabelColor(style.FgColor)
+	dialog.form.SetFieldTextColor(style.FgColor)
+	dialog.form.SetButtonsAlign(tview.AlignCenter)
+
+	highlightColor := style.GetColorHex(style.StatusInstalledColor)
+	shortcutsHint := tview.NewTextView()
+	shortcutsHint.SetBackgroundColor(bgColor)
+	shortcutsHint.SetTextColor(style.FgColor)
+	shortcutsHint.SetDynamicColors(true)
+	shortcutsHint.SetText(" [" + highlightColor + "]Tab[-] Next field | [" + highlightColor + "]Enter[-] Select | [" + highlightColor + "]ESC[-] Cancel")
+
+	dialog.layout = tview.NewFlex().SetDirection(tview.FlexRow)
+	dialog.layout.AddItem(dialog.form, 0, 1, true)
+	dialog.layout.AddItem(shortcutsHint, 1, 0, false)
+	dialog.layout.SetBorder(true)
+	dialog.layout.SetTitle(" Compare Schema ")
+	dialog.layout.SetTitleColor(style.FgColor)
+	dialog.layout.SetBorderColor(style.DialogBorderColor)
+	dialog.layout.SetBackgroundColor(bgColor)
+
+	return dialog
+}
+
+// Show shows the dialog for the sessions the schema can be compared with
+func (d *SchemaCompareDialog) Show(sessions []string) {
+	previous := ""
+	if d.session < len(d.sessions) {
+		previous = d.sessions[d.session]
+	}
+
+	d.sessions = sessions
+	d.session = max(slices.Index(d.sessions, previous), 0)
+
+	d.form.Clear(true)
+	d.form.AddDropDown("Compare with", d.sessions, d.session, func(_ string, index int) {
+		if index >= 0 {
+			d.session = index
+		}
+	})
+	d.form.AddDropDown("Migrate", schemaDirections, d.direction, func(_ string, index int) {
+		if index >= 0 {
+			d.direction = index
+		}
+	})
+	d.form.AddButton("Compare", d.handleCompare)
+	d.form.AddButton("Cancel", d.close)
+	d.form.SetFocus(0)
+	d.display = true
+}
+
+// handleCompare hands the request to the compare handler
+func (d *SchemaCompareDialog) handleCompare() {
+	if d.session >= len(d.sessions) {
+		d.fail(fmt.Errorf("save a session of the other database first"))
+		return
+	}
+	request := schemaCompareRequest{session: d.sessions[d.session], reverse: d.direction == 1}
+
+	d.Hide()
+	if d.compareFunc != nil {
+		d.compareFunc(request)
+	}
+	if d.appFocusHandler != nil {
+		d.appFocusHandler()
+	}
+}
+
+// fail reports an invalid field in the dialog title
+func (d *SchemaCompareDialog) fail(err error) {
+	d.layout.SetTitle(" " + err.Error() + " ")
+	d.layout.SetTitleColor(style.StatusErrorColor)
+}
+
+// close hides the dialog and restores the page focus
+func (d *SchemaCompareDialog) close() {
+	d.Hide()
+	if d.appFocusHandler != nil {
+		d.appFocusHandler()
+	}
+}
+
+// Display displays this primitive
+func (d *SchemaCompareDialog) Display() {
+	d.display = true
+}
+
+// IsDisplay returns true if primitive is shown
+func (d *SchemaCompareDialog) IsDisplay() bool {
+	return d.display
+}
+
+// Hide stops displaying this primitive
+func (d *SchemaCompareDialog) Hide() {
+	d.display = false
+	d.layout.SetTitle(" Compare Schema ")
+	d.layout.SetTitleColor(style.FgColor)
+}
+
+// HasFocus returns whether or not this primitive has focus
+func (d *SchemaCompareDialog) HasFocus() bool {
+	return d.display && (d.form.HasFocus() || d.Box.HasFocus())
+}
+
+// Focus is called when this primitive receives focus
+func (d *SchemaCompareDialog) Focus(delegate func(p tview.Primitive)) {
+	delegate(d.form)
+}
+
+// SetAppFocusHandler sets the app focus handler
+func (d *SchemaCompareDialog) SetAppFocusHandler(handler func()) {
+	d.appFocusHandler = handler
+}
+
+// InputHandler returns input handler function for this primitive
+func (d *SchemaCompareDialog) InputHandler() func(event *tcell.EventKey, setFocus func(p tview.Primitive)) {
+	return d.WrapInputHandler(func(event *tcell.EventKey, setFocus func(p tview.Primitive)) {
+		// ESC closes an open drop-down list before the dialog
+		item, _ := d.form.GetFocusedItemIndex()
+		dropDown, _ := d.form.GetFormItem(max(item, 0)).(*tview.DropDown)
+		if event.Key() == utils.CloseDialogKey.Key && (dropDown == nil || !dropDown.IsOpen()) {
+			d.close()
+			return
+		}
+
+		if handler := d.form.InputHandler(); handler != nil {
+			handler(event, setFocus)
+		}
+	})
+}
+
+// SetRect sets rects for this primitive
+func (d *SchemaCompareDialog) SetRect(x, y, width, height int) {
+	ws := (width - schemaSchemaCompareDialogWidth) / 2
+	hs := (height - schemaSchemaCompareDialogHeight) / 2
+	dy := y + hs
+	bWidth := schemaSchemaCompareDialogWidth
+	bHeight := schemaSchemaCompareDialogHeight
+
+	if schemaSchemaCompareDialogWidth > width {
+		ws = 0
+		bWidth = width - 1
+	}
+
+	if schemaSchemaCompareDialogHeight >= height {
+		dy = y + 1
+		bHeight = height - 1
+	}
+
+	d.Box.SetRect(x+ws, dy, bWidth, bHeight)
+
+	x, y, width, height = d.GetInnerRect()
+	d.layout.SetRect(x, y, width, height)
+}
+
+// Draw draws this primitive onto the screen
+func (d *SchemaCompareDialog) Draw(screen tcell.Screen) {
+	if !d.display {
+		return
+	}
+
+	d.DrawForSubclass(screen, d)
+	d.layout.Draw(screen)
+}
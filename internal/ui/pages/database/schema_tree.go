@@ -0,0 +1,305 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/rivo/tview"
+	"github.com/shangyanjin/gocmder/internal/db"
+	"github.com/shangyanjin/gocmder/internal/ui/style"
+)
+
+// objectKinds names the schema object node types
+var objectKinds = map[string]string{
+	"view":     "View",
+	"matview":  "Materialized view",
+	"function": "Function",
+	"sequence": "Sequence",
+	"enum":     "Enum type",
+}
+
+// systemToggleNode returns the tree node showing whether system databases
+// and schemas are listed, selecting it toggles them
+func (d *Database) systemToggleNode() *tview.TreeNode {
+	label := "(system hidden, s: show)"
+	if d.showSystem {
+		label = "(system shown, s: hide)"
+	}
+
+	node := tview.NewTreeNode(label)
+	node.SetColor(style.BorderColor)
+	node.SetReference(map[string]string{"type": "system"})
+	node.SetSelectable(true)
+	return node
+}
+
+// toggleSystemObjects shows or hides system databases and schemas
+func (d *Database) toggleSystemObjects() {
+	if !d.connected || d.driver == nil {
+		d.showSystem = !d.showSystem
+		return
+	}
+	if !d.canExecute() {
+		return
+	}
+
+	d.showSystem = !d.showSystem
+	if d.showSystem {
+		d.loadDatabases("Showing system databases and schemas")
+	} else {
+		d.loadDatabases("Hiding system databases and schemas")
+	}
+}
+
+// markCurrentDatabase highlights the database a driver bound to one
+// database is connected to
+func (d *Database) markCurrentDatabase() {
+	if _, ok := d.driver.(db.DatabaseSwitcher); !ok {
+		return
+	}
+	sessionNode := d.activeSessionNode()
+	if sessionNode == nil {
+		return
+	}
+
+	for _, child := range sessionNode.GetChildren() {
+		data, ok := child.GetReference().(map[string]string)
+		if !ok || data["type"] != "database" {
+			continue
+		}
+		if data["name"] == d.currentDatabase {
+			child.SetColor(style.StatusInstalledColor)
+		} else {
+			child.SetColor(style.FgColor)
+		}
+	}
+}
+
+// useDatabase reconnects drivers bound to one database before objects of
+// another database are browsed, then runs next. The reconnect runs in the
+// background and is refused while a statement runs or rows are fetched,
+// as they use the connection that is closed.
+func (d *Database) useDatabase(database string, next func()) {
+	switcher, ok := d.driver.(db.DatabaseSwitcher)
+	if !ok || database == "" || database == switcher.CurrentDatabase() {
+		next()
+		return
+	}
+	if d.running != nil || d.fetchingRows() {
+		d.updateStatusBar("A query is still running or fetching rows. Press Esc or Ctrl+C to cancel it before switching databases")
+		return
+	}
+
+	// Results of the old connection end with it
+	d.closeTabs()
+	d.inspector.Hide()
+
+	ctx, cancel := context.WithTimeout(context.Background(), connectTimeout)
+	d.runTask(ctx, cancel, fmt.Sprintf("Connecting to database %s...", tview.Escape(database)), func(ctx context.Context) error {
+		return switcher.UseDatabase(ctx, database)
+	}, func(err error) {
+		if err != nil {
+			d.showError(fmt.Sprintf("Failed to connect to database %s: %v", database, err))
+			return
+		}
+
+		d.currentDatabase = switcher.CurrentDatabase()
+		d.markCurrentDatabase()
+		d.updateStatusBar(fmt.Sprintf("Connected to database %s", tview.Escape(d.currentDatabase)))
+		next()
+	})
+}
+
+// fetchingRows returns true if a result tab is fetching its next page
+func (d *Database) fetchingRows() bool {
+	for _, tab := range d.tabs {
+		if tab.grid != nil && tab.grid.fetching {
+			return true
+		}
+	}
+	return false
+}
+
+// runTask runs work in the background as the running query, so that Esc
+// cancels it and no statement or reconnect starts meanwhile. done gets the
+// error of work on the UI goroutine unless the task was cancelled or
+// discarded by a reconnect, in which case the discard func of the returned
+// task runs instead.
+func (d *Database) runTask(ctx context.Context, cancel context.CancelFunc, status string, work func(ctx context.Context) error, done func(err error)) *runningQuery {
+	run := &runningQuery{
+		ctx:     ctx,
+		cancel:  cancel,
+		started: time.Now(),
+		done:    make(chan struct{}),
+		total:   1,
+	}
+	d.running = run
+	d.updateStatusBar(status + " (Esc/Ctrl+C to cancel)")
+
+	go func() {
+		err := work(ctx)
+		cancel()
+
+		d.queueUpdateDraw(func() {
+			close(run.done)
+			if d.running != run || run.canceled {
+				if d.running == run {
+					d.running = nil
+					d.updateStatusBar("Cancelled")
+				}
+				if run.discard != nil {
+					run.discard()
+				}
+				return
+			}
+			d.running = nil
+			done(err)
+		})
+	}()
+	return run
+}
+
+// loadingNode returns the placeholder of tree children being loaded
+func loadingNode() *tview.TreeNode {
+	node := tview.NewTreeNode("  Loading...")
+	node.SetColor(style.BorderColor)
+	node.SetSelectable(false)
+	return node
+}
+
+// loadTreeNode runs work like runTask while a tree node shows that its
+// children are loading. The placeholder is removed however the task ends.
+func (d *Database) loadTreeNode(ctx context.Context, cancel context.CancelFunc, node *tview.TreeNode, status string, work func(ctx context.Context) error, done func(err error)) {
+	loading := loadingNode()
+	node.ClearChildren()
+	node.AddChild(loading)
+	node.SetExpanded(true)
+
+	run := d.runTask(ctx, cancel, status, work, func(err error) {
+		node.RemoveChild(loading)
+		done(err)
+	})
+	run.discard = func() {
+		node.RemoveChild(loading)
+	}
+}
+
+// loadSchemas loads the schemas of a database under its tree node
+func (d *Database) loadSchemas(node *tview.TreeNode, browser db.SchemaBrowser, database string) {
+	if !d.canExecute() {
+		return
+	}
+
+	d.useDatabase(database, func() {
+		var schemas []string
+		ctx, cancel := d.statementContext()
+		d.loadTreeNode(ctx, cancel, node, fmt.Sprintf("Loading schemas from %s...", tview.Escape(database)), func(ctx context.Context) error {
+			var err error
+			schemas, err = browser.GetSchemas(ctx)
+			return err
+		}, func(err error) {
+			if err != nil {
+				d.showError(fmt.Sprintf("Failed to load schemas: %v", err))
+				return
+			}
+			d.showSchemas(node, database, schemas)
+		})
+	})
+}
+
+// showSchemas lists the schemas of a database under its tree node
+func (d *Database) showSchemas(node *tview.TreeNode, database string, schemas []string) {
+	node.ClearChildren()
+	for _, schema := range schemas {
+		if !d.showSystem && db.IsSystemSchema(schema) {
+			continue
+		}
+		schemaNode := tview.NewTreeNode(tview.Escape(schema))
+		schemaNode.SetColor(style.FgColor)
+		schemaNode.SetReference(map[string]string{
+			"type":     "schema",
+			"database": database,
+			"name":     schema,
+		})
+		schemaNode.SetSelectable(true)
+		node.AddChild(schemaNode)
+	}
+	node.SetExpanded(true)
+
+	d.updateStatusBar(fmt.Sprintf("Loaded %d schemas from %s", len(node.GetChildren()), tview.Escape(database)))
+}
+
+// loadSchemaObjects loads the objects of a schema under its tree node
+func (d *Database) loadSchemaObjects(node *tview.TreeNode, browser db.SchemaBrowser, database, schema string) {
+	if !d.canExecute() {
+		return
+	}
+
+	d.useDatabase(database, func() {
+		d.invalidateMetadata()
+
+		var objects *db.SchemaObjects
+		ctx, cancel := d.statementContext()
+		d.loadTreeNode(ctx, cancel, node, fmt.Sprintf("Loading objects from %s...", tview.Escape(schema)), func(ctx context.Context) error {
+			var err error
+			objects, err = browser.GetSchemaObjects(ctx, schema)
+			return err
+		}, func(err error) {
+			if err != nil {
+				d.showError(fmt.Sprintf("Failed to load schema objects: %v", err))
+				return
+			}
+			d.showSchemaObjects(node, database, schema, objects)
+		})
+	})
+}
+
+// showSchemaObjects lists the objects of a schema under its tree node,
+// grouped by kind
+func (d *Database) showSchemaObjects(node *tview.TreeNode, database, schema string, objects *db.SchemaObjects) {
+	groups := []struct {
+		label string
+		kind  string
+		names []string
+	}{
+		{"Tables", "table", objects.Tables},
+		{"Views", "view", objects.Views},
+		{"Materialized Views", "matview", objects.MaterializedViews},
+		{"Functions", "function", objects.Functions},
+		{"Sequences", "sequence", objects.Sequences},
+		{"Enum Types", "enum", objects.EnumTypes},
+	}
+
+	node.ClearChildren()
+	total := 0
+	for _, group := range groups {
+		groupNode := tview.NewTreeNode(fmt.Sprintf("%s (%d)", group.label, len(group.names)))
+		groupNode.SetColor(style.FgColor)
+		if len(group.names) == 0 {
+			groupNode.SetColor(style.BorderColor)
+		}
+		groupNode.SetReference(map[string]string{"type": "group", "database": database, "schema": schema})
+		groupNode.SetSelectable(true)
+		// Only tables are listed right away
+		groupNode.SetExpanded(group.kind == "table")
+
+		for _, name := range group.names {
+			objectNode := tview.NewTreeNode("  " + tview.Escape(name))
+			objectNode.SetColor(style.StatusSelectedColor)
+			objectNode.SetReference(map[string]string{
+				"type":     group.kind,
+				"database": database,
+				"schema":   schema,
+				"name":     name,
+			})
+			objectNode.SetSelectable(true)
+			groupNode.AddChild(objectNode)
+		}
+		node.AddChild(groupNode)
+		total += len(group.names)
+	}
+	node.SetExpanded(true)
+
+	d.updateStatusBar(fmt.Sprintf("Loaded %d objects from %s.%s", total, tview.Escape(database), tview.Escape(schema)))
+}
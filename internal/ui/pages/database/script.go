@@ -0,0 +1,148 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/shangyanjin/gocmder/internal/db"
+)
+
+// runScript runs statements one after another in the background. Each
+// result is read up to the row limit before the next statement starts.
+func (d *Database) runScript(statements []db.Statement) {
+	ctx, cancel := context.WithCancel(context.Background())
+	run := &runningQuery{
+		ctx:     ctx,
+		cancel:  cancel,
+		started: time.Now(),
+		done:    make(chan struct{}),
+		total:   len(statements),
+	}
+	d.running = run
+	d.updateStatusBar(fmt.Sprintf("Executing %d statements... (Esc/Ctrl+C to cancel)", len(statements)))
+
+	driver := d.driver
+	timeout := d.timeout
+	rowLimit := d.rowLimit
+	stopOnError := d.stopOnError
+	go func() {
+		for i, statement := range statements {
+			if ctx.Err() != nil {
+				break
+			}
+			run.current.Store(int32(i + 1))
+
+			tab := runScriptStatement(ctx, driver, statement, timeout, rowLimit)
+			d.queueUpdateDraw(func() {
+				if d.running == run {
+					d.addTab(tab)
+					d.recordHistory(tab)
+				}
+			})
+
+			if tab.err != nil && stopOnError {
+				break
+			}
+		}
+
+		d.queueUpdateDraw(func() {
+			d.finishScript(run)
+		})
+	}()
+	go d.showElapsed(run)
+}
+
+// runScriptStatement runs one statement of a script and reads its rows
+func runScriptStatement(ctx context.Context, driver db.Driver, statement db.Statement, timeout time.Duration, rowLimit int) *resultTab {
+	started := time.Now()
+	tab := &resultTab{statement: statement}
+
+	statementCtx, cancel := context.WithCancel(ctx)
+	if timeout > 0 {
+		statementCtx, cancel = context.WithTimeout(ctx, timeout)
+	}
+	defer cancel()
+
+	result, err := db.OpenStatement(statementCtx, driver, statement)
+	var rows [][]db.Value
+	limited := false
+	if err == nil && result.Cursor != nil {
+		// One row more than the limit tells whether rows were left out
+		rows, err = result.Cursor.Fetch(rowLimit + 1)
+		if len(rows) > rowLimit {
+			rows = rows[:rowLimit]
+			limited = true
+		}
+		result.Cursor.Close()
+		result.Notices = append(result.Notices, result.Cursor.Notices()...)
+		result.Cursor = nil
+	}
+
+	tab.result = result
+	tab.elapsed = time.Since(started).Round(time.Millisecond)
+
+	switch {
+	case ctx.Err() != nil:
+		tab.err = fmt.Errorf("cancelled after %s", tab.elapsed)
+		tab.status = fmt.Sprintf("Statement cancelled after %s", tab.elapsed)
+	case errors.Is(statementCtx.Err(), context.DeadlineExceeded):
+		tab.err = fmt.Errorf("timed out after %s", timeout)
+		tab.status = fmt.Sprintf("Statement timed out after %s", tab.elapsed)
+	case err != nil:
+		tab.err = err
+		tab.status = fmt.Sprintf("Statement failed after %s", tab.elapsed)
+	case len(result.Columns) > 0:
+		tab.grid = newResultGrid(result, rows, nil, len(rows))
+		tab.grid.limited = limited
+		tab.status = fmt.Sprintf("Statement executed in %s. Rows: %s", tab.elapsed, tab.grid.status())
+	default:
+		tab.status = fmt.Sprintf("Statement executed in %s. Rows: %d", tab.elapsed, result.RowsAffected)
+	}
+	if result != nil && tab.err == nil {
+		tab.status = resultStatus(tab.status, result.Notices)
+	}
+
+	return tab
+}
+
+// finishScript shows the summary of a script run
+func (d *Database) finishScript(run *runningQuery) {
+	close(run.done)
+	run.cancel()
+
+	// The script was replaced by a reconnect or disconnect
+	if d.running != run {
+		return
+	}
+	d.running = nil
+
+	failed, first := 0, -1
+	for i, tab := range d.tabs {
+		if tab.err == nil {
+			continue
+		}
+		failed++
+		if first < 0 {
+			first = i
+		}
+	}
+
+	elapsed := time.Since(run.started).Round(time.Millisecond)
+	message := fmt.Sprintf("Script: %d of %d statements executed in %s", len(d.tabs), run.total, elapsed)
+	switch {
+	case run.canceled:
+		message += ", cancelled"
+	case failed > 0 && len(d.tabs) < run.total:
+		message += fmt.Sprintf(", stopped at statement %d (line %d)", first+1, d.tabs[first].statement.Line)
+	case failed > 0:
+		message += fmt.Sprintf(", %d failed", failed)
+	}
+
+	// Show the first error, otherwise the last result
+	if first >= 0 {
+		d.showTab(first)
+	}
+	d.updateStatusBar(message)
+}
@@ -0,0 +1,342 @@
+package database
+
+import (
+	"fmt"
+
+	"github.com/gdamore/tcell/v2"
+	"github.com/rivo/tview"
+	"github.com/shangyanjin/gocmder/internal/config"
+	"github.com/shangyanjin/gocmder/internal/ui/style"
+)
+
+// buildSessionTree rebuilds the tree with one root node per saved session
+func (d *Database) buildSessionTree() {
+	var sessions []config.Session
+	if d.sessions != nil {
+		sessions = d.sessions.List()
+	}
+
+	// Keep the active session visible even if it could not be saved
+	if d.currentSession != "" && !containsSession(sessions, d.currentSession) {
+		sessions = append(sessions, config.Session{Name: d.currentSession})
+	}
+
+	if len(sessions) == 0 {
+		rootNode := tview.NewTreeNode("Not Connected")
+		rootNode.SetColor(style.StatusNotInstalledColor)
+		d.leftPanel.SetRoot(rootNode)
+		d.leftPanel.SetCurrentNode(rootNode)
+		return
+	}
+
+	rootNode := tview.NewTreeNode("Sessions")
+	rootNode.SetColor(style.StatusInstalledColor)
+	rootNode.SetExpanded(true)
+
+	var current *tview.TreeNode
+	for _, session := range sessions {
+		sessionNode := tview.NewTreeNode(sessionLabel(session))
+		sessionNode.SetReference(map[string]string{"type": "session", "name": session.Name})
+		sessionNode.SetSelectable(true)
+
+		if d.connected && session.Name == d.currentSession {
+			sessionNode.SetColor(style.StatusInstalledColor)
+			current = sessionNode
+		} else {
+			sessionNode.SetColor(style.FgColor)
+		}
+
+		rootNode.AddChild(sessionNode)
+	}
+
+	d.leftPanel.SetRoot(rootNode)
+	if current != nil {
+		d.leftPanel.SetCurrentNode(current)
+	} else {
+		d.leftPanel.SetCurrentNode(rootNode)
+	}
+}
+
+// sessionLabel returns the tree label of a session
+func sessionLabel(session config.Session) string {
+	driver := driverName(session.Driver)
+	switch {
+	case session.Driver == "":
+		return session.Name
+	case tunneled(session):
+		return fmt.Sprintf("%s (%s %s via %s)", session.Name, driver, session.Host, session.SSHHost)
+	case session.Host != "":
+		return fmt.Sprintf("%s (%s %s)", session.Name, driver, session.Host)
+	case session.Database != "":
+		return fmt.Sprintf("%s (%s %s)", session.Name, driver, session.Database)
+	}
+	return fmt.Sprintf("%s (%s)", session.Name, driver)
+}
+
+// containsSession returns true if sessions contains a session named name
+func containsSession(sessions []config.Session, name string) bool {
+	for _, session := range sessions {
+		if session.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// activeSessionNode returns the tree node of the connected session
+func (d *Database) activeSessionNode() *tview.TreeNode {
+	return d.findSessionNode(d.currentSession)
+}
+
+// findSessionNode returns the tree node of a session by name
+func (d *Database) findSessionNode(name string) *tview.TreeNode {
+	if name == "" {
+		return nil
+	}
+
+	for _, child := range d.leftPanel.GetRoot().GetChildren() {
+		if data, ok := child.GetReference().(map[string]string); ok && data["type"] == "session" && data["name"] == name {
+			return child
+		}
+	}
+	return nil
+}
+
+// selectedSession returns the session name of the current tree node
+func (d *Database) selectedSession() string {
+	node := d.leftPanel.GetCurrentNode()
+	if node == nil {
+		return ""
+	}
+	if data, ok := node.GetReference().(map[string]string); ok && data["type"] == "session" {
+		return data["name"]
+	}
+	return ""
+}
+
+// saveSession persists a session from the connection dialog and stores its password in the vault
+func (d *Database) saveSession(session config.Session, password string) {
+	if d.sessions == nil {
+		d.showError("Session store is not available")
+		return
+	}
+
+	if err := d.sessions.Put(session); err != nil {
+		d.showError(fmt.Sprintf("Failed to save session: %v", err))
+		return
+	}
+
+	d.buildSessionTree()
+	d.updateStatusBar(fmt.Sprintf("Saved session %s", session.Name))
+	d.storePassword(session, password)
+}
+
+// connectSession connects to a saved session, asking for the password if unknown
+func (d *Database) connectSession(name string) {
+	if d.sessions == nil {
+		return
+	}
+
+	session, ok := d.sessions.Get(name)
+	if !ok {
+		d.showError(fmt.Sprintf("Session not found: %s", name))
+		return
+	}
+
+	if fileDriver(session.Driver) {
+		d.handleConnect(session, "")
+		return
+	}
+
+	d.resolvePassword(session, func(password string, ok bool) {
+		if !ok {
+			d.connDialog.LoadSession(session, "")
+			d.connDialog.Display()
+			d.updateStatusBar(fmt.Sprintf("Enter password for session %s", name))
+			if d.appFocusHandler != nil {
+				d.appFocusHandler()
+			}
+			return
+		}
+
+		d.handleConnect(session, password)
+	})
+}
+
+// editSession opens the connection dialog with a saved session
+func (d *Database) editSession(name string) {
+	session, ok := d.sessions.Get(name)
+	if !ok {
+		return
+	}
+
+	d.resolvePassword(session, func(password string, _ bool) {
+		d.connDialog.LoadSession(session, password)
+		d.connDialog.Display()
+		if d.appFocusHandler != nil {
+			d.appFocusHandler()
+		}
+	})
+}
+
+// renameSession asks for a new session name
+func (d *Database) renameSession(name string) {
+	d.prompt("Rename Session", "Name: ", name, func(newName string) {
+		if newName == name {
+			return
+		}
+		if err := d.sessions.Rename(name, newName); err != nil {
+			d.showError(fmt.Sprintf("Failed to rename session: %v", err))
+			return
+		}
+
+		d.renameStoredPassword(name, newName)
+		if d.currentSession == name {
+			d.currentSession = newName
+		}
+
+		d.refreshSessionTree()
+		d.updateStatusBar(fmt.Sprintf("Renamed session %s to %s", name, newName))
+	})
+}
+
+// duplicateSession copies a session under a new name
+func (d *Database) duplicateSession(name string) {
+	session, err := d.sessions.Duplicate(name)
+	if err != nil {
+		d.showError(fmt.Sprintf("Failed to duplicate session: %v", err))
+		return
+	}
+
+	d.copyStoredPassword(name, session.Name)
+	d.refreshSessionTree()
+	d.updateStatusBar(fmt.Sprintf("Duplicated session %s as %s", name, session.Name))
+}
+
+// deleteSession deletes a session after confirmation
+func (d *Database) deleteSession(name string) {
+	d.confirm("Delete Session", fmt.Sprintf("Delete session %q?", name), func() {
+		d.removeSession(name)
+	})
+}
+
+// removeSession deletes a session, disconnecting it first if it is the
+// active one. An open transaction is rolled back once the user agrees,
+// the session is kept if they do not.
+func (d *Database) removeSession(name string) {
+	if d.currentSession == name {
+		if d.confirmEndTransaction(func() { d.removeSession(name) }) {
+			return
+		}
+		d.disconnect()
+	}
+	if err := d.sessions.Delete(name); err != nil {
+		d.showError(fmt.Sprintf("Failed to delete session: %v", err))
+		return
+	}
+
+	d.deleteStoredPassword(name)
+	d.buildSessionTree()
+	d.updateStatusBar(fmt.Sprintf("Deleted session %s", name))
+}
+
+// refreshSessionTree rebuilds the tree keeping the loaded databases of the
+// active session, without reading them again
+func (d *Database) refreshSessionTree() {
+	// Only the active session has children
+	var loaded *tview.TreeNode
+	for _, child := range d.leftPanel.GetRoot().GetChildren() {
+		if len(child.GetChildren()) > 0 {
+			loaded = child
+		}
+	}
+
+	d.buildSessionTree()
+	if node := d.activeSessionNode(); node != nil && loaded != nil {
+		node.SetChildren(loaded.GetChildren())
+		node.SetExpanded(loaded.IsExpanded())
+	}
+}
+
+// handleSessionKey handles session actions on the tree, returns true if handled
+func (d *Database) handleSessionKey(event *tcell.EventKey) bool {
+	if event.Key() == tcell.KeyRune && event.Rune() == 'L' {
+		d.lockVault()
+		return true
+	}
+	if event.Key() == tcell.KeyRune && event.Rune() == 's' {
+		d.toggleSystemObjects()
+		return true
+	}
+	if event.Key() == tcell.KeyRune && event.Rune() == 'i' && d.connected {
+		d.showImport()
+		return true
+	}
+	if event.Key() == tcell.KeyRune && event.Rune() == 'D' && d.connected {
+		d.showDump()
+		return true
+	}
+	if event.Key() == tcell.KeyRune && event.Rune() == 'R' && d.connected {
+		d.showRestore()
+		return true
+	}
+
+	name := d.selectedSession()
+	if name == "" || d.sessions == nil {
+		return false
+	}
+
+	if event.Key() == tcell.KeyDelete {
+		d.deleteSession(name)
+		return true
+	}
+
+	if event.Key() != tcell.KeyRune {
+		return false
+	}
+
+	switch event.Rune() {
+	case 'e':
+		d.editSession(name)
+	case 'r':
+		d.renameSession(name)
+	case 'c':
+		d.duplicateSession(name)
+	case 'd':
+		d.deleteSession(name)
+	default:
+		return false
+	}
+
+	return true
+}
+
+// confirm asks for confirmation before running action
+func (d *Database) confirm(title, message string, action func()) {
+	d.confirmAction = action
+	d.confirmDialog.SetTitle(title)
+	d.confirmDialog.SetText(message)
+	d.confirmDialog.Display()
+}
+
+// promptSecret asks for a masked line of input and passes it to handler
+func (d *Database) promptSecret(title, label string, handler func(value string)) {
+	d.prompt(title, label, "", handler)
+	d.inputDialog.SetMaskCharacter('*')
+}
+
+// prompt asks for a single line of input and passes it to handler
+func (d *Database) prompt(title, label, text string, handler func(value string)) {
+	d.inputDialog.SetTitle(title)
+	d.inputDialog.SetLabel(label)
+	d.inputDialog.SetText(text)
+	d.inputDialog.SetSelectedFunc(func() {
+		value := d.inputDialog.GetText()
+		d.inputDialog.Hide()
+		handler(value)
+		if d.appFocusHandler != nil {
+			d.appFocusHandler()
+		}
+	})
+	d.inputDialog.Display()
+}
@@ -0,0 +1,445 @@
+package database
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/gdamore/tcell/v2"
+	"github.com/rivo/tview"
+	"github.com/shangyanjin/gocmder/internal/config"
+	"github.com/shangyanjin/gocmder/internal/db"
+	"github.com/shangyanjin/gocmder/internal/ui/style"
+	"github.com/shangyanjin/gocmder/internal/ui/utils"
+)
+
+// snippetHeaders are the columns of the snippet list
+var snippetHeaders = []string{"NAME", "SESSION", "PARAMETERS", "DESCRIPTION"}
+
+// SnippetPanel lists saved queries with incremental search
+type SnippetPanel struct {
+	*tview.Box
+
+	layout        *tview.Flex
+	search        *tview.InputField
+	table         *tview.Table
+	preview       *tview.TextView
+	snippets      []config.Snippet // snippets of the session
+	matches       []config.Snippet // snippets matching the search text
+	dialect       db.Dialect
+	display       bool
+	doneHandler   func()
+	runHandler    func(snippet config.Snippet)
+	loadHandler   func(snippet config.Snippet)
+	saveHandler   func()
+	deleteHandler func(snippet config.Snippet)
+}
+
+// NewSnippetPanel returns a new snippet panel primitive. runFunc runs a
+// snippet, loadFunc loads it into the editor, saveFunc saves the editor text
+// as a snippet and deleteFunc deletes one.
+func NewSnippetPanel(runFunc, loadFunc func(snippet config.Snippet), saveFunc func(), deleteFunc func(snippet config.Snippet)) *SnippetPanel {
+	bgColor := style.DialogBgColor
+	panel := &SnippetPanel{
+		Box:           tview.NewBox(),
+		runHandler:    runFunc,
+		loadHandler:   loadFunc,
+		saveHandler:   saveFunc,
+		deleteHandler: deleteFunc,
+	}
+
+	panel.search = tview.NewInputField()
+	panel.search.SetLabel(" Search: ")
+	panel.search.SetLabelColor(style.FgColor)
+	panel.search.SetFieldBackgroundColor(style.BgColor)
+	panel.search.SetFieldTextColor(style.FgColor)
+	panel.search.SetBackgroundColor(bgColor)
+	panel.search.SetPlaceholder("name, description or query")
+	panel.search.SetChangedFunc(func(string) {
+		panel.refresh()
+	})
+
+	panel.table = tview.NewTable()
+	panel.table.SetBackgroundColor(bgColor)
+	panel.table.SetSelectable(true, false)
+	panel.table.SetFixed(1, 0)
+	panel.table.SetSelectionChangedFunc(func(row, column int) {
+		panel.showPreview()
+	})
+
+	panel.preview = tview.NewTextView()
+	panel.preview.SetBackgroundColor(style.BgColor)
+	panel.preview.SetTextColor(style.DialogFgColor)
+	panel.preview.SetDynamicColors(true)
+	panel.preview.SetWrap(true)
+
+	highlightColor := style.GetColorHex(style.StatusInstalledColor)
+	hint := tview.NewTextView()
+	hint.SetBackgroundColor(bgColor)
+	hint.SetTextColor(style.FgColor)
+	hint.SetDynamicColors(true)
+	hint.SetText(" [" + highlightColor + "]Enter[-] Run | [" + highlightColor + "]Ctrl+L[-] Load into editor | [" + highlightColor +
+		"]Ctrl+S[-] Save editor text | [" + highlightColor + "]Ctrl+D[-] Delete | [" + highlightColor + "]ESC[-] Close")
+
+	panel.layout = tview.NewFlex().SetDirection(tview.FlexRow)
+	panel.layout.AddItem(panel.search, 1, 0, true)
+	panel.layout.AddItem(panel.table, 0, 2, false)
+	panel.layout.AddItem(panel.preview, 0, 1, false)
+	panel.layout.AddItem(hint, 1, 0, false)
+	panel.layout.SetBorder(true)
+	panel.layout.SetTitleColor(style.FgColor)
+	panel.layout.SetBorderColor(style.DialogBorderColor)
+	panel.layout.SetBackgroundColor(bgColor)
+
+	return panel
+}
+
+// SetSnippets lists snippets, keeping the search text. The parameters are
+// found with the rules of dialect.
+func (s *SnippetPanel) SetSnippets(snippets []config.Snippet, session string, dialect db.Dialect) {
+	s.snippets = snippets
+	s.dialect = dialect
+	if session == "" {
+		s.layout.SetTitle(" Snippets ")
+	} else {
+		s.layout.SetTitle(fmt.Sprintf(" Snippets: %s ", tview.Escape(session)))
+	}
+	s.refresh()
+}
+
+// refresh lists the snippets matching all words of the search text
+func (s *SnippetPanel) refresh() {
+	words := strings.Fields(strings.ToLower(s.search.GetText()))
+	s.matches = nil
+	for _, snippet := range s.snippets {
+		text := strings.ToLower(snippet.Name + " " + snippet.Description + " " + snippet.Query)
+		matched := true
+		for _, word := range words {
+			if !strings.Contains(text, word) {
+				matched = false
+				break
+			}
+		}
+		if matched {
+			s.matches = append(s.matches, snippet)
+		}
+	}
+
+	s.table.Clear()
+	for column, header := range snippetHeaders {
+		cell := tview.NewTableCell(header)
+		cell.SetBackgroundColor(style.PageHeaderBgColor)
+		cell.SetTextColor(style.PageHeaderFgColor)
+		cell.SetSelectable(false)
+		s.table.SetCell(0, column, cell)
+	}
+	for row, snippet := range s.matches {
+		session := snippet.Session
+		if snippet.Global() {
+			session = "(all)"
+		}
+		parameters := db.Parameters(s.dialect, snippet.Query)
+		for i, name := range parameters {
+			parameters[i] = ":" + name
+		}
+		values := []string{snippet.Name, session, strings.Join(parameters, " "), snippet.Description}
+		for column, value := range values {
+			cell := tview.NewTableCell(tview.Escape(value))
+			cell.SetTextColor(style.DialogFgColor)
+			cell.SetMaxWidth(maxCellWidth)
+			if column == len(values)-1 {
+				cell.SetExpansion(1)
+			}
+			s.table.SetCell(row+1, column, cell)
+		}
+	}
+
+	s.table.Select(1, 0)
+	s.table.ScrollToBeginning()
+	s.showPreview()
+}
+
+// showPreview shows the query and the file of the selected snippet
+func (s *SnippetPanel) showPreview() {
+	snippet, ok := s.selected()
+	if !ok {
+		if len(s.snippets) == 0 {
+			s.preview.SetText(" No snippets yet. Ctrl+S saves the editor text as a snippet.")
+		} else {
+			s.preview.SetText(" No matching snippets")
+		}
+		return
+	}
+
+	fileColor := style.GetColorHex(style.BorderColor)
+	s.preview.SetText(fmt.Sprintf("%s\n\n[%s]%s[-]", tview.Escape(snippet.Query), fileColor, tview.Escape(snippet.File)))
+	s.preview.ScrollToBeginning()
+}
+
+// selected returns the selected snippet
+func (s *SnippetPanel) selected() (config.Snippet, bool) {
+	row, _ := s.table.GetSelection()
+	if row < 1 || row > len(s.matches) {
+		return config.Snippet{}, false
+	}
+	return s.matches[row-1], true
+}
+
+// Display displays this primitive
+func (s *SnippetPanel) Display() {
+	s.display = true
+}
+
+// IsDisplay returns true if primitive is shown
+func (s *SnippetPanel) IsDisplay() bool {
+	return s.display
+}
+
+// Hide stops displaying this primitive
+func (s *SnippetPanel) Hide() {
+	s.display = false
+}
+
+// HasFocus returns whether or not this primitive has focus
+func (s *SnippetPanel) HasFocus() bool {
+	return s.display && (s.layout.HasFocus() || s.Box.HasFocus())
+}
+
+// Focus is called when this primitive receives focus, typing always searches
+func (s *SnippetPanel) Focus(delegate func(p tview.Primitive)) {
+	delegate(s.search)
+}
+
+// InputHandler returns input handler function for this primitive
+func (s *SnippetPanel) InputHandler() func(event *tcell.EventKey, setFocus func(p tview.Primitive)) {
+	return s.WrapInputHandler(func(event *tcell.EventKey, setFocus func(p tview.Primitive)) {
+		snippet, ok := s.selected()
+
+		switch event.Key() {
+		case utils.CloseDialogKey.Key:
+			if s.doneHandler != nil {
+				s.doneHandler()
+			}
+		case tcell.KeyUp, tcell.KeyDown, tcell.KeyPgUp, tcell.KeyPgDn:
+			if handler := s.table.InputHandler(); handler != nil {
+				handler(event, setFocus)
+			}
+		case tcell.KeyEnter:
+			if ok {
+				s.runHandler(snippet)
+			}
+		case tcell.KeyCtrlL:
+			if ok {
+				s.loadHandler(snippet)
+			}
+		case tcell.KeyCtrlS:
+			s.saveHandler()
+		case tcell.KeyCtrlD:
+			if ok {
+				s.deleteHandler(snippet)
+			}
+		default:
+			if handler := s.search.InputHandler(); handler != nil {
+				handler(event, setFocus)
+			}
+		}
+	})
+}
+
+// SetDoneFunc sets the handler called when the panel is closed
+func (s *SnippetPanel) SetDoneFunc(handler func()) *SnippetPanel {
+	s.doneHandler = handler
+	return s
+}
+
+// SetRect sets rects for this primitive, the panel covers the given area
+func (s *SnippetPanel) SetRect(x, y, width, height int) {
+	s.Box.SetRect(x, y, width, height)
+	s.layout.SetRect(x, y, width, height)
+}
+
+// Draw draws this primitive onto the screen
+func (s *SnippetPanel) Draw(screen tcell.Screen) {
+	if !s.display {
+		return
+	}
+
+	s.layout.Draw(screen)
+}
+
+// dialect returns the dialect of the connection, PostgreSQL rules are used
+// to find parameters while not connected
+func (d *Database) dialect() db.Dialect {
+	if d.driver == nil {
+		return db.DialectPostgres
+	}
+	return d.driver.GetDialect()
+}
+
+// showSnippets reads the snippet files and opens the snippet panel
+func (d *Database) showSnippets() {
+	if d.snippetStore == nil {
+		d.showError("The snippet library could not be opened")
+		return
+	}
+
+	if err := d.snippetStore.Load(); err != nil {
+		d.updateStatusBar(tview.Escape(err.Error()))
+	}
+	d.refreshSnippets()
+	d.snippets.Display()
+}
+
+// refreshSnippets lists the snippets of the connected session
+func (d *Database) refreshSnippets() {
+	d.snippets.SetSnippets(d.snippetStore.List(d.currentSession), d.currentSession, d.dialect())
+}
+
+// runSnippet runs the statements of a snippet, asking for its parameters
+func (d *Database) runSnippet(snippet config.Snippet) {
+	d.snippets.Hide()
+	if d.canExecute() && !d.confirmDiscard(func() { d.runSnippet(snippet) }) {
+		// Only snippets bind :name parameters, in typed SQL they may be
+		// labels, array slices or text
+		statements := db.Split(d.driver.GetDialect(), snippet.Query)
+		if !d.bindParameters(statements, d.runStatements) {
+			d.runStatements(statements)
+		}
+	}
+
+	if d.appFocusHandler != nil {
+		d.appFocusHandler()
+	}
+}
+
+// loadSnippet replaces the editor text with the query of a snippet, the
+// previous text can be restored with undo
+func (d *Database) loadSnippet(snippet config.Snippet) {
+	d.snippets.Hide()
+	d.sqlEditor.Replace(0, len(d.sqlEditor.GetText()), snippet.Query)
+	d.focusedElement = focusEditor
+	d.updateStatusBar(fmt.Sprintf("Loaded snippet %s (Ctrl+Z restores the previous text)", tview.Escape(snippet.Name)))
+
+	if d.appFocusHandler != nil {
+		d.appFocusHandler()
+	}
+}
+
+// saveSnippet saves the selected editor text, or all of it, as a snippet.
+// It asks for the name, the description and the session.
+func (d *Database) saveSnippet() {
+	query, _, _ := d.sqlEditor.GetSelection()
+	if strings.TrimSpace(query) == "" {
+		query = d.sqlEditor.GetText()
+	}
+	if strings.TrimSpace(query) == "" {
+		d.updateStatusBar("The editor is empty, write the query of the snippet first")
+		return
+	}
+
+	snippet := config.Snippet{Query: strings.TrimSpace(query), Session: d.currentSession}
+
+	d.prompt("Save snippet", "Name: ", snippet.Name, func(name string) {
+		snippet.Name = strings.TrimSpace(name)
+		if snippet.Name == "" {
+			return
+		}
+		d.prompt("Save snippet "+snippet.Name, "Description: ", snippet.Description, func(description string) {
+			snippet.Description = description
+			d.prompt("Save snippet "+snippet.Name, "Session (empty for all): ", snippet.Session, func(session string) {
+				snippet.Session = strings.TrimSpace(session)
+				d.putSnippet(snippet)
+			})
+		})
+	})
+
+	if d.appFocusHandler != nil {
+		d.appFocusHandler()
+	}
+}
+
+// putSnippet saves a snippet to the personal snippets file, asking before
+// one of the same name is replaced
+func (d *Database) putSnippet(snippet config.Snippet) {
+	save := func() {
+		if err := d.snippetStore.Put(snippet); err != nil {
+			d.showError(fmt.Sprintf("Failed to save snippet: %v", err))
+			return
+		}
+		d.refreshSnippets()
+		d.updateStatusBar(fmt.Sprintf("Saved snippet %s to %s", tview.Escape(snippet.Name), tview.Escape(d.snippetStore.Path())))
+	}
+
+	for _, existing := range d.snippetStore.List("") {
+		if existing.Name == snippet.Name && existing.File == d.snippetStore.Path() {
+			d.confirm("Replace snippet", fmt.Sprintf("Snippet %s already exists. Replace it?", snippet.Name), save)
+			return
+		}
+	}
+	save()
+}
+
+// deleteSnippet deletes a snippet from its file after confirmation
+func (d *Database) deleteSnippet(snippet config.Snippet) {
+	d.confirm("Delete snippet", fmt.Sprintf("Delete snippet %s from %s?", snippet.Name, snippet.File), func() {
+		if err := d.snippetStore.Delete(snippet); err != nil {
+			d.showError(fmt.Sprintf("Failed to delete snippet: %v", err))
+			return
+		}
+		d.refreshSnippets()
+		d.updateStatusBar(fmt.Sprintf("Deleted snippet %s", tview.Escape(snippet.Name)))
+	})
+
+	if d.appFocusHandler != nil {
+		d.appFocusHandler()
+	}
+}
+
+// bindParameters asks for the values of the :name placeholders of
+// statements, one prompt per name. It returns true if the user is asked,
+// retry runs the statements with their values once all are entered.
+func (d *Database) bindParameters(statements []db.Statement, retry func(statements []db.Statement)) bool {
+	dialect := d.driver.GetDialect()
+	var names []string
+	for _, statement := range statements {
+		if statement.Params != nil {
+			continue
+		}
+		for _, name := range db.Parameters(dialect, statement.Text) {
+			if !slices.Contains(names, name) {
+				names = append(names, name)
+			}
+		}
+	}
+	if len(names) == 0 {
+		return false
+	}
+
+	values := map[string]any{}
+	var ask func(i int)
+	ask = func(i int) {
+		if i == len(names) {
+			bound := make([]db.Statement, len(statements))
+			for j, statement := range statements {
+				statement.Params = values
+				bound[j] = statement
+			}
+			retry(bound)
+			return
+		}
+
+		name := names[i]
+		title := fmt.Sprintf("Parameter %d of %d", i+1, len(names))
+		d.prompt(title, ":"+name+" = ", d.parameters[name], func(value string) {
+			d.parameters[name] = value
+			values[name] = db.ParameterValue(value)
+			ask(i + 1)
+		})
+	}
+	ask(0)
+
+	if d.appFocusHandler != nil {
+		d.appFocusHandler()
+	}
+	return true
+}
@@ -0,0 +1,628 @@
+package database
+
+import (
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/gdamore/tcell/v2"
+	"github.com/rivo/tview"
+	"github.com/rivo/uniseg"
+	"github.com/shangyanjin/gocmder/internal/db"
+	"github.com/shangyanjin/gocmder/internal/ui/style"
+)
+
+// maxCompletions is the number of completions listed at once
+const maxCompletions = 8
+
+// completionKind tells what a completion names, the list shows columns first
+type completionKind int
+
+const (
+	completeColumn completionKind = iota
+	completeTable
+	completeKeyword
+)
+
+// completionKindNames are shown next to the completions
+var completionKindNames = map[completionKind]string{
+	completeColumn:  "column",
+	completeTable:   "table",
+	completeKeyword: "keyword",
+}
+
+// completion is a word offered by the completion list
+type completion struct {
+	label string // name shown and matched against the typed word
+	text  string // text inserted, e.g. the quoted name
+	kind  completionKind
+}
+
+// completer returns the completions for a word of statement. qualifier is
+// the name before a dot, e.g. the alias in "o.na", and empty otherwise.
+type completer func(statement, qualifier string) []completion
+
+// SQLEditor is a text area for SQL scripts. It highlights the syntax of the
+// visible lines, marks the bracket matching the one at the cursor, keeps the
+// indent of new lines and completes the word before the cursor with Tab.
+type SQLEditor struct {
+	*tview.TextArea
+
+	dialect  db.Dialect
+	complete completer
+
+	// Syntax of the text, updated before it is used after the text changed
+	stale      bool
+	text       string
+	tokens     []db.Token
+	lineStarts []int // byte offset of each line
+
+	list        *tview.Table // completion list, shown while there are completions
+	completions []completion
+	pending     int // cursor of a completion waiting for metadata, -1 if none
+}
+
+// NewSQLEditor returns a new SQL editor, complete returns the completions
+// of a word
+func NewSQLEditor(complete completer) *SQLEditor {
+	editor := &SQLEditor{
+		TextArea: tview.NewTextArea(),
+		complete: complete,
+		stale:    true,
+		pending:  -1,
+	}
+
+	// Lines are not wrapped so that each text line is one screen row
+	editor.SetWrap(false)
+	editor.SetChangedFunc(func() {
+		editor.stale = true
+		editor.pending = -1
+	})
+
+	editor.list = tview.NewTable()
+	editor.list.SetBorder(true)
+	editor.list.SetBorderColor(style.DialogBorderColor)
+	editor.list.SetBackgroundColor(style.DialogBgColor)
+	editor.list.SetSelectable(true, false)
+	editor.list.SetSelectedStyle(tcell.StyleDefault.
+		Foreground(style.PageHeaderFgColor).
+		Background(style.PageHeaderBgColor))
+
+	return editor
+}
+
+// SetDialect sets the dialect whose keywords, literals and comments are highlighted
+func (e *SQLEditor) SetDialect(dialect db.Dialect) {
+	if dialect != e.dialect {
+		e.dialect = dialect
+		e.stale = true
+	}
+}
+
+// Completing returns true if the completion list is shown
+func (e *SQLEditor) Completing() bool {
+	return len(e.completions) > 0
+}
+
+// CapturesKey returns true if the editor handles a key the application
+// would otherwise use, e.g. q to quit or Tab to switch pages
+func (e *SQLEditor) CapturesKey(event *tcell.EventKey) bool {
+	switch event.Key() {
+	case tcell.KeyRune, tcell.KeyTab:
+		return true
+	case tcell.KeyEscape:
+		return e.Completing()
+	}
+	return false
+}
+
+// updateSyntax tokenizes the text again if it changed. Only the text is
+// tokenized, the lines are styled when they are drawn.
+func (e *SQLEditor) updateSyntax() {
+	if !e.stale {
+		return
+	}
+	e.stale = false
+
+	e.text = e.GetText()
+	e.tokens = db.Tokenize(e.dialect, e.text)
+	e.lineStarts = append(e.lineStarts[:0], 0)
+	for i := 0; i < len(e.text); i++ {
+		if e.text[i] == '\n' {
+			e.lineStarts = append(e.lineStarts, i+1)
+		}
+	}
+}
+
+// tokenAt returns the index of the token containing the byte at offset, -1
+// if it is white space
+func (e *SQLEditor) tokenAt(offset int) int {
+	i := sort.Search(len(e.tokens), func(i int) bool {
+		return e.tokens[i].End > offset
+	})
+	if i < len(e.tokens) && e.tokens[i].Start <= offset && offset >= 0 {
+		return i
+	}
+	return -1
+}
+
+// syntaxStyle returns the style of a token kind, false if it is drawn in
+// the text style
+func syntaxStyle(kind db.TokenKind, textStyle tcell.Style) (tcell.Style, bool) {
+	switch kind {
+	case db.TokenKeyword:
+		return textStyle.Foreground(style.SyntaxKeywordColor), true
+	case db.TokenString:
+		return textStyle.Foreground(style.SyntaxStringColor), true
+	case db.TokenNumber:
+		return textStyle.Foreground(style.SyntaxNumberColor), true
+	case db.TokenComment:
+		return textStyle.Foreground(style.SyntaxCommentColor), true
+	case db.TokenQuotedIdentifier:
+		return textStyle.Foreground(style.SyntaxIdentifierColor), true
+	case db.TokenParameter:
+		return textStyle.Foreground(style.SyntaxParameterColor), true
+	}
+	return textStyle, false
+}
+
+// Draw draws the text area and colors the visible text by its syntax.
+// Selected text keeps the selection style.
+func (e *SQLEditor) Draw(screen tcell.Screen) {
+	e.TextArea.Draw(screen)
+	e.updateSyntax()
+	if e.text == "" {
+		return
+	}
+
+	x, y, width, height := e.GetInnerRect()
+	rowOffset, columnOffset := e.GetOffset()
+	textStyle := e.GetTextStyle()
+	bracket, match := e.matchBracket()
+
+	for row := 0; row < height && rowOffset+row < len(e.lineStarts); row++ {
+		start := e.lineStarts[rowOffset+row]
+		end := len(e.text)
+		if rowOffset+row+1 < len(e.lineStarts) {
+			end = e.lineStarts[rowOffset+row+1] - 1
+		}
+		token := sort.Search(len(e.tokens), func(i int) bool {
+			return e.tokens[i].End > start
+		})
+
+		line, offset, column, state := e.text[start:end], start, -columnOffset, -1
+		for line != "" && column < width {
+			var cluster string
+			var boundaries int
+			cluster, line, boundaries, state = uniseg.StepString(line, state)
+			clusterWidth := boundaries >> uniseg.ShiftWidth
+			if cluster == "\t" {
+				clusterWidth = tview.TabSize
+			}
+			for token < len(e.tokens) && e.tokens[token].End <= offset {
+				token++
+			}
+
+			if column >= 0 && clusterWidth > 0 && column+clusterWidth <= width {
+				cellStyle, styled := textStyle, false
+				if token < len(e.tokens) && e.tokens[token].Start <= offset {
+					cellStyle, styled = syntaxStyle(e.tokens[token].Kind, textStyle)
+				}
+				switch offset {
+				case bracket:
+					if match < 0 {
+						cellStyle = cellStyle.Foreground(style.StatusErrorColor)
+					} else {
+						cellStyle = cellStyle.Background(style.BracketMatchBgColor)
+					}
+					styled = true
+				case match:
+					cellStyle = cellStyle.Background(style.BracketMatchBgColor)
+					styled = true
+				}
+
+				if styled {
+					if mainc, combc, current, _ := screen.GetContent(x+column, y+row); current == textStyle {
+						screen.SetContent(x+column, y+row, mainc, combc, cellStyle)
+					}
+				}
+			}
+
+			column += clusterWidth
+			offset += len(cluster)
+		}
+	}
+}
+
+// matchBracket returns the byte offsets of the bracket at or before the
+// cursor and of its match. The match is -1 if the bracket has none, the
+// bracket is -1 if there is no bracket at the cursor.
+func (e *SQLEditor) matchBracket() (int, int) {
+	if e.HasSelection() {
+		return -1, -1
+	}
+	_, cursor, _ := e.GetSelection()
+
+	i := e.tokenAt(cursor)
+	if i < 0 || e.tokens[i].Kind != db.TokenBracket {
+		i = e.tokenAt(cursor - 1)
+	}
+	if i < 0 || e.tokens[i].Kind != db.TokenBracket {
+		return -1, -1
+	}
+
+	pairs := map[byte]byte{'(': ')', '[': ']', '{': '}', ')': '(', ']': '[', '}': '{'}
+	bracket := e.text[e.tokens[i].Start]
+	step := 1
+	if strings.IndexByte(")]}", bracket) >= 0 {
+		step = -1
+	}
+
+	depth := 0
+	for j := i; j >= 0 && j < len(e.tokens); j += step {
+		if e.tokens[j].Kind != db.TokenBracket {
+			continue
+		}
+		switch e.text[e.tokens[j].Start] {
+		case bracket:
+			depth++
+		case pairs[bracket]:
+			depth--
+			if depth == 0 {
+				return e.tokens[i].Start, e.tokens[j].Start
+			}
+		}
+	}
+	return e.tokens[i].Start, -1
+}
+
+// InputHandler returns the handler for this primitive
+func (e *SQLEditor) InputHandler() func(event *tcell.EventKey, setFocus func(p tview.Primitive)) {
+	return e.WrapInputHandler(func(event *tcell.EventKey, setFocus func(p tview.Primitive)) {
+		if e.Completing() && e.handleCompletionKey(event) {
+			return
+		}
+
+		switch {
+		case event.Key() == tcell.KeyTab:
+			if e.startCompletion() {
+				return
+			}
+		case event.Key() == tcell.KeyEnter:
+			e.insertNewLine()
+			return
+		case event.Key() == tcell.KeyRune && event.Rune() == ')':
+			if e.closeBracket() {
+				return
+			}
+		}
+
+		if handler := e.TextArea.InputHandler(); handler != nil {
+			handler(event, setFocus)
+		}
+
+		// The list follows the word being typed
+		if e.Completing() {
+			e.updateCompletions()
+		}
+	})
+}
+
+// insertNewLine breaks the line at the cursor and indents the new line like
+// the current one, one level deeper after an opening bracket. Enter between
+// two brackets moves the closing bracket to a line of its own.
+func (e *SQLEditor) insertNewLine() {
+	e.updateSyntax()
+	_, start, end := e.GetSelection()
+
+	lineStart := strings.LastIndexByte(e.text[:start], '\n') + 1
+	before := e.text[lineStart:start]
+	indent := before[:len(before)-len(strings.TrimLeft(before, " \t"))]
+	insert := "\n" + indent
+
+	if strings.HasSuffix(strings.TrimRight(before, " \t"), "(") {
+		insert += indentUnit(indent)
+		rest := strings.TrimLeft(e.text[end:], " \t")
+		if strings.HasPrefix(rest, ")") {
+			e.Replace(start, len(e.text)-len(rest), insert+"\n"+indent)
+			e.Select(start+len(insert), start+len(insert))
+			return
+		}
+	}
+	e.Replace(start, end, insert)
+}
+
+// closeBracket types a closing bracket on a line with nothing but the
+// indent before the cursor, removing one level of the indent. It returns
+// false if the bracket is typed as usual.
+func (e *SQLEditor) closeBracket() bool {
+	if e.HasSelection() {
+		return false
+	}
+	e.updateSyntax()
+	_, cursor, _ := e.GetSelection()
+
+	lineStart := strings.LastIndexByte(e.text[:cursor], '\n') + 1
+	indent := e.text[lineStart:cursor]
+	if indent == "" || strings.TrimLeft(indent, " \t") != "" {
+		return false
+	}
+
+	if strings.HasSuffix(indent, "\t") {
+		indent = indent[:len(indent)-1]
+	} else {
+		spaces := len(indent) - len(strings.TrimRight(indent, " "))
+		indent = indent[:len(indent)-min(spaces, tview.TabSize)]
+	}
+	e.Replace(lineStart, cursor, indent+")")
+	return true
+}
+
+// indentUnit returns one indent level for a line indented with indent,
+// spaces if the line is indented with spaces only and a tab otherwise
+func indentUnit(indent string) string {
+	if indent != "" && !strings.Contains(indent, "\t") {
+		return strings.Repeat(" ", tview.TabSize)
+	}
+	return "\t"
+}
+
+// isNameRune returns true if r may be part of an unquoted name
+func isNameRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r) || r == '_' || r == '$'
+}
+
+// wordAt returns the start of the word before the cursor, the word and the
+// name before a dot in front of it. It returns false if there is nothing to
+// complete, e.g. in a string or a comment.
+func (e *SQLEditor) wordAt(cursor int) (int, string, string, bool) {
+	e.updateSyntax()
+	if i := e.tokenAt(cursor - 1); i >= 0 {
+		switch e.tokens[i].Kind {
+		case db.TokenString, db.TokenComment, db.TokenQuotedIdentifier, db.TokenNumber, db.TokenParameter:
+			return 0, "", "", false
+		}
+	}
+
+	start := cursor
+	for start > 0 {
+		r, size := utf8.DecodeLastRuneInString(e.text[:start])
+		if !isNameRune(r) {
+			break
+		}
+		start -= size
+	}
+	word := e.text[start:cursor]
+
+	qualifier := ""
+	if start > 0 && e.text[start-1] == '.' {
+		if i := e.tokenAt(start - 2); i >= 0 && (e.tokens[i].Kind == db.TokenWord || e.tokens[i].Kind == db.TokenQuotedIdentifier) {
+			qualifier = strings.Trim(e.text[e.tokens[i].Start:e.tokens[i].End], "\"`[]")
+		}
+	}
+
+	if word == "" && qualifier == "" {
+		return 0, "", "", false
+	}
+	return start, word, qualifier, true
+}
+
+// matchCompletions returns the completions starting with word, ignoring
+// case, for the statement at the cursor
+func (e *SQLEditor) matchCompletions(cursor int, word, qualifier string) []completion {
+	statement := ""
+	statements := db.Split(e.dialect, e.text)
+	if i := db.StatementAt(statements, cursor); i >= 0 {
+		statement = statements[i].Text
+	}
+
+	prefix := strings.ToLower(word)
+	seen := map[string]bool{}
+	var matches []completion
+	for _, match := range e.complete(statement, qualifier) {
+		label := strings.ToLower(match.label)
+		if !strings.HasPrefix(label, prefix) || seen[label] {
+			continue
+		}
+		seen[label] = true
+
+		// Keywords follow the case of the typed word
+		if match.kind == completeKeyword && word != "" && word == strings.ToLower(word) {
+			match.text = strings.ToLower(match.text)
+		}
+		matches = append(matches, match)
+	}
+
+	return matches
+}
+
+// startCompletion completes the word before the cursor, or lists the
+// completions if there are several. It returns false if there is no word,
+// Tab then inserts a tab.
+func (e *SQLEditor) startCompletion() bool {
+	if e.complete == nil || e.HasSelection() {
+		return false
+	}
+	_, cursor, _ := e.GetSelection()
+	start, word, qualifier, ok := e.wordAt(cursor)
+	if !ok {
+		return false
+	}
+
+	completions := e.matchCompletions(cursor, word, qualifier)
+	switch len(completions) {
+	case 0:
+		// Tables and columns may still be loading, RefreshCompletions
+		// completes once they are there
+		e.pending = cursor
+	case 1:
+		if completions[0].text != word {
+			e.Replace(start, cursor, completions[0].text)
+		}
+	default:
+		if prefix := commonPrefix(completions); len(prefix) > len(word) {
+			e.Replace(start, cursor, prefix)
+		}
+		e.showCompletions(completions)
+	}
+	return true
+}
+
+// commonPrefix returns the text all completions start with
+func commonPrefix(completions []completion) string {
+	prefix := completions[0].text
+	for _, completion := range completions[1:] {
+		for !strings.HasPrefix(completion.text, prefix) {
+			prefix = prefix[:len(prefix)-1]
+		}
+	}
+	// A prefix ending in the middle of a character is cut before it
+	for !utf8.ValidString(prefix) {
+		prefix = prefix[:len(prefix)-1]
+	}
+	return prefix
+}
+
+// showCompletions lists completions, selecting the first
+func (e *SQLEditor) showCompletions(completions []completion) {
+	e.completions = completions
+	e.list.Clear()
+	for row, completion := range completions {
+		label := tview.NewTableCell(tview.Escape(completion.label))
+		label.SetTextColor(style.DialogFgColor)
+		label.SetExpansion(1)
+		kind := tview.NewTableCell(completionKindNames[completion.kind])
+		kind.SetTextColor(style.BorderColor)
+		kind.SetAlign(tview.AlignRight)
+		e.list.SetCell(row, 0, label)
+		e.list.SetCell(row, 1, kind)
+	}
+	e.list.Select(0, 0)
+	e.list.ScrollToBeginning()
+}
+
+// updateCompletions lists the completions of the word at the cursor again,
+// the list is hidden if there are none
+func (e *SQLEditor) updateCompletions() {
+	_, cursor, _ := e.GetSelection()
+	_, word, qualifier, ok := e.wordAt(cursor)
+	if !ok || e.HasSelection() {
+		e.hideCompletions()
+		return
+	}
+
+	completions := e.matchCompletions(cursor, word, qualifier)
+	if len(completions) == 0 {
+		e.hideCompletions()
+		return
+	}
+	e.showCompletions(completions)
+}
+
+// hideCompletions hides the completion list
+func (e *SQLEditor) hideCompletions() {
+	e.completions = nil
+	e.list.Clear()
+}
+
+// RefreshCompletions updates the completion list after tables or columns
+// were loaded. A Tab that found nothing while they were loading completes
+// now if the cursor has not moved.
+func (e *SQLEditor) RefreshCompletions() {
+	if e.Completing() {
+		e.updateCompletions()
+		return
+	}
+
+	_, cursor, _ := e.GetSelection()
+	if e.pending >= 0 && e.pending == cursor && !e.HasSelection() {
+		e.pending = -1
+		e.startCompletion()
+	}
+}
+
+// handleCompletionKey handles a key while the completion list is shown. It
+// returns false if the key is left to the text area.
+func (e *SQLEditor) handleCompletionKey(event *tcell.EventKey) bool {
+	row, _ := e.list.GetSelection()
+	switch event.Key() {
+	case tcell.KeyUp:
+		e.list.Select(max(row-1, 0), 0)
+	case tcell.KeyDown:
+		e.list.Select(min(row+1, len(e.completions)-1), 0)
+	case tcell.KeyPgUp:
+		e.list.Select(max(row-maxCompletions, 0), 0)
+	case tcell.KeyPgDn:
+		e.list.Select(min(row+maxCompletions, len(e.completions)-1), 0)
+	case tcell.KeyEnter, tcell.KeyTab:
+		e.acceptCompletion(row)
+	case tcell.KeyEscape:
+		e.hideCompletions()
+	case tcell.KeyRune, tcell.KeyBackspace, tcell.KeyBackspace2:
+		// Typing changes the word, the list is updated afterwards
+		return false
+	default:
+		e.hideCompletions()
+		return false
+	}
+	return true
+}
+
+// acceptCompletion replaces the word before the cursor with a completion
+func (e *SQLEditor) acceptCompletion(row int) {
+	if row < 0 || row >= len(e.completions) {
+		e.hideCompletions()
+		return
+	}
+	completion := e.completions[row]
+	e.hideCompletions()
+
+	_, cursor, _ := e.GetSelection()
+	if start, _, _, ok := e.wordAt(cursor); ok {
+		e.Replace(start, cursor, completion.text)
+	}
+}
+
+// DrawCompletions draws the completion list below the word being completed,
+// or above it if there is no room below. The page draws it after its panels
+// so that it may cover the results.
+func (e *SQLEditor) DrawCompletions(screen tcell.Screen) {
+	if !e.Completing() {
+		return
+	}
+	if !e.HasFocus() {
+		e.hideCompletions()
+		return
+	}
+
+	_, cursor, _ := e.GetSelection()
+	start, _, _, ok := e.wordAt(cursor)
+	if !ok {
+		return
+	}
+
+	x, y, _, _ := e.GetInnerRect()
+	rowOffset, columnOffset := e.GetOffset()
+	_, _, row, column := e.GetCursor()
+	column -= uniseg.StringWidth(e.text[start:cursor])
+
+	labelWidth, kindWidth := 0, 0
+	for _, completion := range e.completions {
+		labelWidth = max(labelWidth, uniseg.StringWidth(completion.label))
+		kindWidth = max(kindWidth, len(completionKindNames[completion.kind]))
+	}
+	width := labelWidth + 1 + kindWidth + 2
+	height := min(len(e.completions), maxCompletions) + 2
+
+	screenWidth, screenHeight := screen.Size()
+	left := x + column - columnOffset - 1
+	top := y + row - rowOffset + 1
+	if top+height > screenHeight && top-1-height >= 0 {
+		top = top - 1 - height
+	}
+	left = max(0, min(left, screenWidth-width))
+
+	e.list.SetRect(left, top, width, height)
+	e.list.Draw(screen)
+}
@@ -0,0 +1,188 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/shangyanjin/gocmder/internal/db"
+	"github.com/shangyanjin/gocmder/internal/ui/style"
+)
+
+// transactionInterval is how often the status bar shows the transaction age
+const transactionInterval = time.Second
+
+// transaction returns the open transaction of the session, false if there is none
+func (d *Database) transaction() (db.TransactionInfo, bool) {
+	if transactor, ok := d.driver.(db.Transactor); ok && d.connected {
+		return transactor.Transaction()
+	}
+	return db.TransactionInfo{}, false
+}
+
+// transactionStatus returns the status bar indicator of the open
+// transaction, empty if there is none
+func (d *Database) transactionStatus() string {
+	info, ok := d.transaction()
+	if !ok {
+		return ""
+	}
+
+	label := "TX " + time.Since(info.Started).Truncate(time.Second).String()
+	if info.Isolation != sql.LevelDefault {
+		label += ", " + info.Isolation.String()
+	}
+	return fmt.Sprintf(" [%s]%s[-] |", style.GetColorHex(style.StatusNotInstalledColor), label)
+}
+
+// beginTransaction pins a connection and starts a transaction on it, the
+// statements that follow run in it until it is committed or rolled back
+func (d *Database) beginTransaction() {
+	if !d.canExecute() {
+		return
+	}
+	transactor, ok := d.driver.(db.Transactor)
+	if !ok {
+		d.showError(fmt.Sprintf("%s does not support transactions", d.driver.GetDriverName()))
+		return
+	}
+	if _, ok := transactor.Transaction(); ok {
+		d.updateStatusBar("A transaction is already open. Press Alt+C to commit or Alt+R to roll back")
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), connectTimeout)
+	defer cancel()
+
+	if err := transactor.Begin(ctx, d.isolation); err != nil {
+		d.showError(fmt.Sprintf("Failed to begin transaction: %v", err))
+		return
+	}
+
+	done := make(chan struct{})
+	d.transactionDone = done
+	go d.showTransactionAge(done)
+	d.updateStatusBar("Transaction started. Alt+C: commit | Alt+R: roll back")
+}
+
+// endTransaction commits or rolls back the open transaction
+func (d *Database) endTransaction(commit bool) {
+	if !d.canExecute() {
+		return
+	}
+	info, ok := d.transaction()
+	if !ok {
+		d.updateStatusBar("No transaction is open. Press Alt+T to begin one")
+		return
+	}
+	// Ending the transaction closes the result set a fetch is reading
+	if d.fetchingRows() {
+		d.updateStatusBar("Rows are still being fetched. Wait for them before ending the transaction")
+		return
+	}
+
+	err := d.finishTransaction(commit)
+	age := time.Since(info.Started).Round(time.Millisecond)
+	switch {
+	case err != nil && commit:
+		d.showError(fmt.Sprintf("Commit failed: %v", err))
+	case err != nil:
+		d.showError(fmt.Sprintf("Rollback failed: %v", err))
+	case commit:
+		d.updateStatusBar(fmt.Sprintf("Transaction committed after %s", age))
+	default:
+		d.updateStatusBar(fmt.Sprintf("Transaction rolled back after %s", age))
+	}
+}
+
+// finishTransaction commits or rolls back the open transaction and stops
+// showing its age. The connection is released even if that fails.
+func (d *Database) finishTransaction(commit bool) error {
+	d.stopTransactionAge()
+
+	transactor := d.driver.(db.Transactor)
+	if commit {
+		return transactor.Commit()
+	}
+	// Tables created in the transaction are gone again
+	d.invalidateMetadata()
+	return transactor.Rollback()
+}
+
+// stopTransactionAge stops updating the transaction age in the status bar
+func (d *Database) stopTransactionAge() {
+	if d.transactionDone != nil {
+		close(d.transactionDone)
+		d.transactionDone = nil
+	}
+}
+
+// showTransactionAge updates the transaction age in the status bar until done is closed
+func (d *Database) showTransactionAge(done chan struct{}) {
+	ticker := time.NewTicker(transactionInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-done:
+			return
+		case <-ticker.C:
+			d.queueUpdateDraw(d.renderStatusBar)
+		}
+	}
+}
+
+// cycleIsolation switches the isolation level of the next transaction
+func (d *Database) cycleIsolation() {
+	if !d.connected || d.driver == nil {
+		d.showError("Not connected to database")
+		return
+	}
+
+	levels := db.IsolationLevels(d.driver.GetDialect())
+	if len(levels) == 1 {
+		d.updateStatusBar(fmt.Sprintf("%s transactions are always serializable", d.driver.GetDriverName()))
+		return
+	}
+	d.isolation = levels[(slices.Index(levels, d.isolation)+1)%len(levels)]
+
+	message := fmt.Sprintf("Isolation level: %s", d.isolation)
+	if _, ok := d.transaction(); ok {
+		message += ", used by the next transaction"
+	}
+	d.updateStatusBar(message)
+}
+
+// confirmEndTransaction asks before an open transaction is rolled back by
+// a disconnect. It returns true if the user is asked, retry runs once the
+// transaction has been rolled back.
+func (d *Database) confirmEndTransaction(retry func()) bool {
+	info, ok := d.transaction()
+	if !ok {
+		return false
+	}
+
+	age := time.Since(info.Started).Truncate(time.Second)
+	d.confirm("Open Transaction", fmt.Sprintf("A transaction has been open for %s. Roll it back?", age), func() {
+		// The rollback waits for a statement running in the transaction
+		d.abortQuery()
+		if err := d.finishTransaction(false); err != nil {
+			d.updateStatusBar(fmt.Sprintf("Rollback failed: %v", err))
+		}
+		retry()
+	})
+	return true
+}
+
+// NeedsQuitConfirm returns true if quitting would roll back an open transaction
+func (d *Database) NeedsQuitConfirm() bool {
+	_, ok := d.transaction()
+	return ok
+}
+
+// ConfirmQuit asks before an open transaction is rolled back by quitting
+func (d *Database) ConfirmQuit(quit func()) {
+	d.confirmEndTransaction(quit)
+}
@@ -128,6 +128,7 @@ About: [%s]Fast & automated installer and expert system setup for developers[-]
   • Press [%s]F6[-] to install Development Tools
   • Press [%s]F7[-] to configure System Settings
   • Press [%s]F8[-] to view detailed System Information
+  • Press [%s]F9[-] to browse Redis keys
 `,
 		headerColor,
 		valueColor, valueColor,
@@ -146,7 +147,7 @@ About: [%s]Fast & automated installer and expert system setup for developers[-]
 		headerColor,
 		highlightColor,
 		headerColor,
-		highlightColor, highlightColor, highlightColor, highlightColor, highlightColor,
+		highlightColor, highlightColor, highlightColor, highlightColor, highlightColor, highlightColor,
 	)
 
 	fmt.Fprint(h.systemInfoView, info)
@@ -169,7 +170,7 @@ func (h *Home) updateHelp() {
   [%s]F6[-]     Development Tools
   [%s]F7[-]     System Settings
   [%s]F8[-]     System Information
-  [%s]F9[-]     Reserved
+  [%s]F9[-]     Redis Key Browser
 
 [%s::b]Global Shortcuts:[-::-]
   [%s]Tab[-]       Cycle through pages
@@ -178,11 +179,36 @@ func (h *Home) updateHelp() {
 
 [%s::b]Database Manager (F4):[-::-]
   [%s]Ctrl+N[-]    New connection
+  [%s]Ctrl+R[-]    Run statement or selection
+  [%s]Ctrl+G[-]    Run all statements
+  [%s]ALT+X[-]     Explain query plan
+  [%s]ALT+T/C/R[-] Begin/commit/rollback tx
+  [%s]ALT+I[-]     Transaction isolation
+  [%s]ALT+D[-]     Compare result
+  [%s]ALT+S[-]     Compare schema
+  [%s]Ctrl+O[-]    Toggle stop on script error
+  [%s]Ctrl+P[-]    Search query history
+  [%s]Ctrl+T[-]    Saved snippets
+  [%s]Tab[-]       Complete word in editor
+  [%s]Ctrl+C/ESC[-] Cancel running query
+  [%s]Enter[-]     Show result cell detail
+  [%s][ / ][-]     Previous/next result tab
+  [%s]e/n/i/x[-]   Edit/NULL/add/delete row
+  [%s]Ctrl+S[-]    Apply staged changes
+  [%s]Ctrl+E[-]    Export result to file
+  [%s]y[-]         Copy cell/row/column
   [%s]ALT+M[-]     MySQL preset
   [%s]ALT+P[-]     PostgreSQL preset
   [%s]ALT+L[-]     SQLite preset
-  [%s]ALT+S[-]     Save connection
+  [%s]ALT+S[-]     Save session
   [%s]ALT+C[-]     Connect & close
+  [%s]Enter[-]     Connect saved session
+  [%s]Enter[-]     Inspect table structure
+  [%s]s[-]         Show/hide system databases
+  [%s]i[-]         Import CSV/JSON into table
+  [%s]D/R[-]       Dump/restore database
+  [%s]e/r/c/d[-]   Edit/rename/copy/delete session
+  [%s]L[-]         Lock credential vault
 
 [%s::b]Development Tools (F6):[-::-]
   [%s]Space[-]     Toggle selection
@@ -194,6 +220,13 @@ func (h *Home) updateHelp() {
   [%s]Space[-]     Toggle selection
   [%s]Enter[-]     Apply settings
 
+[%s::b]Redis Browser (F9):[-::-]
+  [%s]Ctrl+N[-]    New connection
+  [%s]/[-]         Filter keys (MATCH pattern)
+  [%s]e[-]         Edit value
+  [%s]t[-]         Set TTL
+  [%s]d[-]         Delete key / element
+
 [%s::b]About:[-::-]
   Fast & automated installer and expert system
   setup for developers.
@@ -205,11 +238,18 @@ func (h *Home) updateHelp() {
 		highlightColor, highlightColor, highlightColor,
 		headerColor,
 		highlightColor, highlightColor, highlightColor, highlightColor, highlightColor, highlightColor,
+		highlightColor, highlightColor, highlightColor, highlightColor, highlightColor, highlightColor,
+		highlightColor, highlightColor, highlightColor, highlightColor, highlightColor, highlightColor,
+		highlightColor, highlightColor, highlightColor, highlightColor, highlightColor, highlightColor,
+		highlightColor, highlightColor, highlightColor, highlightColor, highlightColor, highlightColor,
+		highlightColor,
 		headerColor,
 		highlightColor, highlightColor, highlightColor, highlightColor,
 		headerColor,
 		highlightColor, highlightColor,
 		headerColor,
+		highlightColor, highlightColor, highlightColor, highlightColor, highlightColor,
+		headerColor,
 	)
 
 	fmt.Fprint(h.helpView, help)
@@ -0,0 +1,232 @@
+package redis
+
+import (
+	"net"
+	"net/url"
+
+	"github.com/gdamore/tcell/v2"
+	"github.com/rivo/tview"
+	"github.com/shangyanjin/gocmder/internal/ui/style"
+	"github.com/shangyanjin/gocmder/internal/ui/utils"
+)
+
+const (
+	connDialogWidth  = 60
+	connDialogHeight = 16
+)
+
+// ConnectionDialog is a dialog for Redis connection
+type ConnectionDialog struct {
+	*tview.Box
+
+	layout          *tview.Flex
+	form            *tview.Form
+	display         bool
+	connectFunc     func(dsn string)
+	appFocusHandler func()
+	host            string
+	port            string
+	username        string
+	password        string
+	database        string
+}
+
+// NewConnectionDialog creates a new Redis connection dialog
+func NewConnectionDialog(connectFunc func(dsn string)) *ConnectionDialog {
+	bgColor := style.DialogBgColor
+
+	dialog := &ConnectionDialog{
+		Box:         tview.NewBox(),
+		display:     false,
+		connectFunc: connectFunc,
+		host:        "localhost",
+		port:        "6379",
+		database:    "0",
+	}
+
+	// Create form
+	dialog.form = tview.NewForm()
+	dialog.form.SetBackgroundColor(bgColor)
+	dialog.form.SetButtonBackgroundColor(style.ButtonBgColor)
+	dialog.form.SetFieldBackgroundColor(style.BgColor)
+	dialog.form.SetLabelColor(style.FgColor)
+	dialog.form.SetFieldTextColor(style.FgColor)
+
+	dialog.form.AddInputField("Host", dialog.host, 30, nil, func(text string) {
+		dialog.host = text
+	})
+
+	dialog.form.AddInputField("Port", dialog.port, 10, nil, func(text string) {
+		dialog.port = text
+	})
+
+	dialog.form.AddInputField("Username", dialog.username, 30, nil, func(text string) {
+		dialog.username = text
+	})
+
+	dialog.form.AddPasswordField("Password", "", 30, '*', func(text string) {
+		dialog.password = text
+	})
+
+	dialog.form.AddInputField("DB Index", dialog.database, 10, tview.InputFieldInteger, func(text string) {
+		dialog.database = text
+	})
+
+	// Add buttons
+	dialog.form.AddButton("Connect", func() {
+		dialog.handleConnect()
+	})
+
+	dialog.form.AddButton("Cancel", func() {
+		dialog.Hide()
+		// Restore focus to parent page
+		if dialog.appFocusHandler != nil {
+			dialog.appFocusHandler()
+		}
+	})
+
+	dialog.form.SetButtonsAlign(tview.AlignCenter)
+
+	// Create keyboard shortcuts hint with consistent style
+	highlightColor := style.GetColorHex(style.StatusInstalledColor)
+	shortcutsHint := tview.NewTextView()
+	shortcutsHint.SetBackgroundColor(bgColor)
+	shortcutsHint.SetTextColor(style.FgColor)
+	shortcutsHint.SetDynamicColors(true)
+	shortcutsHint.SetText(" [" + highlightColor + "]ALT+C[-] Connect | [" + highlightColor + "]ESC[-] Cancel")
+
+	dialog.layout = tview.NewFlex().SetDirection(tview.FlexRow)
+	dialog.layout.AddItem(dialog.form, 0, 1, true)
+	dialog.layout.AddItem(shortcutsHint, 1, 0, false)
+	dialog.layout.SetBorder(true)
+	dialog.layout.SetTitle(" Redis Connection ")
+	dialog.layout.SetTitleColor(style.FgColor)
+	dialog.layout.SetBorderColor(style.DialogBorderColor)
+	dialog.layout.SetBackgroundColor(bgColor)
+
+	return dialog
+}
+
+// Display displays this primitive
+func (d *ConnectionDialog) Display() {
+	d.display = true
+}
+
+// IsDisplay returns true if primitive is shown
+func (d *ConnectionDialog) IsDisplay() bool {
+	return d.display
+}
+
+// Hide stops displaying this primitive
+func (d *ConnectionDialog) Hide() {
+	d.display = false
+}
+
+// HasFocus returns whether or not this primitive has focus
+func (d *ConnectionDialog) HasFocus() bool {
+	return d.display && (d.form.HasFocus() || d.Box.HasFocus())
+}
+
+// Focus is called when this primitive receives focus
+func (d *ConnectionDialog) Focus(delegate func(p tview.Primitive)) {
+	delegate(d.form)
+}
+
+// SetAppFocusHandler sets the app focus handler
+func (d *ConnectionDialog) SetAppFocusHandler(handler func()) {
+	d.appFocusHandler = handler
+}
+
+// buildDSN builds a redis:// URL from the form fields
+func (d *ConnectionDialog) buildDSN() string {
+	u := &url.URL{
+		Scheme: "redis",
+		Host:   net.JoinHostPort(d.host, d.port),
+		Path:   "/" + d.database,
+	}
+
+	if d.username != "" && d.password != "" {
+		u.User = url.UserPassword(d.username, d.password)
+	} else if d.password != "" {
+		u.User = url.UserPassword("", d.password)
+	}
+
+	return u.String()
+}
+
+// handleConnect connects and closes the dialog
+func (d *ConnectionDialog) handleConnect() {
+	d.Hide()
+
+	if d.connectFunc != nil {
+		d.connectFunc(d.buildDSN())
+	}
+
+	// Restore focus to parent page
+	if d.appFocusHandler != nil {
+		d.appFocusHandler()
+	}
+}
+
+// InputHandler returns input handler function for this primitive
+func (d *ConnectionDialog) InputHandler() func(event *tcell.EventKey, setFocus func(p tview.Primitive)) {
+	return d.WrapInputHandler(func(event *tcell.EventKey, setFocus func(p tview.Primitive)) {
+		if event.Key() == utils.CloseDialogKey.Key {
+			d.Hide()
+			// Restore focus to parent page
+			if d.appFocusHandler != nil {
+				d.appFocusHandler()
+			}
+			return
+		}
+
+		if event.Key() == tcell.KeyRune && event.Modifiers()&tcell.ModAlt != 0 {
+			switch event.Rune() {
+			case 'c', 'C': // Alt+C for Connect and close
+				d.handleConnect()
+				return
+			}
+		}
+
+		if d.form.HasFocus() {
+			if formHandler := d.form.InputHandler(); formHandler != nil {
+				formHandler(event, setFocus)
+				return
+			}
+		}
+	})
+}
+
+// SetRect sets rects for this primitive
+func (d *ConnectionDialog) SetRect(x, y, width, height int) {
+	ws := (width - connDialogWidth) / 2
+	hs := (height - connDialogHeight) / 2
+	dy := y + hs
+	bWidth := connDialogWidth
+	bHeight := connDialogHeight
+
+	if connDialogWidth > width {
+		ws = 0
+		bWidth = width - 1
+	}
+
+	if connDialogHeight >= height {
+		dy = y + 1
+		bHeight = height - 1
+	}
+
+	d.Box.SetRect(x+ws, dy, bWidth, bHeight)
+
+	x, y, width, height = d.GetInnerRect()
+	d.layout.SetRect(x, y, width, height)
+}
+
+// Draw draws this primitive onto the screen
+func (d *ConnectionDialog) Draw(screen tcell.Screen) {
+	if !d.display {
+		return
+	}
+
+	d.DrawForSubclass(screen, d)
+	d.layout.Draw(screen)
+}
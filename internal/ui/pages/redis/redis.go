@@ -0,0 +1,1011 @@
+package redis
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gdamore/tcell/v2"
+	"github.com/rivo/tview"
+	"github.com/shangyanjin/gocmder/internal/db"
+	"github.com/shangyanjin/gocmder/internal/ui/components/dialogs"
+	"github.com/shangyanjin/gocmder/internal/ui/style"
+)
+
+const (
+	// keySeparator splits key names into tree groups
+	keySeparator = ":"
+	// scanPageSize is the number of keys loaded per page
+	scanPageSize = 500
+	// scanCount is the COUNT hint passed to each SCAN call
+	scanCount = 100
+	// scanMaxCalls caps the SCAN round trips per page when few keys match
+	scanMaxCalls = 100
+)
+
+const (
+	focusTree = iota
+	focusValue
+)
+
+// Redis implements the Redis key browser page primitive
+type Redis struct {
+	*tview.Box
+
+	title           string
+	mainFlex        *tview.Flex
+	keyTree         *tview.TreeView
+	infoView        *tview.TextView
+	valuePages      *tview.Pages
+	valueText       *tview.TextView
+	valueTable      *tview.Table
+	statusBar       *tview.TextView
+	errorDialog     *dialogs.ErrorDialog
+	confirmDialog   *dialogs.ConfirmDialog
+	inputDialog     *dialogs.SimpleInputDialog
+	connDialog      *ConnectionDialog
+	driver          db.KVDriver
+	connected       bool
+	pattern         string
+	cursor          string
+	keys            []string
+	keySet          map[string]bool
+	expanded        map[string]bool
+	entry           *db.KVEntry
+	confirmAction   func()
+	busy            bool // a Redis call is running in the background
+	queueUpdateDraw func(f func())
+	focusedElement  int
+	appFocusHandler func()
+}
+
+// NewRedis returns Redis key browser page view
+func NewRedis() *Redis {
+	page := &Redis{
+		Box:           tview.NewBox(),
+		title:         "redis",
+		errorDialog:   dialogs.NewErrorDialog(),
+		confirmDialog: dialogs.NewConfirmDialog(),
+		inputDialog:   dialogs.NewSimpleInputDialog(""),
+		pattern:       "*",
+		keySet:        make(map[string]bool),
+		expanded:      make(map[string]bool),
+		queueUpdateDraw: func(f func()) {
+			f()
+		},
+		focusedElement: focusTree,
+	}
+
+	// Create key tree
+	page.keyTree = tview.NewTreeView()
+	page.keyTree.SetBorder(true)
+	page.keyTree.SetTitle(" Keys ")
+	page.keyTree.SetTitleColor(style.FgColor)
+	page.keyTree.SetBorderColor(style.BorderColor)
+	page.keyTree.SetBackgroundColor(style.BgColor)
+	page.keyTree.SetGraphicsColor(style.StatusInstalledColor)
+	page.setNotConnectedRoot()
+
+	// Create key info view
+	page.infoView = tview.NewTextView()
+	page.infoView.SetBorder(true)
+	page.infoView.SetTitle(" Key Info ")
+	page.infoView.SetTitleColor(style.FgColor)
+	page.infoView.SetBorderColor(style.BorderColor)
+	page.infoView.SetBackgroundColor(style.BgColor)
+	page.infoView.SetTextColor(style.FgColor)
+	page.infoView.SetDynamicColors(true)
+
+	// Create value views, text for strings and table for collections
+	page.valueText = tview.NewTextView()
+	page.valueText.SetBorder(true)
+	page.valueText.SetTitle(" Value ")
+	page.valueText.SetTitleColor(style.FgColor)
+	page.valueText.SetBorderColor(style.BorderColor)
+	page.valueText.SetBackgroundColor(style.BgColor)
+	page.valueText.SetTextColor(style.FgColor)
+	page.valueText.SetWrap(true)
+
+	page.valueTable = tview.NewTable()
+	page.valueTable.SetBorder(true)
+	page.valueTable.SetTitle(" Value ")
+	page.valueTable.SetTitleColor(style.FgColor)
+	page.valueTable.SetBorderColor(style.BorderColor)
+	page.valueTable.SetBackgroundColor(style.BgColor)
+	page.valueTable.SetSelectable(true, false)
+	page.valueTable.SetFixed(1, 0)
+
+	page.valuePages = tview.NewPages()
+	page.valuePages.AddPage("text", page.valueText, true, true)
+	page.valuePages.AddPage("table", page.valueTable, true, false)
+
+	// Create status bar
+	page.statusBar = tview.NewTextView()
+	page.statusBar.SetBackgroundColor(style.InfoBarBgColor)
+	page.statusBar.SetTextColor(style.InfoBarFgColor)
+	page.statusBar.SetDynamicColors(true)
+	page.updateStatusBar("Ready. Press Ctrl+N to connect")
+
+	// Create layout
+	rightPanel := tview.NewFlex().SetDirection(tview.FlexRow)
+	rightPanel.AddItem(page.infoView, 5, 0, false)
+	rightPanel.AddItem(page.valuePages, 0, 1, false)
+
+	contentFlex := tview.NewFlex().SetDirection(tview.FlexColumn)
+	contentFlex.AddItem(page.keyTree, 0, 1, true)
+	contentFlex.AddItem(rightPanel, 0, 2, false)
+
+	page.mainFlex = tview.NewFlex().SetDirection(tview.FlexRow)
+	page.mainFlex.AddItem(contentFlex, 0, 1, true)
+	page.mainFlex.AddItem(page.statusBar, 1, 0, false)
+
+	// Create connection dialog
+	page.connDialog = NewConnectionDialog(page.handleConnect)
+	page.connDialog.SetAppFocusHandler(page.restoreFocus)
+
+	// Set dialog handlers with focus restoration
+	page.errorDialog.SetDoneFunc(func() {
+		page.errorDialog.Hide()
+		page.restoreFocus()
+	})
+	page.confirmDialog.SetSelectedFunc(func() {
+		page.confirmDialog.Hide()
+		if page.confirmAction != nil {
+			page.confirmAction()
+			page.confirmAction = nil
+		}
+		page.restoreFocus()
+	})
+	page.confirmDialog.SetCancelFunc(func() {
+		page.confirmDialog.Hide()
+		page.confirmAction = nil
+		page.restoreFocus()
+	})
+	page.inputDialog.SetCancelFunc(func() {
+		page.inputDialog.Hide()
+		page.restoreFocus()
+	})
+
+	page.keyTree.SetSelectedFunc(page.handleTreeSelection)
+
+	return page
+}
+
+// GetTitle returns primitive title
+func (r *Redis) GetTitle() string {
+	return r.title
+}
+
+// HasFocus returns whether or not this primitive has focus
+func (r *Redis) HasFocus() bool {
+	return r.mainFlex.HasFocus() || r.SubDialogHasFocus() || r.Box.HasFocus()
+}
+
+// Focus is called when this primitive receives focus
+func (r *Redis) Focus(delegate func(p tview.Primitive)) {
+	if r.errorDialog.IsDisplay() {
+		delegate(r.errorDialog)
+		return
+	}
+	if r.confirmDialog.IsDisplay() {
+		delegate(r.confirmDialog)
+		return
+	}
+	if r.inputDialog.IsDisplay() {
+		delegate(r.inputDialog)
+		return
+	}
+	if r.connDialog.IsDisplay() {
+		delegate(r.connDialog)
+		return
+	}
+
+	switch r.focusedElement {
+	case focusValue:
+		delegate(r.valuePages)
+	default:
+		delegate(r.keyTree)
+	}
+}
+
+// SetAppFocusHandler sets application focus handler
+func (r *Redis) SetAppFocusHandler(handler func()) {
+	r.appFocusHandler = handler
+}
+
+// SetQueueUpdateDraw sets the function used to apply background results on the UI goroutine
+func (r *Redis) SetQueueUpdateDraw(handler func(f func())) {
+	r.queueUpdateDraw = handler
+}
+
+// HideAllDialogs hides all sub dialogs
+func (r *Redis) HideAllDialogs() {
+	if r.errorDialog.IsDisplay() {
+		r.errorDialog.Hide()
+	}
+	if r.confirmDialog.IsDisplay() {
+		r.confirmDialog.Hide()
+	}
+	if r.inputDialog.IsDisplay() {
+		r.inputDialog.Hide()
+	}
+	if r.connDialog.IsDisplay() {
+		r.connDialog.Hide()
+	}
+}
+
+// SubDialogHasFocus returns whether or not sub dialog primitive has focus
+func (r *Redis) SubDialogHasFocus() bool {
+	return r.errorDialog.HasFocus() || r.confirmDialog.HasFocus() ||
+		r.inputDialog.HasFocus() || r.connDialog.HasFocus()
+}
+
+// restoreFocus gives focus back to this page
+func (r *Redis) restoreFocus() {
+	if r.appFocusHandler != nil {
+		r.appFocusHandler()
+	}
+}
+
+// updateStatusBar updates the status bar
+func (r *Redis) updateStatusBar(message string) {
+	highlightColor := style.GetColorHex(style.StatusInstalledColor)
+	r.statusBar.SetText(fmt.Sprintf(" [%s]Status:[-] %s", highlightColor, message))
+}
+
+// showError shows an error dialog
+func (r *Redis) showError(message string) {
+	r.errorDialog.SetTitle("Error")
+	r.errorDialog.SetText(message)
+	r.errorDialog.Display()
+}
+
+// confirm asks for confirmation before running action
+func (r *Redis) confirm(title, message string, action func()) {
+	r.confirmAction = action
+	r.confirmDialog.SetTitle(title)
+	r.confirmDialog.SetText(message)
+	r.confirmDialog.Display()
+}
+
+// prompt asks for a single line of input and passes it to handler
+func (r *Redis) prompt(title, label, text string, handler func(value string)) {
+	r.inputDialog.SetTitle(title)
+	r.inputDialog.SetLabel(label)
+	r.inputDialog.SetText(text)
+	r.inputDialog.SetSelectedFunc(func() {
+		value := r.inputDialog.GetText()
+		r.inputDialog.Hide()
+		handler(value)
+		r.restoreFocus()
+	})
+	r.inputDialog.Display()
+}
+
+// canRun returns false while another Redis call is running
+func (r *Redis) canRun() bool {
+	if r.busy {
+		r.updateStatusBar("Waiting for the previous Redis call to finish")
+		return false
+	}
+	return true
+}
+
+// runTask runs work against the driver in the background and passes its
+// error to done on the UI goroutine
+func (r *Redis) runTask(status string, work func(driver db.KVDriver) error, done func(err error)) {
+	if !r.canRun() {
+		return
+	}
+	r.busy = true
+	r.updateStatusBar(status)
+
+	driver := r.driver
+	go func() {
+		err := work(driver)
+		r.queueUpdateDraw(func() {
+			r.busy = false
+			if r.driver != driver {
+				// Disconnected meanwhile, the driver was left open for this call
+				if driver != nil {
+					driver.Close()
+				}
+				return
+			}
+
+			// Move focus to a dialog done opened unless the user left the page meanwhile
+			focused := r.HasFocus()
+			done(err)
+			if focused {
+				r.restoreFocus()
+			}
+		})
+	}()
+}
+
+// setNotConnectedRoot resets the key tree
+func (r *Redis) setNotConnectedRoot() {
+	rootNode := tview.NewTreeNode("Not Connected")
+	rootNode.SetColor(style.StatusNotInstalledColor)
+	r.keyTree.SetRoot(rootNode)
+	r.keyTree.SetCurrentNode(rootNode)
+}
+
+// handleConnect handles Redis connection
+func (r *Redis) handleConnect(dsn string) {
+	if !r.canRun() {
+		return
+	}
+	if r.driver != nil {
+		r.driver.Close()
+		r.driver = nil
+	}
+	r.connected = false
+
+	driver := db.NewRedis()
+	r.runTask("Connecting...", func(db.KVDriver) error {
+		return driver.Connect(dsn)
+	}, func(err error) {
+		if err != nil {
+			r.showError(fmt.Sprintf("Connection failed: %v", err))
+			r.updateStatusBar("Ready. Press Ctrl+N to connect")
+			return
+		}
+
+		r.driver = driver
+		r.connected = true
+		r.updateStatusBar(fmt.Sprintf("Connected to %s", driver.GetDriverName()))
+		r.reloadKeys()
+	})
+}
+
+// disconnect disconnects from Redis
+func (r *Redis) disconnect() {
+	if r.driver != nil {
+		// A running call closes the driver once it returns
+		if !r.busy {
+			r.driver.Close()
+		}
+		r.driver = nil
+	}
+
+	r.connected = false
+	r.resetKeys()
+	r.clearEntry()
+	r.setNotConnectedRoot()
+	r.updateStatusBar("Disconnected. Press Ctrl+N to connect")
+}
+
+// resetKeys clears loaded keys and restarts the scan cursor
+func (r *Redis) resetKeys() {
+	r.cursor = "0"
+	r.keys = nil
+	r.keySet = make(map[string]bool)
+}
+
+// reloadKeys restarts the scan and loads the first page of keys
+func (r *Redis) reloadKeys() {
+	r.scanKeys(r.pattern, true)
+}
+
+// loadMoreKeys scans the next page of keys
+func (r *Redis) loadMoreKeys() {
+	r.scanKeys(r.pattern, false)
+}
+
+// scanKeys scans a page of keys matching pattern in the background, from
+// the start of the keyspace if restart is set. A rarely matching pattern
+// stops after scanMaxCalls round trips with more keys available.
+func (r *Redis) scanKeys(pattern string, restart bool) {
+	if !r.connected || r.driver == nil {
+		return
+	}
+
+	cursor := r.cursor
+	if restart {
+		cursor = "0"
+	}
+
+	var found []string
+	r.runTask(fmt.Sprintf("Scanning keys matching %q...", pattern), func(driver db.KVDriver) error {
+		for calls := 0; len(found) < scanPageSize && calls < scanMaxCalls; calls++ {
+			page, err := driver.ScanKeys(cursor, pattern, scanCount)
+			if err != nil {
+				return err
+			}
+
+			found = append(found, page.Keys...)
+			cursor = page.Cursor
+			if page.Done() {
+				cursor = ""
+				break
+			}
+		}
+		return nil
+	}, func(err error) {
+		if err != nil {
+			r.showError(fmt.Sprintf("Failed to scan keys: %v", err))
+			return
+		}
+
+		if restart {
+			r.resetKeys()
+		}
+		r.pattern = pattern
+		r.cursor = cursor
+		for _, key := range found {
+			if !r.keySet[key] {
+				r.keySet[key] = true
+				r.keys = append(r.keys, key)
+			}
+		}
+
+		sort.Strings(r.keys)
+		r.buildKeyTree()
+
+		more := ""
+		if r.cursor != "" {
+			more = ", more available"
+		}
+		r.updateStatusBar(fmt.Sprintf("Loaded %d keys matching %q%s", len(r.keys), r.pattern, more))
+	})
+}
+
+// buildKeyTree builds the key tree grouped by keySeparator
+func (r *Redis) buildKeyTree() {
+	current := ""
+	if node := r.keyTree.GetCurrentNode(); node != nil {
+		if data, ok := node.GetReference().(map[string]string); ok {
+			current = data["name"]
+		}
+	}
+
+	rootNode := tview.NewTreeNode(fmt.Sprintf("%s [%s]", r.driver.GetDriverName(), r.pattern))
+	rootNode.SetColor(style.StatusInstalledColor)
+	rootNode.SetExpanded(true)
+
+	groups := map[string]*tview.TreeNode{}
+	groupNames := map[string]string{}
+	counts := map[string]int{}
+	var selected *tview.TreeNode
+
+	for _, key := range r.keys {
+		parts := strings.Split(key, keySeparator)
+		parent := rootNode
+		prefix := ""
+
+		for _, part := range parts[:len(parts)-1] {
+			prefix += part + keySeparator
+			counts[prefix]++
+
+			node, ok := groups[prefix]
+			if !ok {
+				node = tview.NewTreeNode("")
+				node.SetColor(style.StatusSelectedColor)
+				node.SetReference(map[string]string{"type": "group", "name": prefix})
+				node.SetExpanded(r.expanded[prefix])
+				parent.AddChild(node)
+				groups[prefix] = node
+				groupNames[prefix] = part
+			}
+			if prefix == current {
+				selected = node
+			}
+			parent = node
+		}
+
+		leaf := tview.NewTreeNode(parts[len(parts)-1])
+		leaf.SetColor(style.FgColor)
+		leaf.SetReference(map[string]string{"type": "key", "name": key})
+		parent.AddChild(leaf)
+		if key == current {
+			selected = leaf
+		}
+	}
+
+	for prefix, node := range groups {
+		node.SetText(fmt.Sprintf("%s%s (%d)", groupNames[prefix], keySeparator, counts[prefix]))
+	}
+
+	if r.cursor != "" {
+		moreNode := tview.NewTreeNode("[load more keys]")
+		moreNode.SetColor(style.StatusNotInstalledColor)
+		moreNode.SetReference(map[string]string{"type": "more"})
+		rootNode.AddChild(moreNode)
+	}
+
+	r.keyTree.SetRoot(rootNode)
+	if selected != nil {
+		r.keyTree.SetCurrentNode(selected)
+	} else {
+		r.keyTree.SetCurrentNode(rootNode)
+	}
+}
+
+// handleTreeSelection handles tree node selection
+func (r *Redis) handleTreeSelection(node *tview.TreeNode) {
+	data, ok := node.GetReference().(map[string]string)
+	if !ok {
+		return
+	}
+
+	switch data["type"] {
+	case "group":
+		expanded := !node.IsExpanded()
+		node.SetExpanded(expanded)
+		r.expanded[data["name"]] = expanded
+	case "key":
+		r.loadEntry(data["name"], nil)
+	case "more":
+		r.loadMoreKeys()
+	}
+}
+
+// selectedKey returns the key of the current tree node
+func (r *Redis) selectedKey() string {
+	node := r.keyTree.GetCurrentNode()
+	if node == nil {
+		return ""
+	}
+	if data, ok := node.GetReference().(map[string]string); ok && data["type"] == "key" {
+		return data["name"]
+	}
+	return ""
+}
+
+// loadEntry loads and displays a key in the background, then calls loaded
+func (r *Redis) loadEntry(key string, loaded func()) {
+	if !r.connected || r.driver == nil {
+		return
+	}
+
+	var entry *db.KVEntry
+	r.runTask(fmt.Sprintf("Loading key %s...", key), func(driver db.KVDriver) error {
+		var err error
+		entry, err = driver.GetEntry(key)
+		return err
+	}, func(err error) {
+		if err != nil {
+			r.showError(fmt.Sprintf("Failed to load key: %v", err))
+			return
+		}
+
+		r.entry = entry
+		r.displayEntry()
+		r.updateStatusBar(fmt.Sprintf("Loaded %s key %s", entry.Type, key))
+		if loaded != nil {
+			loaded()
+		}
+	})
+}
+
+// reloadEntry reloads the displayed key after a change
+func (r *Redis) reloadEntry() {
+	if r.entry == nil {
+		return
+	}
+
+	row, _ := r.valueTable.GetSelection()
+	r.loadEntry(r.entry.Key, func() {
+		if row > 0 && row < r.valueTable.GetRowCount() {
+			r.valueTable.Select(row, 0)
+		}
+	})
+}
+
+// clearEntry clears the key info and value views
+func (r *Redis) clearEntry() {
+	r.entry = nil
+	r.infoView.Clear()
+	r.valueText.Clear()
+	r.valueTable.Clear()
+	r.valuePages.SwitchToPage("text")
+}
+
+// displayEntry displays the current key
+func (r *Redis) displayEntry() {
+	entry := r.entry
+	highlightColor := style.GetColorHex(style.StatusInstalledColor)
+
+	ttl := "no expiry"
+	if entry.TTL != db.KVNoExpiry {
+		ttl = entry.TTL.Round(time.Second).String()
+	}
+
+	length := strconv.FormatInt(entry.Length, 10)
+	if entry.Truncated {
+		length += fmt.Sprintf(" (showing first %d)", len(entry.Fields))
+	}
+
+	r.infoView.SetText(fmt.Sprintf(" [%s]Key:[-]    %s\n [%s]Type:[-]   %s\n [%s]TTL:[-]    %s    [%s]Length:[-] %s",
+		highlightColor, tview.Escape(entry.Key),
+		highlightColor, entry.Type,
+		highlightColor, ttl,
+		highlightColor, length))
+
+	if entry.Type == db.KVTypeString {
+		r.valueText.SetText(entry.Value)
+		r.valueText.ScrollToBeginning()
+		r.valuePages.SwitchToPage("text")
+		return
+	}
+
+	r.valueTable.Clear()
+	for i, header := range entryHeaders(entry.Type) {
+		cell := tview.NewTableCell(strings.ToUpper(header))
+		cell.SetExpansion(1)
+		cell.SetBackgroundColor(style.PageHeaderBgColor)
+		cell.SetTextColor(style.PageHeaderFgColor)
+		cell.SetSelectable(false)
+		r.valueTable.SetCell(0, i, cell)
+	}
+
+	for i, field := range entry.Fields {
+		r.valueTable.SetCell(i+1, 0, tview.NewTableCell(tview.Escape(field.Name)).SetTextColor(style.StatusSelectedColor))
+		if entry.Type != db.KVTypeSet {
+			r.valueTable.SetCell(i+1, 1, tview.NewTableCell(tview.Escape(field.Value)).SetTextColor(style.FgColor))
+		}
+	}
+
+	r.valueTable.ScrollToBeginning()
+	r.valuePages.SwitchToPage("table")
+}
+
+// entryHeaders returns the value table headers for a key type
+func entryHeaders(keyType string) []string {
+	switch keyType {
+	case db.KVTypeHash:
+		return []string{"field", "value"}
+	case db.KVTypeList:
+		return []string{"index", "value"}
+	case db.KVTypeSet:
+		return []string{"member"}
+	case db.KVTypeZSet:
+		return []string{"member", "score"}
+	case db.KVTypeStream:
+		return []string{"id", "fields"}
+	}
+	return []string{"value"}
+}
+
+// selectedField returns the collection element under the value table cursor
+func (r *Redis) selectedField() (db.KVField, bool) {
+	if r.entry == nil || r.entry.Type == db.KVTypeString {
+		return db.KVField{}, false
+	}
+
+	row, _ := r.valueTable.GetSelection()
+	if row < 1 || row > len(r.entry.Fields) {
+		return db.KVField{}, false
+	}
+	return r.entry.Fields[row-1], true
+}
+
+// deleteKey deletes the selected key after confirmation
+func (r *Redis) deleteKey() {
+	key := r.selectedKey()
+	if key == "" {
+		return
+	}
+
+	r.confirm("Delete Key", fmt.Sprintf("Delete key %q?", key), func() {
+		r.runTask(fmt.Sprintf("Deleting key %s...", key), func(driver db.KVDriver) error {
+			return driver.DeleteKey(key)
+		}, func(err error) {
+			if err != nil {
+				r.showError(fmt.Sprintf("Failed to delete key: %v", err))
+				return
+			}
+
+			delete(r.keySet, key)
+			for i, k := range r.keys {
+				if k == key {
+					r.keys = append(r.keys[:i], r.keys[i+1:]...)
+					break
+				}
+			}
+			if r.entry != nil && r.entry.Key == key {
+				r.clearEntry()
+			}
+			r.buildKeyTree()
+			r.updateStatusBar(fmt.Sprintf("Deleted key %s", key))
+		})
+	})
+}
+
+// expireKey sets the TTL of the selected key
+func (r *Redis) expireKey() {
+	key := r.selectedKey()
+	if key == "" {
+		return
+	}
+
+	r.prompt("Expire "+key, "TTL seconds (-1 = persist): ", "", func(value string) {
+		seconds, err := strconv.Atoi(strings.TrimSpace(value))
+		if err != nil {
+			r.showError(fmt.Sprintf("Invalid TTL: %s", value))
+			return
+		}
+
+		r.runTask(fmt.Sprintf("Updating TTL of %s...", key), func(driver db.KVDriver) error {
+			return driver.Expire(key, time.Duration(seconds)*time.Second)
+		}, func(err error) {
+			if err != nil {
+				r.showError(fmt.Sprintf("Failed to set TTL: %v", err))
+				return
+			}
+
+			r.updateStatusBar(fmt.Sprintf("Updated TTL of %s", key))
+			if r.entry != nil && r.entry.Key == key {
+				r.reloadEntry()
+			}
+		})
+	})
+}
+
+// editValue edits the string value or the selected collection element
+func (r *Redis) editValue() {
+	if r.entry == nil {
+		return
+	}
+	key := r.entry.Key
+
+	if r.entry.Type == db.KVTypeString {
+		r.prompt("Edit "+key, "Value: ", r.entry.Value, func(value string) {
+			r.runTask(fmt.Sprintf("Setting %s...", key), func(driver db.KVDriver) error {
+				return driver.SetValue(key, value)
+			}, func(err error) {
+				if err != nil {
+					r.showError(fmt.Sprintf("Failed to set value: %v", err))
+					return
+				}
+				r.reloadEntry()
+			})
+		})
+		return
+	}
+
+	field, ok := r.selectedField()
+	if !ok {
+		return
+	}
+
+	label := "Value: "
+	text := field.Value
+	switch r.entry.Type {
+	case db.KVTypeSet:
+		label = "Member: "
+		text = field.Name
+	case db.KVTypeZSet:
+		label = "Score: "
+	case db.KVTypeStream:
+		r.showError("Stream entries can not be edited")
+		return
+	}
+
+	r.prompt("Edit "+key, label, text, func(value string) {
+		r.runTask(fmt.Sprintf("Setting %s...", key), func(driver db.KVDriver) error {
+			return driver.SetField(key, field.Name, value)
+		}, func(err error) {
+			if err != nil {
+				r.showError(fmt.Sprintf("Failed to set value: %v", err))
+				return
+			}
+			r.reloadEntry()
+		})
+	})
+}
+
+// addField adds an element to the current collection key
+func (r *Redis) addField() {
+	if r.entry == nil {
+		return
+	}
+	key := r.entry.Key
+
+	setField := func(field, value string) {
+		r.runTask(fmt.Sprintf("Adding to %s...", key), func(driver db.KVDriver) error {
+			return driver.SetField(key, field, value)
+		}, func(err error) {
+			if err != nil {
+				r.showError(fmt.Sprintf("Failed to add element: %v", err))
+				return
+			}
+			r.reloadEntry()
+		})
+	}
+
+	switch r.entry.Type {
+	case db.KVTypeHash:
+		r.prompt("Add to "+key, "Field: ", "", func(field string) {
+			r.prompt("Add to "+key, "Value: ", "", func(value string) {
+				setField(field, value)
+			})
+		})
+	case db.KVTypeZSet:
+		r.prompt("Add to "+key, "Member: ", "", func(member string) {
+			r.prompt("Add to "+key, "Score: ", "0", func(score string) {
+				setField(member, score)
+			})
+		})
+	case db.KVTypeList:
+		r.prompt("Append to "+key, "Value: ", "", func(value string) {
+			setField("", value)
+		})
+	case db.KVTypeSet:
+		r.prompt("Add to "+key, "Member: ", "", func(member string) {
+			setField("", member)
+		})
+	}
+}
+
+// deleteField deletes the selected collection element after confirmation
+func (r *Redis) deleteField() {
+	field, ok := r.selectedField()
+	if !ok {
+		return
+	}
+	key := r.entry.Key
+
+	r.confirm("Delete Element", fmt.Sprintf("Delete %q from %s?", field.Name, key), func() {
+		r.runTask(fmt.Sprintf("Deleting from %s...", key), func(driver db.KVDriver) error {
+			return driver.DeleteField(key, field.Name)
+		}, func(err error) {
+			if err != nil {
+				r.showError(fmt.Sprintf("Failed to delete element: %v", err))
+				return
+			}
+			r.reloadEntry()
+		})
+	})
+}
+
+// filterKeys asks for a MATCH pattern and rescans
+func (r *Redis) filterKeys() {
+	if !r.connected {
+		return
+	}
+
+	r.prompt("Filter Keys", "Pattern: ", r.pattern, func(value string) {
+		pattern := strings.TrimSpace(value)
+		if pattern == "" {
+			pattern = "*"
+		}
+		r.scanKeys(pattern, true)
+	})
+}
+
+// InputHandler returns the input handler for this primitive
+func (r *Redis) InputHandler() func(event *tcell.EventKey, setFocus func(p tview.Primitive)) {
+	return r.WrapInputHandler(func(event *tcell.EventKey, setFocus func(p tview.Primitive)) {
+		// Handle dialog input first if dialog has focus
+		if r.SubDialogHasFocus() {
+			if r.connDialog.HasFocus() {
+				if handler := r.connDialog.InputHandler(); handler != nil {
+					handler(event, setFocus)
+				}
+			} else if r.errorDialog.HasFocus() {
+				if handler := r.errorDialog.InputHandler(); handler != nil {
+					handler(event, setFocus)
+				}
+			} else if r.confirmDialog.HasFocus() {
+				if handler := r.confirmDialog.InputHandler(); handler != nil {
+					handler(event, setFocus)
+				}
+			} else if r.inputDialog.HasFocus() {
+				if handler := r.inputDialog.InputHandler(); handler != nil {
+					handler(event, setFocus)
+				}
+			}
+			return
+		}
+
+		// Ctrl+N to open connection dialog
+		if event.Key() == tcell.KeyCtrlN {
+			r.connDialog.Display()
+			setFocus(r.connDialog)
+			return
+		}
+
+		// Ctrl+D to disconnect
+		if event.Key() == tcell.KeyCtrlD && r.connected {
+			r.disconnect()
+			return
+		}
+
+		// Ctrl+Left/Right to switch panels
+		if (event.Key() == tcell.KeyLeft || event.Key() == tcell.KeyRight) && event.Modifiers() == tcell.ModCtrl {
+			r.focusedElement = (r.focusedElement + 1) % 2
+			r.Focus(setFocus)
+			return
+		}
+
+		if event.Key() == tcell.KeyRune && r.connected {
+			handled := true
+			switch r.focusedElement {
+			case focusTree:
+				switch event.Rune() {
+				case 'd':
+					r.deleteKey()
+				case 't':
+					r.expireKey()
+				case 'e':
+					if key := r.selectedKey(); key != "" {
+						r.loadEntry(key, r.editValue)
+					}
+				case 'r':
+					r.reloadKeys()
+				case 'n':
+					if r.cursor != "" {
+						r.loadMoreKeys()
+					}
+				case '/':
+					r.filterKeys()
+				default:
+					handled = false
+				}
+			case focusValue:
+				switch event.Rune() {
+				case 'e':
+					r.editValue()
+				case 'a':
+					r.addField()
+				case 'd':
+					r.deleteField()
+				default:
+					handled = false
+				}
+			}
+			if handled {
+				r.Focus(setFocus)
+				return
+			}
+		}
+
+		// Handle current focused element
+		switch r.focusedElement {
+		case focusTree:
+			if handler := r.keyTree.InputHandler(); handler != nil {
+				handler(event, setFocus)
+			}
+		case focusValue:
+			if event.Key() == tcell.KeyEnter {
+				r.editValue()
+				r.Focus(setFocus)
+				return
+			}
+			if handler := r.valuePages.InputHandler(); handler != nil {
+				handler(event, setFocus)
+			}
+		}
+	})
+}
+
+// Draw draws this primitive onto the screen
+func (r *Redis) Draw(screen tcell.Screen) {
+	r.Box.DrawForSubclass(screen, r)
+	x, y, width, height := r.GetInnerRect()
+
+	r.mainFlex.SetRect(x, y, width, height)
+	r.mainFlex.Draw(screen)
+
+	// Draw dialogs
+	if r.connDialog.IsDisplay() {
+		r.connDialog.SetRect(x, y, width, height)
+		r.connDialog.Draw(screen)
+	}
+	if r.inputDialog.IsDisplay() {
+		r.inputDialog.SetRect(x, y, width, height)
+		r.inputDialog.Draw(screen)
+	}
+	if r.confirmDialog.IsDisplay() {
+		r.confirmDialog.SetRect(x, y, width, height)
+		r.confirmDialog.Draw(screen)
+	}
+	if r.errorDialog.IsDisplay() {
+		r.errorDialog.SetRect(x, y, width, height)
+		r.errorDialog.Draw(screen)
+	}
+}
@@ -36,6 +36,15 @@ var (
 	StatusNotInstalledColor = tcell.NewRGBColor(252, 175, 62)  // #fcaf3e orange
 	StatusErrorColor        = tcell.NewRGBColor(239, 41, 41)   // #ef2929 red
 	StatusSelectedColor     = tcell.NewRGBColor(114, 159, 207) // #729fcf blue
+
+	// SQL syntax colors - GNOME palette
+	SyntaxKeywordColor    = tcell.NewRGBColor(114, 159, 207) // #729fcf blue
+	SyntaxStringColor     = tcell.NewRGBColor(138, 226, 52)  // #8ae234 light green
+	SyntaxNumberColor     = tcell.NewRGBColor(173, 127, 168) // #ad7fa8 plum
+	SyntaxCommentColor    = tcell.NewRGBColor(136, 138, 133) // #888a85 gray
+	SyntaxIdentifierColor = tcell.NewRGBColor(233, 185, 110) // #e9b96e brown
+	SyntaxParameterColor  = tcell.NewRGBColor(252, 175, 62)  // #fcaf3e orange
+	BracketMatchBgColor   = tcell.NewRGBColor(85, 87, 83)    // #555753 dark gray
 )
 
 // GetColorHex returns the hex string for a color
@@ -6,6 +6,7 @@ import (
 	"github.com/shangyanjin/gocmder/internal/models"
 	"github.com/shangyanjin/gocmder/internal/ui/pages/database"
 	"github.com/shangyanjin/gocmder/internal/ui/pages/home"
+	"github.com/shangyanjin/gocmder/internal/ui/pages/redis"
 	"github.com/shangyanjin/gocmder/internal/ui/pages/settings"
 	"github.com/shangyanjin/gocmder/internal/ui/pages/system"
 	"github.com/shangyanjin/gocmder/internal/ui/pages/terminal"
@@ -21,13 +22,14 @@ const (
 	toolsPageIndex
 	settingsPageIndex
 	systemPageIndex
+	redisPageIndex
 	// F3 reserved for File Manager
 	// F4 = Database (index 2)
 	// F5 reserved for Editor
 	// F6 = Tools (index 3)
 	// F7 = Settings (index 4)
 	// F8 = System (index 5)
-	// F9 = Reserved
+	// F9 = Redis (index 6)
 )
 
 // UIPage represents a page in the UI
@@ -40,6 +42,19 @@ type UIPage interface {
 	SetAppFocusHandler(handler func())
 }
 
+// keyCapturer is implemented by pages that handle some global keys themselves
+type keyCapturer interface {
+	CapturesKey(event *tcell.EventKey) bool
+}
+
+// quitConfirmer is implemented by pages that may ask before the application quits
+type quitConfirmer interface {
+	// NeedsQuitConfirm returns true if quitting must be confirmed on the page
+	NeedsQuitConfirm() bool
+	// ConfirmQuit asks whether to quit and calls quit if so
+	ConfirmQuit(quit func())
+}
+
 // App represents the main UI application
 type App struct {
 	app            *tview.Application
@@ -54,6 +69,7 @@ type App struct {
 	toolsPage      *tools.Tools
 	settingsPage   *settings.Settings
 	systemPage     *system.System
+	redisPage      *redis.Redis
 	currentPageIdx int
 	pageList       []UIPage
 	installHandler func(toolName string)
@@ -72,9 +88,16 @@ func NewApp(tviewApp *tview.Application) *App {
 	uiApp.homePage = home.NewHome()
 	uiApp.terminalPage = terminal.NewTerminal()
 	uiApp.databasePage = database.NewDatabase()
+	uiApp.databasePage.SetQueueUpdateDraw(func(f func()) {
+		uiApp.app.QueueUpdateDraw(f)
+	})
 	uiApp.toolsPage = tools.NewTools()
 	uiApp.settingsPage = settings.NewSettings()
 	uiApp.systemPage = system.NewSystem()
+	uiApp.redisPage = redis.NewRedis()
+	uiApp.redisPage.SetQueueUpdateDraw(func(f func()) {
+		uiApp.app.QueueUpdateDraw(f)
+	})
 
 	// Set page list
 	uiApp.pageList = []UIPage{
@@ -84,6 +107,7 @@ func NewApp(tviewApp *tview.Application) *App {
 		uiApp.toolsPage,
 		uiApp.settingsPage,
 		uiApp.systemPage,
+		uiApp.redisPage,
 	}
 
 	// Set app focus handlers
@@ -114,6 +138,7 @@ func NewApp(tviewApp *tview.Application) *App {
 	uiApp.pages.AddPage("tools", uiApp.toolsPage, true, false)
 	uiApp.pages.AddPage("settings", uiApp.settingsPage, true, false)
 	uiApp.pages.AddPage("system", uiApp.systemPage, true, false)
+	uiApp.pages.AddPage("redis", uiApp.redisPage, true, false)
 
 	// Create main layout
 	uiApp.mainLayout = tview.NewFlex().SetDirection(tview.FlexRow)
@@ -127,6 +152,7 @@ func NewApp(tviewApp *tview.Application) *App {
 
 	// Set input capture for global key bindings
 	uiApp.layout.SetInputCapture(uiApp.globalInputHandler)
+	uiApp.app.SetInputCapture(uiApp.appInputHandler)
 
 	return uiApp
 }
@@ -168,6 +194,21 @@ func (a *App) RefreshSystemInfo() {
 	a.systemPage.Refresh()
 }
 
+// appInputHandler lets pages claim Ctrl+C before it stops the application
+func (a *App) appInputHandler(event *tcell.EventKey) *tcell.EventKey {
+	if event.Key() == tcell.KeyCtrlC {
+		if capturer, ok := a.getCurrentPage().(keyCapturer); ok && capturer.CapturesKey(event) {
+			// A new event is forwarded to the page instead of stopping the application
+			return tcell.NewEventKey(tcell.KeyCtrlC, 0, tcell.ModNone)
+		}
+		if a.confirmQuit() {
+			return nil
+		}
+	}
+
+	return event
+}
+
 // globalInputHandler handles global key bindings
 func (a *App) globalInputHandler(event *tcell.EventKey) *tcell.EventKey {
 	// Check if any dialog is displayed
@@ -175,6 +216,11 @@ func (a *App) globalInputHandler(event *tcell.EventKey) *tcell.EventKey {
 		return event
 	}
 
+	// Let the page handle keys it claims, e.g. Esc to cancel a running query
+	if capturer, ok := a.getCurrentPage().(keyCapturer); ok && capturer.CapturesKey(event) {
+		return event
+	}
+
 	// Handle ESC key - return to home page (except when already on home page)
 	if event.Key() == utils.CloseDialogKey.Key {
 		if a.currentPageIdx != homePageIndex {
@@ -187,7 +233,9 @@ func (a *App) globalInputHandler(event *tcell.EventKey) *tcell.EventKey {
 
 	// Handle quit key
 	if event.Rune() == utils.QuitKey.Rune {
-		a.app.Stop()
+		if !a.confirmQuit() {
+			a.app.Stop()
+		}
 		return nil
 	}
 
@@ -224,13 +272,27 @@ func (a *App) globalInputHandler(event *tcell.EventKey) *tcell.EventKey {
 		a.switchToPage(systemPageIndex)
 		return nil
 	case tcell.KeyF9:
-		// Reserved for future
+		a.switchToPage(redisPageIndex)
 		return nil
 	}
 
 	return event
 }
 
+// confirmQuit shows the page that asks before quitting, e.g. with a
+// transaction open, and returns true if it does
+func (a *App) confirmQuit() bool {
+	for i, page := range a.pageList {
+		if confirmer, ok := page.(quitConfirmer); ok && confirmer.NeedsQuitConfirm() {
+			a.switchToPage(i)
+			confirmer.ConfirmQuit(a.app.Stop)
+			a.app.SetFocus(page)
+			return true
+		}
+	}
+	return false
+}
+
 // switchToNextPage switches to the next page
 func (a *App) switchToNextPage() {
 	a.currentPageIdx = (a.currentPageIdx + 1) % len(a.pageList)
@@ -266,6 +328,8 @@ func (a *App) updateCurrentPage() {
 		a.pages.SwitchToPage("settings")
 	case systemPageIndex:
 		a.pages.SwitchToPage("system")
+	case redisPageIndex:
+		a.pages.SwitchToPage("redis")
 	}
 
 	// Update help bar
@@ -287,7 +351,7 @@ func (a *App) getCurrentPage() UIPage {
 func (a *App) updateHelpBar() {
 	highlightColor := style.GetColorHex(style.StatusInstalledColor)
 
-	baseHelp := " [" + highlightColor + "]F1[-] Home | [" + highlightColor + "]F2[-] Term | [" + highlightColor + "]F4[-] DB | [" + highlightColor + "]F6[-] Tools | [" + highlightColor + "]F7[-] Settings | [" + highlightColor + "]Tab[-] Next | [" + highlightColor + "]q[-] Quit"
+	baseHelp := " [" + highlightColor + "]F1[-] Home | [" + highlightColor + "]F2[-] Term | [" + highlightColor + "]F4[-] DB | [" + highlightColor + "]F6[-] Tools | [" + highlightColor + "]F7[-] Settings | [" + highlightColor + "]F9[-] Redis | [" + highlightColor + "]Tab[-] Next | [" + highlightColor + "]q[-] Quit"
 
 	var pageHelp string
 	switch a.currentPageIdx {
@@ -296,13 +360,15 @@ func (a *App) updateHelpBar() {
 	case terminalPageIndex:
 		pageHelp = " | [" + highlightColor + "]ESC[-] Home | [" + highlightColor + "]Enter[-] Execute"
 	case databasePageIndex:
-		pageHelp = " | [" + highlightColor + "]ESC[-] Home | [" + highlightColor + "]Ctrl+N[-] Connect | [" + highlightColor + "]Ctrl+R[-] Execute | [" + highlightColor + "]Ctrl+←/→[-] Switch Panel"
+		pageHelp = " | [" + highlightColor + "]ESC[-] Home | [" + highlightColor + "]Ctrl+N[-] Connect | [" + highlightColor + "]Ctrl+R[-] Run | [" + highlightColor + "]Ctrl+G[-] Run All | [" + highlightColor + "]Ctrl+C[-] Cancel | [" + highlightColor + "]Ctrl+←/→[-] Switch Panel"
 	case toolsPageIndex:
 		pageHelp = " | [" + highlightColor + "]ESC[-] Home | [" + highlightColor + "]Space[-] Toggle | [" + highlightColor + "]a[-] All | [" + highlightColor + "]i[-] Install"
 	case settingsPageIndex:
 		pageHelp = " | [" + highlightColor + "]ESC[-] Home | [" + highlightColor + "]Space[-] Toggle | [" + highlightColor + "]a[-] All | [" + highlightColor + "]Enter[-] Apply"
 	case systemPageIndex:
 		pageHelp = " | [" + highlightColor + "]ESC[-] Home | [" + highlightColor + "]↑/↓[-] Scroll"
+	case redisPageIndex:
+		pageHelp = " | [" + highlightColor + "]ESC[-] Home | [" + highlightColor + "]Ctrl+N[-] Connect | [" + highlightColor + "]/[-] Filter | [" + highlightColor + "]e[-] Edit | [" + highlightColor + "]t[-] TTL | [" + highlightColor + "]d[-] Delete"
 	}
 
 	a.helpBar.SetText(baseHelp + pageHelp)
@@ -0,0 +1,62 @@
+package utils
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+
+	"github.com/gdamore/tcell/v2"
+)
+
+// clipboardCommands are the clipboard tools tried in order, with the
+// environment variable that has to be set for them to work
+var clipboardCommands = []struct {
+	env  string
+	args []string
+}{
+	{"WAYLAND_DISPLAY", []string{"wl-copy"}},
+	{"DISPLAY", []string{"xclip", "-selection", "clipboard"}},
+	{"DISPLAY", []string{"xsel", "--clipboard", "--input"}},
+}
+
+// WriteClipboard copies text to the system clipboard and returns how it was
+// copied. Without a clipboard tool the text is sent to the terminal with an
+// OSC 52 sequence, which most terminals pass on to the clipboard.
+func WriteClipboard(screen tcell.Screen, text string) (string, error) {
+	var commands [][]string
+	switch runtime.GOOS {
+	case "darwin":
+		commands = append(commands, []string{"pbcopy"})
+	case "windows":
+		commands = append(commands, []string{"clip.exe"})
+	default:
+		for _, command := range clipboardCommands {
+			if os.Getenv(command.env) != "" {
+				commands = append(commands, command.args)
+			}
+		}
+		// WSL can reach the Windows clipboard
+		if os.Getenv("WSL_DISTRO_NAME") != "" {
+			commands = append(commands, []string{"clip.exe"})
+		}
+	}
+
+	for _, args := range commands {
+		if _, err := exec.LookPath(args[0]); err != nil {
+			continue
+		}
+		cmd := exec.Command(args[0], args[1:]...)
+		cmd.Stdin = strings.NewReader(text)
+		if err := cmd.Run(); err == nil {
+			return args[0], nil
+		}
+	}
+
+	if screen == nil {
+		return "", fmt.Errorf("no clipboard tool found")
+	}
+	screen.SetClipboard([]byte(text))
+	return "terminal", nil
+}
@@ -0,0 +1,204 @@
+package vault
+
+import (
+	"bufio"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+
+	"github.com/shangyanjin/gocmder/internal/config"
+)
+
+// sessionPasswordEnvPrefix prefixes the per-session password environment variable
+const sessionPasswordEnvPrefix = "GOCMDER_PASSWORD_"
+
+// Password sources reported by LookupPassword
+const (
+	SourceVault       = "vault"
+	SourceEnvironment = "environment"
+	SourcePgpass      = ".pgpass"
+	SourceMyCnf       = ".my.cnf"
+)
+
+// SessionEnvVar returns the environment variable holding the password of a session
+func SessionEnvVar(sessionName string) string {
+	var b strings.Builder
+	b.WriteString(sessionPasswordEnvPrefix)
+	for _, r := range strings.ToUpper(sessionName) {
+		if (r >= 'A' && r <= 'Z') || (r >= '0' && r <= '9') {
+			b.WriteRune(r)
+		} else {
+			b.WriteRune('_')
+		}
+	}
+	return b.String()
+}
+
+// LookupPassword finds a password for a session outside the vault. It checks
+// GOCMDER_PASSWORD_<SESSION>, then the driver's own environment variable and
+// password file (PGPASSWORD/.pgpass or MYSQL_PWD/.my.cnf). session.Driver
+// must be the registered driver name, not an alias.
+func LookupPassword(session config.Session) (string, string, bool) {
+	if password, ok := os.LookupEnv(SessionEnvVar(session.Name)); ok {
+		return password, SourceEnvironment, true
+	}
+
+	switch session.Driver {
+	case "PostgreSQL":
+		if password, ok := os.LookupEnv("PGPASSWORD"); ok {
+			return password, SourceEnvironment, true
+		}
+		if password, ok := lookupPgpass(session); ok {
+			return password, SourcePgpass, true
+		}
+	case "MySQL":
+		if password, ok := os.LookupEnv("MYSQL_PWD"); ok {
+			return password, SourceEnvironment, true
+		}
+		if password, ok := lookupMyCnf(); ok {
+			return password, SourceMyCnf, true
+		}
+	}
+
+	return "", "", false
+}
+
+// pgpassPath returns the location of the PostgreSQL password file
+func pgpassPath() string {
+	if path := os.Getenv("PGPASSFILE"); path != "" {
+		return path
+	}
+
+	if runtime.GOOS == "windows" {
+		return filepath.Join(os.Getenv("APPDATA"), "postgresql", "pgpass.conf")
+	}
+
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(home, ".pgpass")
+}
+
+// lookupPgpass finds the first matching hostname:port:database:username:password line
+func lookupPgpass(session config.Session) (string, bool) {
+	path := pgpassPath()
+	if path == "" {
+		return "", false
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		return "", false
+	}
+	defer file.Close()
+
+	host := session.Host
+	if host == "" {
+		host = "localhost"
+	}
+	port := session.Port
+	if port == "" {
+		port = "5432"
+	}
+	database := session.Database
+	if database == "" {
+		database = session.Username
+	}
+	want := []string{host, port, database, session.Username}
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		fields := splitPgpassLine(line)
+		if len(fields) != 5 {
+			continue
+		}
+
+		matched := true
+		for i, value := range want {
+			if fields[i] != "*" && fields[i] != value {
+				matched = false
+				break
+			}
+		}
+		if matched {
+			return fields[4], true
+		}
+	}
+
+	return "", false
+}
+
+// splitPgpassLine splits a .pgpass line on unescaped colons
+func splitPgpassLine(line string) []string {
+	var fields []string
+	var field strings.Builder
+
+	escaped := false
+	for _, r := range line {
+		switch {
+		case escaped:
+			field.WriteRune(r)
+			escaped = false
+		case r == '\\':
+			escaped = true
+		case r == ':' && len(fields) < 4:
+			fields = append(fields, field.String())
+			field.Reset()
+		default:
+			field.WriteRune(r)
+		}
+	}
+
+	return append(fields, field.String())
+}
+
+// lookupMyCnf reads the password option of the [client] or [mysql] group of ~/.my.cnf
+func lookupMyCnf() (string, bool) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", false
+	}
+
+	file, err := os.Open(filepath.Join(home, ".my.cnf"))
+	if err != nil {
+		return "", false
+	}
+	defer file.Close()
+
+	var group string
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") || strings.HasPrefix(line, ";") {
+			continue
+		}
+
+		if strings.HasPrefix(line, "[") && strings.HasSuffix(line, "]") {
+			group = strings.ToLower(strings.TrimSpace(line[1 : len(line)-1]))
+			continue
+		}
+		if group != "client" && group != "mysql" {
+			continue
+		}
+
+		key, value, ok := strings.Cut(line, "=")
+		if !ok || strings.TrimSpace(key) != "password" {
+			continue
+		}
+
+		value = strings.TrimSpace(value)
+		if len(value) >= 2 && (value[0] == '"' || value[0] == '\'') && value[len(value)-1] == value[0] {
+			value = value[1 : len(value)-1]
+		}
+		return value, true
+	}
+
+	return "", false
+}
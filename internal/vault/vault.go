@@ -0,0 +1,330 @@
+package vault
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/pbkdf2"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/shangyanjin/gocmder/internal/config"
+)
+
+const (
+	vaultFileName    = "vault.json"
+	vaultVersion     = 1
+	vaultKDF         = "pbkdf2-sha256"
+	kdfIterations    = 600000
+	minKDFIterations = 100000
+	maxKDFIterations = 10000000
+	keyLength        = 32
+	saltLength       = 16
+	additionalData   = "gocmder-vault-v1"
+	minPassphraseLen = 8
+)
+
+// DefaultIdleTimeout is the idle time after which an unlocked vault locks itself
+const DefaultIdleTimeout = 15 * time.Minute
+
+var (
+	// ErrLocked is returned when the vault is accessed before Unlock
+	ErrLocked = errors.New("vault is locked")
+	// ErrWrongPassphrase is returned when the passphrase does not decrypt the vault
+	ErrWrongPassphrase = errors.New("wrong master passphrase")
+)
+
+// Vault stores passwords encrypted with AES-256-GCM under a key derived
+// from a master passphrase. Entries are only held in memory while unlocked.
+type Vault struct {
+	mu          sync.Mutex
+	path        string
+	key         []byte
+	salt        []byte
+	iterations  int
+	entries     map[string]string
+	idleTimeout time.Duration
+	idleTimer   *time.Timer
+}
+
+// vaultFile is the on-disk format of the vault
+type vaultFile struct {
+	Version    int    `json:"version"`
+	KDF        string `json:"kdf"`
+	Iterations int    `json:"iterations"`
+	Salt       []byte `json:"salt"`
+	Nonce      []byte `json:"nonce"`
+	Data       []byte `json:"data"`
+}
+
+// New creates a vault stored at path that locks after idleTimeout
+func New(path string, idleTimeout time.Duration) *Vault {
+	return &Vault{
+		path:        path,
+		idleTimeout: idleTimeout,
+	}
+}
+
+// NewDefault creates a vault in the user config directory
+func NewDefault() (*Vault, error) {
+	dir, err := config.ConfigDir()
+	if err != nil {
+		return nil, err
+	}
+
+	return New(filepath.Join(dir, vaultFileName), DefaultIdleTimeout), nil
+}
+
+// Exists returns true if the vault file has been created
+func (v *Vault) Exists() bool {
+	_, err := os.Stat(v.path)
+	return err == nil
+}
+
+// IsUnlocked returns true if the vault is unlocked
+func (v *Vault) IsUnlocked() bool {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	return v.key != nil
+}
+
+// Unlock decrypts the vault, or initializes a new one if none exists yet
+func (v *Vault) Unlock(passphrase string) error {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	data, err := os.ReadFile(v.path)
+	if errors.Is(err, os.ErrNotExist) {
+		if len(passphrase) < minPassphraseLen {
+			return fmt.Errorf("master passphrase must be at least %d characters", minPassphraseLen)
+		}
+
+		salt := make([]byte, saltLength)
+		if _, err := rand.Read(salt); err != nil {
+			return err
+		}
+		key, err := deriveKey(passphrase, salt, kdfIterations)
+		if err != nil {
+			return err
+		}
+
+		v.salt = salt
+		v.iterations = kdfIterations
+		v.key = key
+		v.entries = make(map[string]string)
+		v.touch()
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read vault: %w", err)
+	}
+
+	var file vaultFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		return fmt.Errorf("failed to parse vault: %w", err)
+	}
+	if file.Version != vaultVersion || file.KDF != vaultKDF {
+		return fmt.Errorf("unsupported vault format %d/%s", file.Version, file.KDF)
+	}
+	// The count comes from the file, a corrupt one must not stall or weaken the key derivation
+	if file.Iterations < minKDFIterations || file.Iterations > maxKDFIterations {
+		return fmt.Errorf("unsupported vault iteration count %d", file.Iterations)
+	}
+	if len(file.Salt) < saltLength {
+		return fmt.Errorf("corrupt vault: salt too short")
+	}
+
+	key, err := deriveKey(passphrase, file.Salt, file.Iterations)
+	if err != nil {
+		return err
+	}
+
+	gcm, err := newGCM(key)
+	if err != nil {
+		return err
+	}
+
+	if len(file.Nonce) != gcm.NonceSize() {
+		return fmt.Errorf("corrupt vault: invalid nonce")
+	}
+	plaintext, err := gcm.Open(nil, file.Nonce, file.Data, []byte(additionalData))
+	if err != nil {
+		return ErrWrongPassphrase
+	}
+
+	entries := make(map[string]string)
+	if err := json.Unmarshal(plaintext, &entries); err != nil {
+		return fmt.Errorf("failed to decode vault: %w", err)
+	}
+
+	v.salt = file.Salt
+	v.iterations = file.Iterations
+	v.key = key
+	v.entries = entries
+	v.touch()
+	return nil
+}
+
+// Lock discards the key and decrypted entries from memory
+func (v *Vault) Lock() {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	v.lock()
+}
+
+// lock locks the vault, caller must hold the lock
+func (v *Vault) lock() {
+	for i := range v.key {
+		v.key[i] = 0
+	}
+	v.key = nil
+	v.entries = nil
+
+	if v.idleTimer != nil {
+		v.idleTimer.Stop()
+		v.idleTimer = nil
+	}
+}
+
+// touch restarts the idle timer, caller must hold the lock
+func (v *Vault) touch() {
+	if v.idleTimeout <= 0 {
+		return
+	}
+
+	if v.idleTimer != nil {
+		v.idleTimer.Reset(v.idleTimeout)
+		return
+	}
+
+	v.idleTimer = time.AfterFunc(v.idleTimeout, func() {
+		v.Lock()
+	})
+}
+
+// Get returns the password stored under name
+func (v *Vault) Get(name string) (string, bool, error) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	if v.key == nil {
+		return "", false, ErrLocked
+	}
+	v.touch()
+
+	password, ok := v.entries[name]
+	return password, ok, nil
+}
+
+// Set stores a password under name and saves the vault
+func (v *Vault) Set(name, password string) error {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	if v.key == nil {
+		return ErrLocked
+	}
+	v.touch()
+
+	v.entries[name] = password
+	return v.save()
+}
+
+// Delete removes the password stored under name and saves the vault
+func (v *Vault) Delete(name string) error {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	if v.key == nil {
+		return ErrLocked
+	}
+	v.touch()
+
+	if _, ok := v.entries[name]; !ok {
+		return nil
+	}
+
+	delete(v.entries, name)
+	return v.save()
+}
+
+// Rename moves the password stored under oldName to newName and saves the vault
+func (v *Vault) Rename(oldName, newName string) error {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	if v.key == nil {
+		return ErrLocked
+	}
+	v.touch()
+
+	password, ok := v.entries[oldName]
+	if !ok {
+		return nil
+	}
+
+	delete(v.entries, oldName)
+	v.entries[newName] = password
+	return v.save()
+}
+
+// save encrypts the entries and writes the vault file, caller must hold the lock
+func (v *Vault) save() error {
+	plaintext, err := json.Marshal(v.entries)
+	if err != nil {
+		return err
+	}
+
+	gcm, err := newGCM(v.key)
+	if err != nil {
+		return err
+	}
+
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return err
+	}
+
+	data, err := json.MarshalIndent(vaultFile{
+		Version:    vaultVersion,
+		KDF:        vaultKDF,
+		Iterations: v.iterations,
+		Salt:       v.salt,
+		Nonce:      nonce,
+		Data:       gcm.Seal(nil, nonce, plaintext, []byte(additionalData)),
+	}, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	if err := config.WriteFileAtomic(v.path, data, 0600); err != nil {
+		return fmt.Errorf("failed to write vault: %w", err)
+	}
+
+	return nil
+}
+
+// deriveKey derives the vault key from the passphrase
+func deriveKey(passphrase string, salt []byte, iterations int) ([]byte, error) {
+	if passphrase == "" {
+		return nil, fmt.Errorf("master passphrase is required")
+	}
+	return pbkdf2.Key(sha256.New, passphrase, salt, iterations, keyLength)
+}
+
+// newGCM returns an AES-GCM cipher for key
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
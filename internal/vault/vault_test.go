@@ -0,0 +1,197 @@
+package vault
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const testPassphrase = "correct horse battery"
+
+// newTestVault creates and unlocks a vault in a temporary directory holding one password
+func newTestVault(t *testing.T) (*Vault, string) {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), vaultFileName)
+	v := New(path, 0)
+	if v.Exists() {
+		t.Fatal("vault exists before the first save")
+	}
+	if err := v.Unlock(testPassphrase); err != nil {
+		t.Fatal(err)
+	}
+	if err := v.Set("prod", "s3cret"); err != nil {
+		t.Fatal(err)
+	}
+	return v, path
+}
+
+// readFile reads the vault file at path
+func readFile(t *testing.T, path string) vaultFile {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var file vaultFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		t.Fatal(err)
+	}
+	return file
+}
+
+// writeFile writes file as the vault file at path
+func writeFile(t *testing.T, path string, file vaultFile) {
+	t.Helper()
+	data, err := json.Marshal(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, data, 0600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestVaultRoundTrip(t *testing.T) {
+	v, path := newTestVault(t)
+	if !v.Exists() {
+		t.Fatal("vault file not written by Set")
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if perm := info.Mode().Perm(); perm != 0600 {
+		t.Errorf("got file mode %o, want 600", perm)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(data), "s3cret") {
+		t.Error("password written in plain text")
+	}
+	if file := readFile(t, path); file.Iterations != kdfIterations {
+		t.Errorf("got %d iterations, want %d", file.Iterations, kdfIterations)
+	}
+
+	v.Lock()
+	if v.IsUnlocked() {
+		t.Fatal("vault still unlocked after Lock")
+	}
+	if _, _, err := v.Get("prod"); !errors.Is(err, ErrLocked) {
+		t.Fatalf("got %v reading a locked vault, want ErrLocked", err)
+	}
+
+	reopened := New(path, 0)
+	if err := reopened.Unlock(testPassphrase); err != nil {
+		t.Fatal(err)
+	}
+	if password, ok, err := reopened.Get("prod"); err != nil || !ok || password != "s3cret" {
+		t.Fatalf("got %q, %v, %v, want the saved password", password, ok, err)
+	}
+
+	if err := reopened.Rename("prod", "production"); err != nil {
+		t.Fatal(err)
+	}
+	if err := reopened.Set("dev", "dev-pass"); err != nil {
+		t.Fatal(err)
+	}
+	if err := reopened.Delete("dev"); err != nil {
+		t.Fatal(err)
+	}
+
+	saved := New(path, 0)
+	if err := saved.Unlock(testPassphrase); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok, _ := saved.Get("prod"); ok {
+		t.Error("renamed password still stored under its old name")
+	}
+	if password, ok, _ := saved.Get("production"); !ok || password != "s3cret" {
+		t.Errorf("got %q, %v for the renamed password", password, ok)
+	}
+	if _, ok, _ := saved.Get("dev"); ok {
+		t.Error("deleted password still stored")
+	}
+}
+
+func TestVaultNewPassphraseTooShort(t *testing.T) {
+	v := New(filepath.Join(t.TempDir(), vaultFileName), 0)
+	if err := v.Unlock("short"); err == nil {
+		t.Fatal("created a vault with a short passphrase")
+	}
+	if v.IsUnlocked() {
+		t.Error("vault unlocked after a failed Unlock")
+	}
+}
+
+func TestVaultWrongPassphrase(t *testing.T) {
+	_, path := newTestVault(t)
+
+	v := New(path, 0)
+	if err := v.Unlock("wrong passphrase"); !errors.Is(err, ErrWrongPassphrase) {
+		t.Fatalf("got %v, want ErrWrongPassphrase", err)
+	}
+	if v.IsUnlocked() {
+		t.Error("vault unlocked with a wrong passphrase")
+	}
+}
+
+func TestVaultRejectsFile(t *testing.T) {
+	_, path := newTestVault(t)
+	original := readFile(t, path)
+
+	tests := []struct {
+		name   string
+		change func(file *vaultFile)
+		want   error  // matched with errors.Is
+		text   string // contained in the error when want is nil
+	}{
+		{"unsupported version", func(f *vaultFile) { f.Version = 2 }, nil, "unsupported vault format"},
+		{"unsupported kdf", func(f *vaultFile) { f.KDF = "scrypt" }, nil, "unsupported vault format"},
+		{"zero iterations", func(f *vaultFile) { f.Iterations = 0 }, nil, "iteration count"},
+		{"negative iterations", func(f *vaultFile) { f.Iterations = -1 }, nil, "iteration count"},
+		{"too few iterations", func(f *vaultFile) { f.Iterations = minKDFIterations - 1 }, nil, "iteration count"},
+		{"huge iterations", func(f *vaultFile) { f.Iterations = 1 << 40 }, nil, "iteration count"},
+		{"short salt", func(f *vaultFile) { f.Salt = f.Salt[:4] }, nil, "salt"},
+		{"short nonce", func(f *vaultFile) { f.Nonce = f.Nonce[:4] }, nil, "nonce"},
+		{"tampered data", func(f *vaultFile) { f.Data[0] ^= 0xff }, ErrWrongPassphrase, ""},
+		{"tampered salt", func(f *vaultFile) { f.Salt[0] ^= 0xff }, ErrWrongPassphrase, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			file := original
+			file.Salt = append([]byte(nil), original.Salt...)
+			file.Nonce = append([]byte(nil), original.Nonce...)
+			file.Data = append([]byte(nil), original.Data...)
+			tt.change(&file)
+			tampered := filepath.Join(t.TempDir(), vaultFileName)
+			writeFile(t, tampered, file)
+
+			v := New(tampered, 0)
+			err := v.Unlock(testPassphrase)
+			switch {
+			case err == nil:
+				t.Fatal("unlocked a tampered vault")
+			case tt.want != nil && !errors.Is(err, tt.want):
+				t.Errorf("got %v, want %v", err, tt.want)
+			case tt.want == nil && !strings.Contains(err.Error(), tt.text):
+				t.Errorf("got %v, want an error about %s", err, tt.text)
+			}
+			if v.IsUnlocked() {
+				t.Error("vault unlocked after a failed Unlock")
+			}
+		})
+	}
+
+	invalid := filepath.Join(t.TempDir(), vaultFileName)
+	if err := os.WriteFile(invalid, []byte("{not json"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := New(invalid, 0).Unlock(testPassphrase); err == nil || !strings.Contains(err.Error(), "parse") {
+		t.Errorf("got %v, want a parse error", err)
+	}
+}